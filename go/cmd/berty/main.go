@@ -76,6 +76,8 @@ func runMain(args []string) error {
 				peersCommand(),
 				exportCommand(),
 				omnisearchCommand(),
+				revokeDeviceCommand(),
+				mediaGCCommand(),
 			},
 		}
 
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"moul.io/godev"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+)
+
+func mediaGCCommand() *ffcli.Command {
+	var usageFlag bool
+	fsBuilder := func() (*flag.FlagSet, error) {
+		fs := flag.NewFlagSet("berty media-gc", flag.ExitOnError)
+		fs.String("config", "", "config file (optional)")
+		manager.SetupLoggingFlags(fs)              // also available at root level
+		manager.SetupLocalMessengerServerFlags(fs) // by default, start a new local messenger server,
+		manager.SetupRemoteNodeFlags(fs)           // but allow to set a remote server instead
+		fs.BoolVar(&usageFlag, "media-gc.usage", false, "only display the storage used by the medias of each conversation")
+		return fs, nil
+	}
+
+	return &ffcli.Command{
+		Name:           "media-gc",
+		ShortUsage:     "berty [global flags] media-gc [flags]",
+		ShortHelp:      "remove the medias which are not referenced anymore",
+		FlagSetBuilder: fsBuilder,
+		Options:        ffSubcommandOptions(),
+		UsageFunc:      usageFunc,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				return flag.ErrHelp
+			}
+
+			messenger, err := manager.GetMessengerClient()
+			if err != nil {
+				return err
+			}
+
+			if !usageFlag {
+				ret, err := messenger.MediaGC(ctx, &messengertypes.MediaGC_Request{})
+				if err != nil {
+					return errcode.TODO.Wrap(err)
+				}
+
+				fmt.Printf("removed %d medias, freed %d bytes\n", ret.GetRemoved(), ret.GetFreedSize())
+			}
+
+			usage, err := messenger.MediaStorageUsage(ctx, &messengertypes.MediaStorageUsage_Request{})
+			if err != nil {
+				return errcode.TODO.Wrap(err)
+			}
+
+			fmt.Println(godev.PrettyJSONPB(usage))
+
+			return nil
+		},
+	}
+}
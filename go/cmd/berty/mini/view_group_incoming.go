@@ -348,13 +348,19 @@ func metadataEventHandler(ctx context.Context, v *groupView, e *protocoltypes.Gr
 		protocoltypes.EventTypeAccountContactRequestOutgoingSent:      handlerAccountContactRequestOutgoingSent,
 		protocoltypes.EventTypeAccountContactRequestReferenceReset:    handlerNoop,
 		protocoltypes.EventTypeAccountContactUnblocked:                nil, // do it later
+		protocoltypes.EventTypeAccountDeviceAdded:                     nil, // do it later
+		protocoltypes.EventTypeAccountDeviceRevoked:                   nil, // do it later
 		protocoltypes.EventTypeAccountGroupJoined:                     handlerAccountGroupJoined,
 		protocoltypes.EventTypeAccountGroupLeft:                       handlerAccountGroupLeft,
 		protocoltypes.EventTypeContactAliasKeyAdded:                   handlerContactAliasKeyAdded,
 		protocoltypes.EventTypeGroupDeviceSecretAdded:                 handlerGroupDeviceSecretAdded,
+		protocoltypes.EventTypeGroupAdditionalRendezvousSeedAdded:     handlerNoop,
+		protocoltypes.EventTypeGroupAdditionalRendezvousSeedRemoved:   handlerNoop,
 		protocoltypes.EventTypeGroupMemberDeviceAdded:                 handlerGroupMemberDeviceAdded,
 		protocoltypes.EventTypeGroupMetadataPayloadSent:               nil, // do it later
 		protocoltypes.EventTypeMultiMemberGroupAdminRoleGranted:       nil, // do it later
+		protocoltypes.EventTypeMultiMemberGroupAdminRoleRevoked:       nil, // do it later
+		protocoltypes.EventTypeMultiMemberGroupMemberRemoved:          nil, // do it later
 		protocoltypes.EventTypeMultiMemberGroupAliasResolverAdded:     handlerMultiMemberGroupAliasResolverAdded,
 		protocoltypes.EventTypeMultiMemberGroupInitialMemberAnnounced: handlerMultiMemberGroupInitialMemberAnnounced,
 		protocoltypes.EventTypeAccountServiceTokenAdded:               handlerAccountServiceTokenAdded,
@@ -11,6 +11,11 @@ import (
 )
 
 func replicationServerCommand() *ffcli.Command {
+	var (
+		libp2pEnabled bool
+		quota         bertyprotocol.ReplicationQuota
+	)
+
 	fsBuilder := func() (*flag.FlagSet, error) {
 		fs := flag.NewFlagSet("berty repl-server", flag.ExitOnError)
 		fs.String("config", "", "config file (optional)")
@@ -18,6 +23,10 @@ func replicationServerCommand() *ffcli.Command {
 		manager.SetupProtocolAuth(fs)
 		manager.SetupLocalProtocolServerFlags(fs)
 		manager.SetupDefaultGRPCListenersFlags(fs)
+		manager.SetupGRPCTLSFlags(fs)
+		fs.BoolVar(&libp2pEnabled, "repl.libp2p", true, "serve the replication service over libp2p, clients authenticate the server with its peer ID")
+		fs.IntVar(&quota.MaxGroups, "repl.max-groups", 0, "maximum number of groups replicated per token, 0 means unlimited")
+		fs.Uint64Var(&quota.MaxStorage, "repl.max-storage", 0, "maximum size in bytes of the groups replicated per token, 0 means unlimited")
 		return fs, nil
 	}
 
@@ -63,7 +72,7 @@ func replicationServerCommand() *ffcli.Command {
 				return err
 			}
 
-			replicationService, err := bertyprotocol.NewReplicationService(ctx, rootDS, odb, logger)
+			replicationService, err := bertyprotocol.NewReplicationService(ctx, rootDS, odb, logger, quota)
 			if err != nil {
 				return err
 			}
@@ -73,6 +82,12 @@ func replicationServerCommand() *ffcli.Command {
 				return err
 			}
 
+			if libp2pEnabled {
+				if err := manager.ServeGRPCOverLibp2p(bertyprotocol.ReplicationServiceProtocolID); err != nil {
+					return err
+				}
+			}
+
 			return manager.RunWorkers()
 		},
 	}
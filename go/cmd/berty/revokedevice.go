@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+func revokeDeviceCommand() *ffcli.Command {
+	fsBuilder := func() (*flag.FlagSet, error) {
+		fs := flag.NewFlagSet("berty revoke-device", flag.ExitOnError)
+		fs.String("config", "", "config file (optional)")
+		manager.SetupLoggingFlags(fs)             // also available at root level
+		manager.SetupLocalProtocolServerFlags(fs) // by default, start a new local protocol server,
+		manager.SetupRemoteNodeFlags(fs)          // but allow to set a remote server instead
+		return fs, nil
+	}
+
+	return &ffcli.Command{
+		Name:           "revoke-device",
+		ShortUsage:     "berty [global flags] revoke-device [flags] <device public key>",
+		ShortHelp:      "revoke a device of the account, ie. a lost device, using its base64 url encoded public key",
+		FlagSetBuilder: fsBuilder,
+		Options:        ffSubcommandOptions(),
+		UsageFunc:      usageFunc,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+
+			devicePK, err := base64.RawURLEncoding.DecodeString(args[0])
+			if err != nil {
+				return errcode.ErrInvalidInput.Wrap(err)
+			}
+
+			protocol, err := manager.GetProtocolClient()
+			if err != nil {
+				return err
+			}
+
+			if _, err := protocol.DeviceRevoke(ctx, &protocoltypes.DeviceRevoke_Request{DevicePK: devicePK}); err != nil {
+				return errcode.TODO.Wrap(err)
+			}
+
+			fmt.Printf("device %s revoked\n", args[0])
+
+			return nil
+		},
+	}
+}
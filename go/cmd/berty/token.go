@@ -55,7 +55,7 @@ func tokenServerCommand() *ffcli.Command {
 		fs.StringVar(&secretFlag, "auth.secret", secretFlag, "base64 encoded secret")
 		fs.StringVar(&authSKFlag, "auth.sk", authSKFlag, "base64 encoded signature key")
 		fs.StringVar(&listenerFlag, "http.listener", listenerFlag, "http listener")
-		fs.StringVar(&supportedFlag, "svc", supportedFlag, "comma separated list of supported services as name@ip:port")
+		fs.StringVar(&supportedFlag, "svc", supportedFlag, "comma separated list of supported services as name@endpoint (multiaddr with /p2p/<peer-id>, tls://host:port?sha256=<fingerprint> or loopback ip:port)")
 		return fs, nil
 	}
 
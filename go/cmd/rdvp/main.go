@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	// nolint:staticcheck
 	libp2p "github.com/libp2p/go-libp2p"
@@ -20,6 +21,7 @@ import (
 	libp2p_host "github.com/libp2p/go-libp2p-core/host"
 	metrics "github.com/libp2p/go-libp2p-core/metrics"
 	libp2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	libp2p_peerstore "github.com/libp2p/go-libp2p-core/peerstore"
 	libp2p_quic "github.com/libp2p/go-libp2p-quic-transport"
 	libp2p_rp "github.com/libp2p/go-libp2p-rendezvous"
 	libp2p_rpdb "github.com/libp2p/go-libp2p-rendezvous/db/sqlite"
@@ -36,6 +38,7 @@ import (
 
 	"berty.tech/berty/v2/go/internal/ipfsutil"
 	"berty.tech/berty/v2/go/internal/logutil"
+	"berty.tech/berty/v2/go/internal/rendezvous"
 	"berty.tech/berty/v2/go/pkg/errcode"
 )
 
@@ -44,17 +47,33 @@ func main() {
 
 	// opts
 	var (
-		logFormat             = "color"   // json, console, color, light-console, light-color
-		logToFile             = "stderr"  // can be stdout, stderr or a file path
-		logFilters            = "info+:*" // info and more for everythign
-		serveURN              = ":memory:"
-		serveListeners        = "/ip4/0.0.0.0/tcp/4040,/ip4/0.0.0.0/udp/4141/quic"
-		servePK               = ""
-		sharekeyPK            = ""
-		serveAnnounce         = ""
-		serveMetricsListeners = ""
-		genkeyType            = "Ed25519"
-		genkeyLength          = 2048
+		logFormat                 = "color"   // json, console, color, light-console, light-color
+		logToFile                 = "stderr"  // can be stdout, stderr or a file path
+		logFilters                = "info+:*" // info and more for everythign
+		serveURN                  = ":memory:"
+		serveListeners            = "/ip4/0.0.0.0/tcp/4040,/ip4/0.0.0.0/udp/4141/quic"
+		servePK                   = ""
+		sharekeyPK                = ""
+		serveAnnounce             = ""
+		serveMetricsListeners     = ""
+		serveRegistrationsRate    = 0
+		serveMaxNamespaces        = 0
+		serveMaxPeers             = 0
+		serveAllowedPeers         = ""
+		serveTokens               = ""
+		serveSyncPeers            = ""
+		serveSyncInterval         = time.Minute
+		serveSyncMaxRegs          = 10000
+		serveMailbox              = false
+		serveMailboxTTL           = rendezvous.DefaultMailboxTTL
+		serveMailboxMaxSize       = 256 * 1024
+		serveMailboxMaxPerBox     = 1000
+		serveMailboxMaxBytes      = 16 * 1024 * 1024
+		serveMailboxMaxTotal      = 100000
+		serveMailboxMaxTotalBytes = 512 * 1024 * 1024
+		serveMailboxPutsRate      = 0
+		genkeyType                = "Ed25519"
+		genkeyLength              = 2048
 	)
 
 	// parse opts
@@ -73,6 +92,22 @@ func main() {
 	serveFlags.StringVar(&servePK, "pk", servePK, "private key (generated by `rdvp genkey`)")
 	serveFlags.StringVar(&serveAnnounce, "announce", serveAnnounce, "addrs that will be announce by this server")
 	serveFlags.StringVar(&serveMetricsListeners, "metrics", serveMetricsListeners, "metrics listener, if empty will disable metrics")
+	serveFlags.IntVar(&serveRegistrationsRate, "limit.registrations", serveRegistrationsRate, "maximum number of registrations per minute from a single peer, 0 means unlimited")
+	serveFlags.IntVar(&serveMaxNamespaces, "limit.namespaces", serveMaxNamespaces, "maximum number of namespaces a single peer can register on, 0 means unlimited")
+	serveFlags.IntVar(&serveMaxPeers, "limit.peers", serveMaxPeers, "maximum number of peers registered on a single namespace, 0 means unlimited")
+	serveFlags.StringVar(&serveAllowedPeers, "acl.peers", serveAllowedPeers, "list of peer IDs allowed to register separated by a comma, if empty and no token is set everyone can register")
+	serveFlags.StringVar(&serveTokens, "acl.tokens", serveTokens, "list of tokens allowing the peers presenting one of them to register, separated by a comma")
+	serveFlags.StringVar(&serveSyncPeers, "sync.peers", serveSyncPeers, "list of trusted rendezvous points maddrs (including their /p2p/ peer ID) to import the registrations from, separated by a comma")
+	serveFlags.DurationVar(&serveSyncInterval, "sync.interval", serveSyncInterval, "interval between the syncs with the peered rendezvous points")
+	serveFlags.IntVar(&serveSyncMaxRegs, "sync.max-registrations", serveSyncMaxRegs, "maximum number of registrations pulled from each peered rendezvous point per sync, 0 means unlimited")
+	serveFlags.BoolVar(&serveMailbox, "mailbox", serveMailbox, "if true, the peers can drop sealed envelopes for the offline members of their groups, the envelopes are kept in memory")
+	serveFlags.DurationVar(&serveMailboxTTL, "mailbox.ttl", serveMailboxTTL, "duration the envelopes are kept in the mailboxes")
+	serveFlags.IntVar(&serveMailboxMaxSize, "mailbox.max-size", serveMailboxMaxSize, "maximum size of an envelope in bytes, 0 means unlimited")
+	serveFlags.IntVar(&serveMailboxMaxPerBox, "mailbox.max-messages", serveMailboxMaxPerBox, "maximum number of envelopes held by a single mailbox, 0 means unlimited")
+	serveFlags.IntVar(&serveMailboxMaxBytes, "mailbox.max-bytes", serveMailboxMaxBytes, "maximum size in bytes of the envelopes held by a single mailbox, 0 means unlimited")
+	serveFlags.IntVar(&serveMailboxMaxTotal, "mailbox.max-total", serveMailboxMaxTotal, "maximum number of envelopes held by the server, 0 means unlimited")
+	serveFlags.IntVar(&serveMailboxMaxTotalBytes, "mailbox.max-total-bytes", serveMailboxMaxTotalBytes, "maximum size in bytes of the envelopes held by the server, they are kept in memory, 0 means unlimited")
+	serveFlags.IntVar(&serveMailboxPutsRate, "limit.mailbox", serveMailboxPutsRate, "maximum number of envelopes per minute from a single peer, 0 means unlimited")
 	genkeyFlags.StringVar(&genkeyType, "type", genkeyType, "Type of the private key generated, one of : Ed25519, ECDSA, Secp256k1, RSA")
 	genkeyFlags.IntVar(&genkeyLength, "length", genkeyLength, "The length (in bits) of the key generated.")
 	serveFlags.String("config", "", "config file (optional)")
@@ -185,8 +220,68 @@ func main() {
 
 			defer db.Close()
 
+			var allowedPeers []libp2p_peer.ID
+			if serveAllowedPeers != "" {
+				for _, id := range strings.Split(serveAllowedPeers, ",") {
+					p, err := libp2p_peer.Decode(id)
+					if err != nil {
+						return errcode.ErrInvalidInput.Wrap(err)
+					}
+					allowedPeers = append(allowedPeers, p)
+				}
+			}
+
+			var tokens []string
+			if serveTokens != "" {
+				tokens = strings.Split(serveTokens, ",")
+			}
+
+			rdvpDB := rendezvous.NewDB(host, db, rendezvous.Opts{
+				Logger:                 logger.Named("rdvp"),
+				RegistrationsPerMinute: serveRegistrationsRate,
+				MaxNamespacesPerPeer:   serveMaxNamespaces,
+				MaxPeersPerNamespace:   serveMaxPeers,
+				AllowedPeers:           allowedPeers,
+				Tokens:                 tokens,
+			})
+
 			// start service
-			_ = libp2p_rp.NewRendezvousService(host, db)
+			_ = libp2p_rp.NewRendezvousService(host, rdvpDB)
+
+			var mailbox *rendezvous.Mailbox
+			if serveMailbox {
+				mailbox = rendezvous.NewMailbox(host, rdvpDB, rendezvous.MailboxOpts{
+					Logger:                logger.Named("mailbox"),
+					TTL:                   serveMailboxTTL,
+					MaxEnvelopeSize:       serveMailboxMaxSize,
+					MaxMessagesPerMailbox: serveMailboxMaxPerBox,
+					MaxBytesPerMailbox:    serveMailboxMaxBytes,
+					MaxMessages:           serveMailboxMaxTotal,
+					MaxBytes:              serveMailboxMaxTotalBytes,
+					PutsPerMinute:         serveMailboxPutsRate,
+				})
+			}
+
+			if serveSyncPeers != "" {
+				syncPeers, err := ipfsutil.ParseAndResolveRdvpMaddrs(ctx, logger, strings.Split(serveSyncPeers, ","))
+				if err != nil {
+					return errcode.ErrInvalidInput.Wrap(err)
+				}
+
+				ids := make([]libp2p_peer.ID, len(syncPeers))
+				for i, p := range syncPeers {
+					host.Peerstore().AddAddrs(p.ID, p.Addrs, libp2p_peerstore.PermanentAddrTTL)
+					ids[i] = p.ID
+				}
+
+				syncer := rendezvous.NewSyncer(host, rdvpDB, ids, serveSyncInterval, serveSyncMaxRegs)
+				syncCtx, syncCancel := context.WithCancel(ctx)
+				gServe.Add(func() error {
+					return syncer.Run(syncCtx)
+				}, func(error) {
+					syncCancel()
+				})
+			}
 
 			if serveMetricsListeners != "" {
 				ml, err := net.Listen("tcp", serveMetricsListeners)
@@ -199,7 +294,10 @@ func main() {
 				registry.MustRegister(prometheus.NewGoCollector())
 				registry.MustRegister(ipfsutil.NewHostCollector(host))
 				registry.MustRegister(ipfsutil.NewBandwidthCollector(reporter))
-				// @TODO(gfanton): add rdvp specific collector...
+				registry.MustRegister(rdvpDB)
+				if mailbox != nil {
+					registry.MustRegister(mailbox)
+				}
 
 				handerfor := promhttp.HandlerFor(
 					registry,
@@ -0,0 +1,107 @@
+package grpcutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// StreamOpener opens libp2p streams, it is implemented by libp2p hosts
+type StreamOpener interface {
+	NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error)
+}
+
+// Libp2pDialer returns a gRPC context dialer reaching the peer whose id is given as address, the connection is secured and
+// authenticated by libp2p
+func Libp2pDialer(opener StreamOpener, pid protocol.ID) func(ctx context.Context, addr string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		id, err := peer.Decode(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := opener.NewStream(ctx, id, pid)
+		if err != nil {
+			return nil, err
+		}
+
+		return &streamConn{Stream: s}, nil
+	}
+}
+
+// Libp2pListener accepts the streams opened on a libp2p protocol as net connections, so that a gRPC server can serve them
+type Libp2pListener struct {
+	host  host.Host
+	pid   protocol.ID
+	conns chan net.Conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ net.Listener = (*Libp2pListener)(nil)
+
+func NewLibp2pListener(h host.Host, pid protocol.ID) *Libp2pListener {
+	l := &Libp2pListener{
+		host:   h,
+		pid:    pid,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+
+	h.SetStreamHandler(pid, func(s network.Stream) {
+		select {
+		case l.conns <- &streamConn{Stream: s}:
+		case <-l.closed:
+			_ = s.Reset()
+		}
+	})
+
+	return l
+}
+
+func (l *Libp2pListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("libp2p listener closed")
+	}
+}
+
+func (l *Libp2pListener) Close() error {
+	l.closeOnce.Do(func() {
+		l.host.RemoveStreamHandler(l.pid)
+		close(l.closed)
+	})
+
+	return nil
+}
+
+func (l *Libp2pListener) Addr() net.Addr {
+	return libp2pAddr(l.host.ID())
+}
+
+// streamConn exposes a libp2p stream as a net connection
+type streamConn struct {
+	network.Stream
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return libp2pAddr(c.Conn().LocalPeer())
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return libp2pAddr(c.Conn().RemotePeer())
+}
+
+type libp2pAddr peer.ID
+
+func (a libp2pAddr) Network() string { return "libp2p" }
+func (a libp2pAddr) String() string  { return peer.ID(a).String() }
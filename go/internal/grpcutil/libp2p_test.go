@@ -0,0 +1,63 @@
+package grpcutil
+
+import (
+	"context"
+	"testing"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	pb "google.golang.org/grpc/examples/helloworld/helloworld"
+)
+
+const testLibp2pProtocol = "/berty/grpcutil_test/1.0.0"
+
+func TestLibp2pTransport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	server, err := mn.GenPeer()
+	require.NoError(t, err)
+	client, err := mn.GenPeer()
+	require.NoError(t, err)
+	stranger, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterService(grpcServer, &pb.GreeterService{SayHello: func(ctx context.Context, _ *pb.HelloRequest) (*pb.HelloReply, error) {
+		return &pb.HelloReply{Message: "pong libp2p"}, nil
+	}})
+
+	l := NewLibp2pListener(server, testLibp2pProtocol)
+	defer l.Close()
+
+	go grpcServer.Serve(l)
+	defer grpcServer.Stop()
+
+	cc, err := grpc.DialContext(ctx, server.ID().String(),
+		grpc.WithContextDialer(Libp2pDialer(client, testLibp2pProtocol)),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	defer cc.Close()
+
+	res, err := pb.NewGreeterClient(cc).SayHello(ctx, &pb.HelloRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "pong libp2p", res.Message)
+
+	// the stranger doesn't serve the protocol, the client must not reach the server by mistake
+	ccStranger, err := grpc.DialContext(ctx, stranger.ID().String(),
+		grpc.WithContextDialer(Libp2pDialer(client, testLibp2pProtocol)),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	defer ccStranger.Close()
+
+	_, err = pb.NewGreeterClient(ccStranger).SayHello(ctx, &pb.HelloRequest{})
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package grpcutil
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -106,6 +107,9 @@ func (l *listener) GRPCMultiaddr() ma.Multiaddr {
 type Server struct {
 	GRPCServer *grpc.Server
 	GatewayMux *grpcgw.ServeMux
+
+	// TLSConfig enables TLS on the listeners when set
+	TLSConfig *tls.Config
 }
 
 func (s *Server) Serve(l Listener) error {
@@ -154,7 +158,12 @@ func (s *Server) Serve(l Listener) error {
 		return fmt.Errorf("unable to find a way to serve: %s", l.GRPCMultiaddr())
 	}
 
-	return serve(manet.NetListener(l))
+	nl := manet.NetListener(l)
+	if s.TLSConfig != nil {
+		nl = tls.NewListener(nl, s.TLSConfig)
+	}
+
+	return serve(nl)
 }
 
 //nolint:gochecknoinits
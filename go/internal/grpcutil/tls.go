@@ -0,0 +1,63 @@
+package grpcutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// CertificateFingerprint returns the base64 url encoded sha256 of a DER encoded certificate, it is used to pin the certificate
+// of a server
+func CertificateFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewPinnedTLSCredentials returns client credentials accepting only the server certificate matching the given fingerprint, the
+// certificate authorities trusted by the system are used when the fingerprint is empty
+func NewPinnedTLSCredentials(fingerprint string) (credentials.TransportCredentials, error) {
+	if fingerprint == "" {
+		return credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}), nil
+	}
+
+	pin, err := base64.RawURLEncoding.DecodeString(fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate fingerprint: %w", err)
+	}
+
+	if len(pin) != sha256.Size {
+		return nil, fmt.Errorf("invalid certificate fingerprint size, expected %d got %d", sha256.Size, len(pin))
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the chain is not verified against authorities, the pinned certificate is verified instead
+		InsecureSkipVerify: true, //nolint:gosec
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("no certificate presented by the server")
+			}
+
+			sum := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(sum[:], pin) {
+				return fmt.Errorf("server certificate doesn't match the pinned fingerprint")
+			}
+
+			return nil
+		},
+	}), nil
+}
+
+// NewServerTLSConfig returns the TLS configuration of a gRPC server using the given certificate
+func NewServerTLSConfig(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2"},
+	}
+}
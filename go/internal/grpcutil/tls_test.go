@@ -0,0 +1,95 @@
+package grpcutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	pb "google.golang.org/grpc/examples/helloworld/helloworld"
+)
+
+func testSelfSignedCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "replication.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(crand.Reader, template, template, &sk.PublicKey, sk)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: sk}
+}
+
+func TestPinnedTLSCredentials(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cert := testSelfSignedCertificate(t)
+	other := testSelfSignedCertificate(t)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterService(grpcServer, &pb.GreeterService{SayHello: func(ctx context.Context, _ *pb.HelloRequest) (*pb.HelloReply, error) {
+		return &pb.HelloReply{Message: "pong tls"}, nil
+	}})
+
+	l, err := Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/grpc"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	server := Server{GRPCServer: grpcServer, TLSConfig: NewServerTLSConfig(cert)}
+	go server.Serve(l)
+	defer grpcServer.Stop()
+
+	addr, err := manet.ToNetAddr(l.Multiaddr())
+	require.NoError(t, err)
+
+	cases := []struct {
+		name        string
+		fingerprint string
+		assertFunc  assert.ErrorAssertionFunc
+	}{
+		{name: "Pinned", fingerprint: CertificateFingerprint(cert.Certificate[0]), assertFunc: assert.NoError},
+		{name: "Mismatch", fingerprint: CertificateFingerprint(other.Certificate[0]), assertFunc: assert.Error},
+		{name: "SystemRoots", fingerprint: "", assertFunc: assert.Error},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			creds, err := NewPinnedTLSCredentials(tc.fingerprint)
+			require.NoError(t, err)
+
+			cc, err := grpc.DialContext(ctx, addr.String(), grpc.WithTransportCredentials(creds))
+			require.NoError(t, err)
+			defer cc.Close()
+
+			res, err := pb.NewGreeterClient(cc).SayHello(ctx, &pb.HelloRequest{})
+			if tc.assertFunc(t, err) && err == nil {
+				assert.Equal(t, "pong tls", res.Message)
+			}
+		})
+	}
+
+	_, err = NewPinnedTLSCredentials("invalid")
+	require.Error(t, err)
+}
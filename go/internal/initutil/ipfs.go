@@ -21,6 +21,7 @@ import (
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"moul.io/srand"
 
 	ble "berty.tech/berty/v2/go/internal/ble-driver"
@@ -28,6 +29,7 @@ import (
 	"berty.tech/berty/v2/go/internal/ipfsutil"
 	mc "berty.tech/berty/v2/go/internal/multipeer-connectivity-driver"
 	proximity "berty.tech/berty/v2/go/internal/proximity-transport"
+	"berty.tech/berty/v2/go/internal/rendezvous"
 	"berty.tech/berty/v2/go/internal/tinder"
 	"berty.tech/berty/v2/go/pkg/bertyprotocol"
 	"berty.tech/berty/v2/go/pkg/errcode"
@@ -45,8 +47,13 @@ func (m *Manager) SetupLocalIPFSFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&m.Node.Protocol.LocalDiscovery, "p2p.local-discovery", true, "if true local discovery will be enabled")
 	fs.DurationVar(&m.Node.Protocol.MinBackoff, "p2p.min-backoff", time.Second, "minimum p2p backoff duration")
 	fs.DurationVar(&m.Node.Protocol.MaxBackoff, "p2p.max-backoff", time.Minute, "maximum p2p backoff duration")
+	fs.DurationVar(&m.Node.Protocol.FocusedMaxBackoff, "p2p.focused-max-backoff", 10*time.Second, "maximum p2p backoff duration of the groups opened by the user")
 	fs.DurationVar(&m.Node.Protocol.PollInterval, "p2p.poll-interval", pubsub.DiscoveryPollInterval, "how long the discovery system will waits for more peers")
 	fs.StringVar(&m.Node.Protocol.RdvpMaddrs, "p2p.rdvp", ":default:", `list of rendezvous point maddr, ":dev:" will add the default devs servers, ":none:" will disable rdvp`)
+	fs.IntVar(&m.Node.Protocol.RdvpQuorum, "p2p.rdvp-quorum", 0, "number of rendezvous points a peer must be advertised on, 0 means a majority of them")
+	fs.BoolVar(&m.Node.Protocol.RdvpMailbox, "p2p.rdvp-mailbox", false, "if true, the messages are also dropped in the mailboxes of the rendezvous points for the offline members of the groups")
+	fs.DurationVar(&m.Node.Protocol.RdvpMailboxTTL, "p2p.rdvp-mailbox-ttl", rendezvous.DefaultMailboxTTL, "duration the envelopes are kept in the mailboxes of the rendezvous points, must match their mailbox.ttl")
+	fs.StringVar(&m.Node.Protocol.RdvpToken, "p2p.rdvp-token", "", "token presented to the rendezvous points restricting their registrations")
 	fs.BoolVar(&m.Node.Protocol.Ble, "p2p.ble", ble.Supported, "if true Bluetooth Low Energy will be enabled")
 	fs.BoolVar(&m.Node.Protocol.MultipeerConnectivity, "p2p.multipeer-connectivity", mc.Supported, "if true Multipeer Connectivity will be enabled")
 	fs.StringVar(&m.Node.Protocol.Tor.Mode, "tor.mode", defaultTorMode, "changes the behavior of libp2p regarding tor, see advanced help for more details")
@@ -306,21 +313,39 @@ func (m *Manager) getLocalIPFS() (ipfsutil.ExtendedCoreAPI, *ipfs_core.IpfsNode,
 
 			var rdvClients []tinder.AsyncableDriver
 			if lenrdvpeers := len(rdvpeers); lenrdvpeers > 0 {
-				drivers := make([]tinder.AsyncableDriver, lenrdvpeers)
+				ids := make([]peer.ID, lenrdvpeers)
 				for i, peer := range rdvpeers {
 					h.Peerstore().AddAddrs(peer.ID, peer.Addrs, peerstore.PermanentAddrTTL)
-					rng := mrand.New(mrand.NewSource(srand.MustSecure())) // nolint:gosec // we need to use math/rand here, but it is seeded from crypto/rand
-					disc := tinder.NewRendezvousDiscovery(logger, h, peer.ID, rng)
+					ids[i] = peer.ID
+				}
 
-					// monitor this driver
-					disc, err := tinder.MonitorDriverAsync(logger, h, disc)
-					if err != nil {
-						return errors.Wrap(err, "unable to monitor discovery driver")
-					}
+				// the token is presented to the rendezvous points before registering
+				var auth tinder.RendezvousAuthFunc
+				if m.Node.Protocol.RdvpToken != "" {
+					auth = rendezvous.NewAuthClient(h, m.Node.Protocol.RdvpToken).Authenticate
+				}
+
+				rng := mrand.New(mrand.NewSource(srand.MustSecure())) // nolint:gosec // we need to use math/rand here, but it is seeded from crypto/rand
+				var disc tinder.AsyncableDriver
+				if lenrdvpeers == 1 {
+					disc = tinder.NewAuthenticatedRendezvousDiscovery(logger, h, ids[0], rng, auth)
+				} else {
+					// handle the rendezvous points as a cluster
+					disc = tinder.NewRendezvousCluster(logger, h, ids, rng, m.Node.Protocol.RdvpQuorum, auth)
+				}
 
-					drivers[i] = disc
+				// monitor this driver
+				monitor, err := tinder.MonitorDriverAsync(logger, h, disc)
+				if err != nil {
+					return errors.Wrap(err, "unable to monitor discovery driver")
+				}
+
+				m.Node.Protocol.discoveryMonitors = append(m.Node.Protocol.discoveryMonitors, monitor)
+				rdvClients = append(rdvClients, monitor)
+
+				if m.Node.Protocol.RdvpMailbox {
+					m.Node.Protocol.mailboxServers = ids
 				}
-				rdvClients = append(rdvClients, drivers...)
 			}
 
 			var rdvClient tinder.Driver
@@ -334,11 +359,29 @@ func (m *Manager) getLocalIPFS() (ipfsutil.ExtendedCoreAPI, *ipfs_core.IpfsNode,
 				rdvClient = tinder.NewAsyncMultiDriver(logger, rdvClients...)
 			}
 
-			serverRng := mrand.New(mrand.NewSource(srand.MustSecure())) // nolint:gosec // we need to use math/rand here, but it is seeded from crypto/rand
-			m.Node.Protocol.discovery, err = tinder.NewService(
+			if m.Metrics.Listener != "" {
+				registry, err := m.getMetricsRegistry()
+				if err != nil {
+					return err
+				}
+
+				for _, monitor := range m.Node.Protocol.discoveryMonitors {
+					if err := registry.Register(monitor); err != nil {
+						return err
+					}
+				}
+			}
+
+			// the topics of the groups opened by the user are looked up with a shorter backoff
+			serverRng := mrand.New(mrand.NewSource(srand.MustSecure()))  // nolint:gosec // we need to use math/rand here, but it is seeded from crypto/rand
+			focusedRng := mrand.New(mrand.NewSource(srand.MustSecure())) // nolint:gosec // we need to use math/rand here, but it is seeded from crypto/rand
+			m.Node.Protocol.focusedTopics = tinder.NewFocusedTopics()
+			m.Node.Protocol.discovery, err = tinder.NewAdaptiveService(
 				logger,
 				rdvClient,
 				discovery.NewExponentialBackoff(m.Node.Protocol.MinBackoff, m.Node.Protocol.MaxBackoff, discovery.FullJitter, time.Second, 5.0, 0, serverRng),
+				discovery.NewExponentialBackoff(m.Node.Protocol.MinBackoff, m.Node.Protocol.FocusedMaxBackoff, discovery.FullJitter, time.Second, 5.0, 0, focusedRng),
+				m.Node.Protocol.focusedTopics,
 			)
 			if err != nil {
 				return err
@@ -12,6 +12,7 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	datastore "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-ipfs/core"
+	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus"
@@ -72,11 +73,17 @@ type Manager struct {
 			MultipeerConnectivity bool          `json:"MultipeerConnectivity,omitempty"`
 			MinBackoff            time.Duration `json:"MinBackoff,omitempty"`
 			MaxBackoff            time.Duration `json:"MaxBackoff,omitempty"`
+			FocusedMaxBackoff     time.Duration `json:"FocusedMaxBackoff,omitempty"`
 			DisableIPFSNetwork    bool          `json:"DisableIPFSNetwork,omitempty"`
 			RdvpMaddrs            string        `json:"RdvpMaddrs,omitempty"`
+			RdvpToken             string        `json:"RdvpToken,omitempty"`
+			RdvpQuorum            int           `json:"RdvpQuorum,omitempty"`
+			RdvpMailbox           bool          `json:"RdvpMailbox,omitempty"`
+			RdvpMailboxTTL        time.Duration `json:"RdvpMailboxTTL,omitempty"`
 			AuthSecret            string        `json:"AuthSecret,omitempty"`
 			AuthPublicKey         string        `json:"AuthPublicKey,omitempty"`
 			PollInterval          time.Duration `json:"PollInterval,omitempty"`
+			DeviceLinkInvitation  string        `json:"DeviceLinkInvitation,omitempty"`
 			Tor                   struct {
 				Mode       string `json:"Mode,omitempty"`
 				BinaryPath string `json:"BinaryPath,omitempty"`
@@ -85,16 +92,19 @@ type Manager struct {
 			RelayHack bool `json:"RelayHack,omitempty"`
 
 			// internal
-			needAuth         bool
-			ipfsNode         *core.IpfsNode
-			ipfsAPI          ipfsutil.ExtendedCoreAPI
-			pubsub           *pubsub.PubSub
-			discovery        tinder.Driver
-			server           bertyprotocol.Service
-			client           protocoltypes.ProtocolServiceClient
-			requiredByClient bool
-			ipfsWebUICleanup func()
-			orbitDB          *bertyprotocol.BertyOrbitDB
+			needAuth          bool
+			ipfsNode          *core.IpfsNode
+			ipfsAPI           ipfsutil.ExtendedCoreAPI
+			pubsub            *pubsub.PubSub
+			discovery         tinder.Driver
+			discoveryMonitors []*tinder.DriverMonitor
+			focusedTopics     *tinder.FocusedTopics
+			mailboxServers    []peer.ID
+			server            bertyprotocol.Service
+			client            protocoltypes.ProtocolServiceClient
+			requiredByClient  bool
+			ipfsWebUICleanup  func()
+			orbitDB           *bertyprotocol.BertyOrbitDB
 		}
 		Messenger struct {
 			DisableGroupMonitor  bool   `json:"DisableGroupMonitor,omitempty"`
@@ -103,6 +113,7 @@ type Manager struct {
 			RebuildSqlite        bool   `json:"RebuildSqlite,omitempty"`
 			MessengerSqliteOpts  string `json:"MessengerSqliteOpts,omitempty"`
 			ExportPathToRestore  string `json:"ExportPathToRestore,omitempty"`
+			MediaStorageQuota    uint64 `json:"MediaStorageQuota,omitempty"`
 
 			// internal
 			protocolClient      bertyprotocol.Client
@@ -118,6 +129,8 @@ type Manager struct {
 		GRPC struct {
 			RemoteAddr string `json:"RemoteAddr,omitempty"`
 			Listeners  string `json:"Listeners,omitempty"`
+			TLSCert    string `json:"TLSCert,omitempty"`
+			TLSKey     string `json:"TLSKey,omitempty"`
 
 			// internal
 			clientConn        *grpc.ClientConn
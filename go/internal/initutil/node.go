@@ -3,6 +3,7 @@ package initutil
 import (
 	"context"
 	"crypto/ed25519"
+	"crypto/tls"
 	"encoding/base64"
 	"flag"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	grpcgw "github.com/grpc-ecosystem/grpc-gateway/runtime"
 	datastore "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/protocol"
 	grpc_trace "go.opentelemetry.io/otel/instrumentation/grpctrace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -52,6 +54,7 @@ func (m *Manager) SetupLocalProtocolServerFlags(fs *flag.FlagSet) {
 	m.Node.Protocol.requiredByClient = true
 	m.SetupDatastoreFlags(fs)
 	m.SetupLocalIPFSFlags(fs)
+	fs.StringVar(&m.Node.Protocol.DeviceLinkInvitation, "node.link-device", "", "inits node by joining the account of another device using a device link invitation")
 	// p2p.remote-ipfs
 }
 
@@ -70,6 +73,11 @@ func (m *Manager) SetupDefaultGRPCListenersFlags(fs *flag.FlagSet) {
 	fs.StringVar(&m.Node.Protocol.IPFSWebUIListener, "p2p.webui-listener", ":3999", "IPFS WebUI listener")
 }
 
+func (m *Manager) SetupGRPCTLSFlags(fs *flag.FlagSet) {
+	fs.StringVar(&m.Node.GRPC.TLSCert, "node.tls-cert", "", "PEM encoded certificate used to serve the gRPC API over TLS")
+	fs.StringVar(&m.Node.GRPC.TLSKey, "node.tls-key", "", "PEM encoded private key of the gRPC API certificate")
+}
+
 func (m *Manager) SetupPresetFlags(fs *flag.FlagSet) {
 	fs.StringVar(&m.Node.Preset, "preset", "", "applies various default values, see ADVANCED section below")
 	m.longHelp = append(m.longHelp, [2]string{
@@ -103,6 +111,7 @@ func (m *Manager) SetupLocalMessengerServerFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&m.Node.Messenger.RebuildSqlite, "node.rebuild-db", false, "reconstruct messenger DB from OrbitDB logs")
 	fs.BoolVar(&m.Node.Messenger.DisableGroupMonitor, "node.disable-group-monitor", false, "disable group monitoring")
 	fs.StringVar(&m.Node.Messenger.DisplayName, "node.display-name", safeDefaultDisplayName(), "display name")
+	fs.Uint64Var(&m.Node.Messenger.MediaStorageQuota, "node.media-quota", 0, "maximum size in bytes of the received medias kept in cache, 0 means unlimited")
 	// node.db-opts // see https://github.com/mattn/go-sqlite3#connection-string
 }
 
@@ -192,6 +201,15 @@ func (m *Manager) getLocalProtocolServer() (bertyprotocol.Service, error) {
 			deviceKS = bertyprotocol.NewDeviceKeystore(deviceDS)
 		)
 
+		// join an existing account before the account group is created
+		if m.Node.Protocol.DeviceLinkInvitation != "" {
+			if err := bertyprotocol.LinkDeviceToAccount(m.getContext(), m.Node.Protocol.ipfsAPI, deviceKS, m.Node.Protocol.DeviceLinkInvitation, logger); err != nil {
+				return nil, errcode.TODO.Wrap(err)
+			}
+
+			m.Node.Protocol.DeviceLinkInvitation = ""
+		}
+
 		// initialize new protocol client
 		opts := bertyprotocol.Opts{
 			Host:           m.Node.Protocol.ipfsNode.PeerHost,
@@ -202,6 +220,11 @@ func (m *Manager) getLocalProtocolServer() (bertyprotocol.Service, error) {
 			RootDatastore:  rootDS,
 			DeviceKeystore: deviceKS,
 			OrbitDB:        odb,
+
+			DiscoveryMonitors: m.Node.Protocol.discoveryMonitors,
+			FocusedTopics:     m.Node.Protocol.focusedTopics,
+			MailboxServers:    m.Node.Protocol.mailboxServers,
+			MailboxTTL:        m.Node.Protocol.RdvpMailboxTTL,
 		}
 
 		m.Node.Protocol.server, err = bertyprotocol.New(m.getContext(), opts)
@@ -451,6 +474,16 @@ func (m *Manager) getGRPCServer() (*grpc.Server, *grpcgw.ServeMux, error) {
 			GatewayMux: grpcGatewayMux,
 		}
 
+		if m.Node.GRPC.TLSCert != "" || m.Node.GRPC.TLSKey != "" {
+			cert, err := tls.LoadX509KeyPair(m.Node.GRPC.TLSCert, m.Node.GRPC.TLSKey)
+			if err != nil {
+				return nil, nil, errcode.ErrInvalidInput.Wrap(err)
+			}
+
+			server.TLSConfig = grpcutil.NewServerTLSConfig(cert)
+			m.initLogger.Info("serving gRPC over TLS", zap.String("sha256", grpcutil.CertificateFingerprint(cert.Certificate[0])))
+		}
+
 		for idx, maddr := range maddrs {
 			maddrStr := maddr.String()
 			l, err := grpcutil.Listen(maddr)
@@ -475,6 +508,33 @@ func (m *Manager) getGRPCServer() (*grpc.Server, *grpcgw.ServeMux, error) {
 	return m.Node.GRPC.server, m.Node.GRPC.gatewayMux, nil
 }
 
+// ServeGRPCOverLibp2p serves the gRPC API to the peers opening streams on the given protocol, the remote peers are authenticated
+// by libp2p
+func (m *Manager) ServeGRPCOverLibp2p(pid protocol.ID) error {
+	defer m.prepareForGetter()()
+
+	grpcServer, _, err := m.getGRPCServer()
+	if err != nil {
+		return err
+	}
+
+	ipfs, _, err := m.getLocalIPFS()
+	if err != nil {
+		return err
+	}
+
+	l := grpcutil.NewLibp2pListener(ipfs, pid)
+	m.workers.Add(func() error {
+		m.initLogger.Info("serving over libp2p", zap.String("peer-id", ipfs.ID().String()), zap.String("protocol", string(pid)))
+		return grpcServer.Serve(l)
+	}, func(error) {
+		l.Close()
+		m.initLogger.Debug("closing done", zap.String("protocol", string(pid)))
+	})
+
+	return nil
+}
+
 func (m *Manager) GetGRPCListeners() []grpcutil.Listener {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -628,6 +688,7 @@ func (m *Manager) getLocalMessengerServer() (messengertypes.MessengerServiceServ
 		NotificationManager: notifmanager,
 		LifeCycleManager:    lcmanager,
 		StateBackup:         m.Node.Messenger.localDBState,
+		MediaStorageQuota:   m.Node.Messenger.MediaStorageQuota,
 	}
 	messengerServer, err := bertymessenger.New(protocolClient, &opts)
 	if err != nil {
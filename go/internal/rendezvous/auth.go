@@ -0,0 +1,245 @@
+package rendezvous
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+const (
+	// AuthProtocolID is the protocol used by the peers to present a token to the rendezvous point before registering
+	AuthProtocolID = protocol.ID("/berty/rdvp/auth/1.0.0")
+
+	authMaxTokenSize = 1024
+	authTimeout      = 10 * time.Second
+
+	authAccepted byte = 1
+	authRejected byte = 0
+)
+
+// Authenticate presents a token to the rendezvous point, the peer stays authenticated until it disconnects from it
+func Authenticate(ctx context.Context, h host.Host, rdvp peer.ID, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
+
+	s, err := h.NewStream(ctx, rdvp, AuthProtocolID)
+	if err != nil {
+		return fmt.Errorf("unable to open auth stream: %w", err)
+	}
+	defer s.Close()
+
+	_ = s.SetDeadline(time.Now().Add(authTimeout))
+
+	if _, err := s.Write([]byte(token)); err != nil {
+		_ = s.Reset()
+		return fmt.Errorf("unable to send token: %w", err)
+	}
+
+	if err := s.CloseWrite(); err != nil {
+		_ = s.Reset()
+		return err
+	}
+
+	res := []byte{authRejected}
+	if _, err := io.ReadFull(s, res); err != nil {
+		_ = s.Reset()
+		return fmt.Errorf("unable to read auth response: %w", err)
+	}
+
+	if res[0] != authAccepted {
+		return fmt.Errorf("token rejected by the rendezvous point")
+	}
+
+	return nil
+}
+
+// AuthClient presents a token to the rendezvous points before registering, the token is presented again once the host
+// reconnects to a rendezvous point
+type AuthClient struct {
+	host  host.Host
+	token string
+
+	mu    sync.Mutex
+	peers map[peer.ID]*authClientPeer
+}
+
+type authClientPeer struct {
+	// mu serializes the authentications to the rendezvous point
+	mu sync.Mutex
+
+	// authenticated is guarded by the mutex of the client
+	authenticated bool
+}
+
+func NewAuthClient(h host.Host, token string) *AuthClient {
+	c := &AuthClient{
+		host:  h,
+		token: token,
+		peers: map[peer.ID]*authClientPeer{},
+	}
+
+	h.Network().Notify(&network.NotifyBundle{DisconnectedF: c.disconnected})
+
+	return c
+}
+
+// Authenticate presents the token to the rendezvous point unless it has already been accepted on the current connection
+func (c *AuthClient) Authenticate(ctx context.Context, rdvp peer.ID) error {
+	ap := c.peer(rdvp)
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	c.mu.Lock()
+	authenticated := ap.authenticated
+	c.mu.Unlock()
+
+	if authenticated && c.host.Network().Connectedness(rdvp) == network.Connected {
+		return nil
+	}
+
+	if err := Authenticate(ctx, c.host, rdvp, c.token); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	ap.authenticated = true
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *AuthClient) peer(p peer.ID) *authClientPeer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ap, ok := c.peers[p]
+	if !ok {
+		ap = &authClientPeer{}
+		c.peers[p] = ap
+	}
+
+	return ap
+}
+
+func (c *AuthClient) disconnected(n network.Network, conn network.Conn) {
+	p := conn.RemotePeer()
+	if n.Connectedness(p) == network.Connected {
+		return
+	}
+
+	c.mu.Lock()
+	if ap, ok := c.peers[p]; ok {
+		ap.authenticated = false
+	}
+	c.mu.Unlock()
+}
+
+// authenticator keeps track of the peers which presented a valid token, they are forgotten once disconnected
+type authenticator struct {
+	logger *zap.Logger
+	tokens [][]byte
+
+	mu            sync.Mutex
+	authenticated map[peer.ID]struct{}
+
+	// onAttempt is called after each authentication attempt
+	onAttempt func(accepted bool)
+}
+
+func newAuthenticator(logger *zap.Logger, tokens []string, onAttempt func(accepted bool)) *authenticator {
+	a := &authenticator{
+		logger:        logger,
+		authenticated: map[peer.ID]struct{}{},
+		onAttempt:     onAttempt,
+	}
+
+	for _, token := range tokens {
+		a.tokens = append(a.tokens, []byte(token))
+	}
+
+	return a
+}
+
+func (a *authenticator) isAuthenticated(p peer.ID) bool {
+	a.mu.Lock()
+	_, ok := a.authenticated[p]
+	a.mu.Unlock()
+
+	return ok
+}
+
+func (a *authenticator) checkToken(token []byte) bool {
+	valid := 0
+	for _, expected := range a.tokens {
+		// keep comparing once a token matched, so that the duration doesn't tell which token matched
+		valid |= subtle.ConstantTimeCompare(token, expected)
+	}
+
+	return valid == 1
+}
+
+func (a *authenticator) handleStream(s network.Stream) {
+	defer s.Close()
+
+	_ = s.SetDeadline(time.Now().Add(authTimeout))
+
+	token, err := ioutil.ReadAll(io.LimitReader(s, authMaxTokenSize))
+	if err != nil {
+		_ = s.Reset()
+		return
+	}
+
+	p := s.Conn().RemotePeer()
+	accepted := a.checkToken(token)
+	if accepted {
+		a.mu.Lock()
+		a.authenticated[p] = struct{}{}
+		a.mu.Unlock()
+	} else {
+		a.logger.Debug("invalid token presented", zap.Stringer("peer", p))
+	}
+
+	if a.onAttempt != nil {
+		a.onAttempt(accepted)
+	}
+
+	res := authRejected
+	if accepted {
+		res = authAccepted
+	}
+
+	if _, err := s.Write([]byte{res}); err != nil {
+		_ = s.Reset()
+	}
+}
+
+func (a *authenticator) Disconnected(n network.Network, c network.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) == network.Connected {
+		return
+	}
+
+	a.mu.Lock()
+	delete(a.authenticated, p)
+	a.mu.Unlock()
+}
+
+func (a *authenticator) Listen(network.Network, ma.Multiaddr)         {}
+func (a *authenticator) ListenClose(network.Network, ma.Multiaddr)    {}
+func (a *authenticator) Connected(network.Network, network.Conn)      {}
+func (a *authenticator) OpenedStream(network.Network, network.Stream) {}
+func (a *authenticator) ClosedStream(network.Network, network.Stream) {}
+
+var _ network.Notifiee = (*authenticator)(nil)
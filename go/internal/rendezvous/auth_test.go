@@ -0,0 +1,57 @@
+package rendezvous
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	p2pmocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := p2pmocknet.New(ctx)
+
+	server, err := mn.GenPeer()
+	require.NoError(t, err)
+	client, err := mn.GenPeer()
+	require.NoError(t, err)
+	other, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	require.NoError(t, mn.LinkAll())
+
+	attempts := make(chan bool, 10)
+	auth := newAuthenticator(zap.NewNop(), []string{"token1", "token2"}, func(accepted bool) {
+		attempts <- accepted
+	})
+	server.Network().Notify(auth)
+	server.SetStreamHandler(AuthProtocolID, auth.handleStream)
+
+	// an invalid token is rejected
+	require.Error(t, Authenticate(ctx, other, server.ID(), "invalid"))
+	require.False(t, <-attempts)
+	require.False(t, auth.isAuthenticated(other.ID()))
+
+	// any of the configured tokens is accepted
+	c := NewAuthClient(client, "token2")
+	require.NoError(t, c.Authenticate(ctx, server.ID()))
+	require.True(t, <-attempts)
+	require.True(t, auth.isAuthenticated(client.ID()))
+
+	// the token is presented once per connection
+	require.NoError(t, c.Authenticate(ctx, server.ID()))
+	require.Len(t, attempts, 0)
+
+	// both sides forget the authentication on disconnection, the token is presented again on the new connection
+	require.NoError(t, mn.DisconnectPeers(client.ID(), server.ID()))
+	require.Eventually(t, func() bool { return !auth.isAuthenticated(client.ID()) }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, c.Authenticate(ctx, server.ID()))
+	require.True(t, <-attempts)
+	require.True(t, auth.isAuthenticated(client.ID()))
+}
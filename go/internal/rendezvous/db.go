@@ -0,0 +1,277 @@
+package rendezvous
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	rpdb "github.com/libp2p/go-libp2p-rendezvous/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// reasons of the rejected registrations, used as metrics label
+const (
+	RejectReasonAccessDenied  = "access_denied"
+	RejectReasonRateLimited   = "rate_limited"
+	RejectReasonMaxNamespaces = "max_namespaces"
+	RejectReasonMaxPeers      = "max_peers"
+	RejectReasonInternalError = "internal_error"
+)
+
+const discoverPageSize = 1000
+
+var (
+	registrationsAcceptedOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "registrations", "accepted_total"),
+		Help: "number of accepted registrations",
+	}
+	registrationsRejectedOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "registrations", "rejected_total"),
+		Help: "number of rejected registrations by reason",
+	}
+	authAttemptsOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "auth", "attempts_total"),
+		Help: "number of token authentication attempts by result",
+	}
+	syncImportedOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "sync", "imported_total"),
+		Help: "number of registrations imported from peered rendezvous points",
+	}
+	syncErrorsOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "sync", "errors_total"),
+		Help: "number of failed syncs with peered rendezvous points",
+	}
+)
+
+// Opts configures the checks applied to the registrations, zero values disable the related check
+type Opts struct {
+	Logger *zap.Logger
+
+	// RegistrationsPerMinute is the number of registrations (including refreshes) accepted per minute from a single peer
+	RegistrationsPerMinute int
+
+	// MaxNamespacesPerPeer is the maximum number of namespaces a peer can be registered on
+	MaxNamespacesPerPeer int
+
+	// MaxPeersPerNamespace is the maximum number of peers registered on a namespace
+	MaxPeersPerNamespace int
+
+	// AllowedPeers and Tokens restrict the registrations to the listed peers and to the peers authenticated with one
+	// of the tokens through AuthProtocolID, everyone can register when both are empty
+	AllowedPeers []peer.ID
+	Tokens       []string
+}
+
+// DB wraps a rendezvous database and rejects the registrations which don't pass the configured checks, the rejections
+// are exposed as prometheus metrics
+type DB struct {
+	rpdb.DB
+
+	logger       *zap.Logger
+	opts         Opts
+	allowedPeers map[peer.ID]struct{}
+	auth         *authenticator
+	limiter      *rateLimiter
+
+	// onRegister is called after each accepted registration
+	onRegister func(p peer.ID, ns string, ttl int)
+
+	accepted     prometheus.Counter
+	rejected     *prometheus.CounterVec
+	authAttempts *prometheus.CounterVec
+	syncImported prometheus.Counter
+	syncErrors   prometheus.Counter
+}
+
+var (
+	_ rpdb.DB              = (*DB)(nil)
+	_ prometheus.Collector = (*DB)(nil)
+)
+
+// NewDB wraps the given database, the auth protocol handler is set on the host when tokens are configured
+func NewDB(h host.Host, db rpdb.DB, opts Opts) *DB {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	d := &DB{
+		DB:           db,
+		logger:       opts.Logger,
+		opts:         opts,
+		allowedPeers: map[peer.ID]struct{}{},
+		accepted:     prometheus.NewCounter(registrationsAcceptedOpts),
+		rejected:     prometheus.NewCounterVec(registrationsRejectedOpts, []string{"reason"}),
+		authAttempts: prometheus.NewCounterVec(authAttemptsOpts, []string{"result"}),
+		syncImported: prometheus.NewCounter(syncImportedOpts),
+		syncErrors:   prometheus.NewCounter(syncErrorsOpts),
+	}
+
+	for _, p := range opts.AllowedPeers {
+		d.allowedPeers[p] = struct{}{}
+	}
+
+	if opts.RegistrationsPerMinute > 0 {
+		d.limiter = newRateLimiter(opts.RegistrationsPerMinute)
+	}
+
+	if len(opts.Tokens) > 0 {
+		d.auth = newAuthenticator(opts.Logger, opts.Tokens, func(accepted bool) {
+			if accepted {
+				d.authAttempts.WithLabelValues("accepted").Inc()
+			} else {
+				d.authAttempts.WithLabelValues("rejected").Inc()
+			}
+		})
+		h.Network().Notify(d.auth)
+		h.SetStreamHandler(AuthProtocolID, d.auth.handleStream)
+	}
+
+	return d
+}
+
+func (d *DB) Register(p peer.ID, ns string, addrs [][]byte, ttl int) (uint64, error) {
+	if reason, err := d.checkRegistration(p, ns); err != nil {
+		d.rejected.WithLabelValues(reason).Inc()
+		d.logger.Debug("registration rejected",
+			zap.Stringer("peer", p), zap.String("ns", ns), zap.String("reason", reason), zap.Error(err))
+		return 0, err
+	}
+
+	counter, err := d.DB.Register(p, ns, addrs, ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	d.accepted.Inc()
+	if d.onRegister != nil {
+		d.onRegister(p, ns, ttl)
+	}
+
+	return counter, nil
+}
+
+// Import stores a registration pulled from a peered rendezvous point, it returns false when the registration exceeds the
+// quotas, the access control and the rate limit have been enforced by the rendezvous point the peer registered on
+func (d *DB) Import(p peer.ID, ns string, addrs [][]byte, ttl int) (bool, error) {
+	reason, err := d.checkQuotas(p, ns)
+	switch {
+	case err != nil && reason == RejectReasonInternalError:
+		return false, err
+	case err != nil:
+		d.rejected.WithLabelValues(reason).Inc()
+		d.logger.Debug("imported registration rejected",
+			zap.Stringer("peer", p), zap.String("ns", ns), zap.String("reason", reason), zap.Error(err))
+		return false, nil
+	}
+
+	if _, err := d.DB.Register(p, ns, addrs, ttl); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// checkRegistration returns the reason and the error when the registration of the peer on the namespace must be rejected
+func (d *DB) checkRegistration(p peer.ID, ns string) (string, error) {
+	if !d.isAllowed(p) {
+		return RejectReasonAccessDenied, fmt.Errorf("peer is not allowed to register")
+	}
+
+	if d.limiter != nil && !d.limiter.allow(p, time.Now()) {
+		return RejectReasonRateLimited, fmt.Errorf("too many registrations, limit is %d per minute", d.opts.RegistrationsPerMinute)
+	}
+
+	return d.checkQuotas(p, ns)
+}
+
+// checkQuotas returns the reason and the error when the registration of the peer on the namespace exceeds the quotas
+func (d *DB) checkQuotas(p peer.ID, ns string) (string, error) {
+	if d.opts.MaxNamespacesPerPeer == 0 && d.opts.MaxPeersPerNamespace == 0 {
+		return "", nil
+	}
+
+	// refreshing an existing registration doesn't count against the quotas
+	registered, peers, err := d.namespacePeers(p, ns)
+	if err != nil {
+		return RejectReasonInternalError, err
+	}
+
+	if registered {
+		return "", nil
+	}
+
+	if d.opts.MaxPeersPerNamespace > 0 && peers >= d.opts.MaxPeersPerNamespace {
+		return RejectReasonMaxPeers, fmt.Errorf("maximum number of peers reached on namespace (%d)", d.opts.MaxPeersPerNamespace)
+	}
+
+	if d.opts.MaxNamespacesPerPeer > 0 {
+		count, err := d.DB.CountRegistrations(p)
+		if err != nil {
+			return RejectReasonInternalError, err
+		}
+
+		if count >= d.opts.MaxNamespacesPerPeer {
+			return RejectReasonMaxNamespaces, fmt.Errorf("maximum number of namespaces reached (%d)", d.opts.MaxNamespacesPerPeer)
+		}
+	}
+
+	return "", nil
+}
+
+func (d *DB) isAllowed(p peer.ID) bool {
+	if len(d.allowedPeers) == 0 && d.auth == nil {
+		return true
+	}
+
+	if _, ok := d.allowedPeers[p]; ok {
+		return true
+	}
+
+	return d.auth != nil && d.auth.isAuthenticated(p)
+}
+
+// namespacePeers tells if the peer is registered on the namespace and counts the registered peers, the count stops
+// once the quota is exceeded
+func (d *DB) namespacePeers(p peer.ID, ns string) (bool, int, error) {
+	registered, count := false, 0
+
+	var cookie []byte
+	for {
+		records, next, err := d.DB.Discover(ns, cookie, discoverPageSize)
+		if err != nil {
+			return false, 0, err
+		}
+
+		for _, record := range records {
+			if record.Id == p {
+				registered = true
+			}
+			count++
+		}
+
+		if registered || len(records) < discoverPageSize ||
+			(d.opts.MaxPeersPerNamespace > 0 && count > d.opts.MaxPeersPerNamespace) {
+			return registered, count, nil
+		}
+
+		cookie = next
+	}
+}
+
+func (d *DB) Describe(ch chan<- *prometheus.Desc) {
+	d.accepted.Describe(ch)
+	d.rejected.Describe(ch)
+	d.authAttempts.Describe(ch)
+	d.syncImported.Describe(ch)
+	d.syncErrors.Describe(ch)
+}
+
+func (d *DB) Collect(ch chan<- prometheus.Metric) {
+	d.accepted.Collect(ch)
+	d.rejected.Collect(ch)
+	d.authAttempts.Collect(ch)
+	d.syncImported.Collect(ch)
+	d.syncErrors.Collect(ch)
+}
@@ -0,0 +1,176 @@
+package rendezvous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	rpdb "github.com/libp2p/go-libp2p-rendezvous/db"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryDB struct {
+	registrations map[string][]peer.ID // indexed by namespace
+	counter       uint64
+}
+
+func newMemoryDB() *memoryDB {
+	return &memoryDB{registrations: map[string][]peer.ID{}}
+}
+
+func (m *memoryDB) Close() error { return nil }
+
+func (m *memoryDB) Register(p peer.ID, ns string, _ [][]byte, _ int) (uint64, error) {
+	for _, id := range m.registrations[ns] {
+		if id == p {
+			return m.counter, nil
+		}
+	}
+
+	m.registrations[ns] = append(m.registrations[ns], p)
+	m.counter++
+	return m.counter, nil
+}
+
+func (m *memoryDB) Unregister(p peer.ID, ns string) error { return nil }
+
+func (m *memoryDB) CountRegistrations(p peer.ID) (int, error) {
+	count := 0
+	for _, peers := range m.registrations {
+		for _, id := range peers {
+			if id == p {
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+func (m *memoryDB) Discover(ns string, _ []byte, _ int) ([]rpdb.RegistrationRecord, []byte, error) {
+	records := []rpdb.RegistrationRecord(nil)
+	for _, id := range m.registrations[ns] {
+		records = append(records, rpdb.RegistrationRecord{Id: id, Ns: ns})
+	}
+
+	return records, nil, nil
+}
+
+func (m *memoryDB) ValidCookie(string, []byte) bool { return true }
+
+func TestRateLimiter(t *testing.T) {
+	const p = peer.ID("peer")
+
+	now := time.Now()
+	l := newRateLimiter(2)
+
+	require.True(t, l.allow(p, now))
+	require.True(t, l.allow(p, now))
+	require.False(t, l.allow(p, now))
+
+	// other peers have their own bucket
+	require.True(t, l.allow(peer.ID("other"), now))
+
+	// a token is refilled every 30 seconds
+	require.False(t, l.allow(p, now.Add(20*time.Second)))
+	require.True(t, l.allow(p, now.Add(31*time.Second)))
+
+	// full buckets are dropped
+	l.allow(p, now.Add(time.Hour))
+	require.Len(t, l.buckets, 1)
+}
+
+func TestDB_Quotas(t *testing.T) {
+	const (
+		peerA = peer.ID("peerA")
+		peerB = peer.ID("peerB")
+		peerC = peer.ID("peerC")
+	)
+
+	d := NewDB(nil, newMemoryDB(), Opts{MaxNamespacesPerPeer: 2, MaxPeersPerNamespace: 2})
+
+	_, err := d.Register(peerA, "ns1", nil, 60)
+	require.NoError(t, err)
+	_, err = d.Register(peerA, "ns2", nil, 60)
+	require.NoError(t, err)
+
+	_, err = d.Register(peerA, "ns3", nil, 60)
+	require.Error(t, err)
+
+	// refreshing a registration is still allowed
+	_, err = d.Register(peerA, "ns1", nil, 60)
+	require.NoError(t, err)
+
+	_, err = d.Register(peerB, "ns1", nil, 60)
+	require.NoError(t, err)
+
+	_, err = d.Register(peerC, "ns1", nil, 60)
+	require.Error(t, err)
+}
+
+func TestDB_AllowedPeers(t *testing.T) {
+	const (
+		allowed  = peer.ID("allowed")
+		stranger = peer.ID("stranger")
+	)
+
+	d := NewDB(nil, newMemoryDB(), Opts{AllowedPeers: []peer.ID{allowed}})
+
+	_, err := d.Register(allowed, "ns", nil, 60)
+	require.NoError(t, err)
+
+	_, err = d.Register(stranger, "ns", nil, 60)
+	require.Error(t, err)
+}
+
+func TestDB_Import(t *testing.T) {
+	const (
+		peerA = peer.ID("peerA")
+		peerB = peer.ID("peerB")
+	)
+
+	// the imported registrations bypass the access control, they are checked by the peered rendezvous points
+	d := NewDB(nil, newMemoryDB(), Opts{AllowedPeers: []peer.ID{peer.ID("allowed")}, MaxPeersPerNamespace: 1})
+
+	ok, err := d.Import(peerA, "ns", nil, 60)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// the quotas still apply
+	ok, err = d.Import(peerB, "ns", nil, 60)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = d.Import(peerA, "ns", nil, 60)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestAuthenticator_CheckToken(t *testing.T) {
+	a := newAuthenticator(nil, []string{"token1", "token2"}, nil)
+
+	require.True(t, a.checkToken([]byte("token1")))
+	require.True(t, a.checkToken([]byte("token2")))
+	require.False(t, a.checkToken([]byte("token3")))
+	require.False(t, a.checkToken(nil))
+}
+
+func TestSyncer_Track(t *testing.T) {
+	const p = peer.ID("peer")
+
+	d := NewDB(nil, newMemoryDB(), Opts{})
+	s := NewSyncer(nil, d, nil, time.Minute, 0)
+	now := time.Now().Unix()
+
+	require.True(t, s.isNewer("ns", p, now+60))
+
+	// the local registrations are tracked
+	_, err := d.Register(p, "ns", nil, 60)
+	require.NoError(t, err)
+	require.False(t, s.isNewer("ns", p, now+60))
+	require.True(t, s.isNewer("ns", p, now+3600))
+	require.True(t, s.isNewer("other", p, now+60))
+
+	s.prune(now + 120)
+	require.True(t, s.isNewer("ns", p, now+60))
+}
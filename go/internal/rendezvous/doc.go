@@ -0,0 +1,3 @@
+// Package rendezvous adds access control, rate limiting and quotas to the registrations of a rendezvous point, and
+// offline mailboxes holding the sealed envelopes of the peers until they expire.
+package rendezvous
@@ -0,0 +1,74 @@
+package rendezvous
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// rateLimiter is a per-peer token bucket, each bucket holds up to burst tokens and is refilled at the given rate
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second
+	burst       float64
+	buckets     map[peer.ID]*bucket
+	lastCleanup time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(perMinute) / time.Minute.Seconds(),
+		burst:   float64(perMinute),
+		buckets: map[peer.ID]*bucket{},
+	}
+}
+
+// allow consumes a token from the bucket of the peer, it returns false when the bucket is empty
+func (l *rateLimiter) allow(p peer.ID, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	b, ok := l.buckets[p]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[p] = b
+	}
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// cleanup drops the buckets which are full again, so that the map doesn't grow with every peer ever seen
+func (l *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < time.Minute {
+		return
+	}
+	l.lastCleanup = now
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for p, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, p)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+package rendezvous
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	// MailboxProtocolID is the protocol used by the peers to drop sealed envelopes on the rendezvous point and to fetch
+	// the envelopes dropped for them
+	MailboxProtocolID = protocol.ID("/berty/rdvp/mailbox/1.0.0")
+
+	// DefaultMailboxTTL is the duration the envelopes are kept when no TTL is configured
+	DefaultMailboxTTL = 7 * 24 * time.Hour
+
+	// MailboxMaxIDSize is the maximum size of a mailbox ID
+	MailboxMaxIDSize = 64
+
+	// MailboxMaxFetch is the maximum number of envelopes returned by a single fetch
+	MailboxMaxFetch = 100
+
+	mailboxTimeout       = 30 * time.Second
+	mailboxMessageIDSize = 16
+
+	// mailboxMaxEnvelopeSize bounds the frames read by the clients, the servers use their own limit
+	mailboxMaxEnvelopeSize = 1 << 20
+)
+
+// operations of the mailbox protocol
+const (
+	mailboxOpPut   byte = 1
+	mailboxOpFetch byte = 2
+)
+
+// statuses of the mailbox protocol responses
+const (
+	mailboxStatusOK           byte = 0
+	mailboxStatusDenied       byte = 1
+	mailboxStatusLimited      byte = 2
+	mailboxStatusInvalid      byte = 3
+	mailboxStatusInternalFail byte = 4
+)
+
+// reasons of the rejected envelopes, used as metrics label
+const (
+	MailboxRejectReasonAccessDenied = "access_denied"
+	MailboxRejectReasonRateLimited  = "rate_limited"
+	MailboxRejectReasonTooLarge     = "too_large"
+	MailboxRejectReasonMailboxFull  = "mailbox_full"
+	MailboxRejectReasonServerFull   = "server_full"
+)
+
+var (
+	mailboxStoredOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "mailbox", "stored_total"),
+		Help: "number of envelopes dropped in the mailboxes",
+	}
+	mailboxRejectedOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "mailbox", "rejected_total"),
+		Help: "number of rejected envelopes by reason",
+	}
+	mailboxFetchedOpts = prometheus.CounterOpts{
+		Name: prometheus.BuildFQName("rdvp", "mailbox", "fetched_total"),
+		Help: "number of envelopes returned to the recipients",
+	}
+	mailboxMessagesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("rdvp", "mailbox", "messages"),
+		"number of envelopes currently held in the mailboxes", nil, nil)
+	mailboxBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("rdvp", "mailbox", "bytes"),
+		"size of the envelopes currently held in the mailboxes", nil, nil)
+)
+
+// MailboxOpts configures the limits of the mailboxes, zero values disable the related limit
+type MailboxOpts struct {
+	Logger *zap.Logger
+
+	// TTL is the duration the envelopes are kept before being dropped, DefaultMailboxTTL is used when zero
+	TTL time.Duration
+
+	// MaxEnvelopeSize is the maximum size of a single envelope
+	MaxEnvelopeSize int
+
+	// MaxMessagesPerMailbox is the maximum number of envelopes held by a single mailbox
+	MaxMessagesPerMailbox int
+
+	// MaxBytesPerMailbox is the maximum size of the envelopes held by a single mailbox
+	MaxBytesPerMailbox int
+
+	// MaxMessages is the maximum number of envelopes held by the server
+	MaxMessages int
+
+	// MaxBytes is the maximum size of the envelopes held by the server, as they are kept in memory it should be set
+	// according to the memory available
+	MaxBytes int
+
+	// PutsPerMinute is the number of envelopes accepted per minute from a single peer
+	PutsPerMinute int
+}
+
+// MailboxMessage is an envelope held in a mailbox, the ID is assigned by the server and is used by the recipients to
+// fetch the envelopes following it
+type MailboxMessage struct {
+	ID       []byte
+	Envelope []byte
+}
+
+type mailboxMessage struct {
+	MailboxMessage
+	expire time.Time
+}
+
+type mailbox struct {
+	msgs []*mailboxMessage
+	size int
+}
+
+// Mailbox stores the sealed envelopes dropped by the peers until they expire, the envelopes are addressed to opaque
+// mailbox IDs and are kept in memory
+//
+// A mailbox can have several recipients, so the envelopes are never deleted on their behalf, each recipient fetches the
+// envelopes following the last one it received instead. The server never learns the keys of the groups, the mailbox
+// IDs are derived by the group members from a secret and are rotated periodically so they can't be linked together.
+// The peers allowed to register on the rendezvous point are the ones allowed to use the mailboxes.
+type Mailbox struct {
+	logger  *zap.Logger
+	db      *DB
+	opts    MailboxOpts
+	limiter *rateLimiter
+
+	mu          sync.Mutex
+	boxes       map[string]*mailbox
+	count       int
+	size        int
+	lastCleanup time.Time
+
+	stored   prometheus.Counter
+	rejected *prometheus.CounterVec
+	fetched  prometheus.Counter
+}
+
+var _ prometheus.Collector = (*Mailbox)(nil)
+
+// NewMailbox creates the mailboxes and sets the protocol handler on the host, the access control of the database is
+// applied to the envelopes dropped, everyone is allowed when db is nil
+func NewMailbox(h host.Host, db *DB, opts MailboxOpts) *Mailbox {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	if opts.TTL == 0 {
+		opts.TTL = DefaultMailboxTTL
+	}
+
+	m := &Mailbox{
+		logger:   opts.Logger,
+		db:       db,
+		opts:     opts,
+		boxes:    map[string]*mailbox{},
+		stored:   prometheus.NewCounter(mailboxStoredOpts),
+		rejected: prometheus.NewCounterVec(mailboxRejectedOpts, []string{"reason"}),
+		fetched:  prometheus.NewCounter(mailboxFetchedOpts),
+	}
+
+	if opts.PutsPerMinute > 0 {
+		m.limiter = newRateLimiter(opts.PutsPerMinute)
+	}
+
+	if h != nil {
+		h.SetStreamHandler(MailboxProtocolID, m.handleStream)
+	}
+
+	return m
+}
+
+// put stores the envelope in the mailbox, it returns the reason and the error when the envelope is rejected
+func (m *Mailbox) put(p peer.ID, id, envelope []byte, now time.Time) (string, error) {
+	if m.db != nil && !m.db.isAllowed(p) {
+		return MailboxRejectReasonAccessDenied, fmt.Errorf("peer is not allowed to use the mailboxes")
+	}
+
+	if m.limiter != nil && !m.limiter.allow(p, now) {
+		return MailboxRejectReasonRateLimited, fmt.Errorf("too many envelopes, limit is %d per minute", m.opts.PutsPerMinute)
+	}
+
+	if m.opts.MaxEnvelopeSize > 0 && len(envelope) > m.opts.MaxEnvelopeSize {
+		return MailboxRejectReasonTooLarge, fmt.Errorf("envelope is too large, limit is %d bytes", m.opts.MaxEnvelopeSize)
+	}
+
+	msgID := make([]byte, mailboxMessageIDSize)
+	if _, err := crand.Read(msgID); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cleanup(now)
+	box := m.pruneBox(string(id), now)
+
+	if m.opts.MaxMessagesPerMailbox > 0 && len(box.msgs) >= m.opts.MaxMessagesPerMailbox {
+		return MailboxRejectReasonMailboxFull, fmt.Errorf("maximum number of envelopes reached on mailbox (%d)", m.opts.MaxMessagesPerMailbox)
+	}
+
+	if m.opts.MaxBytesPerMailbox > 0 && box.size+len(envelope) > m.opts.MaxBytesPerMailbox {
+		return MailboxRejectReasonMailboxFull, fmt.Errorf("maximum size reached on mailbox (%d bytes)", m.opts.MaxBytesPerMailbox)
+	}
+
+	if m.opts.MaxMessages > 0 && m.count >= m.opts.MaxMessages {
+		return MailboxRejectReasonServerFull, fmt.Errorf("maximum number of envelopes reached (%d)", m.opts.MaxMessages)
+	}
+
+	if m.opts.MaxBytes > 0 && m.size+len(envelope) > m.opts.MaxBytes {
+		return MailboxRejectReasonServerFull, fmt.Errorf("maximum size reached (%d bytes)", m.opts.MaxBytes)
+	}
+
+	box.msgs = append(box.msgs, &mailboxMessage{
+		MailboxMessage: MailboxMessage{ID: msgID, Envelope: envelope},
+		expire:         now.Add(m.opts.TTL),
+	})
+	box.size += len(envelope)
+	m.boxes[string(id)] = box
+	m.count++
+	m.size += len(envelope)
+
+	return "", nil
+}
+
+// fetch returns the oldest envelopes of the mailbox following the one with the given ID, or the oldest envelopes of the
+// mailbox when the ID is empty or has expired, they are kept until they expire
+func (m *Mailbox) fetch(id, after []byte, now time.Time) []MailboxMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msgs := m.pruneBox(string(id), now).msgs
+	if len(after) > 0 {
+		for i, msg := range msgs {
+			if bytes.Equal(msg.ID, after) {
+				msgs = msgs[i+1:]
+				break
+			}
+		}
+	}
+
+	if len(msgs) > MailboxMaxFetch {
+		msgs = msgs[:MailboxMaxFetch]
+	}
+
+	fetched := make([]MailboxMessage, len(msgs))
+	for i, msg := range msgs {
+		fetched[i] = msg.MailboxMessage
+	}
+
+	return fetched
+}
+
+// pruneBox drops the expired envelopes of a mailbox and returns it, the returned mailbox is empty when unknown, the lock
+// must be held
+func (m *Mailbox) pruneBox(id string, now time.Time) *mailbox {
+	box, ok := m.boxes[id]
+	if !ok {
+		return &mailbox{}
+	}
+
+	// the envelopes are sorted by expiration
+	expired := 0
+	for expired < len(box.msgs) && !now.Before(box.msgs[expired].expire) {
+		m.count--
+		m.size -= len(box.msgs[expired].Envelope)
+		box.size -= len(box.msgs[expired].Envelope)
+		expired++
+	}
+
+	box.msgs = box.msgs[expired:]
+	if len(box.msgs) == 0 {
+		delete(m.boxes, id)
+	}
+
+	return box
+}
+
+// cleanup drops the expired envelopes of every mailbox, the lock must be held
+func (m *Mailbox) cleanup(now time.Time) {
+	if now.Sub(m.lastCleanup) < time.Minute {
+		return
+	}
+	m.lastCleanup = now
+
+	for id := range m.boxes {
+		m.pruneBox(id, now)
+	}
+}
+
+func (m *Mailbox) handleStream(s network.Stream) {
+	defer s.Close()
+
+	_ = s.SetDeadline(time.Now().Add(mailboxTimeout))
+
+	p := s.Conn().RemotePeer()
+	r := bufio.NewReader(s)
+	maxEnvelopeSize := mailboxMaxEnvelopeSize
+	if m.opts.MaxEnvelopeSize > 0 {
+		maxEnvelopeSize = m.opts.MaxEnvelopeSize
+	}
+
+	op, err := r.ReadByte()
+	if err != nil {
+		_ = s.Reset()
+		return
+	}
+
+	id, err := readMailboxFrame(r, MailboxMaxIDSize)
+	if err != nil || len(id) == 0 {
+		m.writeStatus(s, mailboxStatusInvalid)
+		return
+	}
+
+	switch op {
+	case mailboxOpPut:
+		envelope, err := readMailboxFrame(r, maxEnvelopeSize)
+		if err != nil {
+			m.rejected.WithLabelValues(MailboxRejectReasonTooLarge).Inc()
+			m.writeStatus(s, mailboxStatusInvalid)
+			return
+		}
+
+		reason, err := m.put(p, id, envelope, time.Now())
+		switch {
+		case err == nil:
+			m.stored.Inc()
+			m.writeStatus(s, mailboxStatusOK)
+		case reason == "":
+			m.logger.Error("unable to store envelope", zap.Error(err))
+			m.writeStatus(s, mailboxStatusInternalFail)
+		default:
+			m.rejected.WithLabelValues(reason).Inc()
+			m.logger.Debug("envelope rejected", zap.Stringer("peer", p), zap.String("reason", reason), zap.Error(err))
+			if reason == MailboxRejectReasonAccessDenied {
+				m.writeStatus(s, mailboxStatusDenied)
+			} else {
+				m.writeStatus(s, mailboxStatusLimited)
+			}
+		}
+
+	case mailboxOpFetch:
+		after, err := readMailboxFrame(r, mailboxMessageIDSize)
+		if err != nil {
+			m.writeStatus(s, mailboxStatusInvalid)
+			return
+		}
+
+		msgs := m.fetch(id, after, time.Now())
+		m.fetched.Add(float64(len(msgs)))
+
+		w := bufio.NewWriter(s)
+		_ = w.WriteByte(mailboxStatusOK)
+		writeMailboxUvarint(w, uint64(len(msgs)))
+		for _, msg := range msgs {
+			writeMailboxFrame(w, msg.ID)
+			writeMailboxFrame(w, msg.Envelope)
+		}
+
+		if err := w.Flush(); err != nil {
+			_ = s.Reset()
+		}
+
+	default:
+		m.writeStatus(s, mailboxStatusInvalid)
+	}
+}
+
+func (m *Mailbox) writeStatus(s network.Stream, status byte) {
+	if _, err := s.Write([]byte{status}); err != nil {
+		_ = s.Reset()
+	}
+}
+
+func (m *Mailbox) Describe(ch chan<- *prometheus.Desc) {
+	m.stored.Describe(ch)
+	m.rejected.Describe(ch)
+	m.fetched.Describe(ch)
+	ch <- mailboxMessagesDesc
+	ch <- mailboxBytesDesc
+}
+
+func (m *Mailbox) Collect(ch chan<- prometheus.Metric) {
+	m.stored.Collect(ch)
+	m.rejected.Collect(ch)
+	m.fetched.Collect(ch)
+
+	m.mu.Lock()
+	count, size := m.count, m.size
+	m.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(mailboxMessagesDesc, prometheus.GaugeValue, float64(count))
+	ch <- prometheus.MustNewConstMetric(mailboxBytesDesc, prometheus.GaugeValue, float64(size))
+}
+
+// MailboxPut drops a sealed envelope in a mailbox of the rendezvous point
+func MailboxPut(ctx context.Context, h host.Host, rdvp peer.ID, id, envelope []byte) error {
+	return mailboxRequest(ctx, h, rdvp, func(w *bufio.Writer) {
+		_ = w.WriteByte(mailboxOpPut)
+		writeMailboxFrame(w, id)
+		writeMailboxFrame(w, envelope)
+	}, nil)
+}
+
+// MailboxFetch returns the envelopes held in a mailbox of the rendezvous point following the one with the given ID, the
+// oldest envelopes are returned when after is empty or when it has expired
+func MailboxFetch(ctx context.Context, h host.Host, rdvp peer.ID, id, after []byte) ([]MailboxMessage, error) {
+	var msgs []MailboxMessage
+
+	err := mailboxRequest(ctx, h, rdvp, func(w *bufio.Writer) {
+		_ = w.WriteByte(mailboxOpFetch)
+		writeMailboxFrame(w, id)
+		writeMailboxFrame(w, after)
+	}, func(r *bufio.Reader) error {
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+
+		if count > MailboxMaxFetch {
+			return fmt.Errorf("too many envelopes (%d)", count)
+		}
+
+		msgs = make([]MailboxMessage, count)
+		for i := range msgs {
+			if msgs[i].ID, err = readMailboxFrame(r, mailboxMessageIDSize); err != nil {
+				return err
+			}
+
+			if msgs[i].Envelope, err = readMailboxFrame(r, mailboxMaxEnvelopeSize); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
+
+// mailboxRequest sends a request to the rendezvous point and checks the status of the response, the rest of the
+// response is read by the given function when set
+func mailboxRequest(ctx context.Context, h host.Host, rdvp peer.ID, write func(w *bufio.Writer), read func(r *bufio.Reader) error) error {
+	ctx, cancel := context.WithTimeout(ctx, mailboxTimeout)
+	defer cancel()
+
+	s, err := h.NewStream(ctx, rdvp, MailboxProtocolID)
+	if err != nil {
+		return fmt.Errorf("unable to open mailbox stream: %w", err)
+	}
+	defer s.Close()
+
+	_ = s.SetDeadline(time.Now().Add(mailboxTimeout))
+
+	w := bufio.NewWriter(s)
+	write(w)
+	if err := w.Flush(); err != nil {
+		_ = s.Reset()
+		return fmt.Errorf("unable to send mailbox request: %w", err)
+	}
+
+	if err := s.CloseWrite(); err != nil {
+		_ = s.Reset()
+		return err
+	}
+
+	r := bufio.NewReader(s)
+	status, err := r.ReadByte()
+	if err != nil {
+		_ = s.Reset()
+		return fmt.Errorf("unable to read mailbox response: %w", err)
+	}
+
+	switch status {
+	case mailboxStatusOK:
+	case mailboxStatusDenied:
+		return fmt.Errorf("access to the mailboxes denied by the rendezvous point")
+	case mailboxStatusLimited:
+		return fmt.Errorf("envelope rejected by the rendezvous point, limit reached")
+	case mailboxStatusInvalid:
+		return fmt.Errorf("invalid mailbox request")
+	default:
+		return fmt.Errorf("mailbox request failed on the rendezvous point")
+	}
+
+	if read != nil {
+		if err := read(r); err != nil {
+			_ = s.Reset()
+			return fmt.Errorf("unable to read mailbox response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// the frames are prefixed by their size encoded as an uvarint
+
+func writeMailboxUvarint(w *bufio.Writer, v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	_, _ = w.Write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func writeMailboxFrame(w *bufio.Writer, data []byte) {
+	writeMailboxUvarint(w, uint64(len(data)))
+	_, _ = w.Write(data)
+}
+
+func readMailboxFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > uint64(maxSize) {
+		return nil, fmt.Errorf("frame too large (%d bytes), limit is %d", size, maxSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package rendezvous
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	p2pmocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailbox_PutFetch(t *testing.T) {
+	const p = peer.ID("peer")
+
+	now := time.Now()
+	m := NewMailbox(nil, nil, MailboxOpts{TTL: time.Hour})
+
+	_, err := m.put(p, []byte("box1"), []byte("envelope1"), now)
+	require.NoError(t, err)
+	_, err = m.put(p, []byte("box1"), []byte("envelope2"), now)
+	require.NoError(t, err)
+	_, err = m.put(p, []byte("box2"), []byte("envelope3"), now)
+	require.NoError(t, err)
+
+	msgs := m.fetch([]byte("box1"), nil, now)
+	require.Len(t, msgs, 2)
+	require.Equal(t, []byte("envelope1"), msgs[0].Envelope)
+	require.Equal(t, []byte("envelope2"), msgs[1].Envelope)
+
+	// the envelopes are kept for the other recipients
+	require.Len(t, m.fetch([]byte("box1"), nil, now), 2)
+
+	// the envelopes following the last one received are fetched
+	after := m.fetch([]byte("box1"), msgs[0].ID, now)
+	require.Len(t, after, 1)
+	require.Equal(t, []byte("envelope2"), after[0].Envelope)
+	require.Len(t, m.fetch([]byte("box1"), msgs[1].ID, now), 0)
+
+	// the ids of the messages are bound to their mailbox, an unknown id fetches the whole mailbox
+	require.Len(t, m.fetch([]byte("box2"), msgs[0].ID, now), 1)
+	require.Equal(t, 3, m.count)
+	require.Equal(t, 27, m.size)
+
+	// the envelopes expire after the ttl
+	require.Len(t, m.fetch([]byte("box2"), nil, now.Add(2*time.Hour)), 0)
+	require.Equal(t, 2, m.count)
+	require.Equal(t, 18, m.size)
+}
+
+func TestMailbox_Limits(t *testing.T) {
+	const p = peer.ID("peer")
+
+	now := time.Now()
+	m := NewMailbox(nil, nil, MailboxOpts{MaxEnvelopeSize: 8, MaxMessagesPerMailbox: 2, MaxMessages: 3})
+
+	_, err := m.put(p, []byte("box1"), []byte("too large envelope"), now)
+	require.Error(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = m.put(p, []byte("box1"), []byte("envelope"), now)
+		require.NoError(t, err)
+	}
+
+	reason, err := m.put(p, []byte("box1"), []byte("envelope"), now)
+	require.Error(t, err)
+	require.Equal(t, MailboxRejectReasonMailboxFull, reason)
+
+	_, err = m.put(p, []byte("box2"), []byte("envelope"), now)
+	require.NoError(t, err)
+
+	reason, err = m.put(p, []byte("box3"), []byte("envelope"), now)
+	require.Error(t, err)
+	require.Equal(t, MailboxRejectReasonServerFull, reason)
+}
+
+func TestMailbox_ByteLimits(t *testing.T) {
+	const p = peer.ID("peer")
+
+	now := time.Now()
+	m := NewMailbox(nil, nil, MailboxOpts{MaxBytesPerMailbox: 16, MaxBytes: 24})
+
+	_, err := m.put(p, []byte("box1"), []byte("envelope"), now)
+	require.NoError(t, err)
+	_, err = m.put(p, []byte("box1"), []byte("envelope"), now)
+	require.NoError(t, err)
+
+	reason, err := m.put(p, []byte("box1"), []byte("e"), now)
+	require.Error(t, err)
+	require.Equal(t, MailboxRejectReasonMailboxFull, reason)
+
+	_, err = m.put(p, []byte("box2"), []byte("envelope"), now)
+	require.NoError(t, err)
+
+	reason, err = m.put(p, []byte("box3"), []byte("e"), now)
+	require.Error(t, err)
+	require.Equal(t, MailboxRejectReasonServerFull, reason)
+
+	// the budget is released once the envelopes expire
+	_, err = m.put(p, []byte("box3"), []byte("e"), now.Add(2*DefaultMailboxTTL))
+	require.NoError(t, err)
+	require.Equal(t, 1, m.size)
+}
+
+func TestMailbox_AllowedPeers(t *testing.T) {
+	const (
+		allowed  = peer.ID("allowed")
+		stranger = peer.ID("stranger")
+	)
+
+	d := NewDB(nil, newMemoryDB(), Opts{AllowedPeers: []peer.ID{allowed}})
+	m := NewMailbox(nil, d, MailboxOpts{})
+
+	_, err := m.put(allowed, []byte("box"), []byte("envelope"), time.Now())
+	require.NoError(t, err)
+
+	reason, err := m.put(stranger, []byte("box"), []byte("envelope"), time.Now())
+	require.Error(t, err)
+	require.Equal(t, MailboxRejectReasonAccessDenied, reason)
+}
+
+func TestMailbox_Protocol(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := p2pmocknet.New(ctx)
+
+	server, err := mn.GenPeer()
+	require.NoError(t, err)
+	sender, err := mn.GenPeer()
+	require.NoError(t, err)
+	recipient, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	NewMailbox(server, nil, MailboxOpts{MaxEnvelopeSize: 64})
+
+	id := []byte("mailbox id")
+	require.NoError(t, MailboxPut(ctx, sender, server.ID(), id, []byte("envelope1")))
+	require.NoError(t, MailboxPut(ctx, sender, server.ID(), id, []byte("envelope2")))
+	require.Error(t, MailboxPut(ctx, sender, server.ID(), id, make([]byte, 128)))
+
+	msgs, err := MailboxFetch(ctx, recipient, server.ID(), id, nil)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, []byte("envelope1"), msgs[0].Envelope)
+
+	msgs, err = MailboxFetch(ctx, recipient, server.ID(), id, msgs[0].ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, []byte("envelope2"), msgs[0].Envelope)
+
+	msgs, err = MailboxFetch(ctx, recipient, server.ID(), id, msgs[0].ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 0)
+}
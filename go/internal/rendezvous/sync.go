@@ -0,0 +1,172 @@
+package rendezvous
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	libp2p_rp "github.com/libp2p/go-libp2p-rendezvous"
+	"go.uber.org/zap"
+)
+
+const (
+	// syncPageSize is the maximum number of registrations returned by a rendezvous point in a single discover response
+	syncPageSize = 1000
+
+	// syncExpireSlack absorbs the rounding of the remaining ttl returned by the rendezvous points, in seconds
+	syncExpireSlack = 30
+)
+
+type syncKey struct {
+	ns string
+	id peer.ID
+}
+
+// Syncer imports into the local database the registrations of peered rendezvous points, the registrations are pulled
+// with the rendezvous protocol itself by discovering every namespace, so any rendezvous point can be peered
+type Syncer struct {
+	logger   *zap.Logger
+	host     host.Host
+	db       *DB
+	peers    []peer.ID
+	interval time.Duration
+
+	// maxRegistrations is the maximum number of registrations pulled from each peer per sync, the remaining ones are
+	// pulled during the next syncs
+	maxRegistrations int
+
+	// cookies are the positions of the last registrations pulled from each peer
+	cookies map[peer.ID][]byte
+
+	// expires holds the expiration of the known registrations, so that the registrations exchanged back and forth
+	// between the peers are only imported once
+	muExpires sync.Mutex
+	expires   map[syncKey]int64
+}
+
+// NewSyncer creates a syncer pulling the registrations of the given rendezvous points, their addresses must be known
+// by the host, only trusted rendezvous points should be peered as they enforce the access control of the registrations,
+// maxRegistrations limits the number of registrations pulled from each of them per sync, zero means unlimited
+func NewSyncer(h host.Host, db *DB, peers []peer.ID, interval time.Duration, maxRegistrations int) *Syncer {
+	s := &Syncer{
+		logger:           db.logger.Named("sync"),
+		host:             h,
+		db:               db,
+		peers:            peers,
+		interval:         interval,
+		maxRegistrations: maxRegistrations,
+		cookies:          map[peer.ID][]byte{},
+		expires:          map[syncKey]int64{},
+	}
+	db.onRegister = s.track
+
+	return s
+}
+
+// Run pulls the registrations of the peers every interval until the context is done
+func (s *Syncer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, p := range s.peers {
+			imported, err := s.pull(ctx, p)
+			if err != nil {
+				s.db.syncErrors.Inc()
+				s.logger.Warn("unable to sync with rendezvous point", zap.Stringer("peer", p), zap.Error(err))
+			} else if imported > 0 {
+				s.logger.Debug("synced with rendezvous point", zap.Stringer("peer", p), zap.Int("imported", imported))
+			}
+		}
+		s.prune(time.Now().Unix())
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// pull imports the registrations made on the peer since the last pull, the registrations exceeding the quotas of the
+// local database are dropped
+func (s *Syncer) pull(ctx context.Context, p peer.ID) (int, error) {
+	rp := libp2p_rp.NewRendezvousPoint(s.host, p)
+	imported, pulled := 0, 0
+
+	for {
+		limit := syncPageSize
+		if s.maxRegistrations > 0 && s.maxRegistrations-pulled < limit {
+			limit = s.maxRegistrations - pulled
+		}
+
+		// an empty namespace matches every namespace
+		regs, cookie, err := rp.Discover(ctx, "", limit, s.cookies[p])
+		if err != nil {
+			return imported, err
+		}
+		pulled += len(regs)
+
+		now, count := time.Now().Unix(), 0
+		for _, reg := range regs {
+			if reg.Ttl <= 0 || reg.Peer.ID == s.host.ID() || !s.isNewer(reg.Ns, reg.Peer.ID, now+int64(reg.Ttl)) {
+				continue
+			}
+
+			addrs := make([][]byte, len(reg.Peer.Addrs))
+			for i, addr := range reg.Peer.Addrs {
+				addrs[i] = addr.Bytes()
+			}
+
+			ok, err := s.db.Import(reg.Peer.ID, reg.Ns, addrs, reg.Ttl)
+			if err != nil {
+				return imported, err
+			}
+
+			// rejected registrations are tracked as well, so they aren't checked again until they are refreshed
+			s.track(reg.Peer.ID, reg.Ns, reg.Ttl)
+			if ok {
+				count++
+			}
+		}
+
+		imported += count
+		s.db.syncImported.Add(float64(count))
+		s.cookies[p] = cookie
+
+		if len(regs) < limit {
+			return imported, nil
+		}
+
+		if s.maxRegistrations > 0 && pulled >= s.maxRegistrations {
+			s.logger.Debug("maximum number of registrations pulled, the sync will resume later", zap.Stringer("peer", p))
+			return imported, nil
+		}
+	}
+}
+
+func (s *Syncer) track(p peer.ID, ns string, ttl int) {
+	s.muExpires.Lock()
+	s.expires[syncKey{ns: ns, id: p}] = time.Now().Unix() + int64(ttl)
+	s.muExpires.Unlock()
+}
+
+func (s *Syncer) isNewer(ns string, p peer.ID, expire int64) bool {
+	s.muExpires.Lock()
+	known, ok := s.expires[syncKey{ns: ns, id: p}]
+	s.muExpires.Unlock()
+
+	return !ok || expire > known+syncExpireSlack
+}
+
+func (s *Syncer) prune(now int64) {
+	s.muExpires.Lock()
+	for key, expire := range s.expires {
+		if expire < now {
+			delete(s.expires, key)
+		}
+	}
+	s.muExpires.Unlock()
+}
@@ -0,0 +1,91 @@
+package tinder
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	p2p_discovery "github.com/libp2p/go-libp2p-core/discovery"
+	p2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	discovery "github.com/libp2p/go-libp2p-discovery"
+	"go.uber.org/zap"
+)
+
+// FocusedTopics is the set of topics currently used by the user, their peers are looked up more often
+type FocusedTopics struct {
+	mu     sync.RWMutex
+	topics map[string]struct{}
+}
+
+func NewFocusedTopics() *FocusedTopics {
+	return &FocusedTopics{topics: map[string]struct{}{}}
+}
+
+// SetFocus adds or removes the topics from the focused set
+func (f *FocusedTopics) SetFocus(focused bool, topics ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, topic := range topics {
+		topic = strings.TrimPrefix(topic, "floodsub:")
+		if focused {
+			f.topics[topic] = struct{}{}
+		} else {
+			delete(f.topics, topic)
+		}
+	}
+}
+
+// IsFocused tells if the topic, or the pubsub namespace of the topic, is focused
+func (f *FocusedTopics) IsFocused(ns string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	_, ok := f.topics[strings.TrimPrefix(ns, "floodsub:")]
+	return ok
+}
+
+// Len returns the number of focused topics
+func (f *FocusedTopics) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return len(f.topics)
+}
+
+// adaptiveDiscovery looks up the peers of the focused topics using a shorter backoff strategy
+type adaptiveDiscovery struct {
+	idle    p2p_discovery.Discovery
+	focused p2p_discovery.Discovery
+	topics  *FocusedTopics
+}
+
+// NewAdaptiveService is a tinder service using the focused backoff strategy for the topics in the focused set and the idle
+// strategy for the others, the backoff state of a topic is kept for each strategy so it is looked up right away once focused
+func NewAdaptiveService(_ *zap.Logger, driver Driver, idleStrat, focusedStrat discovery.BackoffFactory, topics *FocusedTopics, opts ...discovery.BackoffDiscoveryOption) (Service, error) {
+	idle, err := discovery.NewBackoffDiscovery(driver, idleStrat, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	focused, err := discovery.NewBackoffDiscovery(driver, focusedStrat, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	disc := &adaptiveDiscovery{
+		idle:    idle,
+		focused: focused,
+		topics:  topics,
+	}
+
+	return ComposeDriver("tinder", idle, disc, driver), nil
+}
+
+func (a *adaptiveDiscovery) FindPeers(ctx context.Context, ns string, opts ...p2p_discovery.Option) (<-chan p2p_peer.AddrInfo, error) {
+	if a.topics.IsFocused(ns) {
+		return a.focused.FindPeers(ctx, ns, opts...)
+	}
+
+	return a.idle.FindPeers(ctx, ns, opts...)
+}
@@ -0,0 +1,99 @@
+package tinder
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	p2p_discovery "github.com/libp2p/go-libp2p-core/discovery"
+	p2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	p2p_disc "github.com/libp2p/go-libp2p-discovery"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/internal/testutil"
+)
+
+// countingDriver counts the lookups made on each namespace
+type countingDriver struct {
+	Driver
+
+	mu      sync.Mutex
+	lookups map[string]int
+}
+
+func (d *countingDriver) FindPeers(_ context.Context, ns string, _ ...p2p_discovery.Option) (<-chan p2p_peer.AddrInfo, error) {
+	d.mu.Lock()
+	d.lookups[ns]++
+	d.mu.Unlock()
+
+	ch := make(chan p2p_peer.AddrInfo)
+	close(ch)
+	return ch, nil
+}
+
+func (d *countingDriver) count(ns string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.lookups[ns]
+}
+
+func TestFocusedTopics(t *testing.T) {
+	topics := NewFocusedTopics()
+
+	topics.SetFocus(true, "topic1", "floodsub:topic2")
+	require.True(t, topics.IsFocused("topic1"))
+	require.True(t, topics.IsFocused("floodsub:topic1"))
+	require.True(t, topics.IsFocused("topic2"))
+	require.False(t, topics.IsFocused("topic3"))
+	require.Equal(t, 2, topics.Len())
+
+	topics.SetFocus(false, "topic1")
+	require.False(t, topics.IsFocused("floodsub:topic1"))
+	require.Equal(t, 1, topics.Len())
+}
+
+func TestAdaptiveService_FindPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger, cleanup := testutil.Logger(t)
+	defer cleanup()
+
+	driver := &countingDriver{Driver: ComposeDriver("counting", nil, nil, NoopUnregisterer), lookups: map[string]int{}}
+	topics := NewFocusedTopics()
+
+	// the backoff of the focused topics is already expired once a lookup completes, so the test doesn't depend on the
+	// time elapsed between the lookups
+	service, err := NewAdaptiveService(logger, driver, p2p_disc.NewFixedBackoff(time.Hour), p2p_disc.NewFixedBackoff(-time.Hour), topics)
+	require.NoError(t, err)
+
+	lookup := func(ns string) {
+		cpeers, err := service.FindPeers(ctx, ns)
+		require.NoError(t, err)
+		for range cpeers {
+		}
+	}
+
+	const (
+		idleKey    = "floodsub:idle"
+		focusedKey = "floodsub:focused"
+	)
+
+	topics.SetFocus(true, "focused")
+
+	for i := 0; i < 3; i++ {
+		lookup(idleKey)
+		lookup(focusedKey)
+	}
+
+	// the idle topic is served from the cache until its backoff expires
+	require.Equal(t, 1, driver.count(idleKey))
+	require.Equal(t, 3, driver.count(focusedKey))
+
+	// once focused, the topic is looked up right away
+	topics.SetFocus(true, "idle")
+	lookup(idleKey)
+	require.Equal(t, 2, driver.count(idleKey))
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	fmt "fmt"
 	"strings"
+	"sync"
 	"time"
 
 	p2p_discovery "github.com/libp2p/go-libp2p-core/discovery"
 	p2p_event "github.com/libp2p/go-libp2p-core/event"
 	p2p_host "github.com/libp2p/go-libp2p-core/host"
 	p2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -28,34 +30,106 @@ type EvtDriverMonitor struct {
 	DriverName string
 }
 
+// DriverStats are the counters of a monitored driver
+type DriverStats struct {
+	DriverName string
+
+	AdvertiseSuccesses uint64
+	AdvertiseFailures  uint64
+	FindPeersSuccesses uint64
+	FindPeersFailures  uint64
+
+	// PeersFound is the number of peers returned by the driver, including the ones already known
+	PeersFound uint64
+
+	// AdvertiseLatency and FindPeersLatency are the average durations of the successful requests
+	AdvertiseLatency time.Duration
+	FindPeersLatency time.Duration
+}
+
+var (
+	_ AsyncableDriver      = (*DriverMonitor)(nil)
+	_ prometheus.Collector = (*DriverMonitor)(nil)
+)
+
 type DriverMonitor struct {
 	logger  *zap.Logger
 	h       p2p_host.Host
 	driver  AsyncableDriver
 	emitter p2p_event.Emitter
+
+	muStats sync.Mutex
+	stats   DriverStats
+
+	// the latencies are summed to compute their averages, the asynchronous find peers requests are not timed
+	advertiseLatencySum time.Duration
+	findPeersLatencySum time.Duration
+	findPeersTimed      uint64
+
+	advertiseDesc    *prometheus.Desc
+	findPeersDesc    *prometheus.Desc
+	peersFoundDesc   *prometheus.Desc
+	advertiseLatency prometheus.Histogram
+	findPeersLatency prometheus.Histogram
 }
 
 func MonitorDriver(l *zap.Logger, h p2p_host.Host, driver Driver) (Driver, error) {
 	asDriver := ComposeAsyncableDriver(driver, &noopAsyncDriver{})
-	return MonitorDriverAsync(l, h, asDriver)
+	monitor, err := MonitorDriverAsync(l, h, asDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	return monitor, nil
 }
 
-func MonitorDriverAsync(l *zap.Logger, h p2p_host.Host, adriver AsyncableDriver) (AsyncableDriver, error) {
+func MonitorDriverAsync(l *zap.Logger, h p2p_host.Host, adriver AsyncableDriver) (*DriverMonitor, error) {
 	emitter, err := h.EventBus().Emitter(new(EvtDriverMonitor))
 	if err != nil {
 		return nil, err
 	}
+
+	// the driver name is set as a constant label, so the monitors of several drivers can be registered on the same registry
+	labels := prometheus.Labels{"driver": adriver.Name()}
 	return &DriverMonitor{
 		driver:  adriver,
 		emitter: emitter,
 		logger:  l,
 		h:       h,
+		stats:   DriverStats{DriverName: adriver.Name()},
+
+		advertiseDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("tinder", "", "advertise_total"),
+			"number of advertise requests by result", []string{"result"}, labels),
+		findPeersDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("tinder", "", "find_peers_total"),
+			"number of find peers requests by result", []string{"result"}, labels),
+		peersFoundDesc: prometheus.NewDesc(
+			prometheus.BuildFQName("tinder", "", "peers_found_total"),
+			"number of peers returned by the driver", nil, labels),
+		advertiseLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   "tinder",
+			Subsystem:   "advertise",
+			Name:        "latency_seconds",
+			Help:        "duration of the successful advertise requests",
+			ConstLabels: labels,
+		}),
+		findPeersLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   "tinder",
+			Subsystem:   "find_peers",
+			Name:        "latency_seconds",
+			Help:        "duration of the successful find peers requests, until the driver returned its last peer",
+			ConstLabels: labels,
+		}),
 	}, nil
 }
 
 func (d *DriverMonitor) Advertise(ctx context.Context, ns string, opts ...p2p_discovery.Option) (ttl time.Duration, err error) {
 	topic := strings.TrimPrefix(ns, "floodsub:")
-	if ttl, err = d.driver.Advertise(ctx, ns, opts...); err == nil {
+	start := time.Now()
+	ttl, err = d.driver.Advertise(ctx, ns, opts...)
+	d.reportAdvertise(err, time.Since(start))
+	if err == nil {
 		d.Emit(&EvtDriverMonitor{
 			EventType:  TypeEventMonitorAdvertise,
 			Topic:      topic,
@@ -68,8 +142,10 @@ func (d *DriverMonitor) Advertise(ctx context.Context, ns string, opts ...p2p_di
 }
 
 func (d *DriverMonitor) FindPeers(ctx context.Context, ns string, opts ...p2p_discovery.Option) (<-chan p2p_peer.AddrInfo, error) {
+	start := time.Now()
 	ccDriver, err := d.driver.FindPeers(ctx, ns, opts...)
 	if err != nil {
+		d.reportFindPeers(err, 0)
 		return nil, err
 	}
 
@@ -77,14 +153,24 @@ func (d *DriverMonitor) FindPeers(ctx context.Context, ns string, opts ...p2p_di
 	ccMonitor := make(chan p2p_peer.AddrInfo)
 	go func() {
 		defer close(ccMonitor)
+
+		// the request is over once the driver closes the channel, the time spent waiting for the consumer to receive the
+		// peers is not part of the request
+		var waiting time.Duration
+		defer func() { d.reportFindPeers(nil, time.Since(start)-waiting) }()
+
 		for p := range ccDriver {
+			d.reportPeerFound()
 			d.Emit(&EvtDriverMonitor{
 				EventType:  TypeEventMonitorFoundPeer,
 				Topic:      topic,
 				AddrInfo:   p,
 				DriverName: d.driver.Name(),
 			})
+
+			sent := time.Now()
 			ccMonitor <- p
+			waiting += time.Since(sent)
 		}
 	}()
 
@@ -97,6 +183,7 @@ func (d *DriverMonitor) FindPeersAsync(ctx context.Context, ccMonitor chan<- p2p
 	defer close(ccDriver)
 	go func() {
 		for p := range ccDriver {
+			d.reportPeerFound()
 			d.Emit(&EvtDriverMonitor{
 				EventType:  TypeEventMonitorFoundPeer,
 				Topic:      topic,
@@ -106,7 +193,11 @@ func (d *DriverMonitor) FindPeersAsync(ctx context.Context, ccMonitor chan<- p2p
 			ccMonitor <- p
 		}
 	}()
-	return d.driver.FindPeersAsync(ctx, ccDriver, ns, opts...)
+
+	// the asynchronous requests return before completion, their latency is not tracked
+	err := d.driver.FindPeersAsync(ctx, ccDriver, ns, opts...)
+	d.reportFindPeers(err, 0)
+	return err
 }
 
 func (d *DriverMonitor) Unregister(ctx context.Context, ns string) error {
@@ -122,3 +213,77 @@ func (d *DriverMonitor) Emit(evt *EvtDriverMonitor) {
 		d.logger.Warn("unable to emit `EvtDriverMonitor`", zap.Error(err))
 	}
 }
+
+// Stats returns a copy of the current counters of the driver
+func (d *DriverMonitor) Stats() DriverStats {
+	d.muStats.Lock()
+	defer d.muStats.Unlock()
+
+	stats := d.stats
+	if stats.AdvertiseSuccesses > 0 {
+		stats.AdvertiseLatency = d.advertiseLatencySum / time.Duration(stats.AdvertiseSuccesses)
+	}
+	if d.findPeersTimed > 0 {
+		stats.FindPeersLatency = d.findPeersLatencySum / time.Duration(d.findPeersTimed)
+	}
+
+	return stats
+}
+
+func (d *DriverMonitor) reportAdvertise(err error, latency time.Duration) {
+	d.muStats.Lock()
+	defer d.muStats.Unlock()
+
+	if err != nil {
+		d.stats.AdvertiseFailures++
+		return
+	}
+
+	d.advertiseLatency.Observe(latency.Seconds())
+	d.advertiseLatencySum += latency
+	d.stats.AdvertiseSuccesses++
+}
+
+// reportFindPeers counts a find peers request, a zero latency is not accounted in the latencies
+func (d *DriverMonitor) reportFindPeers(err error, latency time.Duration) {
+	d.muStats.Lock()
+	defer d.muStats.Unlock()
+
+	if err != nil {
+		d.stats.FindPeersFailures++
+		return
+	}
+
+	if latency > 0 {
+		d.findPeersLatency.Observe(latency.Seconds())
+		d.findPeersLatencySum += latency
+		d.findPeersTimed++
+	}
+	d.stats.FindPeersSuccesses++
+}
+
+func (d *DriverMonitor) reportPeerFound() {
+	d.muStats.Lock()
+	d.stats.PeersFound++
+	d.muStats.Unlock()
+}
+
+func (d *DriverMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- d.advertiseDesc
+	ch <- d.findPeersDesc
+	ch <- d.peersFoundDesc
+	d.advertiseLatency.Describe(ch)
+	d.findPeersLatency.Describe(ch)
+}
+
+func (d *DriverMonitor) Collect(ch chan<- prometheus.Metric) {
+	stats := d.Stats()
+
+	ch <- prometheus.MustNewConstMetric(d.advertiseDesc, prometheus.CounterValue, float64(stats.AdvertiseSuccesses), "success")
+	ch <- prometheus.MustNewConstMetric(d.advertiseDesc, prometheus.CounterValue, float64(stats.AdvertiseFailures), "failure")
+	ch <- prometheus.MustNewConstMetric(d.findPeersDesc, prometheus.CounterValue, float64(stats.FindPeersSuccesses), "success")
+	ch <- prometheus.MustNewConstMetric(d.findPeersDesc, prometheus.CounterValue, float64(stats.FindPeersFailures), "failure")
+	ch <- prometheus.MustNewConstMetric(d.peersFoundDesc, prometheus.CounterValue, float64(stats.PeersFound))
+	d.advertiseLatency.Collect(ch)
+	d.findPeersLatency.Collect(ch)
+}
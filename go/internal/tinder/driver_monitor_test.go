@@ -0,0 +1,71 @@
+package tinder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	p2p_discovery "github.com/libp2p/go-libp2p-core/discovery"
+	p2p_mock "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/internal/testutil"
+)
+
+func TestDriverMonitor_Stats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger, cleanup := testutil.Logger(t)
+	defer cleanup()
+
+	mn := p2p_mock.New(ctx)
+	ms := NewMockedDriverServer()
+	peers := testingPeers(t, mn, 2)
+	drivers := testingMockedAsyncDriverClients(t, ms, peers...)
+
+	monitor, err := MonitorDriverAsync(logger, peers[0], drivers[0])
+	require.NoError(t, err)
+
+	const testKey = "testkey"
+
+	_, err = drivers[1].Advertise(ctx, testKey, p2p_discovery.TTL(time.Minute))
+	require.NoError(t, err)
+
+	_, err = monitor.Advertise(ctx, testKey, p2p_discovery.TTL(time.Minute))
+	require.NoError(t, err)
+
+	cpeers, err := monitor.FindPeers(ctx, testKey)
+	require.NoError(t, err)
+	for range cpeers {
+	}
+
+	stats := monitor.Stats()
+	require.Equal(t, "mock", stats.DriverName)
+	require.Equal(t, uint64(1), stats.AdvertiseSuccesses)
+	require.Equal(t, uint64(0), stats.AdvertiseFailures)
+	require.Equal(t, uint64(1), stats.FindPeersSuccesses)
+	require.Equal(t, uint64(2), stats.PeersFound)
+
+	// the failed requests are counted separately
+	_, err = monitor.Advertise(ctx, testKey, p2p_discovery.TTL(time.Minute), func(*p2p_discovery.Options) error { return context.Canceled })
+	require.Error(t, err)
+	require.Equal(t, uint64(1), monitor.Stats().AdvertiseFailures)
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(monitor))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 5)
+
+	// the latencies of the successful requests are observed in histograms
+	for _, family := range families {
+		switch family.GetName() {
+		case "tinder_advertise_latency_seconds", "tinder_find_peers_latency_seconds":
+			require.Len(t, family.GetMetric(), 1)
+			require.Equal(t, uint64(1), family.GetMetric()[0].GetHistogram().GetSampleCount())
+		}
+	}
+}
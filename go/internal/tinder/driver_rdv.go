@@ -17,9 +17,14 @@ import (
 	"go.uber.org/zap"
 )
 
+// RendezvousAuthFunc authenticates the host to a rendezvous point, it is called before each registration
+type RendezvousAuthFunc func(ctx context.Context, rdvPeer peer.ID) error
+
 type rendezvousDiscovery struct {
 	logger       *zap.Logger
 	rp           p2p_rp.RendezvousPoint
+	rdvPeer      peer.ID
+	auth         RendezvousAuthFunc
 	peerCache    map[string]*rpCache
 	peerCacheMux sync.RWMutex
 	rng          *mrand.Rand
@@ -39,10 +44,18 @@ type rpRecord struct {
 }
 
 func NewRendezvousDiscovery(logger *zap.Logger, host host.Host, rdvPeer peer.ID, rng *mrand.Rand) AsyncableDriver {
+	return NewAuthenticatedRendezvousDiscovery(logger, host, rdvPeer, rng, nil)
+}
+
+// NewAuthenticatedRendezvousDiscovery returns a rendezvous driver calling auth before each registration, so that the
+// rendezvous point accepts it
+func NewAuthenticatedRendezvousDiscovery(logger *zap.Logger, host host.Host, rdvPeer peer.ID, rng *mrand.Rand, auth RendezvousAuthFunc) AsyncableDriver {
 	rp := p2p_rp.NewRendezvousPoint(host, rdvPeer)
 	return &rendezvousDiscovery{
 		logger:    logger.Named("tinder/rdvp"),
 		rp:        rp,
+		rdvPeer:   rdvPeer,
+		auth:      auth,
 		rng:       rng,
 		peerCache: make(map[string]*rpCache),
 		selfID:    host.ID(),
@@ -66,6 +79,12 @@ func (c *rendezvousDiscovery) Advertise(ctx context.Context, ns string, opts ...
 		ttlSeconds = int(math.Round(ttl.Seconds()))
 	}
 
+	if c.auth != nil {
+		if err := c.auth(ctx, c.rdvPeer); err != nil {
+			return 0, err
+		}
+	}
+
 	rttl, err := c.rp.Register(ctx, ns, ttlSeconds)
 	if err != nil {
 		return 0, err
@@ -0,0 +1,395 @@
+package tinder
+
+import (
+	"context"
+	"fmt"
+	mrand "math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/discovery"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// unreachable servers are skipped for an exponentially growing duration bounded by those values
+	rdvpClusterMinBackoff = 10 * time.Second
+	rdvpClusterMaxBackoff = 10 * time.Minute
+
+	// weight of the last request in the latency moving average
+	rdvpClusterLatencyWeight = 0.3
+)
+
+// RendezvousServerHealth is the health of a rendezvous point as seen by a RendezvousCluster
+type RendezvousServerHealth struct {
+	ID peer.ID
+
+	// Latency is a moving average of the duration of the successful requests
+	Latency time.Duration
+
+	// Failures is the number of consecutive failed requests
+	Failures int
+
+	// RetryAt is the time before which the server is skipped, it is zero when the server is healthy
+	RetryAt time.Time
+
+	LastError error
+}
+
+type rdvpClusterMember struct {
+	id     peer.ID
+	driver AsyncableDriver
+
+	mu     sync.Mutex
+	health RendezvousServerHealth
+}
+
+// report updates the health of the member with the result of a request, it returns true when the server became healthy
+// or unhealthy
+func (m *rdvpClusterMember) report(err error, latency time.Duration, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		recovered := m.health.Failures > 0
+		m.health.Failures = 0
+		m.health.RetryAt = time.Time{}
+		m.health.LastError = nil
+
+		if m.health.Latency == 0 {
+			m.health.Latency = latency
+		} else {
+			m.health.Latency = time.Duration(rdvpClusterLatencyWeight*float64(latency) + (1-rdvpClusterLatencyWeight)*float64(m.health.Latency))
+		}
+
+		return recovered
+	}
+
+	m.health.Failures++
+	m.health.LastError = err
+
+	backoff := rdvpClusterMaxBackoff
+	if shift := uint(m.health.Failures - 1); shift < 16 && rdvpClusterMinBackoff<<shift < rdvpClusterMaxBackoff {
+		backoff = rdvpClusterMinBackoff << shift
+	}
+	m.health.RetryAt = now.Add(backoff)
+
+	return m.health.Failures == 1
+}
+
+func (m *rdvpClusterMember) getHealth() RendezvousServerHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.health
+}
+
+// RendezvousCluster is a driver handling a list of rendezvous points as a cluster, peers are advertised on a quorum of
+// servers and looked up on every healthy server, unreachable servers are skipped until their backoff expires
+type RendezvousCluster struct {
+	logger  *zap.Logger
+	members []*rdvpClusterMember
+	quorum  int
+}
+
+var _ AsyncableDriver = (*RendezvousCluster)(nil)
+
+// NewRendezvousCluster creates a driver using the given rendezvous points, a majority of the servers is used as quorum
+// when it is zero, auth is called before each registration on a server when set
+func NewRendezvousCluster(logger *zap.Logger, h host.Host, rdvPeers []peer.ID, rng *mrand.Rand, quorum int, auth RendezvousAuthFunc) *RendezvousCluster {
+	drivers := make([]AsyncableDriver, len(rdvPeers))
+	for i, id := range rdvPeers {
+		// each driver locks its own rng
+		memberRng := mrand.New(mrand.NewSource(rng.Int63())) // nolint:gosec // seeded from the given rng
+		drivers[i] = NewAuthenticatedRendezvousDiscovery(logger, h, id, memberRng, auth)
+	}
+
+	return newRendezvousCluster(logger, rdvPeers, drivers, quorum)
+}
+
+func newRendezvousCluster(logger *zap.Logger, ids []peer.ID, drivers []AsyncableDriver, quorum int) *RendezvousCluster {
+	if len(ids) == 0 {
+		panic("tinder.NewRendezvousCluster requires at least one rendezvous point")
+	}
+
+	if quorum <= 0 {
+		quorum = len(ids)/2 + 1
+	}
+	if quorum > len(ids) {
+		quorum = len(ids)
+	}
+
+	members := make([]*rdvpClusterMember, len(ids))
+	for i, id := range ids {
+		members[i] = &rdvpClusterMember{
+			id:     id,
+			driver: drivers[i],
+			health: RendezvousServerHealth{ID: id},
+		}
+	}
+
+	return &RendezvousCluster{
+		logger:  logger.Named("tinder/rdvp-cluster"),
+		members: members,
+		quorum:  quorum,
+	}
+}
+
+// Health returns the health of each server of the cluster
+func (c *RendezvousCluster) Health() []RendezvousServerHealth {
+	health := make([]RendezvousServerHealth, len(c.members))
+	for i, m := range c.members {
+		health[i] = m.getHealth()
+	}
+
+	return health
+}
+
+// candidates returns the members ordered by preference, healthy servers first sorted by latency then the unhealthy
+// ones sorted by retry time, the number of healthy servers is returned as well
+func (c *RendezvousCluster) candidates(now time.Time) ([]*rdvpClusterMember, int) {
+	type candidate struct {
+		member *rdvpClusterMember
+		health RendezvousServerHealth
+	}
+
+	candidates := make([]candidate, len(c.members))
+	healthy := 0
+	for i, m := range c.members {
+		candidates[i] = candidate{member: m, health: m.getHealth()}
+		if !candidates[i].health.RetryAt.After(now) {
+			healthy++
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		hi, hj := candidates[i].health, candidates[j].health
+		upi, upj := !hi.RetryAt.After(now), !hj.RetryAt.After(now)
+		switch {
+		case upi != upj:
+			return upi
+		case upi:
+			return hi.Latency < hj.Latency
+		default:
+			return hi.RetryAt.Before(hj.RetryAt)
+		}
+	})
+
+	members := make([]*rdvpClusterMember, len(candidates))
+	for i, cand := range candidates {
+		members[i] = cand.member
+	}
+
+	return members, healthy
+}
+
+func (c *RendezvousCluster) report(ctx context.Context, m *rdvpClusterMember, err error, start time.Time) {
+	// a canceled request doesn't tell anything about the server
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	if changed := m.report(err, now.Sub(start), now); changed {
+		if err != nil {
+			c.logger.Warn("rendezvous point unreachable", zap.Stringer("peer", m.id), zap.Error(err))
+		} else {
+			c.logger.Info("rendezvous point reachable again", zap.Stringer("peer", m.id))
+		}
+	}
+}
+
+// Advertise registers the peer on the best servers until the quorum is reached, a failing server is replaced by the
+// next one
+func (c *RendezvousCluster) Advertise(ctx context.Context, ns string, opts ...discovery.Option) (time.Duration, error) {
+	members, _ := c.candidates(time.Now())
+
+	type result struct {
+		member *rdvpClusterMember
+		ttl    time.Duration
+		err    error
+	}
+
+	results := make(chan result, len(members))
+	next := 0
+	advertise := func() {
+		m := members[next]
+		next++
+
+		go func() {
+			start := time.Now()
+			ttl, err := m.driver.Advertise(ctx, ns, opts...)
+			c.report(ctx, m, err, start)
+			results <- result{member: m, ttl: ttl, err: err}
+		}()
+	}
+
+	for next < c.quorum {
+		advertise()
+	}
+
+	var (
+		errs      error
+		ttl       time.Duration
+		successes int
+	)
+	for pending := c.quorum; pending > 0; pending-- {
+		res := <-results
+		if res.err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: %w", res.member.id, res.err))
+
+			// fail over to the next server
+			if next < len(members) {
+				advertise()
+				pending++
+			}
+			continue
+		}
+
+		successes++
+		if ttl == 0 || res.ttl < ttl {
+			ttl = res.ttl
+		}
+	}
+
+	if successes < c.quorum {
+		return 0, fmt.Errorf("unable to reach quorum, advertised on %d/%d servers: %w", successes, c.quorum, errs)
+	}
+
+	return ttl, nil
+}
+
+func (c *RendezvousCluster) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+
+	limit := options.Limit
+	if limit == 0 || limit > maxLimit {
+		limit = maxLimit
+	}
+
+	outPeers := make(chan peer.AddrInfo, limit)
+	if err := c.query(ctx, outPeers, ns, limit, func() { close(outPeers) }, opts...); err != nil {
+		return nil, err
+	}
+
+	return outPeers, nil
+}
+
+func (c *RendezvousCluster) FindPeersAsync(ctx context.Context, outPeers chan<- peer.AddrInfo, ns string, opts ...discovery.Option) error {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return err
+	}
+
+	limit := options.Limit
+	if limit == 0 || limit > maxLimit {
+		limit = maxLimit
+	}
+
+	go func() {
+		if err := c.query(ctx, outPeers, ns, limit, func() {}, opts...); err != nil {
+			c.logger.Debug("unable to find peers", zap.String("ns", ns), zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// query looks up the namespace on the healthy servers in parallel, or on every server when none is healthy, the peers
+// are de-duplicated and forwarded to outPeers, done is called once every server answered
+func (c *RendezvousCluster) query(ctx context.Context, outPeers chan<- peer.AddrInfo, ns string, limit int, done func(), opts ...discovery.Option) error {
+	members, healthy := c.candidates(time.Now())
+	if healthy > 0 {
+		members = members[:healthy]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var (
+		wg, errWg   sync.WaitGroup
+		seenLock    sync.Mutex
+		alreadySeen = make(map[peer.ID]struct{})
+		errs        = make([]error, len(members))
+	)
+
+	wg.Add(len(members))
+	errWg.Add(len(members))
+	for i, m := range members {
+		go func(i int, m *rdvpClusterMember) {
+			defer wg.Done()
+
+			start := time.Now()
+			inPeers, err := m.driver.FindPeers(ctx, ns, opts...)
+			c.report(ctx, m, err, start)
+			errs[i] = err
+			errWg.Done()
+
+			if err != nil {
+				return
+			}
+
+			for p := range inPeers {
+				seenLock.Lock()
+				if len(alreadySeen) == limit {
+					seenLock.Unlock()
+					return
+				}
+				if _, ok := alreadySeen[p.ID]; ok {
+					seenLock.Unlock()
+					continue
+				}
+				alreadySeen[p.ID] = struct{}{}
+				seenLock.Unlock()
+
+				select {
+				case outPeers <- p:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(i, m)
+	}
+
+	errWg.Wait()
+	for _, err := range errs {
+		if err == nil {
+			// at least one server answered
+			go func() {
+				wg.Wait()
+				cancel()
+				done()
+			}()
+			return nil
+		}
+	}
+
+	cancel()
+	return multierr.Combine(errs...)
+}
+
+// Unregister removes the registration from every server, the advertised servers may have changed since the
+// registration
+func (c *RendezvousCluster) Unregister(ctx context.Context, ns string) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(c.members))
+
+	wg.Add(len(c.members))
+	for i, m := range c.members {
+		go func(i int, m *rdvpClusterMember) {
+			defer wg.Done()
+			errs[i] = m.driver.Unregister(ctx, ns)
+		}(i, m)
+	}
+	wg.Wait()
+
+	return multierr.Combine(errs...)
+}
+
+func (*RendezvousCluster) Name() string { return "rdvp-cluster" }
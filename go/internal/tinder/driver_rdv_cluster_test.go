@@ -0,0 +1,113 @@
+package tinder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	p2p_discovery "github.com/libp2p/go-libp2p-core/discovery"
+	p2p_host "github.com/libp2p/go-libp2p-core/host"
+	p2p_peer "github.com/libp2p/go-libp2p-core/peer"
+	p2p_disc "github.com/libp2p/go-libp2p-discovery"
+	p2p_mock "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/internal/testutil"
+)
+
+// unreachableDriver simulates a rendezvous point which can't be reached
+type unreachableDriver struct{ AsyncableDriver }
+
+func (unreachableDriver) Advertise(context.Context, string, ...p2p_discovery.Option) (time.Duration, error) {
+	return 0, fmt.Errorf("unreachable")
+}
+
+func (unreachableDriver) FindPeers(context.Context, string, ...p2p_discovery.Option) (<-chan p2p_peer.AddrInfo, error) {
+	return nil, fmt.Errorf("unreachable")
+}
+
+func testingRendezvousCluster(t *testing.T, h p2p_host.Host, servers []*MockDriverServer, unreachable map[int]bool, quorum int) *RendezvousCluster {
+	t.Helper()
+
+	logger, cleanup := testutil.Logger(t)
+	t.Cleanup(cleanup)
+
+	ids := make([]p2p_peer.ID, len(servers))
+	drivers := make([]AsyncableDriver, len(servers))
+	for i, s := range servers {
+		ids[i] = p2p_peer.ID(fmt.Sprintf("rdvp-%d", i))
+		drivers[i] = NewMockedDriverClient(h, s)
+		if unreachable[i] {
+			drivers[i] = unreachableDriver{drivers[i]}
+		}
+	}
+
+	return newRendezvousCluster(logger, ids, drivers, quorum)
+}
+
+func TestRendezvousCluster_AdvertiseQuorum(t *testing.T) {
+	ctx := context.Background()
+	mn := p2p_mock.New(ctx)
+	peers := testingPeers(t, mn, 1)
+
+	servers := []*MockDriverServer{NewMockedDriverServer(), NewMockedDriverServer(), NewMockedDriverServer()}
+	const testKey = "testkey"
+
+	// the unreachable server is replaced by the third one
+	cluster := testingRendezvousCluster(t, peers[0], servers, map[int]bool{0: true}, 2)
+	ttl, err := cluster.Advertise(ctx, testKey, p2p_discovery.TTL(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	assert.True(t, servers[1].HasPeerRecord(testKey, peers[0].ID()))
+	assert.True(t, servers[2].HasPeerRecord(testKey, peers[0].ID()))
+
+	health := cluster.Health()
+	assert.Equal(t, 1, health[0].Failures)
+	assert.True(t, health[0].RetryAt.After(time.Now()))
+	assert.Equal(t, 0, health[1].Failures)
+
+	// the unreachable server is now tried last
+	members, healthy := cluster.candidates(time.Now())
+	assert.Equal(t, 2, healthy)
+	assert.Equal(t, health[0].ID, members[2].id)
+
+	// the quorum can't be reached with a single reachable server
+	cluster = testingRendezvousCluster(t, peers[0], servers, map[int]bool{0: true, 1: true}, 2)
+	_, err = cluster.Advertise(ctx, testKey, p2p_discovery.TTL(time.Minute))
+	require.Error(t, err)
+}
+
+func TestRendezvousCluster_FindPeers(t *testing.T) {
+	ctx := context.Background()
+	mn := p2p_mock.New(ctx)
+	peers := testingPeers(t, mn, 3)
+
+	servers := []*MockDriverServer{NewMockedDriverServer(), NewMockedDriverServer(), NewMockedDriverServer()}
+	const testKey = "testkey"
+
+	// every peer is registered on two servers
+	for i, p := range peers {
+		for _, s := range []*MockDriverServer{servers[i%3], servers[(i+1)%3]} {
+			_, err := s.Advertise(testKey, *p2p_host.InfoFromHost(p), time.Minute)
+			require.NoError(t, err)
+		}
+	}
+
+	cluster := testingRendezvousCluster(t, peers[0], servers, map[int]bool{2: true}, 0)
+	ps, err := p2p_disc.FindPeers(ctx, cluster, testKey)
+	require.NoError(t, err)
+
+	// peers are de-duplicated and found despite the unreachable server
+	require.Len(t, ps, len(peers))
+	for _, p := range peers {
+		assert.Contains(t, ps, *p2p_host.InfoFromHost(p))
+	}
+
+	// the query fails when no server can be reached
+	cluster = testingRendezvousCluster(t, peers[0], servers, map[int]bool{0: true, 1: true, 2: true}, 0)
+	_, err = cluster.FindPeers(ctx, testKey)
+	require.Error(t, err)
+}
@@ -24,10 +24,15 @@ type Bot struct {
 	isReplaying       bool
 	handledEvents     uint
 	commands          map[string]command
+	handlingMutex     sync.Mutex
 	store             struct {
 		conversations map[string]*messengertypes.Conversation
 		mutex         sync.Mutex
 	}
+	ephemeral struct {
+		stream messengertypes.MessengerService_ConversationEphemeralStreamClient
+		mutex  sync.Mutex
+	}
 }
 
 // New initializes a new Bot.
@@ -92,6 +97,13 @@ func (b *Bot) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to listen to EventStream: %w", err)
 	}
 
+	// ephemeral events are only streamed if someone is interested in them
+	if len(b.handlers[EphemeralEventHandler]) > 0 {
+		if err := b.startEphemeralStream(ctx); err != nil {
+			return err
+		}
+	}
+
 	b.isReplaying = true
 	for {
 		gme, err := s.Recv()
@@ -99,6 +111,12 @@ func (b *Bot) Start(ctx context.Context) error {
 			return fmt.Errorf("stream error: %w", err)
 		}
 
+		if gme.Event.Type == messengertypes.StreamEvent_TypeConversationUpdated {
+			if err := b.subscribeEphemeralEvents(gme.Event); err != nil {
+				b.logger.Error("bot.subscribeEphemeralEvents failed", zap.Error(err))
+			}
+		}
+
 		if b.isReplaying {
 			if gme.Event.Type == messengertypes.StreamEvent_TypeListEnded {
 				b.logger.Info("finished replaying logs from the previous sessions", zap.Uint("count", b.handledEvents))
@@ -111,8 +129,63 @@ func (b *Bot) Start(ctx context.Context) error {
 			}
 		}
 
-		if err := b.handleEvent(ctx, gme.Event); err != nil {
+		b.handlingMutex.Lock()
+		err = b.handleEvent(ctx, gme.Event)
+		b.handlingMutex.Unlock()
+		if err != nil {
 			b.logger.Error("bot.handleEvent failed", zap.Error(err))
 		}
 	}
 }
+
+func (b *Bot) startEphemeralStream(ctx context.Context) error {
+	s, err := b.client.ConversationEphemeralStream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open ConversationEphemeralStream: %w", err)
+	}
+
+	b.ephemeral.mutex.Lock()
+	b.ephemeral.stream = s
+	b.ephemeral.mutex.Unlock()
+
+	go func() {
+		for {
+			reply, err := s.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					b.logger.Error("ephemeral stream error", zap.Error(err))
+				}
+				return
+			}
+
+			b.handlingMutex.Lock()
+			b.handleEphemeralEvent(ctx, reply.Event)
+			b.handlingMutex.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// subscribeEphemeralEvents subscribes the ephemeral stream to the events of an updated conversation,
+// the messenger ignores the conversations that are already subscribed
+func (b *Bot) subscribeEphemeralEvents(event *messengertypes.StreamEvent) error {
+	b.ephemeral.mutex.Lock()
+	defer b.ephemeral.mutex.Unlock()
+
+	if b.ephemeral.stream == nil {
+		return nil
+	}
+
+	payload, err := event.UnmarshalPayload()
+	if err != nil {
+		return fmt.Errorf("unmarshal event payload failed: %w", err)
+	}
+
+	pk := payload.(*messengertypes.StreamEvent_ConversationUpdated).Conversation.GetPublicKey()
+	if err := b.ephemeral.stream.Send(&messengertypes.ConversationEphemeralStream_Request{ConversationPublicKey: pk}); err != nil {
+		return fmt.Errorf("ephemeral stream send failed: %w", err)
+	}
+
+	return nil
+}
@@ -25,14 +25,15 @@ type Context struct {
 	IsNew        bool // whether the event is new or an entity update
 
 	// parsed payloads, depending on the context
-	Contact        *messengertypes.Contact      `json:"Contact,omitempty"`
-	Conversation   *messengertypes.Conversation `json:"Conversation,omitempty"`
-	Interaction    *messengertypes.Interaction  `json:"Interaction,omitempty"`
-	Member         *messengertypes.Member       `json:"Member,omitempty"`
-	Account        *messengertypes.Account      `json:"Account,omitempty"`
-	Device         *messengertypes.Device       `json:"Device,omitempty"`
-	ConversationPK string                       `json:"ConversationPK,omitempty"`
-	UserMessage    string                       `json:"UserMessage,omitempty"`
+	Contact        *messengertypes.Contact        `json:"Contact,omitempty"`
+	Conversation   *messengertypes.Conversation   `json:"Conversation,omitempty"`
+	Interaction    *messengertypes.Interaction    `json:"Interaction,omitempty"`
+	Member         *messengertypes.Member         `json:"Member,omitempty"`
+	Account        *messengertypes.Account        `json:"Account,omitempty"`
+	Device         *messengertypes.Device         `json:"Device,omitempty"`
+	EphemeralEvent *messengertypes.EphemeralEvent `json:"EphemeralEvent,omitempty"`
+	ConversationPK string                         `json:"ConversationPK,omitempty"`
+	UserMessage    string                         `json:"UserMessage,omitempty"`
 	CommandArgs    []string
 
 	// internal
@@ -31,6 +31,7 @@ const (
 	MemberUpdatedHandler
 	DeviceUpdatedHandler
 	NotificationHandler
+	EphemeralEventHandler
 
 	// specialized events
 
@@ -151,6 +152,23 @@ func (b *Bot) handleEvent(ctx context.Context, event *messengertypes.StreamEvent
 	return nil
 }
 
+func (b *Bot) handleEphemeralEvent(ctx context.Context, event *messengertypes.EphemeralEvent) {
+	context := &Context{
+		Context:        ctx,
+		EventPayload:   event,
+		IsReplay:       false,
+		Client:         b.client,
+		Logger:         b.logger,
+		IsMe:           event.IsMe,
+		IsNew:          true,
+		ConversationPK: event.ConversationPublicKey,
+		EphemeralEvent: event,
+	}
+
+	b.callHandlers(context, EphemeralEventHandler)
+	b.callHandlers(context, PostAnythingHandler)
+}
+
 func (b *Bot) callHandlers(context *Context, typ HandlerType) {
 	if !b.withFromMyself && context.IsMe {
 		return
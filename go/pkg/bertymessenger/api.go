@@ -1,6 +1,7 @@
 package bertymessenger
 
 import (
+	"archive/tar"
 	"context"
 	"errors"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	ipfscid "github.com/ipfs/go-cid"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
 	"berty.tech/berty/v2/go/internal/bertylinks"
 	"berty.tech/berty/v2/go/internal/discordlog"
@@ -146,7 +148,8 @@ func (svc *service) ShareableBertyGroup(ctx context.Context, req *messengertypes
 	svc.handlerMutex.Lock()
 	defer svc.handlerMutex.Unlock()
 
-	grpInfo, err := svc.protocolClient.GroupInfo(ctx, &protocoltypes.GroupInfo_Request{
+	// only the admins of the group can invite new members
+	invitation, err := svc.protocolClient.MultiMemberGroupInvitationCreate(ctx, &protocoltypes.MultiMemberGroupInvitationCreate_Request{
 		GroupPK: req.GroupPK,
 	})
 	if err != nil {
@@ -154,7 +157,7 @@ func (svc *service) ShareableBertyGroup(ctx context.Context, req *messengertypes
 	}
 
 	group := &messengertypes.BertyGroup{
-		Group:       grpInfo.Group,
+		Group:       invitation.Group,
 		DisplayName: req.GroupName,
 	}
 	link := group.GetBertyLink()
@@ -468,8 +471,14 @@ func (svc *service) EventStream(req *messengertypes.EventStream_Request, sub mes
 	}
 
 	// send interactions
+	var interactions []*messengertypes.Interaction
 	{
-		interactions, err := svc.db.getAllInteractions()
+		var err error
+		if req.GetShallow() {
+			interactions, err = svc.db.getLatestInteractionsPerConversation(int(req.GetShallowAmount()))
+		} else {
+			interactions, err = svc.db.getAllInteractions()
+		}
 		if err != nil {
 			return err
 		}
@@ -485,11 +494,18 @@ func (svc *service) EventStream(req *messengertypes.EventStream_Request, sub mes
 		}
 	}
 
-	// send medias
+	// send medias, only the ones of the sent interactions when shallow
 	{
-		medias, err := svc.db.getAllMedias()
-		if err != nil {
-			return err
+		var medias []*messengertypes.Media
+		if req.GetShallow() {
+			for _, inte := range interactions {
+				medias = append(medias, inte.GetMedias()...)
+			}
+		} else {
+			var err error
+			if medias, err = svc.db.getAllMedias(); err != nil {
+				return err
+			}
 		}
 		svc.logger.Info("sending existing medias", zap.Int("count", len(medias)))
 		for _, media := range medias {
@@ -657,6 +673,233 @@ func (svc *service) ConversationCreate(ctx context.Context, req *messengertypes.
 	return &rep, nil
 }
 
+func (svc *service) ConversationUpdate(ctx context.Context, req *messengertypes.ConversationUpdate_Request) (*messengertypes.ConversationUpdate_Reply, error) {
+	pk := req.GetConversationPublicKey()
+	if pk == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	pkb, err := b64DecodeBytes(pk)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	avatarCID := req.GetAvatarCID()
+	if avatarCID != "" {
+		if err := ensureValidBase64CID(avatarCID); err != nil {
+			err = fmt.Errorf("couldn't ensure the avatar cid is a valid ipfs cid: %s", err)
+			svc.logger.Error("ConversationUpdate: bad avatar cid", zap.Error(err))
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+	}
+
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	conv, err := svc.db.getConversationByPK(pk)
+	if err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if conv.GetType() != messengertypes.Conversation_MultiMemberType {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("only multi-member conversations can be updated"))
+	}
+
+	gir, err := svc.protocolClient.GroupInfo(ctx, &protocoltypes.GroupInfo_Request{GroupPK: pkb})
+	if err != nil {
+		return nil, errcode.ErrGroupInfo.Wrap(err)
+	}
+
+	isAdmin, err := svc.db.isMemberAdmin(b64EncodeBytes(gir.GetMemberPK()), pk)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAdmin {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("only group admins can update the conversation"))
+	}
+
+	dn := req.GetDisplayName()
+	if dn == "" {
+		dn = conv.GetDisplayName()
+	}
+
+	if avatarCID == "" {
+		avatarCID = conv.GetAvatarCID()
+	}
+
+	if dn == conv.GetDisplayName() && avatarCID == conv.GetAvatarCID() {
+		svc.logger.Debug("ConversationUpdate: nothing to do")
+		return &messengertypes.ConversationUpdate_Reply{}, nil
+	}
+
+	am, err := messengertypes.AppMessage_TypeSetGroupInfo.MarshalPayload(timestampMs(time.Now()), nil, &messengertypes.AppMessage_SetGroupInfo{DisplayName: dn, AvatarCID: avatarCID})
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	if _, err := svc.protocolClient.AppMetadataSend(ctx, &protocoltypes.AppMetadataSend_Request{GroupPK: pkb, Payload: am}); err != nil {
+		return nil, errcode.ErrProtocolSend.Wrap(err)
+	}
+
+	return &messengertypes.ConversationUpdate_Reply{}, nil
+}
+
+func (svc *service) ConversationSetMessageRetention(ctx context.Context, req *messengertypes.ConversationSetMessageRetention_Request) (*messengertypes.ConversationSetMessageRetention_Reply, error) {
+	pk := req.GetConversationPublicKey()
+	if pk == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	if req.GetRetention() < 0 {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("retention must be positive"))
+	}
+
+	pkb, err := b64DecodeBytes(pk)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	conv, err := svc.db.getConversationByPK(pk)
+	if err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	switch conv.GetType() {
+	case messengertypes.Conversation_ContactType, messengertypes.Conversation_MultiMemberType:
+	default:
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("message retention can only be set on contact and multi-member conversations"))
+	}
+
+	if req.GetRetention() == conv.GetMessageRetention() {
+		svc.logger.Debug("ConversationSetMessageRetention: nothing to do")
+		return &messengertypes.ConversationSetMessageRetention_Reply{}, nil
+	}
+
+	am, err := messengertypes.AppMessage_TypeSetMessageRetention.MarshalPayload(timestampMs(time.Now()), nil, &messengertypes.AppMessage_SetMessageRetention{Retention: req.GetRetention()})
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	if _, err := svc.protocolClient.AppMetadataSend(ctx, &protocoltypes.AppMetadataSend_Request{GroupPK: pkb, Payload: am}); err != nil {
+		return nil, errcode.ErrProtocolSend.Wrap(err)
+	}
+
+	return &messengertypes.ConversationSetMessageRetention_Reply{}, nil
+}
+
+func (svc *service) PinnedMessageSet(ctx context.Context, req *messengertypes.PinnedMessageSet_Request) (*messengertypes.PinnedMessageSet_Reply, error) {
+	pk := req.GetConversationPublicKey()
+	if pk == "" || req.GetCID() == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	pkb, err := b64DecodeBytes(pk)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	conv, err := svc.db.getConversationByPK(pk)
+	if err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	switch conv.GetType() {
+	case messengertypes.Conversation_ContactType, messengertypes.Conversation_MultiMemberType:
+	default:
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("messages can only be pinned in contact and multi-member conversations"))
+	}
+
+	if req.GetPinned() {
+		target, err := svc.db.getInteractionByCID(req.GetCID())
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown interaction: %w", err))
+		}
+
+		if target.GetConversationPublicKey() != pk || target.GetIsDeleted() {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("interaction is not part of the conversation"))
+		}
+	}
+
+	am, err := messengertypes.AppMessage_TypeSetPinnedMessage.MarshalPayload(timestampMs(time.Now()), nil, &messengertypes.AppMessage_SetPinnedMessage{Target: req.GetCID(), Pinned: req.GetPinned()})
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	if _, err := svc.protocolClient.AppMetadataSend(ctx, &protocoltypes.AppMetadataSend_Request{GroupPK: pkb, Payload: am}); err != nil {
+		return nil, errcode.ErrProtocolSend.Wrap(err)
+	}
+
+	return &messengertypes.PinnedMessageSet_Reply{}, nil
+}
+
+func (svc *service) PinnedMessageList(ctx context.Context, req *messengertypes.PinnedMessageList_Request) (*messengertypes.PinnedMessageList_Reply, error) {
+	if req.GetConversationPublicKey() == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	interactions, err := svc.db.getPinnedInteractions(req.GetConversationPublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return &messengertypes.PinnedMessageList_Reply{Interactions: interactions}, nil
+}
+
+func (svc *service) StarredMessageSet(ctx context.Context, req *messengertypes.StarredMessageSet_Request) (*messengertypes.StarredMessageSet_Reply, error) {
+	pk := req.GetConversationPublicKey()
+	if pk == "" || req.GetCID() == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	if req.GetStarred() {
+		target, err := svc.db.getInteractionByCID(req.GetCID())
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown interaction: %w", err))
+		}
+
+		if target.GetConversationPublicKey() != pk || target.GetIsDeleted() {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("interaction is not part of the conversation"))
+		}
+	}
+
+	config, err := svc.protocolClient.InstanceGetConfiguration(ctx, &protocoltypes.InstanceGetConfiguration_Request{})
+	if err != nil {
+		return nil, err
+	}
+
+	am, err := messengertypes.AppMessage_TypeSetStarredMessage.MarshalPayload(timestampMs(time.Now()), nil, &messengertypes.AppMessage_SetStarredMessage{
+		ConversationPublicKey: pk,
+		Target:                req.GetCID(),
+		Starred:               req.GetStarred(),
+	})
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	// sent on the account group so that it is only synchronized with the devices of the account
+	if _, err := svc.protocolClient.AppMetadataSend(ctx, &protocoltypes.AppMetadataSend_Request{GroupPK: config.GetAccountGroupPK(), Payload: am}); err != nil {
+		return nil, errcode.ErrProtocolSend.Wrap(err)
+	}
+
+	return &messengertypes.StarredMessageSet_Reply{}, nil
+}
+
+func (svc *service) StarredMessageList(ctx context.Context, req *messengertypes.StarredMessageList_Request) (*messengertypes.StarredMessageList_Reply, error) {
+	interactions, err := svc.db.getStarredInteractions(req.GetConversationPublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return &messengertypes.StarredMessageList_Reply{Interactions: interactions}, nil
+}
+
 func (svc *service) ConversationJoin(ctx context.Context, req *messengertypes.ConversationJoin_Request) (*messengertypes.ConversationJoin_Reply, error) {
 	url := req.GetLink()
 	if url == "" {
@@ -933,17 +1176,78 @@ func (svc *service) Interact(ctx context.Context, req *messengertypes.Interact_R
 		if err != nil {
 			return nil, errcode.ErrInternal.Wrap(err)
 		}
-		cids := make([][]byte, len(req.GetMediaCids()))
-		for i, mediaCID := range req.GetMediaCids() {
-			cids[i], err = b64DecodeBytes(mediaCID)
-			if err != nil {
-				return nil, errcode.ErrDeserialization.Wrap(err)
+		// unencrypted medias don't have a secret to share, thumbnails are always encrypted
+		var cids [][]byte
+		for _, media := range medias {
+			mediaCIDs := []string{media.GetThumbnailCID()}
+			if !media.GetUnencrypted() {
+				mediaCIDs = append(mediaCIDs, media.GetCID())
+			}
+			for _, mediaCID := range mediaCIDs {
+				if mediaCID == "" {
+					continue
+				}
+				cid, err := b64DecodeBytes(mediaCID)
+				if err != nil {
+					return nil, errcode.ErrDeserialization.Wrap(err)
+				}
+				cids = append(cids, cid)
 			}
 		}
 		_, err = svc.protocolClient.AppMessageSend(ctx, &protocoltypes.AppMessageSend_Request{GroupPK: gpkb, Payload: fp, AttachmentCIDs: cids})
 		if err != nil {
 			return nil, err
 		}
+	case messengertypes.AppMessage_TypeUserReaction:
+		var p messengertypes.AppMessage_UserReaction
+		if err := proto.Unmarshal(req.GetPayload(), &p); err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+		if p.GetTarget() == "" || p.GetEmoji() == "" {
+			return nil, errcode.ErrMissingInput
+		}
+		fp, err := messengertypes.AppMessage_TypeUserReaction.MarshalPayload(timestampMs(time.Now()), nil, &p)
+		if err != nil {
+			return nil, errcode.ErrInternal.Wrap(err)
+		}
+		_, err = svc.protocolClient.AppMessageSend(ctx, &protocoltypes.AppMessageSend_Request{GroupPK: gpkb, Payload: fp})
+		if err != nil {
+			return nil, err
+		}
+	case messengertypes.AppMessage_TypeEditUserMessage, messengertypes.AppMessage_TypeDeleteUserMessage:
+		p, err := (&messengertypes.AppMessage{Type: req.GetType(), Payload: req.GetPayload()}).UnmarshalPayload()
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+		var targetCID string
+		switch payload := p.(type) {
+		case *messengertypes.AppMessage_EditUserMessage:
+			targetCID = payload.GetTarget()
+		case *messengertypes.AppMessage_DeleteUserMessage:
+			targetCID = payload.GetTarget()
+		}
+		target, err := svc.db.getInteractionByCID(targetCID)
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+		isMine, err := checkInteractionIsFromAccount(ctx, svc.protocolClient, svc.db, target)
+		if err != nil {
+			return nil, err
+		}
+		if !isMine || target.GetType() != messengertypes.AppMessage_TypeUserMessage || target.GetIsDeleted() {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("only our own user messages can be edited or deleted"))
+		}
+		if target.GetConversationPublicKey() != gpk {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("target interaction is not part of the conversation"))
+		}
+		fp, err := req.GetType().MarshalPayload(timestampMs(time.Now()), nil, p)
+		if err != nil {
+			return nil, errcode.ErrInternal.Wrap(err)
+		}
+		_, err = svc.protocolClient.AppMessageSend(ctx, &protocoltypes.AppMessageSend_Request{GroupPK: gpkb, Payload: fp})
+		if err != nil {
+			return nil, err
+		}
 	case messengertypes.AppMessage_TypeAcknowledge:
 		// trick gocritic
 	}
@@ -1008,7 +1312,11 @@ func (svc *service) ConversationOpen(ctx context.Context, req *messengertypes.Co
 
 	if err != nil {
 		return nil, err
-	} else if !updated {
+	}
+
+	svc.setConversationDiscoveryFocus(ctx, req.GetGroupPK(), true)
+
+	if !updated {
 		return &ret, nil
 	}
 
@@ -1016,6 +1324,10 @@ func (svc *service) ConversationOpen(ctx context.Context, req *messengertypes.Co
 		return nil, errcode.TODO.Wrap(err)
 	}
 
+	if err := svc.eventHandler.sendReadReceipt(req.GetGroupPK()); err != nil {
+		svc.logger.Error("unable to send read receipt", zap.String("conv", req.GetGroupPK()), zap.Error(err))
+	}
+
 	return &ret, nil
 }
 
@@ -1031,7 +1343,11 @@ func (svc *service) ConversationClose(ctx context.Context, req *messengertypes.C
 
 	if err != nil {
 		return nil, err
-	} else if !updated {
+	}
+
+	svc.setConversationDiscoveryFocus(ctx, req.GetGroupPK(), false)
+
+	if !updated {
 		return &ret, nil
 	}
 
@@ -1043,6 +1359,20 @@ func (svc *service) ConversationClose(ctx context.Context, req *messengertypes.C
 	return &ret, nil
 }
 
+// setConversationDiscoveryFocus asks the protocol to look up the peers of the opened conversations more often, the
+// lookups keep working with the default backoff on failure
+func (svc *service) setConversationDiscoveryFocus(ctx context.Context, groupPK string, focused bool) {
+	pk, err := b64DecodeBytes(groupPK)
+	if err != nil {
+		svc.logger.Warn("unable to decode group pk", zap.String("conv", groupPK), zap.Error(err))
+		return
+	}
+
+	if _, err := svc.protocolClient.GroupDiscoveryFocus(ctx, &protocoltypes.GroupDiscoveryFocus_Request{GroupPK: pk, Focused: focused}); err != nil {
+		svc.logger.Warn("unable to set group discovery focus", zap.String("conv", groupPK), zap.Bool("focused", focused), zap.Error(err))
+	}
+}
+
 func (svc *service) ServicesTokenList(req *protocoltypes.ServicesTokenList_Request, server messengertypes.MessengerService_ServicesTokenListServer) error {
 	cl, err := svc.protocolClient.ServicesTokenList(server.Context(), req)
 	if err != nil {
@@ -1097,6 +1427,71 @@ func (svc *service) ReplicationServiceRegisterGroup(ctx context.Context, req *me
 	return &messengertypes.ReplicationServiceRegisterGroup_Reply{}, nil
 }
 
+func (svc *service) ReplicationServiceUnregisterGroup(ctx context.Context, req *messengertypes.ReplicationServiceUnregisterGroup_Request) (*messengertypes.ReplicationServiceUnregisterGroup_Reply, error) {
+	gpk := req.GetConversationPublicKey()
+	if gpk == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	gpkb, err := b64DecodeBytes(gpk)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	if _, err := svc.protocolClient.ReplicationServiceUnregisterGroup(ctx, &protocoltypes.ReplicationServiceUnregisterGroup_Request{
+		TokenID: req.TokenID,
+		GroupPK: gpkb,
+	}); err != nil {
+		svc.logger.Error("failed to stop replicating group", zap.String("public-key", gpk), zap.String("token-id", req.TokenID), zap.Error(err))
+		return nil, err
+	}
+
+	svc.logger.Info("group not replicated anymore", zap.String("public-key", gpk), zap.String("token-id", req.TokenID))
+
+	return &messengertypes.ReplicationServiceUnregisterGroup_Reply{}, nil
+}
+
+func (svc *service) ReplicationServiceGroupStatus(ctx context.Context, req *messengertypes.ReplicationServiceGroupStatus_Request) (*messengertypes.ReplicationServiceGroupStatus_Reply, error) {
+	gpk := req.GetConversationPublicKey()
+	if gpk == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	gpkb, err := b64DecodeBytes(gpk)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	reply, err := svc.protocolClient.ReplicationServiceGroupStatus(ctx, &protocoltypes.ReplicationServiceGroupStatus_Request{
+		TokenID: req.TokenID,
+		GroupPK: gpkb,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	updated, err := svc.db.updateConversationReplicationStatus(gpk, reply.GetReplicationServer(), reply.GetStatus(), timestampMs(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	if updated > 0 {
+		conv, err := svc.db.getConversationByPK(gpk)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeConversationUpdated, &messengertypes.StreamEvent_ConversationUpdated{Conversation: conv}, false); err != nil {
+			svc.logger.Error("unable to dispatch notification for conversation", zap.String("conversation-pk", gpk), zap.Error(err))
+		}
+	}
+
+	return &messengertypes.ReplicationServiceGroupStatus_Reply{Status: reply.GetStatus()}, nil
+}
+
 func (svc *service) BannerQuote(ctx context.Context, req *messengertypes.BannerQuote_Request) (*messengertypes.BannerQuote_Reply, error) {
 	var quote banner.Quote
 	if req != nil && req.Random {
@@ -1157,6 +1552,55 @@ func (svc *service) ReplicationSetAutoEnable(ctx context.Context, req *messenger
 	return &messengertypes.ReplicationSetAutoEnable_Reply{}, nil
 }
 
+func (svc *service) ReadReceiptsSetEnable(ctx context.Context, req *messengertypes.ReadReceiptsSetEnable_Request) (*messengertypes.ReadReceiptsSetEnable_Reply, error) {
+	config, err := svc.protocolClient.InstanceGetConfiguration(svc.ctx, &protocoltypes.InstanceGetConfiguration_Request{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.db.accountSetReadReceiptsEnable(b64EncodeBytes(config.AccountPK), req.Enabled); err != nil {
+		return nil, err
+	}
+
+	acc, err := svc.db.getAccount()
+	if err != nil {
+		return nil, err
+	}
+
+	// dispatch event
+	if err := svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeAccountUpdated, &messengertypes.StreamEvent_AccountUpdated{Account: acc}, false); err != nil {
+		return nil, errcode.TODO.Wrap(err)
+	}
+
+	return &messengertypes.ReadReceiptsSetEnable_Reply{}, nil
+}
+
+func (svc *service) ThreadReplyList(ctx context.Context, req *messengertypes.ThreadReplyList_Request) (*messengertypes.ThreadReplyList_Reply, error) {
+	if req.GetConversationPublicKey() == "" || req.GetThreadRootCID() == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	replies, err := svc.db.getThreadReplies(req.GetConversationPublicKey(), req.GetThreadRootCID())
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &messengertypes.ThreadReplyList_Reply{Replies: replies}
+
+	root, err := svc.db.getInteractionByCID(req.GetThreadRootCID())
+	switch {
+	case err == gorm.ErrRecordNotFound:
+	case err != nil:
+		return nil, errcode.ErrDBRead.Wrap(err)
+	case root.GetConversationPublicKey() != req.GetConversationPublicKey():
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("thread root is not part of the conversation"))
+	case !root.GetIsDeleted():
+		ret.Root = root
+	}
+
+	return ret, nil
+}
+
 func (svc *service) InstanceExportData(_ *messengertypes.InstanceExportData_Request, server messengertypes.MessengerService_InstanceExportDataServer) error {
 	tmpFile, err := ioutil.TempFile(os.TempDir(), "export-")
 	if err != nil {
@@ -1183,26 +1627,37 @@ func (svc *service) InstanceExportData(_ *messengertypes.InstanceExportData_Requ
 		}
 	}
 
-	// Remove trailing headers to append messenger data
-	_, err = tmpFile.Seek(-1024, io.SeekEnd)
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	exportFile, err := ioutil.TempFile(os.TempDir(), "export-")
 	if err != nil {
 		return errcode.ErrInternal.Wrap(err)
 	}
 
-	svc.handlerMutex.Lock()
-	defer svc.handlerMutex.Unlock()
+	defer os.Remove(exportFile.Name())
 
-	if err := exportMessengerData(tmpFile, svc.db.db, svc.logger); err != nil {
+	if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
 		return errcode.ErrInternal.Wrap(err)
 	}
 
-	if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
+	// the trailer of the protocol export isn't copied to append messenger data, the expired messages are dropped when
+	// the logs are replayed on import
+	if err := bertyprotocol.CopyAccountExport(tmpFile, tar.NewWriter(exportFile)); err != nil {
+		return errcode.ErrInternal.Wrap(err)
+	}
+
+	if err := exportMessengerData(exportFile, svc.db.db, svc.logger); err != nil {
+		return errcode.ErrInternal.Wrap(err)
+	}
+
+	if _, err = exportFile.Seek(0, io.SeekStart); err != nil {
 		return errcode.ErrInternal.Wrap(err)
 	}
 
 	buffer := make([]byte, 1024)
 	for {
-		_, err := tmpFile.Read(buffer)
+		_, err := exportFile.Read(buffer)
 		if err == io.EOF {
 			return nil
 		} else if err != nil {
@@ -1250,18 +1705,25 @@ func (svc *service) MediaPrepare(srv messengertypes.MessengerService_MediaPrepar
 	defer file.Close()
 
 	// upload media and get cid in return
-	cidBytes, err := svc.attachmentPrepare(file)
+	recorder := &mediaMetadataRecorder{}
+	cidBytes, err := svc.attachmentPrepare(io.TeeReader(file, recorder), header.GetInfo().GetUnencrypted())
 	if err != nil {
 		return errcode.ErrAttachmentPrepare.Wrap(err)
 	}
 	cid := b64EncodeBytes(cidBytes)
 
+	// extract metadata and upload thumbnail
+	media := *header.Info
+	media.LastAccessDate = timestampMs(time.Now())
+	if err := svc.prepareMediaThumbnail(&media, recorder); err != nil {
+		svc.logger.Warn("unable to prepare media thumbnail", zap.String("cid", cid), zap.Error(err))
+	}
+
 	svc.handlerMutex.Lock()
 	defer svc.handlerMutex.Unlock()
 
 	return svc.db.tx(func(tx *dbWrapper) error {
 		// add to db
-		media := *header.Info
 		media.CID = cid
 		media.State = messengertypes.Media_StatePrepared
 		added, err := tx.addMedias([]*messengertypes.Media{&media})
@@ -1287,30 +1749,57 @@ func (svc *service) MediaPrepare(srv messengertypes.MessengerService_MediaPrepar
 }
 
 func (svc *service) MediaRetrieve(req *messengertypes.MediaRetrieve_Request, srv messengertypes.MessengerService_MediaRetrieveServer) error {
-	var attachment *io.PipeReader
+	var (
+		attachment  *io.PipeReader
+		isThumbnail bool
+	)
 	if err := func() error {
 		svc.handlerMutex.Lock()
 		defer svc.handlerMutex.Unlock()
 
-		// prepare header
-		medias, err := svc.db.getMedias([]string{req.GetCid()})
+		// prepare header, thumbnails don't have their own media and are described using the media they were generated for
+		media, err := svc.db.getThumbnailMedia(req.GetCid())
 		if err != nil {
 			return errcode.ErrInvalidInput.Wrap(err)
 		}
-		if len(medias) == 0 {
-			return errcode.ErrInternal.Wrap(err)
+		if isThumbnail = media != nil; !isThumbnail {
+			medias, err := svc.db.getMedias([]string{req.GetCid()})
+			if err != nil {
+				return errcode.ErrInvalidInput.Wrap(err)
+			}
+			if len(medias) == 0 {
+				return errcode.ErrInternal.Wrap(err)
+			}
+			media = medias[0]
+		}
+
+		if !isThumbnail {
+			if err := svc.checkMediaStorageQuota(media); err != nil {
+				return err
+			}
 		}
-		media := medias[0]
 
 		// send header
 		if err := srv.Send(&messengertypes.MediaRetrieve_Reply{Info: media}); err != nil {
 			return errcode.ErrStreamHeaderWrite.Wrap(err)
 		}
 
+		// recently retrieved medias are evicted last
+		if !isThumbnail {
+			if err := svc.db.markMediaAsAccessed(req.GetCid(), timestampMs(time.Now())); err != nil {
+				svc.logger.Warn("unable to update media access date", zap.String("cid", req.GetCid()), zap.Error(err))
+			}
+		}
+
 		// open download
-		if attachment, err = svc.attachmentRetrieve(req.GetCid()); err != nil {
+		if attachment, err = svc.attachmentRetrieve(req.GetCid(), media.GetUnencrypted(), req.GetOffset(), req.GetLength()); err != nil {
 			return errcode.ErrAttachmentRetrieve.Wrap(err)
 		}
+
+		// the blocks of the media must not be evicted while they are being retrieved
+		if !isThumbnail {
+			svc.startMediaRetrieval(req.GetCid())
+		}
 		return nil
 	}(); err != nil {
 		return err
@@ -1318,12 +1807,94 @@ func (svc *service) MediaRetrieve(req *messengertypes.MediaRetrieve_Request, srv
 	defer attachment.Close()
 
 	// stream to client
-	if err := streamutil.FuncSink(make([]byte, 64*1024), attachment, func(b []byte) error {
-		return srv.Send(&messengertypes.MediaRetrieve_Reply{Block: b})
-	}); err != nil {
+	sent := uint64(0)
+	err := streamutil.FuncSink(make([]byte, 64*1024), attachment, func(b []byte) error {
+		if err := srv.Send(&messengertypes.MediaRetrieve_Reply{Block: b}); err != nil {
+			return err
+		}
+		sent += uint64(len(b))
+		return nil
+	})
+
+	// persist progress so an interrupted download can be resumed, the end of the file has been reached when less than the
+	// requested length has been sent
+	if !isThumbnail {
+		complete := err == nil && (req.GetLength() == 0 || sent < req.GetLength())
+		svc.updateMediaDownloadProgress(req.GetCid(), req.GetOffset(), sent, complete)
+	}
+
+	if err != nil {
 		return errcode.ErrStreamSink.Wrap(err)
 	}
 
 	// success
 	return nil
 }
+
+// updateMediaDownloadProgress ends the retrieval of a media and records its progress, the quota is then enforced
+func (svc *service) updateMediaDownloadProgress(cid string, offset uint64, retrievedSize uint64, complete bool) {
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	svc.endMediaRetrieval(cid)
+
+	media, updated, err := svc.db.updateMediaDownloadProgress(cid, offset, retrievedSize, complete)
+	if err != nil {
+		svc.logger.Error("unable to update media download progress", zap.String("cid", cid), zap.Error(err))
+		return
+	}
+
+	if updated {
+		if err := svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeMediaUpdated, &messengertypes.StreamEvent_MediaUpdated{Media: media}, false); err != nil {
+			svc.logger.Error("unable to dispatch notification for media", zap.String("cid", cid), zap.Error(err))
+		}
+
+		if err := svc.enforceMediaStorageQuota(svc.ctx); err != nil {
+			svc.logger.Error("unable to enforce media storage quota", zap.Error(err))
+		}
+	}
+}
+
+func (svc *service) MessageSearch(ctx context.Context, req *messengertypes.MessageSearch_Request) (*messengertypes.MessageSearch_Reply, error) {
+	if strings.TrimSpace(req.GetQuery()) == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	results, nextRefCID, err := svc.db.searchInteractions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &messengertypes.MessageSearch_Reply{Results: results, NextRefCID: nextRefCID}, nil
+}
+
+const interactionListDefaultPageSize = 50
+
+func (svc *service) InteractionList(ctx context.Context, req *messengertypes.InteractionList_Request) (*messengertypes.InteractionList_Reply, error) {
+	if req.GetConversationPublicKey() == "" {
+		return nil, errcode.ErrMissingInput
+	}
+
+	pageSize := int(req.GetPageSize())
+	if pageSize < 0 {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("page size cannot be negative"))
+	} else if pageSize == 0 {
+		pageSize = interactionListDefaultPageSize
+	}
+
+	interactions, hasMore, err := svc.db.getPaginatedInteractions(req.GetConversationPublicKey(), req.GetRefCID(), req.GetRefDate(), req.GetAfter(), pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &messengertypes.InteractionList_Reply{Interactions: interactions}
+	if hasMore {
+		if req.GetAfter() {
+			rep.NextRefCID = interactions[len(interactions)-1].GetCID()
+		} else {
+			rep.NextRefCID = interactions[0].GetCID()
+		}
+	}
+
+	return rep, nil
+}
@@ -214,14 +214,35 @@ func TestServiceShareableBertyGroup(t *testing.T) {
 	})
 	defer cleanup()
 
-	g, _, err := bertyprotocol.NewGroupMultiMember()
+	created, err := protocol.Client.MultiMemberGroupCreate(ctx, &protocoltypes.MultiMemberGroupCreate_Request{})
+	require.NoError(t, err)
+
+	_, err = protocol.Client.ActivateGroup(ctx, &protocoltypes.ActivateGroup_Request{GroupPK: created.GroupPK})
+	require.NoError(t, err)
+
+	info, err := protocol.Client.GroupInfo(ctx, &protocoltypes.GroupInfo_Request{GroupPK: created.GroupPK})
+	require.NoError(t, err)
+
+	g := info.Group
+
+	// a member who is not an admin can't share the group
+	joined, _, err := bertyprotocol.NewGroupMultiMember()
 	require.NoError(t, err)
 
 	_, err = protocol.Client.MultiMemberGroupJoin(ctx, &protocoltypes.MultiMemberGroupJoin_Request{
-		Group: g,
+		Group: joined,
 	})
 	require.NoError(t, err)
 
+	_, err = protocol.Client.ActivateGroup(ctx, &protocoltypes.ActivateGroup_Request{GroupPK: joined.PublicKey})
+	require.NoError(t, err)
+
+	_, err = svc.ShareableBertyGroup(ctx, &messengertypes.ShareableBertyGroup_Request{
+		GroupPK:   joined.PublicKey,
+		GroupName: "",
+	})
+	require.True(t, errcode.Has(err, errcode.ErrGroupMemberNotAdmin))
+
 	ret1, err := svc.ShareableBertyGroup(ctx, nil)
 	require.Error(t, err)
 
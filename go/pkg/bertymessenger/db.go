@@ -21,6 +21,9 @@ import (
 type dbWrapper struct {
 	db  *gorm.DB
 	log *zap.Logger
+
+	// searchIndex is set when the interactions search index is available, see initSearchIndex
+	searchIndex bool
 }
 
 func newDBWrapper(db *gorm.DB, log *zap.Logger) *dbWrapper {
@@ -49,6 +52,13 @@ func getDBModels() []interface{} {
 		&messengertypes.Device{},
 		&messengertypes.ConversationReplicationInfo{},
 		&messengertypes.Media{},
+		&messengertypes.MediaReference{},
+		&messengertypes.Reaction{},
+		&messengertypes.UserMessageEdit{},
+		&messengertypes.ReadReceipt{},
+		&messengertypes.PinnedMessage{},
+		&messengertypes.StarredMessage{},
+		&messengertypes.AdminRoleBacklog{},
 	}
 }
 
@@ -57,6 +67,10 @@ func (d *dbWrapper) initDB(replayer func(d *dbWrapper) error) error {
 		return err
 	}
 
+	if err := d.initSearchIndex(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -103,7 +117,7 @@ func isSQLiteError(err error, sqliteErr sqlite3.ErrNo) bool {
 func (d *dbWrapper) tx(txFunc func(*dbWrapper) error) error {
 	// Use this to propagate scope, ie. opened account
 	return d.db.Transaction(func(tx *gorm.DB) error {
-		return txFunc(&dbWrapper{db: tx})
+		return txFunc(&dbWrapper{db: tx, searchIndex: d.searchIndex})
 	})
 }
 
@@ -224,6 +238,24 @@ func (d *dbWrapper) updateConversation(c messengertypes.Conversation) (bool, err
 	return isNew, nil
 }
 
+// updateConversationInfo applies the given group info if it is more recent than the current one, the updates sent at the same date
+// are ordered by their cid so every replica keeps the same one
+func (d *dbWrapper) updateConversationInfo(pk, displayName, avatarCID string, infoDate int64, infoCID string) (bool, error) {
+	if pk == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	res := d.db.
+		Model(&messengertypes.Conversation{}).
+		Where("public_key = ? AND (info_date < ? OR (info_date = ? AND COALESCE(info_cid, '') < ?))", pk, infoDate, infoDate, infoCID).
+		Updates(&messengertypes.Conversation{DisplayName: displayName, AvatarCID: avatarCID, InfoDate: infoDate, InfoCID: infoCID})
+	if res.Error != nil {
+		return false, errcode.ErrDBWrite.Wrap(res.Error)
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
 func (d *dbWrapper) updateConversationReadState(pk string, newUnread bool, eventDate time.Time) error {
 	if pk == "" {
 		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
@@ -300,6 +332,12 @@ func (d *dbWrapper) updateAccount(pk, url, displayName, avatarCID string) (*mess
 		return nil, tx.Error
 	}
 
+	if displayName != "" {
+		if err := d.indexInteractionsForSearch("is_me = ?", true); err != nil {
+			return nil, err
+		}
+	}
+
 	return acc, nil
 }
 
@@ -355,6 +393,9 @@ func (d *dbWrapper) getConversationByPK(publicKey string) (*messengertypes.Conve
 	if err := d.db.
 		Preload("ReplyOptions").
 		Preload("ReplicationInfo").
+		Preload("ReadReceipts").
+		Preload("PinnedMessages", "pinned = ?", true).
+		Preload("StarredMessages", "starred = ?", true).
 		First(
 			&conversation,
 			&messengertypes.Conversation{PublicKey: publicKey},
@@ -383,10 +424,94 @@ func (d *dbWrapper) getMemberByPK(publicKey string, convPK string) (*messengerty
 	return member, nil
 }
 
+func (d *dbWrapper) isMemberAdmin(publicKey string, convPK string) (bool, error) {
+	member, err := d.getMemberByPK(publicKey, convPK)
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		return false, nil
+	case err != nil:
+		return false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return member.GetIsCreator() || member.GetIsAdmin(), nil
+}
+
+func (d *dbWrapper) setMemberAdminRole(publicKey string, convPK string, isAdmin bool) (bool, error) {
+	if publicKey == "" || convPK == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a member public key and a conversation public key are required"))
+	}
+
+	res := d.db.Model(&messengertypes.Member{}).
+		Where("public_key = ? AND conversation_public_key = ? AND is_admin != ?", publicKey, convPK, isAdmin).
+		Update("is_admin", isAdmin)
+	if res.Error != nil {
+		return false, errcode.ErrDBWrite.Wrap(res.Error)
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+// addAdminRoleBacklog stores an admin role event until its sender device is known
+func (d *dbWrapper) addAdminRoleBacklog(b messengertypes.AdminRoleBacklog) error {
+	if b.CID == "" || b.ConversationPublicKey == "" || b.DevicePublicKey == "" || b.MemberPublicKey == "" {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("a cid, a conversation, a device and a member public key are required"))
+	}
+
+	if err := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&b).Error; err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return nil
+}
+
+// popAdminRoleBacklog removes and returns the admin role events sent by a device in a conversation, in the order they were
+// received
+func (d *dbWrapper) popAdminRoleBacklog(devicePK, convPK string) ([]*messengertypes.AdminRoleBacklog, error) {
+	if devicePK == "" || convPK == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a device and a conversation public key are required"))
+	}
+
+	backlog := []*messengertypes.AdminRoleBacklog(nil)
+
+	if err := d.tx(func(tx *dbWrapper) error {
+		if err := tx.db.
+			Where("device_public_key = ? AND conversation_public_key = ?", devicePK, convPK).
+			Order("ROWID asc").
+			Find(&backlog).
+			Error; err != nil {
+			return errcode.ErrDBRead.Wrap(err)
+		}
+
+		if len(backlog) == 0 {
+			return nil
+		}
+
+		if err := tx.db.
+			Where("device_public_key = ? AND conversation_public_key = ?", devicePK, convPK).
+			Delete(&messengertypes.AdminRoleBacklog{}).
+			Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return backlog, nil
+}
+
 func (d *dbWrapper) getAllConversations() ([]*messengertypes.Conversation, error) {
 	convs := []*messengertypes.Conversation(nil)
 
-	return convs, d.db.Preload("ReplyOptions").Preload("ReplicationInfo").Find(&convs).Error
+	return convs, d.db.
+		Preload("ReplyOptions").
+		Preload("ReplicationInfo").
+		Preload("ReadReceipts").
+		Preload("PinnedMessages", "pinned = ?", true).
+		Preload("StarredMessages", "starred = ?", true).
+		Find(&convs).
+		Error
 }
 
 func (d *dbWrapper) getAllMembers() ([]*messengertypes.Member, error) {
@@ -410,7 +535,28 @@ func (d *dbWrapper) getContactsByState(state messengertypes.Contact_State) ([]*m
 func (d *dbWrapper) getAllInteractions() ([]*messengertypes.Interaction, error) {
 	interactions := []*messengertypes.Interaction(nil)
 
-	return interactions, d.db.Preload(clause.Associations).Find(&interactions).Error
+	if err := d.db.Preload(clause.Associations).Where("is_deleted = ?", false).Find(&interactions).Error; err != nil {
+		return nil, err
+	}
+
+	reactions, err := d.getReactionsViews()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inte := range interactions {
+		inte.Reactions = reactions[inte.CID]
+	}
+
+	if err := d.attachReadBy(interactions); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReplyTo(interactions); err != nil {
+		return nil, err
+	}
+
+	return interactions, nil
 }
 
 func (d *dbWrapper) getInteractionByCID(cid string) (*messengertypes.Interaction, error) {
@@ -419,7 +565,160 @@ func (d *dbWrapper) getInteractionByCID(cid string) (*messengertypes.Interaction
 	}
 
 	interaction := &messengertypes.Interaction{}
-	return interaction, d.db.Preload(clause.Associations).First(&interaction, &messengertypes.Interaction{CID: cid}).Error
+	if err := d.db.Preload(clause.Associations).First(&interaction, &messengertypes.Interaction{CID: cid}).Error; err != nil {
+		return interaction, err
+	}
+
+	reactions, err := d.getReactionsViews(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction.Reactions = reactions[cid]
+
+	if err := d.attachReadBy([]*messengertypes.Interaction{interaction}); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReplyTo([]*messengertypes.Interaction{interaction}); err != nil {
+		return nil, err
+	}
+
+	return interaction, nil
+}
+
+// getPaginatedInteractions returns up to count interactions of a conversation sent before (or after) the given reference,
+// sorted from the oldest to the most recent, along with whether more interactions are available in that direction
+func (d *dbWrapper) getPaginatedInteractions(convPK, refCID string, refDate int64, after bool, count int) ([]*messengertypes.Interaction, bool, error) {
+	if convPK == "" {
+		return nil, false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	if count <= 0 {
+		return nil, false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a positive count is required"))
+	}
+
+	cmp, order := "<", "DESC"
+	if after {
+		cmp, order = ">", "ASC"
+	}
+
+	query := d.db.Preload(clause.Associations).Where("conversation_public_key = ? AND is_deleted = ?", convPK, false)
+
+	if refCID != "" {
+		ref := &messengertypes.Interaction{}
+		if err := d.db.Select("cid, sent_date").First(&ref, &messengertypes.Interaction{CID: refCID, ConversationPublicKey: convPK}).Error; err != nil {
+			return nil, false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown ref cid: %w", err))
+		}
+
+		query = query.Where(
+			fmt.Sprintf("(sent_date %s ? OR (sent_date = ? AND cid %s ?))", cmp, cmp),
+			ref.SentDate, ref.SentDate, ref.CID,
+		)
+	} else if refDate != 0 {
+		query = query.Where(fmt.Sprintf("sent_date %s ?", cmp), refDate)
+	}
+
+	interactions := []*messengertypes.Interaction(nil)
+	if err := query.
+		Order(fmt.Sprintf("sent_date %s, cid %s", order, order)).
+		Limit(count + 1).
+		Find(&interactions).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(interactions) > count
+	if hasMore {
+		interactions = interactions[:count]
+	}
+
+	if !after {
+		for i, j := 0, len(interactions)-1; i < j; i, j = i+1, j-1 {
+			interactions[i], interactions[j] = interactions[j], interactions[i]
+		}
+	}
+
+	if err := d.attachReactionsViews(interactions); err != nil {
+		return nil, false, err
+	}
+
+	if err := d.attachReadBy(interactions); err != nil {
+		return nil, false, err
+	}
+
+	if err := d.attachReplyTo(interactions); err != nil {
+		return nil, false, err
+	}
+
+	return interactions, hasMore, nil
+}
+
+// visibleInteractionTypes are the types of the interactions displayed in the conversations, the other stored interactions are
+// waiting in the backlog for their target or their member
+var visibleInteractionTypes = []messengertypes.AppMessage_Type{
+	messengertypes.AppMessage_TypeUserMessage,
+	messengertypes.AppMessage_TypeGroupInvitation,
+	messengertypes.AppMessage_TypeReplyOptions,
+}
+
+// getLatestInteractionsPerConversation returns the count most recent visible interactions of every conversation,
+// sorted from the oldest to the most recent
+func (d *dbWrapper) getLatestInteractionsPerConversation(count int) ([]*messengertypes.Interaction, error) {
+	interactions := []*messengertypes.Interaction(nil)
+
+	if count <= 0 {
+		return interactions, nil
+	}
+
+	if err := d.db.
+		Preload(clause.Associations).
+		Where(`cid IN (
+			SELECT cid FROM (
+				SELECT cid, ROW_NUMBER() OVER (PARTITION BY conversation_public_key ORDER BY sent_date DESC, cid DESC) AS position
+				FROM interactions
+				WHERE is_deleted = ? AND type IN ?
+			) WHERE position <= ?
+		)`, false, visibleInteractionTypes, count).
+		Order("sent_date ASC, cid ASC").
+		Find(&interactions).Error; err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReactionsViews(interactions); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReadBy(interactions); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReplyTo(interactions); err != nil {
+		return nil, err
+	}
+
+	return interactions, nil
+}
+
+func (d *dbWrapper) attachReactionsViews(interactions []*messengertypes.Interaction) error {
+	if len(interactions) == 0 {
+		return nil
+	}
+
+	cids := make([]string, len(interactions))
+	for i, inte := range interactions {
+		cids[i] = inte.CID
+	}
+
+	reactions, err := d.getReactionsViews(cids...)
+	if err != nil {
+		return err
+	}
+
+	for _, inte := range interactions {
+		inte.Reactions = reactions[inte.CID]
+	}
+
+	return nil
 }
 
 func (d *dbWrapper) addContactRequestOutgoingEnqueued(contactPK, displayName, convPK string) (*messengertypes.Contact, error) {
@@ -587,92 +886,379 @@ func (d *dbWrapper) getAcknowledgementsCIDsForInteraction(cid string) ([]string,
 	return cids, nil
 }
 
-func (d *dbWrapper) deleteInteractions(cids []string) error {
-	if len(cids) == 0 {
-		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("a list of cids is required"))
+func (d *dbWrapper) getUserMessageChangesForInteraction(cid string) ([]*messengertypes.Interaction, error) {
+	if cid == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("an interaction cid is required"))
 	}
 
-	return d.db.Model(&messengertypes.Interaction{}).Delete(&messengertypes.Interaction{}, &cids).Error
+	changes := []*messengertypes.Interaction(nil)
+
+	if err := d.db.
+		Where("target_cid = ? AND type IN ?", cid, []messengertypes.AppMessage_Type{messengertypes.AppMessage_TypeEditUserMessage, messengertypes.AppMessage_TypeDeleteUserMessage}).
+		Order("sent_date asc").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+
+	return changes, nil
 }
 
-func (d *dbWrapper) getDBInfo() (*messengertypes.SystemInfo_DB, error) {
-	var err, errs error
-	infos := &messengertypes.SystemInfo_DB{}
+// addUserMessageEdit stores a new version of a user message and applies it if it is the most recent one,
+// it returns true if the interaction has been modified
+func (d *dbWrapper) addUserMessageEdit(targetCID, editCID string, payload []byte, sentDate int64) (bool, error) {
+	if targetCID == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a target cid is required"))
+	}
 
-	infos.Accounts, err = d.dbModelRowsCount(messengertypes.Account{})
-	errs = multierr.Append(errs, err)
+	if editCID == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("an edit cid is required"))
+	}
 
-	infos.Contacts, err = d.dbModelRowsCount(messengertypes.Contact{})
-	errs = multierr.Append(errs, err)
+	updated := false
 
-	infos.Interactions, err = d.dbModelRowsCount(messengertypes.Interaction{})
-	errs = multierr.Append(errs, err)
+	if err := d.tx(func(tx *dbWrapper) error {
+		target := &messengertypes.Interaction{}
+		if err := tx.db.First(&target, &messengertypes.Interaction{CID: targetCID}).Error; err != nil {
+			return err
+		}
 
-	infos.Conversations, err = d.dbModelRowsCount(messengertypes.Conversation{})
-	errs = multierr.Append(errs, err)
+		if target.IsDeleted {
+			return nil
+		}
 
-	infos.Members, err = d.dbModelRowsCount(messengertypes.Member{})
-	errs = multierr.Append(errs, err)
+		// keep the original version in the history
+		if err := tx.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&messengertypes.UserMessageEdit{
+			CID:       target.CID,
+			TargetCID: target.CID,
+			Payload:   target.Payload,
+			SentDate:  target.SentDate,
+		}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
 
-	infos.Devices, err = d.dbModelRowsCount(messengertypes.Device{})
-	errs = multierr.Append(errs, err)
+		if err := tx.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&messengertypes.UserMessageEdit{
+			CID:       editCID,
+			TargetCID: targetCID,
+			Payload:   payload,
+			SentDate:  sentDate,
+		}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
 
-	infos.ServiceTokens, err = d.dbModelRowsCount(messengertypes.ServiceToken{})
-	errs = multierr.Append(errs, err)
+		if sentDate <= target.EditedDate {
+			return nil
+		}
 
-	infos.ConversationReplicationInfo, err = d.dbModelRowsCount(messengertypes.ConversationReplicationInfo{})
-	errs = multierr.Append(errs, err)
+		if err := tx.db.Model(&messengertypes.Interaction{}).Where("cid = ?", targetCID).Updates(map[string]interface{}{
+			"payload":     payload,
+			"edited_date": sentDate,
+		}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
 
-	return infos, errs
+		if err := tx.indexInteractionsForSearch("cid = ?", targetCID); err != nil {
+			return err
+		}
+
+		updated = true
+
+		return nil
+	}); err != nil {
+		return false, err
+	}
+
+	return updated, nil
 }
 
-func (d *dbWrapper) addDevice(devicePK string, memberPK string) (*messengertypes.Device, error) {
-	if devicePK == "" {
-		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a device public key is required"))
+func (d *dbWrapper) getUserMessageEdits(targetCID string) ([]*messengertypes.UserMessageEdit, error) {
+	if targetCID == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a target cid is required"))
 	}
 
-	if memberPK == "" {
-		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a member public key is required"))
+	edits := []*messengertypes.UserMessageEdit(nil)
+
+	return edits, d.db.Where("target_cid = ?", targetCID).Order("sent_date asc").Find(&edits).Error
+}
+
+// markInteractionAsDeleted replaces the interaction by a tombstone and drops its edit history,
+// it returns true if the interaction was not already deleted
+func (d *dbWrapper) markInteractionAsDeleted(cid string) (bool, error) {
+	if cid == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("an interaction cid is required"))
 	}
 
+	deleted := false
+
 	if err := d.tx(func(tx *dbWrapper) error {
-		// Check if this device already exists for another member
-		{
-			count := int64(0)
+		res := tx.db.Model(&messengertypes.Interaction{}).Where("cid = ? AND is_deleted = ?", cid, false).Updates(map[string]interface{}{
+			"payload":    nil,
+			"is_deleted": true,
+		})
+		if res.Error != nil {
+			return errcode.ErrDBWrite.Wrap(res.Error)
+		}
 
-			if err := tx.db.
-				Model(&messengertypes.Device{}).
-				Where("member_public_key != ? AND public_key == ?", memberPK, devicePK).
-				Count(&count).
-				Error; err != nil {
-				return err
-			}
+		if res.RowsAffected == 0 {
+			return nil
+		}
 
-			if count > 0 {
-				return errcode.ErrDBWrite.Wrap(fmt.Errorf("the device already exists for another member"))
-			}
+		deleted = true
+
+		if err := tx.db.Where("target_cid = ?", cid).Delete(&messengertypes.UserMessageEdit{}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
 		}
 
-		return tx.db.
-			Clauses(clause.OnConflict{DoNothing: true}).
-			Create(&messengertypes.Device{
-				PublicKey:       devicePK,
-				MemberPublicKey: memberPK,
-			}).
-			Error
+		return tx.unindexInteractionsForSearch([]string{cid})
 	}); err != nil {
-		return nil, err
+		return false, err
 	}
 
-	return d.getDeviceByPK(devicePK)
+	return deleted, nil
 }
 
-func (d *dbWrapper) updateContact(pk string, contact messengertypes.Contact) error {
-	if pk == "" {
-		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("no public key specified"))
+func (d *dbWrapper) deleteInteractions(cids []string) error {
+	if len(cids) == 0 {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("a list of cids is required"))
 	}
 
-	count := int64(0)
+	if err := d.unindexInteractionsForSearch(cids); err != nil {
+		return err
+	}
+
+	return d.db.Model(&messengertypes.Interaction{}).Delete(&messengertypes.Interaction{}, &cids).Error
+}
+
+// updateConversationMessageRetention sets the retention (in seconds) of a conversation's interactions,
+// it returns true if the value has been updated, older settings are ignored
+func (d *dbWrapper) updateConversationMessageRetention(pk string, retention int64, retentionDate int64) (bool, error) {
+	if pk == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	if retention < 0 {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("retention must be positive"))
+	}
+
+	res := d.db.
+		Model(&messengertypes.Conversation{}).
+		Where("public_key = ? AND message_retention_date <= ?", pk, retentionDate).
+		Updates(map[string]interface{}{
+			"message_retention":      retention,
+			"message_retention_date": retentionDate,
+		})
+	if res.Error != nil {
+		return false, errcode.ErrDBWrite.Wrap(res.Error)
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+// getExpiredInteractionsCIDs returns at most `limit` cids of interactions older than the retention of their conversation
+func (d *dbWrapper) getExpiredInteractionsCIDs(now time.Time, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("limit must be positive"))
+	}
+
+	cids := []string(nil)
+
+	if err := d.db.
+		Model(&messengertypes.Interaction{}).
+		Joins("JOIN conversations ON conversations.public_key = interactions.conversation_public_key").
+		Where("conversations.message_retention > 0 AND interactions.sent_date + conversations.message_retention * 1000 <= ?", timestampMs(now)).
+		Order("interactions.sent_date ASC").
+		Limit(limit).
+		Pluck("interactions.cid", &cids).
+		Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return cids, nil
+}
+
+// getConversationsWithMessageRetention returns the conversations whose interactions expire
+func (d *dbWrapper) getConversationsWithMessageRetention() ([]*messengertypes.Conversation, error) {
+	convs := []*messengertypes.Conversation(nil)
+
+	if err := d.db.Where("message_retention > 0").Find(&convs).Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return convs, nil
+}
+
+// purgeInteractions removes interactions along with their medias, reactions and edits,
+// it returns the medias that were attached to them
+func (d *dbWrapper) purgeInteractions(cids []string) ([]*messengertypes.Media, error) {
+	if len(cids) == 0 {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a list of cids is required"))
+	}
+
+	medias := []*messengertypes.Media(nil)
+
+	if err := d.tx(func(tx *dbWrapper) error {
+		// the medias also attached to other interactions are kept
+		if err := tx.db.
+			Where("interaction_cid IN ? OR cid IN (?)", cids, tx.db.Model(&messengertypes.MediaReference{}).Select("media_cid").Where("interaction_cid IN ?", cids)).
+			Find(&medias).
+			Error; err != nil {
+			return errcode.ErrDBRead.Wrap(err)
+		}
+
+		if err := tx.db.Where("interaction_cid IN ?", cids).Delete(&messengertypes.MediaReference{}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		remaining := []*messengertypes.MediaReference(nil)
+		if err := tx.db.
+			Where("media_cid IN ?", mediaCIDs(medias)).
+			Where("interaction_cid IN (?)", tx.db.Model(&messengertypes.Interaction{}).Select("cid")).
+			Find(&remaining).
+			Error; err != nil {
+			return errcode.ErrDBRead.Wrap(err)
+		}
+
+		referenced := make(map[string]struct{}, len(remaining))
+		for _, ref := range remaining {
+			if _, ok := referenced[ref.GetMediaCID()]; ok {
+				continue
+			}
+
+			referenced[ref.GetMediaCID()] = struct{}{}
+
+			if err := tx.db.Model(&messengertypes.Media{}).
+				Where("cid = ? AND interaction_cid IN ?", ref.GetMediaCID(), cids).
+				Update("interaction_cid", ref.GetInteractionCID()).
+				Error; err != nil {
+				return errcode.ErrDBWrite.Wrap(err)
+			}
+		}
+
+		unreferenced := []*messengertypes.Media(nil)
+		for _, media := range medias {
+			if _, ok := referenced[media.GetCID()]; !ok {
+				unreferenced = append(unreferenced, media)
+			}
+		}
+		medias = unreferenced
+
+		if err := tx.db.Where("cid IN ?", mediaCIDs(medias)).Delete(&messengertypes.Media{}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		if err := tx.db.Where("target_cid IN ?", cids).Delete(&messengertypes.Reaction{}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		if err := tx.db.Where("target_cid IN ?", cids).Delete(&messengertypes.UserMessageEdit{}).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		if err := tx.deleteInteractions(cids); err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return medias, nil
+}
+
+func (d *dbWrapper) getDBInfo() (*messengertypes.SystemInfo_DB, error) {
+	var err, errs error
+	infos := &messengertypes.SystemInfo_DB{}
+
+	infos.Accounts, err = d.dbModelRowsCount(messengertypes.Account{})
+	errs = multierr.Append(errs, err)
+
+	infos.Contacts, err = d.dbModelRowsCount(messengertypes.Contact{})
+	errs = multierr.Append(errs, err)
+
+	infos.Interactions, err = d.dbModelRowsCount(messengertypes.Interaction{})
+	errs = multierr.Append(errs, err)
+
+	infos.Conversations, err = d.dbModelRowsCount(messengertypes.Conversation{})
+	errs = multierr.Append(errs, err)
+
+	infos.Members, err = d.dbModelRowsCount(messengertypes.Member{})
+	errs = multierr.Append(errs, err)
+
+	infos.Devices, err = d.dbModelRowsCount(messengertypes.Device{})
+	errs = multierr.Append(errs, err)
+
+	infos.ServiceTokens, err = d.dbModelRowsCount(messengertypes.ServiceToken{})
+	errs = multierr.Append(errs, err)
+
+	infos.ConversationReplicationInfo, err = d.dbModelRowsCount(messengertypes.ConversationReplicationInfo{})
+	errs = multierr.Append(errs, err)
+
+	return infos, errs
+}
+
+func (d *dbWrapper) addDevice(devicePK string, memberPK string) (*messengertypes.Device, error) {
+	if devicePK == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a device public key is required"))
+	}
+
+	if memberPK == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a member public key is required"))
+	}
+
+	if err := d.tx(func(tx *dbWrapper) error {
+		// Check if this device already exists for another member
+		{
+			count := int64(0)
+
+			if err := tx.db.
+				Model(&messengertypes.Device{}).
+				Where("member_public_key != ? AND public_key == ?", memberPK, devicePK).
+				Count(&count).
+				Error; err != nil {
+				return err
+			}
+
+			if count > 0 {
+				return errcode.ErrDBWrite.Wrap(fmt.Errorf("the device already exists for another member"))
+			}
+		}
+
+		return tx.db.
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&messengertypes.Device{
+				PublicKey:       devicePK,
+				MemberPublicKey: memberPK,
+			}).
+			Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return d.getDeviceByPK(devicePK)
+}
+
+// markDeviceAsRevoked returns true if the device was known and not revoked yet
+func (d *dbWrapper) markDeviceAsRevoked(devicePK string) (bool, error) {
+	if devicePK == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a device public key is required"))
+	}
+
+	res := d.db.Model(&messengertypes.Device{}).
+		Where("public_key = ? AND revoked = ?", devicePK, false).
+		Update("revoked", true)
+	if res.Error != nil {
+		return false, errcode.ErrDBWrite.Wrap(res.Error)
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (d *dbWrapper) updateContact(pk string, contact messengertypes.Contact) error {
+	if pk == "" {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("no public key specified"))
+	}
+
+	count := int64(0)
 	if err := d.db.Model(&messengertypes.Contact{}).Where(&messengertypes.Contact{PublicKey: pk}).Count(&count).Error; err != nil {
 		return err
 	}
@@ -681,7 +1267,20 @@ func (d *dbWrapper) updateContact(pk string, contact messengertypes.Contact) err
 		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("contact not found"))
 	}
 
-	return d.db.Model(&messengertypes.Contact{PublicKey: pk}).Updates(&contact).Error
+	if err := d.db.Model(&messengertypes.Contact{PublicKey: pk}).Updates(&contact).Error; err != nil {
+		return err
+	}
+
+	if contact.DisplayName != "" {
+		c, err := d.getContactByPK(pk)
+		if err != nil {
+			return err
+		}
+
+		return d.indexInteractionsForSearch("conversation_public_key = ? AND is_me = ?", c.ConversationPublicKey, false)
+	}
+
+	return nil
 }
 
 func (d *dbWrapper) addInteraction(rawInte messengertypes.Interaction) (*messengertypes.Interaction, bool, error) {
@@ -696,6 +1295,10 @@ func (d *dbWrapper) addInteraction(rawInte messengertypes.Interaction) (*messeng
 			return nil, true, err
 		}
 		isNew = true
+
+		if err := d.indexInteractionsForSearch("cid = ?", rawInte.CID); err != nil {
+			return nil, true, err
+		}
 	} else if err != nil {
 		return nil, false, err
 	}
@@ -771,6 +1374,10 @@ func (d *dbWrapper) attributeBacklogInteractions(devicePK, groupPK, memberPK str
 			return err
 		}
 
+		if err := tx.indexInteractionsForSearch("cid IN ?", cids); err != nil {
+			return err
+		}
+
 		if err := tx.db.Preload(clause.Associations).Order("ROWID asc").Find(&backlog, cids).Error; err != nil {
 			return err
 		}
@@ -863,6 +1470,12 @@ func (d *dbWrapper) upsertMember(memberPK, groupPK string, m messengertypes.Memb
 		if err != nil {
 			return nil, false, errcode.ErrDBWrite.Wrap(err)
 		}
+
+		if m.DisplayName != "" && m.DisplayName != em.GetDisplayName() {
+			if err := d.indexInteractionsForSearch("member_public_key = ? AND conversation_public_key = ? AND is_me = ?", memberPK, groupPK, false); err != nil {
+				return nil, false, err
+			}
+		}
 	}
 
 	um, err := d.getMemberByPK(memberPK, groupPK)
@@ -968,6 +1581,25 @@ func (d *dbWrapper) addServiceToken(accountPK string, serviceToken *protocoltype
 	return nil
 }
 
+func (d *dbWrapper) accountSetReadReceiptsEnable(pk string, enabled bool) error {
+	updates := map[string]interface{}{
+		"disable_read_receipts": !enabled,
+	}
+
+	// db update
+	tx := d.db.Model(&messengertypes.Account{}).Where(&messengertypes.Account{PublicKey: pk}).Updates(updates)
+
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return errcode.ErrDBWrite.Wrap(fmt.Errorf("record not found"))
+	}
+
+	return nil
+}
+
 func (d *dbWrapper) accountSetReplicationAutoEnable(pk string, enabled bool) error {
 	updates := map[string]interface{}{
 		"replicate_new_groups_automatically": enabled,
@@ -1000,6 +1632,36 @@ func (d *dbWrapper) saveConversationReplicationInfo(c messengertypes.Conversatio
 	return nil
 }
 
+// deleteConversationReplicationInfo removes the replication info of a conversation for a replication server, it returns
+// the number of deleted replication infos
+func (d *dbWrapper) deleteConversationReplicationInfo(convPK string, replicationServer string) (int64, error) {
+	tx := d.db.
+		Where("conversation_public_key = ? AND replication_server = ?", convPK, replicationServer).
+		Delete(&messengertypes.ConversationReplicationInfo{})
+	if tx.Error != nil {
+		return 0, errcode.ErrDBWrite.Wrap(tx.Error)
+	}
+
+	return tx.RowsAffected, nil
+}
+
+// updateConversationReplicationStatus stores the status reported by a replication server in the replication info of a
+// conversation, it returns the number of updated replication infos
+func (d *dbWrapper) updateConversationReplicationStatus(convPK string, replicationServer string, status *protocoltypes.ReplicationGroupStatus, date int64) (int64, error) {
+	tx := d.db.Model(&messengertypes.ConversationReplicationInfo{}).
+		Where("conversation_public_key = ? AND replication_server = ?", convPK, replicationServer).
+		Updates(map[string]interface{}{
+			"metadata_entries": status.GetMetadataEntries(),
+			"message_entries":  status.GetMessageEntries(),
+			"status_date":      date,
+		})
+	if tx.Error != nil {
+		return 0, errcode.ErrDBWrite.Wrap(tx.Error)
+	}
+
+	return tx.RowsAffected, nil
+}
+
 func (d *dbWrapper) addMedias(medias []*messengertypes.Media) ([]bool, error) {
 	if len(medias) == 0 {
 		return []bool{}, nil
@@ -1069,6 +1731,254 @@ func (d *dbWrapper) getMedias(cids []string) ([]*messengertypes.Media, error) {
 	return medias, nil
 }
 
+// updateMediaDownloadProgress records the range of bytes of a received media which has been retrieved, the progress of a download
+// never goes backward and only advances when the range follows the bytes already retrieved
+func (d *dbWrapper) updateMediaDownloadProgress(cid string, offset uint64, retrievedSize uint64, complete bool) (*messengertypes.Media, bool, error) {
+	if err := ensureValidBase64CID(cid); err != nil {
+		return nil, false, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	media := &messengertypes.Media{}
+	if err := d.db.Where("cid = ?", cid).First(media).Error; err != nil {
+		return nil, false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	switch media.GetState() {
+	case messengertypes.Media_StateNeverDownloaded, messengertypes.Media_StatePartiallyDownloaded:
+	default:
+		return media, false, nil // sent or already downloaded
+	}
+
+	// the bytes preceding the range might not have been retrieved
+	if offset > media.GetDownloadedSize() {
+		return media, false, nil
+	}
+
+	state := messengertypes.Media_StatePartiallyDownloaded
+	if complete {
+		state = messengertypes.Media_StateDownloaded
+	}
+	downloadedSize := offset + retrievedSize
+	if downloadedSize < media.GetDownloadedSize() {
+		downloadedSize = media.GetDownloadedSize()
+	}
+	if state == media.GetState() && downloadedSize == media.GetDownloadedSize() {
+		return media, false, nil
+	}
+
+	if err := d.db.Model(&messengertypes.Media{}).Where("cid = ?", cid).Updates(map[string]interface{}{
+		"state":           state,
+		"downloaded_size": downloadedSize,
+	}).Error; err != nil {
+		return nil, false, errcode.ErrDBWrite.Wrap(err)
+	}
+
+	media.State = state
+	media.DownloadedSize = downloadedSize
+
+	return media, true, nil
+}
+
+// addMediaReferences records that the medias are attached to an interaction
+func (d *dbWrapper) addMediaReferences(interactionCID string, medias []*messengertypes.Media) error {
+	if len(medias) == 0 {
+		return nil
+	}
+
+	refs := make([]*messengertypes.MediaReference, len(medias))
+	for i, media := range medias {
+		refs[i] = &messengertypes.MediaReference{MediaCID: media.GetCID(), InteractionCID: interactionCID}
+	}
+
+	if err := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(refs).Error; err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return nil
+}
+
+// mediaCIDs returns the cids of the medias
+func mediaCIDs(medias []*messengertypes.Media) []string {
+	cids := make([]string, len(medias))
+	for i, media := range medias {
+		cids[i] = media.GetCID()
+	}
+
+	return cids
+}
+
+// mediaSentStates are the states of the medias prepared by the account
+var mediaSentStates = []messengertypes.Media_State{messengertypes.Media_StatePrepared, messengertypes.Media_StateAttached}
+
+func (d *dbWrapper) markMediaAsAccessed(cid string, date int64) error {
+	if err := ensureValidBase64CID(cid); err != nil {
+		return errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	if err := d.db.Model(&messengertypes.Media{}).Where("cid = ?", cid).Update("last_access_date", date).Error; err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return nil
+}
+
+// resetMediaDownloadProgress marks a received media as never downloaded once its local blocks have been evicted
+func (d *dbWrapper) resetMediaDownloadProgress(cid string) error {
+	if err := d.db.Model(&messengertypes.Media{}).Where("cid = ?", cid).Updates(map[string]interface{}{
+		"state":           messengertypes.Media_StateNeverDownloaded,
+		"downloaded_size": 0,
+	}).Error; err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return nil
+}
+
+// getMediaStorageUsage returns the storage used by the medias attached to the interactions of each conversation
+func (d *dbWrapper) getMediaStorageUsage() ([]*messengertypes.MediaStorageUsage_ConversationUsage, error) {
+	usages := []*messengertypes.MediaStorageUsage_ConversationUsage(nil)
+
+	if err := d.db.Model(&messengertypes.Media{}).
+		Select("interactions.conversation_public_key AS conversation_public_key, "+
+			"SUM(CASE WHEN state IN ? THEN size ELSE 0 END) AS sent_size, "+
+			"SUM(CASE WHEN state IN ? THEN 0 ELSE downloaded_size END) AS received_size, "+
+			"COUNT(*) AS media_count", mediaSentStates, mediaSentStates).
+		Joins("JOIN interactions ON interactions.cid = interaction_cid").
+		Group("interactions.conversation_public_key").
+		Order("interactions.conversation_public_key").
+		Scan(&usages).
+		Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return usages, nil
+}
+
+// getReceivedMediasSize returns the size of the downloaded parts of the received medias
+func (d *dbWrapper) getReceivedMediasSize() (uint64, error) {
+	size := uint64(0)
+
+	if err := d.db.Model(&messengertypes.Media{}).
+		Select("COALESCE(SUM(downloaded_size), 0)").
+		Where("state NOT IN ?", mediaSentStates).
+		Scan(&size).
+		Error; err != nil {
+		return 0, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return size, nil
+}
+
+// getLeastRecentlyUsedReceivedMedias returns the received medias with local blocks, sorted from the least recently accessed,
+// the excluded medias are ignored
+func (d *dbWrapper) getLeastRecentlyUsedReceivedMedias(limit int, excludedCIDs ...string) ([]*messengertypes.Media, error) {
+	medias := []*messengertypes.Media(nil)
+
+	query := d.db.Where("state NOT IN ? AND downloaded_size > 0", mediaSentStates)
+	if len(excludedCIDs) > 0 {
+		query = query.Where("cid NOT IN ?", excludedCIDs)
+	}
+
+	if err := query.
+		Order("last_access_date ASC").
+		Limit(limit).
+		Find(&medias).
+		Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return medias, nil
+}
+
+// getUnreferencedMedias returns the medias which are neither attached to an existing interaction nor used as an avatar,
+// medias accessed after the given date are ignored as they might be about to be sent
+func (d *dbWrapper) getUnreferencedMedias(accessedBefore int64) ([]*messengertypes.Media, error) {
+	medias := []*messengertypes.Media(nil)
+
+	if err := d.db.
+		Where("interaction_cid NOT IN (?)", d.db.Model(&messengertypes.Interaction{}).Select("cid")).
+		Where("cid NOT IN (?)", d.db.Model(&messengertypes.MediaReference{}).
+			Select("media_cid").
+			Where("interaction_cid IN (?)", d.db.Model(&messengertypes.Interaction{}).Select("cid"))).
+		Where("cid NOT IN (?)", d.db.Model(&messengertypes.Account{}).Select("avatar_cid")).
+		Where("cid NOT IN (?)", d.db.Model(&messengertypes.Contact{}).Select("avatar_cid")).
+		Where("cid NOT IN (?)", d.db.Model(&messengertypes.Conversation{}).Select("avatar_cid")).
+		Where("cid NOT IN (?)", d.db.Model(&messengertypes.Member{}).Select("avatar_cid")).
+		Where("last_access_date < ?", accessedBefore).
+		Find(&medias).
+		Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return medias, nil
+}
+
+// getThumbnailMedia returns the description of a thumbnail based on the media it was generated for, nil is returned if the cid
+// is not the one of a thumbnail
+func (d *dbWrapper) getThumbnailMedia(cid string) (*messengertypes.Media, error) {
+	if err := ensureValidBase64CID(cid); err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	media := &messengertypes.Media{}
+	if err := d.db.Where("thumbnail_cid = ?", cid).First(media).Error; err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return &messengertypes.Media{
+		CID:            cid,
+		MimeType:       mediaThumbnailMimeType,
+		InteractionCID: media.GetInteractionCID(),
+	}, nil
+}
+
+// getLocalMediaCIDs returns the cids of the medias and thumbnails whose blocks are stored on the device, except the
+// excluded ones
+func (d *dbWrapper) getLocalMediaCIDs(excluded ...string) ([]string, error) {
+	medias := []*messengertypes.Media(nil)
+
+	if err := d.db.
+		Select("cid, thumbnail_cid").
+		Where("state IN ? OR downloaded_size > 0", mediaSentStates).
+		Find(&medias).
+		Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	skip := make(map[string]struct{}, len(excluded))
+	for _, cid := range excluded {
+		skip[cid] = struct{}{}
+	}
+
+	cids := []string(nil)
+	for _, media := range medias {
+		for _, cid := range []string{media.GetCID(), media.GetThumbnailCID()} {
+			if _, ok := skip[cid]; ok || cid == "" {
+				continue
+			}
+
+			skip[cid] = struct{}{}
+			cids = append(cids, cid)
+		}
+	}
+
+	return cids, nil
+}
+
+func (d *dbWrapper) deleteMedias(cids []string) error {
+	if len(cids) == 0 {
+		return nil
+	}
+
+	if err := d.db.Where("cid IN ?", cids).Delete(&messengertypes.Media{}).Error; err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return nil
+}
+
 func (d *dbWrapper) getAllMedias() ([]*messengertypes.Media, error) {
 	var medias []*messengertypes.Media
 	err := d.db.Find(&medias).Error
@@ -1093,3 +2003,377 @@ func (d *dbWrapper) getMemberPKFromDevicePK(dpk string) (string, error) {
 		return "", errcode.ErrDBRead.Wrap(err)
 	}
 }
+
+// upsertReaction stores the reaction state of a member if it is more recent than the known one,
+// it returns true if the stored state has been modified
+func (d *dbWrapper) upsertReaction(r messengertypes.Reaction) (bool, error) {
+	if r.TargetCID == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a target cid is required"))
+	}
+
+	if r.Emoji == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("an emoji is required"))
+	}
+
+	existing := messengertypes.Reaction{}
+	err := d.db.Where(map[string]interface{}{
+		"target_cid":        r.TargetCID,
+		"member_public_key": r.MemberPublicKey,
+		"is_mine":           r.IsMine,
+		"emoji":             r.Emoji,
+	}).First(&existing).Error
+
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		// a removal is stored as well to discard older additions received later
+	case err != nil:
+		return false, errcode.ErrDBRead.Wrap(err)
+	case existing.StateDate > r.StateDate:
+		return false, nil
+	}
+
+	if err := d.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&r).Error; err != nil {
+		return false, errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return existing.State != r.State, nil
+}
+
+// getReactionsViews returns the aggregated reactions indexed by target cid, for all interactions if no target is specified
+func (d *dbWrapper) getReactionsViews(targetCIDs ...string) (map[string][]*messengertypes.Interaction_ReactionView, error) {
+	rows := []struct {
+		TargetCID string
+		Emoji     string
+		OwnState  bool
+		Count     uint64
+	}(nil)
+
+	query := d.db.Model(&messengertypes.Reaction{}).
+		Select("target_cid, emoji, MAX(is_mine) AS own_state, COUNT(*) AS count").
+		Where("state = true")
+
+	if len(targetCIDs) > 0 {
+		query = query.Where("target_cid IN ?", targetCIDs)
+	}
+
+	if err := query.
+		Group("target_cid, emoji").
+		Order("MIN(state_date) asc").
+		Scan(&rows).Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	views := map[string][]*messengertypes.Interaction_ReactionView{}
+	for _, row := range rows {
+		views[row.TargetCID] = append(views[row.TargetCID], &messengertypes.Interaction_ReactionView{
+			Emoji:    row.Emoji,
+			OwnState: row.OwnState,
+			Count:    row.Count,
+		})
+	}
+
+	return views, nil
+}
+
+// upsertReadReceipt stores the last interaction read by a member, older receipts are ignored,
+// it returns true if the last read interaction changed
+func (d *dbWrapper) upsertReadReceipt(r messengertypes.ReadReceipt) (bool, error) {
+	if r.ConversationPublicKey == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	if r.LastReadCID == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a last read cid is required"))
+	}
+
+	existing := messengertypes.ReadReceipt{}
+	err := d.db.Where(map[string]interface{}{
+		"conversation_public_key": r.ConversationPublicKey,
+		"member_public_key":       r.MemberPublicKey,
+		"is_mine":                 r.IsMine,
+	}).First(&existing).Error
+
+	switch {
+	case err == gorm.ErrRecordNotFound:
+	case err != nil:
+		return false, errcode.ErrDBRead.Wrap(err)
+	case existing.ReadDate >= r.ReadDate:
+		return false, nil
+	}
+
+	if err := d.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&r).Error; err != nil {
+		return false, errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return existing.LastReadCID != r.LastReadCID, nil
+}
+
+// getOwnReadReceipt returns the last read receipt sent by the account in a conversation
+func (d *dbWrapper) getOwnReadReceipt(convPK string) (*messengertypes.ReadReceipt, error) {
+	if convPK == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	receipt := &messengertypes.ReadReceipt{}
+
+	return receipt, d.db.
+		Where("conversation_public_key = ? AND is_mine = ?", convPK, true).
+		Order("read_date DESC").
+		First(receipt).
+		Error
+}
+
+// getLastReceivedInteraction returns the most recent interaction of a conversation sent by another member
+func (d *dbWrapper) getLastReceivedInteraction(convPK string) (*messengertypes.Interaction, error) {
+	if convPK == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	interaction := &messengertypes.Interaction{}
+
+	return interaction, d.db.
+		Where("conversation_public_key = ? AND is_me = ? AND is_deleted = ?", convPK, false, false).
+		Order("sent_date DESC, cid DESC").
+		First(interaction).
+		Error
+}
+
+// attachReadBy fills the members who read each interaction, according to the read receipts of their conversation
+func (d *dbWrapper) attachReadBy(interactions []*messengertypes.Interaction) error {
+	if len(interactions) == 0 {
+		return nil
+	}
+
+	convPKs := []string(nil)
+	seen := map[string]bool{}
+	for _, inte := range interactions {
+		if !seen[inte.ConversationPublicKey] {
+			seen[inte.ConversationPublicKey] = true
+			convPKs = append(convPKs, inte.ConversationPublicKey)
+		}
+	}
+
+	rows := []struct {
+		ConversationPublicKey string
+		MemberPublicKey       string
+		ContactPublicKey      string
+		Type                  messengertypes.Conversation_Type
+		SentDate              int64
+	}(nil)
+
+	// receipts pointing to unknown interactions are ignored until the interaction is received
+	if err := d.db.
+		Table("read_receipts").
+		Select("read_receipts.conversation_public_key, read_receipts.member_public_key, conversations.contact_public_key, conversations.type, interactions.sent_date").
+		Joins("JOIN interactions ON interactions.cid = read_receipts.last_read_cid").
+		Joins("JOIN conversations ON conversations.public_key = read_receipts.conversation_public_key").
+		Where("read_receipts.conversation_public_key IN ? AND read_receipts.is_mine = ?", convPKs, false).
+		Order("read_receipts.read_date ASC").
+		Scan(&rows).Error; err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	for _, inte := range interactions {
+		inte.ReadBy = nil
+
+		for _, row := range rows {
+			if row.ConversationPublicKey != inte.ConversationPublicKey || row.SentDate < inte.SentDate {
+				continue
+			}
+
+			// the author has obviously read the interaction
+			if !inte.IsMe && row.MemberPublicKey == inte.MemberPublicKey {
+				continue
+			}
+
+			pk := row.MemberPublicKey
+			if pk == "" && row.Type == messengertypes.Conversation_ContactType {
+				pk = row.ContactPublicKey
+			}
+
+			inte.ReadBy = append(inte.ReadBy, pk)
+		}
+	}
+
+	return nil
+}
+
+// attachReplyTo fills the interactions quoted by the given interactions
+func (d *dbWrapper) attachReplyTo(interactions []*messengertypes.Interaction) error {
+	cids := []string(nil)
+	convPKs := []string(nil)
+	for _, inte := range interactions {
+		if inte.ReplyToCID != "" {
+			cids = append(cids, inte.ReplyToCID)
+			convPKs = append(convPKs, inte.ConversationPublicKey)
+		}
+	}
+
+	if len(cids) == 0 {
+		return nil
+	}
+
+	// deleted interactions are kept so that the tombstone can be displayed
+	quoted := []*messengertypes.Interaction(nil)
+	if err := d.db.Preload("Member").Where("cid IN ? AND conversation_public_key IN ?", cids, convPKs).Find(&quoted).Error; err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	quotedByCID := make(map[string]*messengertypes.Interaction, len(quoted))
+	for _, q := range quoted {
+		quotedByCID[q.CID] = q
+	}
+
+	for _, inte := range interactions {
+		// a reply can only quote an interaction of its own conversation
+		if q, ok := quotedByCID[inte.ReplyToCID]; ok && q.ConversationPublicKey == inte.ConversationPublicKey {
+			inte.ReplyTo = q
+		}
+	}
+
+	return nil
+}
+
+// getThreadReplies returns the user messages of a thread, sorted from the oldest to the most recent
+func (d *dbWrapper) getThreadReplies(convPK, rootCID string) ([]*messengertypes.Interaction, error) {
+	if convPK == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	if rootCID == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a thread root cid is required"))
+	}
+
+	interactions := []*messengertypes.Interaction(nil)
+	if err := d.db.
+		Preload(clause.Associations).
+		Where("conversation_public_key = ? AND thread_root_cid = ? AND is_deleted = ?", convPK, rootCID, false).
+		Order("sent_date ASC, cid ASC").
+		Find(&interactions).Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := d.attachReactionsViews(interactions); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReadBy(interactions); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReplyTo(interactions); err != nil {
+		return nil, err
+	}
+
+	return interactions, nil
+}
+
+// upsertPinnedMessage applies the pinned state of an interaction if it is more recent than the current one,
+// it returns true if the state changed
+func (d *dbWrapper) upsertPinnedMessage(p messengertypes.PinnedMessage) (bool, error) {
+	if p.ConversationPublicKey == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	if p.TargetCID == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a target cid is required"))
+	}
+
+	existing := messengertypes.PinnedMessage{}
+	err := d.db.Where(&messengertypes.PinnedMessage{ConversationPublicKey: p.ConversationPublicKey, TargetCID: p.TargetCID}).First(&existing).Error
+
+	switch {
+	case err == gorm.ErrRecordNotFound:
+	case err != nil:
+		return false, errcode.ErrDBRead.Wrap(err)
+	case existing.StateDate >= p.StateDate:
+		return false, nil
+	}
+
+	if err := d.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&p).Error; err != nil {
+		return false, errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return existing.Pinned != p.Pinned, nil
+}
+
+// upsertStarredMessage applies the starred state of an interaction if it is more recent than the current one,
+// it returns true if the state changed
+func (d *dbWrapper) upsertStarredMessage(s messengertypes.StarredMessage) (bool, error) {
+	if s.ConversationPublicKey == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	if s.TargetCID == "" {
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a target cid is required"))
+	}
+
+	existing := messengertypes.StarredMessage{}
+	err := d.db.Where(&messengertypes.StarredMessage{ConversationPublicKey: s.ConversationPublicKey, TargetCID: s.TargetCID}).First(&existing).Error
+
+	switch {
+	case err == gorm.ErrRecordNotFound:
+	case err != nil:
+		return false, errcode.ErrDBRead.Wrap(err)
+	case existing.StateDate >= s.StateDate:
+		return false, nil
+	}
+
+	if err := d.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&s).Error; err != nil {
+		return false, errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return existing.Starred != s.Starred, nil
+}
+
+// getPinnedInteractions returns the pinned interactions of a conversation, sorted from the oldest to the most recent
+func (d *dbWrapper) getPinnedInteractions(convPK string) ([]*messengertypes.Interaction, error) {
+	if convPK == "" {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a conversation public key is required"))
+	}
+
+	return d.getMarkedInteractions(
+		d.db.Where("cid IN (SELECT target_cid FROM pinned_messages WHERE conversation_public_key = ? AND pinned = ?)", convPK, true).
+			Where("conversation_public_key = ?", convPK),
+	)
+}
+
+// getStarredInteractions returns the starred interactions of a conversation, or of every conversation when convPK is empty,
+// sorted from the oldest to the most recent
+func (d *dbWrapper) getStarredInteractions(convPK string) ([]*messengertypes.Interaction, error) {
+	if convPK == "" {
+		return d.getMarkedInteractions(
+			d.db.Where("cid IN (SELECT target_cid FROM starred_messages WHERE starred = ?)", true),
+		)
+	}
+
+	return d.getMarkedInteractions(
+		d.db.Where("cid IN (SELECT target_cid FROM starred_messages WHERE conversation_public_key = ? AND starred = ?)", convPK, true).
+			Where("conversation_public_key = ?", convPK),
+	)
+}
+
+func (d *dbWrapper) getMarkedInteractions(query *gorm.DB) ([]*messengertypes.Interaction, error) {
+	interactions := []*messengertypes.Interaction(nil)
+	if err := query.
+		Preload(clause.Associations).
+		Where("is_deleted = ?", false).
+		Order("sent_date ASC, cid ASC").
+		Find(&interactions).Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := d.attachReactionsViews(interactions); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReadBy(interactions); err != nil {
+		return nil, err
+	}
+
+	if err := d.attachReplyTo(interactions); err != nil {
+		return nil, err
+	}
+
+	return interactions, nil
+}
@@ -0,0 +1,445 @@
+package bertymessenger
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+)
+
+const (
+	// interactionsSearchTable is an FTS5 table whose rowids are the ids of interactionsSearchKeysTable,
+	// it requires go-sqlite3 to be built with the sqlite_fts5 tag
+	interactionsSearchTable = "interactions_search"
+
+	// interactionsSearchKeysTable maps the cids of the indexed interactions to explicit integer primary keys,
+	// unlike the implicit rowids of the interactions table they are stable across VACUUM and row rewrites
+	interactionsSearchKeysTable = "interactions_search_keys"
+
+	searchIndexBatchSize   = 500
+	searchDefaultLimit     = 50
+	searchSnippetMaxTokens = 16
+	searchSnippetOpen      = "<b>"
+	searchSnippetClose     = "</b>"
+	searchSnippetEllipsis  = "…"
+
+	// searchSnippetOpenMarker and searchSnippetCloseMarker enclose the matches in the raw snippets, they are replaced by the
+	// html tags once the text has been escaped and are removed from the indexed text
+	searchSnippetOpenMarker  = "\x02"
+	searchSnippetCloseMarker = "\x03"
+)
+
+var searchSnippetMarkersRemover = strings.NewReplacer(searchSnippetOpenMarker, "", searchSnippetCloseMarker, "")
+
+var searchIndexedTypes = []messengertypes.AppMessage_Type{
+	messengertypes.AppMessage_TypeUserMessage,
+	messengertypes.AppMessage_TypeGroupInvitation,
+	messengertypes.AppMessage_TypeReplyOptions,
+}
+
+func isSearchIndexedType(t messengertypes.AppMessage_Type) bool {
+	for _, it := range searchIndexedTypes {
+		if it == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isMissingSQLiteModuleError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such module")
+}
+
+// initSearchIndex creates the search index and fills it with the existing interactions if needed,
+// the index is disabled when sqlite has been built without fts5
+func (d *dbWrapper) initSearchIndex() error {
+	d.searchIndex = false
+
+	count := int64(0)
+	if err := d.db.Raw(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
+		interactionsSearchTable,
+	).Scan(&count).Error; err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	rebuild := count == 0
+
+	if rebuild {
+		err := d.db.Exec(fmt.Sprintf(
+			"CREATE VIRTUAL TABLE %s USING fts5(body, filenames, display_name, tokenize = 'unicode61 remove_diacritics 2')",
+			interactionsSearchTable,
+		)).Error
+		if isMissingSQLiteModuleError(err) {
+			d.log.Warn("sqlite has been built without fts5, message search is disabled", zap.Error(err))
+			return nil
+		} else if err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		if err := d.db.Exec(fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s (id INTEGER PRIMARY KEY, cid TEXT NOT NULL UNIQUE)",
+			interactionsSearchKeysTable,
+		)).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+	} else if err := d.db.Exec(fmt.Sprintf("SELECT rowid FROM %s LIMIT 0", interactionsSearchTable)).Error; isMissingSQLiteModuleError(err) {
+		d.log.Warn("sqlite has been built without fts5, message search is disabled", zap.Error(err))
+		return nil
+	} else if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	d.searchIndex = true
+
+	if rebuild {
+		return d.indexInteractionsForSearch("type IN ?", searchIndexedTypes)
+	}
+
+	return nil
+}
+
+// searchKeys returns the search index keys of the given interactions, creating the missing ones
+func (d *dbWrapper) searchKeys(cids []string) (map[string]int64, error) {
+	for _, cid := range cids {
+		if err := d.db.Exec(fmt.Sprintf("INSERT OR IGNORE INTO %s (cid) VALUES (?)", interactionsSearchKeysTable), cid).Error; err != nil {
+			return nil, errcode.ErrDBWrite.Wrap(err)
+		}
+	}
+
+	rows := []struct {
+		ID  int64
+		CID string
+	}(nil)
+	if err := d.db.Raw(fmt.Sprintf("SELECT id, cid FROM %s WHERE cid IN ?", interactionsSearchKeysTable), cids).Scan(&rows).Error; err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	keys := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		keys[row.CID] = row.ID
+	}
+
+	return keys, nil
+}
+
+// indexInteractionsForSearch refreshes the search index entries of the interactions matching the given conditions
+func (d *dbWrapper) indexInteractionsForSearch(query interface{}, args ...interface{}) error {
+	if !d.searchIndex {
+		return nil
+	}
+
+	cids := []string(nil)
+	if err := d.db.Model(&messengertypes.Interaction{}).Where(query, args...).Pluck("cid", &cids).Error; err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	displayNames := map[string]string{}
+
+	for start := 0; start < len(cids); start += searchIndexBatchSize {
+		end := start + searchIndexBatchSize
+		if end > len(cids) {
+			end = len(cids)
+		}
+
+		batch := cids[start:end]
+
+		keys, err := d.searchKeys(batch)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(keys))
+		for _, id := range keys {
+			ids = append(ids, id)
+		}
+
+		if err := d.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE rowid IN ?", interactionsSearchTable), ids).Error; err != nil {
+			return errcode.ErrDBWrite.Wrap(err)
+		}
+
+		interactions := []*messengertypes.Interaction(nil)
+		if err := d.db.Preload("Medias").Where("cid IN ?", batch).Find(&interactions).Error; err != nil {
+			return errcode.ErrDBRead.Wrap(err)
+		}
+
+		interactionsByCID := make(map[string]*messengertypes.Interaction, len(interactions))
+		for _, i := range interactions {
+			interactionsByCID[i.CID] = i
+		}
+
+		for _, cid := range batch {
+			i, ok := interactionsByCID[cid]
+			if !ok || i.IsDeleted || !isSearchIndexedType(i.Type) {
+				continue
+			}
+
+			displayName, err := d.searchIndexDisplayName(i, displayNames)
+			if err != nil {
+				return err
+			}
+
+			filenames := []string(nil)
+			for _, m := range i.Medias {
+				if m.Filename != "" {
+					filenames = append(filenames, m.Filename)
+				}
+			}
+
+			if err := d.db.Exec(
+				fmt.Sprintf("INSERT INTO %s (rowid, body, filenames, display_name) VALUES (?, ?, ?, ?)", interactionsSearchTable),
+				keys[cid],
+				searchSnippetMarkersRemover.Replace(searchIndexBody(i)),
+				searchSnippetMarkersRemover.Replace(strings.Join(filenames, "\n")),
+				searchSnippetMarkersRemover.Replace(displayName),
+			).Error; err != nil {
+				return errcode.ErrDBWrite.Wrap(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// unindexInteractionsForSearch removes the search index entries of the given interactions,
+// it must be called before the interactions themselves are removed
+func (d *dbWrapper) unindexInteractionsForSearch(cids []string) error {
+	if !d.searchIndex || len(cids) == 0 {
+		return nil
+	}
+
+	if err := d.db.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE rowid IN (SELECT id FROM %s WHERE cid IN ?)", interactionsSearchTable, interactionsSearchKeysTable),
+		cids,
+	).Error; err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
+	if err := d.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE cid IN ?", interactionsSearchKeysTable), cids).Error; err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
+	return nil
+}
+
+func searchIndexBody(i *messengertypes.Interaction) string {
+	payload, err := i.UnmarshalPayload()
+	if err != nil {
+		return ""
+	}
+
+	switch p := payload.(type) {
+	case *messengertypes.AppMessage_UserMessage:
+		return p.GetBody()
+	case *messengertypes.AppMessage_ReplyOptions:
+		options := make([]string, len(p.GetOptions()))
+		for j, o := range p.GetOptions() {
+			options[j] = o.GetDisplay()
+		}
+		return strings.Join(options, "\n")
+	}
+
+	return ""
+}
+
+// searchIndexDisplayName returns the display name of the author of an interaction, falling back
+// on the contact display name for contact conversations, results are cached in displayNames
+func (d *dbWrapper) searchIndexDisplayName(i *messengertypes.Interaction, displayNames map[string]string) (string, error) {
+	key := "account"
+	if !i.IsMe {
+		key = i.ConversationPublicKey + "/" + i.MemberPublicKey
+	}
+
+	if name, ok := displayNames[key]; ok {
+		return name, nil
+	}
+
+	name := ""
+	if i.IsMe {
+		if err := d.db.Raw("SELECT display_name FROM accounts LIMIT 1").Scan(&name).Error; err != nil {
+			return "", errcode.ErrDBRead.Wrap(err)
+		}
+	} else {
+		if i.MemberPublicKey != "" {
+			if err := d.db.Raw(
+				"SELECT display_name FROM members WHERE public_key = ? AND conversation_public_key = ?",
+				i.MemberPublicKey, i.ConversationPublicKey,
+			).Scan(&name).Error; err != nil {
+				return "", errcode.ErrDBRead.Wrap(err)
+			}
+		}
+
+		if name == "" {
+			if err := d.db.Raw(
+				"SELECT display_name FROM contacts WHERE conversation_public_key = ?",
+				i.ConversationPublicKey,
+			).Scan(&name).Error; err != nil {
+				return "", errcode.ErrDBRead.Wrap(err)
+			}
+		}
+	}
+
+	displayNames[key] = name
+
+	return name, nil
+}
+
+// searchMatchExpression converts user input into an FTS5 query where every word is a quoted prefix
+func searchMatchExpression(query string) string {
+	terms := []string(nil)
+	for _, word := range strings.Fields(query) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// searchInteractions returns the interactions matching the request, from the most recent to the oldest,
+// along with the cursor to use to fetch the next page
+func (d *dbWrapper) searchInteractions(req *messengertypes.MessageSearch_Request) ([]*messengertypes.MessageSearch_Result, string, error) {
+	if !d.searchIndex {
+		return nil, "", errcode.ErrNotImplemented.Wrap(fmt.Errorf("search index is not available, sqlite must be built with fts5"))
+	}
+
+	match := searchMatchExpression(req.GetQuery())
+	if match == "" {
+		return nil, "", errcode.ErrInvalidInput.Wrap(fmt.Errorf("a search query is required"))
+	}
+
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		return nil, "", errcode.ErrInvalidInput.Wrap(fmt.Errorf("limit cannot be negative"))
+	} else if limit == 0 {
+		limit = searchDefaultLimit
+	}
+
+	query := d.db.
+		Table(interactionsSearchTable).
+		Select(fmt.Sprintf(
+			"interactions.cid AS cid, snippet(%s, -1, '%s', '%s', '%s', %d) AS snippet",
+			interactionsSearchTable, searchSnippetOpenMarker, searchSnippetCloseMarker, searchSnippetEllipsis, searchSnippetMaxTokens,
+		)).
+		Joins(fmt.Sprintf("JOIN %s ON %s.id = %s.rowid", interactionsSearchKeysTable, interactionsSearchKeysTable, interactionsSearchTable)).
+		Joins(fmt.Sprintf("JOIN interactions ON interactions.cid = %s.cid", interactionsSearchKeysTable)).
+		Where(fmt.Sprintf("%s MATCH ?", interactionsSearchTable), match).
+		Where("interactions.is_deleted = ?", false)
+
+	if pk := req.GetConversationPublicKey(); pk != "" {
+		query = query.Where("interactions.conversation_public_key = ?", pk)
+	}
+
+	// the interactions of the account and of the contact in a contact conversation have no member public key
+	if pk := req.GetMemberPublicKey(); pk != "" {
+		query = query.
+			Joins("JOIN conversations ON conversations.public_key = interactions.conversation_public_key").
+			Where(
+				"(interactions.member_public_key = ?"+
+					" OR (interactions.is_me = ? AND (conversations.account_member_public_key = ? OR ? IN (SELECT public_key FROM accounts)))"+
+					" OR (interactions.is_me = ? AND interactions.member_public_key = '' AND conversations.contact_public_key = ?))",
+				pk, true, pk, pk, false, pk,
+			)
+	}
+
+	if t := req.GetType(); t != messengertypes.AppMessage_Undefined {
+		query = query.Where("interactions.type = ?", t)
+	}
+
+	if after := req.GetSentAfter(); after != 0 {
+		query = query.Where("interactions.sent_date >= ?", after)
+	}
+
+	if before := req.GetSentBefore(); before != 0 {
+		query = query.Where("interactions.sent_date <= ?", before)
+	}
+
+	if refCID := req.GetRefCID(); refCID != "" {
+		ref := &messengertypes.Interaction{}
+		if err := d.db.Select("cid, sent_date").First(&ref, &messengertypes.Interaction{CID: refCID}).Error; err != nil {
+			return nil, "", errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown ref cid: %w", err))
+		}
+
+		query = query.Where(
+			"(interactions.sent_date < ? OR (interactions.sent_date = ? AND interactions.cid < ?))",
+			ref.SentDate, ref.SentDate, ref.CID,
+		)
+	}
+
+	matches := []struct {
+		CID     string
+		Snippet string
+	}(nil)
+	if err := query.
+		Order("interactions.sent_date DESC, interactions.cid DESC").
+		Limit(limit + 1).
+		Scan(&matches).Error; err != nil {
+		return nil, "", errcode.ErrDBRead.Wrap(err)
+	}
+
+	nextRefCID := ""
+	if len(matches) > limit {
+		matches = matches[:limit]
+		nextRefCID = matches[limit-1].CID
+	}
+
+	if len(matches) == 0 {
+		return []*messengertypes.MessageSearch_Result{}, "", nil
+	}
+
+	cids := make([]string, len(matches))
+	for j, m := range matches {
+		cids[j] = m.CID
+	}
+
+	interactions := []*messengertypes.Interaction(nil)
+	if err := d.db.Preload(clause.Associations).Where("cid IN ?", cids).Find(&interactions).Error; err != nil {
+		return nil, "", errcode.ErrDBRead.Wrap(err)
+	}
+
+	reactions, err := d.getReactionsViews(cids...)
+	if err != nil {
+		return nil, "", errcode.ErrDBRead.Wrap(err)
+	}
+
+	interactionsByCID := make(map[string]*messengertypes.Interaction, len(interactions))
+	for _, i := range interactions {
+		i.Reactions = reactions[i.CID]
+		interactionsByCID[i.CID] = i
+	}
+
+	if err := d.attachReadBy(interactions); err != nil {
+		return nil, "", err
+	}
+
+	if err := d.attachReplyTo(interactions); err != nil {
+		return nil, "", err
+	}
+
+	results := make([]*messengertypes.MessageSearch_Result, 0, len(matches))
+	for _, m := range matches {
+		i, ok := interactionsByCID[m.CID]
+		if !ok {
+			continue
+		}
+
+		results = append(results, &messengertypes.MessageSearch_Result{
+			Interaction: i,
+			Snippet:     searchSnippet(m.Snippet),
+		})
+	}
+
+	return results, nextRefCID, nil
+}
+
+// searchSnippet escapes the text of a raw snippet and encloses its matches in html tags
+func searchSnippet(raw string) string {
+	escaped := html.EscapeString(raw)
+	escaped = strings.ReplaceAll(escaped, searchSnippetOpenMarker, searchSnippetOpen)
+
+	return strings.ReplaceAll(escaped, searchSnippetCloseMarker, searchSnippetClose)
+}
@@ -0,0 +1,245 @@
+package bertymessenger
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+)
+
+func getSearchTestDB(t *testing.T) (*dbWrapper, func()) {
+	t.Helper()
+
+	db, dispose := getInMemoryTestDB(t)
+	if !db.searchIndex {
+		dispose()
+		t.Skip("search index is not available, run tests with -tags sqlite_fts5")
+	}
+
+	return db, dispose
+}
+
+func searchTestUserMessage(t *testing.T, body string) []byte {
+	t.Helper()
+
+	payload, err := proto.Marshal(&messengertypes.AppMessage_UserMessage{Body: body})
+	require.NoError(t, err)
+
+	return payload
+}
+
+func searchResultsCIDs(results []*messengertypes.MessageSearch_Result) []string {
+	cids := make([]string, len(results))
+	for i, r := range results {
+		cids[i] = r.Interaction.CID
+	}
+
+	return cids
+}
+
+func Test_searchMatchExpression(t *testing.T) {
+	require.Equal(t, "", searchMatchExpression(""))
+	require.Equal(t, "", searchMatchExpression("   "))
+	require.Equal(t, `"hello"*`, searchMatchExpression("hello"))
+	require.Equal(t, `"hello"* "world"*`, searchMatchExpression(" hello  world "))
+	require.Equal(t, `"say"* """hi"""*`, searchMatchExpression(`say "hi"`))
+	require.Equal(t, `"body:"* "NOT"* "x*"*`, searchMatchExpression("body: NOT x*"))
+}
+
+func Test_dbWrapper_initSearchIndex(t *testing.T) {
+	db, dispose := getSearchTestDB(t)
+	defer dispose()
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0001", Type: messengertypes.AppMessage_TypeUserMessage, Payload: searchTestUserMessage(t, "indexed before the index"), SentDate: 1}).Error)
+
+	require.NoError(t, db.db.Exec("DROP TABLE "+interactionsSearchTable).Error)
+	require.NoError(t, db.initSearchIndex())
+	require.True(t, db.searchIndex)
+
+	results, _, err := db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "before"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0001"}, searchResultsCIDs(results))
+
+	// the index is not part of the gorm models schema
+	schemas, err := getDBTablesSchemas(db.db)
+	require.NoError(t, err)
+	for name := range schemas {
+		require.NotContains(t, name, interactionsSearchTable)
+	}
+}
+
+func Test_dbWrapper_searchInteractionsRowIDChanges(t *testing.T) {
+	db, dispose := getSearchTestDB(t)
+	defer dispose()
+
+	for _, i := range []messengertypes.Interaction{
+		{CID: "Qm0001", Type: messengertypes.AppMessage_TypeUserMessage, SentDate: 1, Payload: searchTestUserMessage(t, "first message")},
+		{CID: "Qm0002", Type: messengertypes.AppMessage_TypeUserMessage, SentDate: 2, Payload: searchTestUserMessage(t, "second message")},
+	} {
+		_, _, err := db.addInteraction(i)
+		require.NoError(t, err)
+	}
+
+	// the implicit rowids of the interactions are not stable, the index must not rely on them
+	require.NoError(t, db.db.Exec("UPDATE interactions SET ROWID = ROWID + 100").Error)
+	require.NoError(t, db.db.Exec("VACUUM").Error)
+
+	results, _, err := db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "second"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0002"}, searchResultsCIDs(results))
+
+	require.NoError(t, db.deleteInteractions([]string{"Qm0002"}))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "message"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0001"}, searchResultsCIDs(results))
+}
+
+func Test_dbWrapper_searchInteractions(t *testing.T) {
+	db, dispose := getSearchTestDB(t)
+	defer dispose()
+
+	_, _, err := db.searchInteractions(&messengertypes.MessageSearch_Request{Query: " "})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	_, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "a", Limit: -1})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	require.NoError(t, db.db.Create(&messengertypes.Account{PublicKey: "pk_account", DisplayName: "Alice"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_1", Type: messengertypes.Conversation_MultiMemberType, AccountMemberPublicKey: "member_me"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_2", Type: messengertypes.Conversation_ContactType, ContactPublicKey: "contact_1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Member{PublicKey: "member_1", ConversationPublicKey: "conv_1", DisplayName: "Bob"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Contact{PublicKey: "contact_1", ConversationPublicKey: "conv_2", DisplayName: "Charlie"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Media{CID: "Qm_media", Filename: "holidays.jpg", InteractionCID: "Qm0004"}).Error)
+
+	for _, i := range []messengertypes.Interaction{
+		{CID: "Qm0001", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_1", IsMe: true, SentDate: 1, Payload: searchTestUserMessage(t, "hello everyone")},
+		{CID: "Qm0002", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", SentDate: 2, Payload: searchTestUserMessage(t, "Hello Alice, how are you?")},
+		{CID: "Qm0003", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_2", SentDate: 3, Payload: searchTestUserMessage(t, "héllo from the other conversation")},
+		{CID: "Qm0004", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_2", IsMe: true, SentDate: 4, Payload: searchTestUserMessage(t, "")},
+		{CID: "Qm0005", Type: messengertypes.AppMessage_TypeSetUserInfo, ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", SentDate: 5},
+	} {
+		_, isNew, err := db.addInteraction(i)
+		require.NoError(t, err)
+		require.True(t, isNew)
+	}
+
+	results, next, err := db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0003", "Qm0002", "Qm0001"}, searchResultsCIDs(results))
+	require.Equal(t, "", next)
+	require.Equal(t, "<b>Hello</b> Alice, how are you?", results[1].Snippet)
+
+	// media filenames and prefixes
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "holi"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0004"}, searchResultsCIDs(results))
+	require.Len(t, results[0].Interaction.Medias, 1)
+
+	// display names of members, contacts and account
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "bob"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0002"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "charlie"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0003"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0004", "Qm0002", "Qm0001"}, searchResultsCIDs(results))
+
+	// filters
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", ConversationPublicKey: "conv_1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0002", "Qm0001"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", MemberPublicKey: "member_1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0002"}, searchResultsCIDs(results))
+
+	// the messages of the account and of the contacts have no member public key
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", MemberPublicKey: "member_me"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0001"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", MemberPublicKey: "contact_1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0003"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "holi", MemberPublicKey: "pk_account"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0004"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", SentAfter: 2, SentBefore: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0002"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "bob", Type: messengertypes.AppMessage_TypeGroupInvitation})
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	// pagination
+	results, next, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0003", "Qm0002"}, searchResultsCIDs(results))
+	require.Equal(t, "Qm0002", next)
+
+	results, next, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", Limit: 2, RefCID: next})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0001"}, searchResultsCIDs(results))
+	require.Equal(t, "", next)
+
+	_, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello", RefCID: "Qm_unknown"})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	// edits, renames and deletions are reflected in the index
+	updated, err := db.addUserMessageEdit("Qm0001", "Qm0006", searchTestUserMessage(t, "goodbye everyone"), 6)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	_, _, err = db.upsertMember("member_1", "conv_1", messengertypes.Member{DisplayName: "Robert"})
+	require.NoError(t, err)
+
+	deleted, err := db.markInteractionAsDeleted("Qm0003")
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0002"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "goodbye"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0001"}, searchResultsCIDs(results))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "robert"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0002"}, searchResultsCIDs(results))
+
+	require.NoError(t, db.deleteInteractions([]string{"Qm0002"}))
+
+	results, _, err = db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "robert"})
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func Test_dbWrapper_searchInteractionsSnippet(t *testing.T) {
+	db, dispose := getSearchTestDB(t)
+	defer dispose()
+
+	_, _, err := db.addInteraction(messengertypes.Interaction{CID: "Qm0001", Type: messengertypes.AppMessage_TypeUserMessage, SentDate: 1, Payload: searchTestUserMessage(t, "<i>hello</i> & \x02bye\x03")})
+	require.NoError(t, err)
+
+	// the text is escaped, only the matches are enclosed in tags
+	results, _, err := db.searchInteractions(&messengertypes.MessageSearch_Request{Query: "hello"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "&lt;i&gt;<b>hello</b>&lt;/i&gt; &amp; bye", results[0].Snippet)
+}
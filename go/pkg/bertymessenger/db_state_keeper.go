@@ -57,6 +57,31 @@ func keepAutoReplicateFlag(db *gorm.DB, logger *zap.Logger) bool {
 	return true
 }
 
+func keepDisableReadReceiptsFlag(db *gorm.DB, logger *zap.Logger) bool {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	result := int64(0)
+	count := int64(0)
+
+	if err := db.Table("accounts").Count(&count).Order("ROWID").Limit(1).Pluck("disable_read_receipts", &result).Error; err == nil {
+		if count != 1 {
+			logger.Warn("expected one result", zap.Int64("count", count))
+		}
+
+		if count > 0 {
+			return result != 0
+		}
+	} else {
+		logger.Warn("attempt at retrieving read receipts flag failed", zap.Error(err))
+	}
+
+	logger.Warn("nothing found returning a default value")
+
+	return false
+}
+
 func keepConversationsLocalData(db *gorm.DB, logger *zap.Logger) []*messengertypes.LocalConversationState {
 	if logger == nil {
 		logger = zap.NewNop()
@@ -107,5 +132,6 @@ func keepDatabaseLocalState(db *gorm.DB, logger *zap.Logger) *messengertypes.Loc
 		ReplicateFlag:           keepAutoReplicateFlag(db, logger),
 		LocalConversationsState: keepConversationsLocalData(db, logger),
 		AccountLink:             keepAccountStringField(db, "link", logger),
+		DisableReadReceipts:     keepDisableReadReceiptsFlag(db, logger),
 	}
 }
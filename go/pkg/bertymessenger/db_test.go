@@ -1055,11 +1055,11 @@ func Test_dbWrapper_getDBInfo(t *testing.T) {
 	require.Equal(t, int64(7), info.ServiceTokens)
 	require.Equal(t, int64(8), info.ConversationReplicationInfo)
 
-	// Ensure all tables are in the debug data
+	// Ensure all tables are in the debug data, the search index excepted
 	tables := []string(nil)
-	err = db.db.Raw("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'").Scan(&tables).Error
+	err = db.db.Raw("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name NOT GLOB ?", interactionsSearchTable+"*").Scan(&tables).Error
 	require.NoError(t, err)
-	require.Equal(t, 9, len(tables))
+	require.Equal(t, 11, len(tables))
 }
 
 func Test_dbWrapper_getMemberByPK(t *testing.T) {
@@ -1541,3 +1541,1037 @@ func Test_dbWrapper_getMedias_mix(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, testMedias, medias)
 }
+
+func Test_dbWrapper_getThumbnailMedia(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	testMedia := messengertypes.Media{
+		CID: "EiBnLu1b0PFzPcVd_QPPfhzIs0kmzAH2g0VUfiAqvIXMLg", InteractionCID: "testInteractionCID",
+		MimeType: "testMimeType", ThumbnailCID: "EiBnLu1b0PFzPcVd_QPPfhzIs1kmzAH2g0VUfiAqvIXMLg",
+	}
+
+	_, err := db.addMedias([]*messengertypes.Media{&testMedia})
+	require.NoError(t, err)
+
+	thumbnail, err := db.getThumbnailMedia(testMedia.ThumbnailCID)
+	require.NoError(t, err)
+	require.Equal(t, &messengertypes.Media{
+		CID: testMedia.ThumbnailCID, MimeType: mediaThumbnailMimeType, InteractionCID: testMedia.InteractionCID,
+	}, thumbnail)
+
+	thumbnail, err = db.getThumbnailMedia(testMedia.CID)
+	require.NoError(t, err)
+	require.Nil(t, thumbnail)
+}
+
+func Test_dbWrapper_updateMediaDownloadProgress(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	const (
+		receivedCID = "EiBnLu1b0PFzPcVd_QPPfhzIs0kmzAH2g0VUfiAqvIXMLg"
+		sentCID     = "EiBnLu1b0PFzPcVd_QPPfhzIs1kmzAH2g0VUfiAqvIXMLg"
+	)
+
+	_, _, err := db.updateMediaDownloadProgress(receivedCID, 0, 42, false)
+	require.Error(t, err)
+
+	_, err = db.addMedias([]*messengertypes.Media{
+		{CID: receivedCID, State: messengertypes.Media_StateNeverDownloaded},
+		{CID: sentCID, State: messengertypes.Media_StatePrepared},
+	})
+	require.NoError(t, err)
+
+	media, updated, err := db.updateMediaDownloadProgress(receivedCID, 0, 42, false)
+	require.NoError(t, err)
+	require.True(t, updated)
+	require.Equal(t, messengertypes.Media_StatePartiallyDownloaded, media.State)
+	require.Equal(t, uint64(42), media.DownloadedSize)
+
+	// progress never goes backward
+	_, updated, err = db.updateMediaDownloadProgress(receivedCID, 0, 10, false)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	// a range following a gap doesn't advance the progress, even if it reaches the end of the media
+	_, updated, err = db.updateMediaDownloadProgress(receivedCID, 50, 50, true)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	media, updated, err = db.updateMediaDownloadProgress(receivedCID, 42, 58, true)
+	require.NoError(t, err)
+	require.True(t, updated)
+	require.Equal(t, messengertypes.Media_StateDownloaded, media.State)
+	require.Equal(t, uint64(100), media.DownloadedSize)
+
+	medias, err := db.getMedias([]string{receivedCID})
+	require.NoError(t, err)
+	require.Equal(t, media, medias[0])
+
+	// sent medias are not updated
+	_, updated, err = db.updateMediaDownloadProgress(sentCID, 0, 42, true)
+	require.NoError(t, err)
+	require.False(t, updated)
+}
+
+func Test_dbWrapper_mediaStorage(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	const (
+		sentCID       = "EiBnLu1b0PFzPcVd_QPPfhzIs0kmzAH2g0VUfiAqvIXMLg"
+		receivedCID   = "EiBnLu1b0PFzPcVd_QPPfhzIs1kmzAH2g0VUfiAqvIXMLg"
+		avatarCID     = "EiBnLu1b0PFzPcVd_QPPfhzIs2kmzAH2g0VUfiAqvIXMLg"
+		orphanCID     = "EiBnLu1b0PFzPcVd_QPPfhzIs3kmzAH2g0VUfiAqvIXMLg"
+		recentCID     = "EiBnLu1b0PFzPcVd_QPPfhzIs4kmzAH2g0VUfiAqvIXMLg"
+		oldReceiveCID = "EiBnLu1b0PFzPcVd_QPPfhzIs5kmzAH2g0VUfiAqvIXMLg"
+		attachedCID   = "EiBnLu1b0PFzPcVd_QPPfhzIs6kmzAH2g0VUfiAqvIXMLg"
+	)
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "inte1", ConversationPublicKey: "conv1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "inte2", ConversationPublicKey: "conv2"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Contact{PublicKey: "contact1", AvatarCID: avatarCID}).Error)
+
+	_, err := db.addMedias([]*messengertypes.Media{
+		{CID: sentCID, InteractionCID: "inte1", State: messengertypes.Media_StatePrepared, Size: 100},
+		{CID: receivedCID, InteractionCID: "inte1", State: messengertypes.Media_StateDownloaded, Size: 1000, DownloadedSize: 1000, LastAccessDate: 20},
+		{CID: oldReceiveCID, InteractionCID: "inte2", State: messengertypes.Media_StatePartiallyDownloaded, Size: 1000, DownloadedSize: 10, LastAccessDate: 10},
+		{CID: avatarCID, State: messengertypes.Media_StateNeverDownloaded},
+		{CID: orphanCID, InteractionCID: "deleted", State: messengertypes.Media_StatePrepared, Size: 50},
+		{CID: recentCID, State: messengertypes.Media_StatePrepared, LastAccessDate: 100},
+	})
+	require.NoError(t, err)
+
+	usages, err := db.getMediaStorageUsage()
+	require.NoError(t, err)
+	require.Equal(t, []*messengertypes.MediaStorageUsage_ConversationUsage{
+		{ConversationPublicKey: "conv1", SentSize: 100, ReceivedSize: 1000, MediaCount: 2},
+		{ConversationPublicKey: "conv2", ReceivedSize: 10, MediaCount: 1},
+	}, usages)
+
+	size, err := db.getReceivedMediasSize()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1010), size)
+
+	medias, err := db.getLeastRecentlyUsedReceivedMedias(10)
+	require.NoError(t, err)
+	require.Len(t, medias, 2)
+	require.Equal(t, oldReceiveCID, medias[0].CID)
+	require.Equal(t, receivedCID, medias[1].CID)
+
+	// the medias being retrieved are not evicted
+	medias, err = db.getLeastRecentlyUsedReceivedMedias(10, oldReceiveCID)
+	require.NoError(t, err)
+	require.Len(t, medias, 1)
+	require.Equal(t, receivedCID, medias[0].CID)
+
+	require.NoError(t, db.resetMediaDownloadProgress(oldReceiveCID))
+	size, err = db.getReceivedMediasSize()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), size)
+
+	// the medias are referenced by every interaction they are attached to, references to deleted interactions are ignored
+	_, err = db.addMedias([]*messengertypes.Media{{CID: attachedCID, State: messengertypes.Media_StateAttached, Size: 10}})
+	require.NoError(t, err)
+	require.NoError(t, db.addMediaReferences("inte2", []*messengertypes.Media{{CID: attachedCID}}))
+	require.NoError(t, db.addMediaReferences("deleted", []*messengertypes.Media{{CID: orphanCID}}))
+
+	medias, err = db.getUnreferencedMedias(50)
+	require.NoError(t, err)
+	require.Len(t, medias, 1)
+	require.Equal(t, orphanCID, medias[0].CID)
+
+	require.NoError(t, db.markMediaAsAccessed(orphanCID, 60))
+	medias, err = db.getUnreferencedMedias(50)
+	require.NoError(t, err)
+	require.Empty(t, medias)
+
+	// the medias with local blocks, the evicted and never downloaded ones are skipped
+	cids, err := db.getLocalMediaCIDs(sentCID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{receivedCID, orphanCID, recentCID, attachedCID}, cids)
+
+	require.NoError(t, db.deleteMedias([]string{orphanCID}))
+	medias, err = db.getMedias([]string{orphanCID})
+	require.NoError(t, err)
+	require.Equal(t, messengertypes.Media_StateUnknown, medias[0].State)
+}
+
+func Test_dbWrapper_upsertReaction(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	updated, err := db.upsertReaction(messengertypes.Reaction{Emoji: "👍"})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	updated, err = db.upsertReaction(messengertypes.Reaction{TargetCID: "Qm0001"})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	// removing an unknown reaction is a noop
+	updated, err = db.upsertReaction(messengertypes.Reaction{TargetCID: "Qm0001", MemberPublicKey: "pk1", Emoji: "👍", State: false, StateDate: 10})
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	// older addition is discarded
+	updated, err = db.upsertReaction(messengertypes.Reaction{TargetCID: "Qm0001", MemberPublicKey: "pk1", Emoji: "👍", State: true, StateDate: 5})
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = db.upsertReaction(messengertypes.Reaction{TargetCID: "Qm0001", MemberPublicKey: "pk1", Emoji: "👍", State: true, StateDate: 20})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	// same state
+	updated, err = db.upsertReaction(messengertypes.Reaction{TargetCID: "Qm0001", MemberPublicKey: "pk1", Emoji: "👍", State: true, StateDate: 30})
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = db.upsertReaction(messengertypes.Reaction{TargetCID: "Qm0001", MemberPublicKey: "pk1", Emoji: "👍", State: false, StateDate: 40})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	count := int64(0)
+	require.NoError(t, db.db.Model(&messengertypes.Reaction{}).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+}
+
+func Test_dbWrapper_getReactionsViews(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	views, err := db.getReactionsViews()
+	require.NoError(t, err)
+	require.Empty(t, views)
+
+	for _, r := range []messengertypes.Reaction{
+		{TargetCID: "Qm0001", MemberPublicKey: "pk1", Emoji: "👍", State: true, StateDate: 1},
+		{TargetCID: "Qm0001", MemberPublicKey: "pk2", Emoji: "👍", State: true, StateDate: 2},
+		{TargetCID: "Qm0001", IsMine: true, Emoji: "🎉", State: true, StateDate: 3},
+		{TargetCID: "Qm0001", MemberPublicKey: "pk3", Emoji: "🎉", State: false, StateDate: 4},
+		{TargetCID: "Qm0002", MemberPublicKey: "pk1", Emoji: "👍", State: true, StateDate: 5},
+	} {
+		_, err := db.upsertReaction(r)
+		require.NoError(t, err)
+	}
+
+	views, err = db.getReactionsViews()
+	require.NoError(t, err)
+	require.Len(t, views, 2)
+	require.Equal(t, []*messengertypes.Interaction_ReactionView{
+		{Emoji: "👍", OwnState: false, Count: 2},
+		{Emoji: "🎉", OwnState: true, Count: 1},
+	}, views["Qm0001"])
+	require.Equal(t, []*messengertypes.Interaction_ReactionView{
+		{Emoji: "👍", OwnState: false, Count: 1},
+	}, views["Qm0002"])
+
+	views, err = db.getReactionsViews("Qm0002")
+	require.NoError(t, err)
+	require.Len(t, views, 1)
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0001"}).Error)
+	interaction, err := db.getInteractionByCID("Qm0001")
+	require.NoError(t, err)
+	require.Len(t, interaction.Reactions, 2)
+}
+
+func Test_dbWrapper_updateConversationInfo(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	updated, err := db.updateConversationInfo("", "name", "", 1, "Qm0001")
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	updated, err = db.updateConversationInfo("conv1", "name", "", 1, "Qm0001")
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	_, err = db.addConversation("conv1")
+	require.NoError(t, err)
+
+	updated, err = db.updateConversationInfo("conv1", "name1", "", 10, "Qm0002")
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	conv, err := db.getConversationByPK("conv1")
+	require.NoError(t, err)
+	require.Equal(t, "name1", conv.DisplayName)
+	require.Equal(t, int64(10), conv.InfoDate)
+
+	// older info is discarded
+	updated, err = db.updateConversationInfo("conv1", "name0", "", 5, "Qm0003")
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = db.updateConversationInfo("conv1", "name2", "avatar2", 20, "Qm0005")
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	conv, err = db.getConversationByPK("conv1")
+	require.NoError(t, err)
+	require.Equal(t, "name2", conv.DisplayName)
+	require.Equal(t, "avatar2", conv.AvatarCID)
+	require.Equal(t, int64(20), conv.InfoDate)
+
+	// the updates sent at the same date are ordered by their cid, whatever the order they are received in
+	updated, err = db.updateConversationInfo("conv1", "name3", "", 20, "Qm0004")
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = db.updateConversationInfo("conv1", "name4", "", 20, "Qm0006")
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	conv, err = db.getConversationByPK("conv1")
+	require.NoError(t, err)
+	require.Equal(t, "name4", conv.DisplayName)
+	require.Equal(t, "Qm0006", conv.InfoCID)
+}
+
+func Test_dbWrapper_isMemberAdmin(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	isAdmin, err := db.isMemberAdmin("member1", "conv1")
+	require.NoError(t, err)
+	require.False(t, isAdmin)
+
+	_, err = db.addMember("member1", "conv1", "", "", false, true)
+	require.NoError(t, err)
+
+	_, err = db.addMember("member2", "conv1", "", "", false, false)
+	require.NoError(t, err)
+
+	isAdmin, err = db.isMemberAdmin("member1", "conv1")
+	require.NoError(t, err)
+	require.True(t, isAdmin)
+
+	isAdmin, err = db.isMemberAdmin("member2", "conv1")
+	require.NoError(t, err)
+	require.False(t, isAdmin)
+
+	isAdmin, err = db.isMemberAdmin("member1", "conv2")
+	require.NoError(t, err)
+	require.False(t, isAdmin)
+}
+
+func Test_dbWrapper_setMemberAdminRole(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	_, err := db.setMemberAdminRole("", "conv1", true)
+	require.Error(t, err)
+
+	updated, err := db.setMemberAdminRole("member1", "conv1", true)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	_, err = db.addMember("member1", "conv1", "", "", false, false)
+	require.NoError(t, err)
+
+	updated, err = db.setMemberAdminRole("member1", "conv1", true)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	isAdmin, err := db.isMemberAdmin("member1", "conv1")
+	require.NoError(t, err)
+	require.True(t, isAdmin)
+
+	updated, err = db.setMemberAdminRole("member1", "conv1", true)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = db.setMemberAdminRole("member1", "conv1", false)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	isAdmin, err = db.isMemberAdmin("member1", "conv1")
+	require.NoError(t, err)
+	require.False(t, isAdmin)
+}
+
+func Test_dbWrapper_popAdminRoleBacklog(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	require.Error(t, db.addAdminRoleBacklog(messengertypes.AdminRoleBacklog{CID: "Qm0001", ConversationPublicKey: "conv1"}))
+
+	require.NoError(t, db.addAdminRoleBacklog(messengertypes.AdminRoleBacklog{CID: "Qm0002", ConversationPublicKey: "conv1", DevicePublicKey: "device1", MemberPublicKey: "member2", IsAdmin: true}))
+	require.NoError(t, db.addAdminRoleBacklog(messengertypes.AdminRoleBacklog{CID: "Qm0001", ConversationPublicKey: "conv1", DevicePublicKey: "device1", MemberPublicKey: "member2", IsAdmin: false}))
+	require.NoError(t, db.addAdminRoleBacklog(messengertypes.AdminRoleBacklog{CID: "Qm0003", ConversationPublicKey: "conv2", DevicePublicKey: "device1", MemberPublicKey: "member2", IsAdmin: true}))
+
+	// the same event is only stored once
+	require.NoError(t, db.addAdminRoleBacklog(messengertypes.AdminRoleBacklog{CID: "Qm0002", ConversationPublicKey: "conv1", DevicePublicKey: "device1", MemberPublicKey: "member2", IsAdmin: true}))
+
+	_, err := db.popAdminRoleBacklog("", "conv1")
+	require.Error(t, err)
+
+	// the events are returned in the order they were received
+	backlog, err := db.popAdminRoleBacklog("device1", "conv1")
+	require.NoError(t, err)
+	require.Len(t, backlog, 2)
+	require.Equal(t, "Qm0002", backlog[0].GetCID())
+	require.Equal(t, "Qm0001", backlog[1].GetCID())
+
+	backlog, err = db.popAdminRoleBacklog("device1", "conv1")
+	require.NoError(t, err)
+	require.Empty(t, backlog)
+
+	backlog, err = db.popAdminRoleBacklog("device1", "conv2")
+	require.NoError(t, err)
+	require.Len(t, backlog, 1)
+}
+
+func Test_dbWrapper_markDeviceAsRevoked(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	_, err := db.markDeviceAsRevoked("")
+	require.Error(t, err)
+
+	updated, err := db.markDeviceAsRevoked("device1")
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	_, err = db.addDevice("device1", "member1")
+	require.NoError(t, err)
+
+	updated, err = db.markDeviceAsRevoked("device1")
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	device, err := db.getDeviceByPK("device1")
+	require.NoError(t, err)
+	require.True(t, device.Revoked)
+
+	updated, err = db.markDeviceAsRevoked("device1")
+	require.NoError(t, err)
+	require.False(t, updated)
+}
+
+func Test_dbWrapper_addUserMessageEdit(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	updated, err := db.addUserMessageEdit("", "Qm0002", []byte("edit"), 2)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	updated, err = db.addUserMessageEdit("Qm0001", "", []byte("edit"), 2)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	updated, err = db.addUserMessageEdit("Qm0001", "Qm0002", []byte("edit"), 2)
+	require.Equal(t, gorm.ErrRecordNotFound, err)
+	require.False(t, updated)
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0001", Type: messengertypes.AppMessage_TypeUserMessage, Payload: []byte("original"), SentDate: 1}).Error)
+
+	updated, err = db.addUserMessageEdit("Qm0001", "Qm0003", []byte("edit2"), 3)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	// older edits are kept in history only
+	updated, err = db.addUserMessageEdit("Qm0001", "Qm0002", []byte("edit1"), 2)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	interaction, err := db.getInteractionByCID("Qm0001")
+	require.NoError(t, err)
+	require.Equal(t, []byte("edit2"), interaction.Payload)
+	require.Equal(t, int64(3), interaction.EditedDate)
+
+	edits, err := db.getUserMessageEdits("Qm0001")
+	require.NoError(t, err)
+	require.Len(t, edits, 3)
+	require.Equal(t, []byte("original"), edits[0].Payload)
+	require.Equal(t, []byte("edit1"), edits[1].Payload)
+	require.Equal(t, []byte("edit2"), edits[2].Payload)
+}
+
+func Test_dbWrapper_markInteractionAsDeleted(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	deleted, err := db.markInteractionAsDeleted("")
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, deleted)
+
+	deleted, err = db.markInteractionAsDeleted("Qm0001")
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0001", Type: messengertypes.AppMessage_TypeUserMessage, Payload: []byte("original"), SentDate: 1}).Error)
+
+	_, err = db.addUserMessageEdit("Qm0001", "Qm0002", []byte("edit"), 2)
+	require.NoError(t, err)
+
+	deleted, err = db.markInteractionAsDeleted("Qm0001")
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	deleted, err = db.markInteractionAsDeleted("Qm0001")
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	interaction, err := db.getInteractionByCID("Qm0001")
+	require.NoError(t, err)
+	require.True(t, interaction.IsDeleted)
+	require.Empty(t, interaction.Payload)
+
+	edits, err := db.getUserMessageEdits("Qm0001")
+	require.NoError(t, err)
+	require.Empty(t, edits)
+
+	// edits are ignored once deleted
+	updated, err := db.addUserMessageEdit("Qm0001", "Qm0003", []byte("edit"), 3)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	interactions, err := db.getAllInteractions()
+	require.NoError(t, err)
+	require.Empty(t, interactions)
+}
+
+func Test_dbWrapper_getUserMessageChangesForInteraction(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	_, err := db.getUserMessageChangesForInteraction("")
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0002", Type: messengertypes.AppMessage_TypeDeleteUserMessage, TargetCID: "Qm0001", SentDate: 3}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0003", Type: messengertypes.AppMessage_TypeEditUserMessage, TargetCID: "Qm0001", SentDate: 2}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0004", Type: messengertypes.AppMessage_TypeAcknowledge, TargetCID: "Qm0001", SentDate: 1}).Error)
+
+	changes, err := db.getUserMessageChangesForInteraction("Qm0001")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, "Qm0003", changes[0].CID)
+	require.Equal(t, "Qm0002", changes[1].CID)
+}
+
+func Test_dbWrapper_getPaginatedInteractions(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	_, _, err := db.getPaginatedInteractions("", "", 0, false, 10)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	_, _, err = db.getPaginatedInteractions("conv_1", "", 0, false, 0)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	for i, sentDate := range []int64{1, 2, 3, 3, 4, 5} {
+		require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: fmt.Sprintf("Qm000%d", i+1), ConversationPublicKey: "conv_1", SentDate: sentDate}).Error)
+	}
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0007", ConversationPublicKey: "conv_1", SentDate: 6, IsDeleted: true}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0008", ConversationPublicKey: "conv_2", SentDate: 7}).Error)
+
+	cids := func(interactions []*messengertypes.Interaction) []string {
+		ret := []string{}
+		for _, i := range interactions {
+			ret = append(ret, i.CID)
+		}
+		return ret
+	}
+
+	interactions, hasMore, err := db.getPaginatedInteractions("conv_1", "", 0, false, 4)
+	require.NoError(t, err)
+	require.True(t, hasMore)
+	require.Equal(t, []string{"Qm0003", "Qm0004", "Qm0005", "Qm0006"}, cids(interactions))
+
+	interactions, hasMore, err = db.getPaginatedInteractions("conv_1", "Qm0003", 0, false, 4)
+	require.NoError(t, err)
+	require.False(t, hasMore)
+	require.Equal(t, []string{"Qm0001", "Qm0002"}, cids(interactions))
+
+	interactions, hasMore, err = db.getPaginatedInteractions("conv_1", "", 0, true, 3)
+	require.NoError(t, err)
+	require.True(t, hasMore)
+	require.Equal(t, []string{"Qm0001", "Qm0002", "Qm0003"}, cids(interactions))
+
+	interactions, hasMore, err = db.getPaginatedInteractions("conv_1", "Qm0003", 0, true, 3)
+	require.NoError(t, err)
+	require.False(t, hasMore)
+	require.Equal(t, []string{"Qm0004", "Qm0005", "Qm0006"}, cids(interactions))
+
+	interactions, hasMore, err = db.getPaginatedInteractions("conv_1", "", 3, false, 10)
+	require.NoError(t, err)
+	require.False(t, hasMore)
+	require.Equal(t, []string{"Qm0001", "Qm0002"}, cids(interactions))
+
+	interactions, hasMore, err = db.getPaginatedInteractions("conv_1", "", 3, true, 10)
+	require.NoError(t, err)
+	require.False(t, hasMore)
+	require.Equal(t, []string{"Qm0005", "Qm0006"}, cids(interactions))
+
+	// the anchor must belong to the conversation
+	_, _, err = db.getPaginatedInteractions("conv_1", "Qm0008", 0, false, 10)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+}
+
+func Test_dbWrapper_getLatestInteractionsPerConversation(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	interactions, err := db.getLatestInteractionsPerConversation(2)
+	require.NoError(t, err)
+	require.Empty(t, interactions)
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0001", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_1", SentDate: 1}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0002", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_1", SentDate: 2}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0003", Type: messengertypes.AppMessage_TypeGroupInvitation, ConversationPublicKey: "conv_1", SentDate: 3}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0004", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_1", SentDate: 4, IsDeleted: true}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0005", Type: messengertypes.AppMessage_TypeUserMessage, ConversationPublicKey: "conv_2", SentDate: 5}).Error)
+
+	// the interactions waiting in the backlog are not displayed, they are not counted
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0006", Type: messengertypes.AppMessage_TypeAcknowledge, ConversationPublicKey: "conv_1", TargetCID: "Qm_unknown", SentDate: 6}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0007", Type: messengertypes.AppMessage_TypeUserReaction, ConversationPublicKey: "conv_2", TargetCID: "Qm_unknown", SentDate: 7}).Error)
+
+	interactions, err = db.getLatestInteractionsPerConversation(0)
+	require.NoError(t, err)
+	require.Empty(t, interactions)
+
+	interactions, err = db.getLatestInteractionsPerConversation(2)
+	require.NoError(t, err)
+	require.Len(t, interactions, 3)
+	require.Equal(t, "Qm0002", interactions[0].CID)
+	require.Equal(t, "Qm0003", interactions[1].CID)
+	require.Equal(t, "Qm0005", interactions[2].CID)
+}
+
+func Test_dbWrapper_updateConversationMessageRetention(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	_, err := db.updateConversationMessageRetention("", 60, 1)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	_, err = db.updateConversationMessageRetention("conv_1", -1, 1)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	updated, err := db.updateConversationMessageRetention("conv_1", 60, 1)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_1"}).Error)
+
+	updated, err = db.updateConversationMessageRetention("conv_1", 60, 2)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	// older settings are ignored
+	updated, err = db.updateConversationMessageRetention("conv_1", 120, 1)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	conv, err := db.getConversationByPK("conv_1")
+	require.NoError(t, err)
+	require.Equal(t, int64(60), conv.MessageRetention)
+	require.Equal(t, int64(2), conv.MessageRetentionDate)
+
+	// retention can be disabled
+	updated, err = db.updateConversationMessageRetention("conv_1", 0, 3)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	conv, err = db.getConversationByPK("conv_1")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), conv.MessageRetention)
+	require.Equal(t, int64(3), conv.MessageRetentionDate)
+}
+
+func Test_dbWrapper_getExpiredInteractionsCIDs(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	now := time.Now()
+	nowMs := timestampMs(now)
+
+	_, err := db.getExpiredInteractionsCIDs(now, 0)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_1", MessageRetention: 60}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_2"}).Error)
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0001", ConversationPublicKey: "conv_1", SentDate: nowMs - 120*1000}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0002", ConversationPublicKey: "conv_1", SentDate: nowMs - 60*1000}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0003", ConversationPublicKey: "conv_1", SentDate: nowMs - 30*1000}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0004", ConversationPublicKey: "conv_2", SentDate: nowMs - 120*1000}).Error)
+
+	cids, err := db.getExpiredInteractionsCIDs(now, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0001", "Qm0002"}, cids)
+
+	cids, err = db.getExpiredInteractionsCIDs(now, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Qm0001"}, cids)
+}
+
+func Test_dbWrapper_purgeInteractions(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	_, err := db.purgeInteractions(nil)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0001", ConversationPublicKey: "conv_1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Interaction{CID: "Qm0002", ConversationPublicKey: "conv_1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Media{CID: "Qm_media_1", InteractionCID: "Qm0001"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Media{CID: "Qm_media_2", InteractionCID: "Qm0002"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Reaction{TargetCID: "Qm0001", MemberPublicKey: "member_1", Emoji: "👍", State: true}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.UserMessageEdit{CID: "Qm0003", TargetCID: "Qm0001"}).Error)
+
+	// a media attached to both interactions is kept
+	require.NoError(t, db.db.Create(&messengertypes.Media{CID: "Qm_media_3", InteractionCID: "Qm0001"}).Error)
+	require.NoError(t, db.addMediaReferences("Qm0001", []*messengertypes.Media{{CID: "Qm_media_1"}, {CID: "Qm_media_3"}}))
+	require.NoError(t, db.addMediaReferences("Qm0002", []*messengertypes.Media{{CID: "Qm_media_2"}, {CID: "Qm_media_3"}}))
+
+	medias, err := db.purgeInteractions([]string{"Qm0001"})
+	require.NoError(t, err)
+	require.Len(t, medias, 1)
+	require.Equal(t, "Qm_media_1", medias[0].CID)
+
+	var count int64
+	require.NoError(t, db.db.Model(&messengertypes.Interaction{}).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+
+	require.NoError(t, db.db.Model(&messengertypes.Media{}).Count(&count).Error)
+	require.Equal(t, int64(2), count)
+
+	require.NoError(t, db.db.Model(&messengertypes.MediaReference{}).Count(&count).Error)
+	require.Equal(t, int64(2), count)
+
+	shared := &messengertypes.Media{}
+	require.NoError(t, db.db.Where("cid = ?", "Qm_media_3").First(shared).Error)
+	require.Equal(t, "Qm0002", shared.InteractionCID)
+
+	require.NoError(t, db.db.Model(&messengertypes.Reaction{}).Count(&count).Error)
+	require.Equal(t, int64(0), count)
+
+	require.NoError(t, db.db.Model(&messengertypes.UserMessageEdit{}).Count(&count).Error)
+	require.Equal(t, int64(0), count)
+}
+
+func Test_dbWrapper_upsertReadReceipt(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	updated, err := db.upsertReadReceipt(messengertypes.ReadReceipt{LastReadCID: "Qm0001"})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	updated, err = db.upsertReadReceipt(messengertypes.ReadReceipt{ConversationPublicKey: "conv_1"})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	updated, err = db.upsertReadReceipt(messengertypes.ReadReceipt{ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", LastReadCID: "Qm0002", ReadDate: 20})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	// older receipt is discarded
+	updated, err = db.upsertReadReceipt(messengertypes.ReadReceipt{ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", LastReadCID: "Qm0001", ReadDate: 10})
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	// same interaction
+	updated, err = db.upsertReadReceipt(messengertypes.ReadReceipt{ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", LastReadCID: "Qm0002", ReadDate: 30})
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = db.upsertReadReceipt(messengertypes.ReadReceipt{ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", LastReadCID: "Qm0003", ReadDate: 40})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	updated, err = db.upsertReadReceipt(messengertypes.ReadReceipt{ConversationPublicKey: "conv_1", IsMine: true, LastReadCID: "Qm0003", ReadDate: 50})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	own, err := db.getOwnReadReceipt("conv_1")
+	require.NoError(t, err)
+	require.Equal(t, "Qm0003", own.LastReadCID)
+
+	count := int64(0)
+	require.NoError(t, db.db.Model(&messengertypes.ReadReceipt{}).Count(&count).Error)
+	require.Equal(t, int64(2), count)
+}
+
+func Test_dbWrapper_attachReadBy(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_1", Type: messengertypes.Conversation_MultiMemberType}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_2", Type: messengertypes.Conversation_ContactType, ContactPublicKey: "contact_1"}).Error)
+
+	for _, i := range []*messengertypes.Interaction{
+		{CID: "Qm0001", ConversationPublicKey: "conv_1", IsMe: true, SentDate: 1},
+		{CID: "Qm0002", ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", SentDate: 2},
+		{CID: "Qm0003", ConversationPublicKey: "conv_1", MemberPublicKey: "member_2", SentDate: 3},
+		{CID: "Qm0004", ConversationPublicKey: "conv_1", IsMe: true, SentDate: 4},
+		{CID: "Qm0005", ConversationPublicKey: "conv_2", IsMe: true, SentDate: 5},
+		{CID: "Qm0006", ConversationPublicKey: "conv_2", SentDate: 6},
+	} {
+		require.NoError(t, db.db.Create(i).Error)
+	}
+
+	last, err := db.getLastReceivedInteraction("conv_1")
+	require.NoError(t, err)
+	require.Equal(t, "Qm0003", last.CID)
+
+	for _, r := range []messengertypes.ReadReceipt{
+		{ConversationPublicKey: "conv_1", MemberPublicKey: "member_1", LastReadCID: "Qm0003", ReadDate: 10},
+		{ConversationPublicKey: "conv_1", MemberPublicKey: "member_2", LastReadCID: "Qm0004", ReadDate: 11},
+		{ConversationPublicKey: "conv_1", IsMine: true, LastReadCID: "Qm0003", ReadDate: 12},
+		{ConversationPublicKey: "conv_2", LastReadCID: "Qm0005", ReadDate: 13},
+		{ConversationPublicKey: "conv_2", IsMine: true, LastReadCID: "Qm0006", ReadDate: 14},
+	} {
+		_, err := db.upsertReadReceipt(r)
+		require.NoError(t, err)
+	}
+
+	interactions, err := db.getAllInteractions()
+	require.NoError(t, err)
+
+	readBy := map[string][]string{}
+	for _, i := range interactions {
+		readBy[i.CID] = i.ReadBy
+	}
+
+	require.ElementsMatch(t, []string{"member_1", "member_2"}, readBy["Qm0001"])
+	require.ElementsMatch(t, []string{"member_2"}, readBy["Qm0002"])
+	require.ElementsMatch(t, []string{"member_1"}, readBy["Qm0003"])
+	require.ElementsMatch(t, []string{"member_2"}, readBy["Qm0004"])
+	require.ElementsMatch(t, []string{"contact_1"}, readBy["Qm0005"])
+	require.Empty(t, readBy["Qm0006"])
+}
+
+func Test_dbWrapper_getThreadReplies(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	_, err := db.getThreadReplies("", "Qm0001")
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	_, err = db.getThreadReplies("conv_1", "")
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	for _, i := range []*messengertypes.Interaction{
+		{CID: "Qm0001", ConversationPublicKey: "conv_1", SentDate: 1},
+		{CID: "Qm0002", ConversationPublicKey: "conv_1", SentDate: 3, ReplyToCID: "Qm0001", ThreadRootCID: "Qm0001"},
+		{CID: "Qm0003", ConversationPublicKey: "conv_1", SentDate: 2, ReplyToCID: "Qm0001", ThreadRootCID: "Qm0001"},
+		{CID: "Qm0004", ConversationPublicKey: "conv_1", SentDate: 4, ReplyToCID: "Qm0002", ThreadRootCID: "Qm0001", IsDeleted: true},
+		{CID: "Qm0005", ConversationPublicKey: "conv_1", SentDate: 5, ReplyToCID: "Qm0004", ThreadRootCID: "Qm0001"},
+		{CID: "Qm0006", ConversationPublicKey: "conv_1", SentDate: 6, ReplyToCID: "Qm0001"},
+		{CID: "Qm0007", ConversationPublicKey: "conv_2", SentDate: 7, ThreadRootCID: "Qm0001"},
+		{CID: "Qm0008", ConversationPublicKey: "conv_2", SentDate: 8, ReplyToCID: "Qm0001"},
+	} {
+		require.NoError(t, db.db.Create(i).Error)
+	}
+
+	replies, err := db.getThreadReplies("conv_1", "Qm0001")
+	require.NoError(t, err)
+	require.Len(t, replies, 3)
+	require.Equal(t, "Qm0003", replies[0].CID)
+	require.Equal(t, "Qm0002", replies[1].CID)
+	require.Equal(t, "Qm0005", replies[2].CID)
+
+	require.NotNil(t, replies[0].ReplyTo)
+	require.Equal(t, "Qm0001", replies[0].ReplyTo.CID)
+
+	// deleted quoted interactions are returned as tombstones
+	require.NotNil(t, replies[2].ReplyTo)
+	require.True(t, replies[2].ReplyTo.IsDeleted)
+
+	// quoted interactions are attached outside of threads too
+	i, err := db.getInteractionByCID("Qm0006")
+	require.NoError(t, err)
+	require.NotNil(t, i.ReplyTo)
+	require.Equal(t, "Qm0001", i.ReplyTo.CID)
+
+	// interactions of other conversations can't be quoted
+	i, err = db.getInteractionByCID("Qm0008")
+	require.NoError(t, err)
+	require.Nil(t, i.ReplyTo)
+
+	replies, err = db.getThreadReplies("conv_1", "Qm0002")
+	require.NoError(t, err)
+	require.Empty(t, replies)
+}
+
+func Test_dbWrapper_upsertPinnedMessage(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	updated, err := db.upsertPinnedMessage(messengertypes.PinnedMessage{TargetCID: "Qm0001"})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	updated, err = db.upsertPinnedMessage(messengertypes.PinnedMessage{ConversationPublicKey: "conv_1"})
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+	require.False(t, updated)
+
+	// unpinning an unknown interaction is a noop
+	updated, err = db.upsertPinnedMessage(messengertypes.PinnedMessage{ConversationPublicKey: "conv_1", TargetCID: "Qm0001", Pinned: false, StateDate: 10})
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	// older state is discarded
+	updated, err = db.upsertPinnedMessage(messengertypes.PinnedMessage{ConversationPublicKey: "conv_1", TargetCID: "Qm0001", Pinned: true, StateDate: 5})
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = db.upsertPinnedMessage(messengertypes.PinnedMessage{ConversationPublicKey: "conv_1", TargetCID: "Qm0001", Pinned: true, MemberPublicKey: "member_1", StateDate: 20})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	updated, err = db.upsertPinnedMessage(messengertypes.PinnedMessage{ConversationPublicKey: "conv_1", TargetCID: "Qm0002", Pinned: true, IsMine: true, StateDate: 30})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	updated, err = db.upsertPinnedMessage(messengertypes.PinnedMessage{ConversationPublicKey: "conv_1", TargetCID: "Qm0002", Pinned: false, StateDate: 40})
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv_1"}).Error)
+
+	conv, err := db.getConversationByPK("conv_1")
+	require.NoError(t, err)
+	require.Len(t, conv.PinnedMessages, 1)
+	require.Equal(t, "Qm0001", conv.PinnedMessages[0].TargetCID)
+	require.Equal(t, "member_1", conv.PinnedMessages[0].MemberPublicKey)
+}
+
+func Test_dbWrapper_getPinnedAndStarredInteractions(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	for _, i := range []*messengertypes.Interaction{
+		{CID: "Qm0001", ConversationPublicKey: "conv_1", SentDate: 2},
+		{CID: "Qm0002", ConversationPublicKey: "conv_1", SentDate: 1},
+		{CID: "Qm0003", ConversationPublicKey: "conv_1", SentDate: 3, IsDeleted: true},
+		{CID: "Qm0004", ConversationPublicKey: "conv_2", SentDate: 4},
+	} {
+		require.NoError(t, db.db.Create(i).Error)
+	}
+
+	for _, p := range []messengertypes.PinnedMessage{
+		{ConversationPublicKey: "conv_1", TargetCID: "Qm0001", Pinned: true, StateDate: 1},
+		{ConversationPublicKey: "conv_1", TargetCID: "Qm0002", Pinned: true, StateDate: 2},
+		{ConversationPublicKey: "conv_1", TargetCID: "Qm0003", Pinned: true, StateDate: 3},
+		// pinned from another conversation
+		{ConversationPublicKey: "conv_1", TargetCID: "Qm0004", Pinned: true, StateDate: 4},
+	} {
+		_, err := db.upsertPinnedMessage(p)
+		require.NoError(t, err)
+	}
+
+	_, err := db.getPinnedInteractions("")
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	pinned, err := db.getPinnedInteractions("conv_1")
+	require.NoError(t, err)
+	require.Len(t, pinned, 2)
+	require.Equal(t, "Qm0002", pinned[0].CID)
+	require.Equal(t, "Qm0001", pinned[1].CID)
+
+	for _, s := range []messengertypes.StarredMessage{
+		{ConversationPublicKey: "conv_1", TargetCID: "Qm0001", Starred: true, StateDate: 1},
+		{ConversationPublicKey: "conv_1", TargetCID: "Qm0002", Starred: false, StateDate: 2},
+		{ConversationPublicKey: "conv_2", TargetCID: "Qm0004", Starred: true, StateDate: 3},
+	} {
+		_, err := db.upsertStarredMessage(s)
+		require.NoError(t, err)
+	}
+
+	starred, err := db.getStarredInteractions("conv_1")
+	require.NoError(t, err)
+	require.Len(t, starred, 1)
+	require.Equal(t, "Qm0001", starred[0].CID)
+
+	starred, err = db.getStarredInteractions("")
+	require.NoError(t, err)
+	require.Len(t, starred, 2)
+	require.Equal(t, "Qm0001", starred[0].CID)
+	require.Equal(t, "Qm0004", starred[1].CID)
+}
+
+func Test_dbWrapper_updateConversationReplicationStatus(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.ConversationReplicationInfo{CID: "cid_1", ConversationPublicKey: "conv1", ReplicationServer: "server1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.ConversationReplicationInfo{CID: "cid_2", ConversationPublicKey: "conv1", ReplicationServer: "server2"}).Error)
+
+	status := &protocoltypes.ReplicationGroupStatus{MetadataEntries: 3, MessageEntries: 12}
+
+	updated, err := db.updateConversationReplicationStatus("conv1", "server1", status, 42)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), updated)
+
+	updated, err = db.updateConversationReplicationStatus("conv1", "unknown", status, 42)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), updated)
+
+	conv, err := db.getConversationByPK("conv1")
+	require.NoError(t, err)
+	require.Len(t, conv.ReplicationInfo, 2)
+
+	for _, info := range conv.ReplicationInfo {
+		if info.ReplicationServer == "server1" {
+			require.Equal(t, uint64(3), info.MetadataEntries)
+			require.Equal(t, uint64(12), info.MessageEntries)
+			require.Equal(t, int64(42), info.StatusDate)
+		} else {
+			require.Zero(t, info.MetadataEntries)
+			require.Zero(t, info.StatusDate)
+		}
+	}
+}
+
+func Test_dbWrapper_deleteConversationReplicationInfo(t *testing.T) {
+	db, dispose := getInMemoryTestDB(t)
+	defer dispose()
+
+	require.NoError(t, db.db.Create(&messengertypes.Conversation{PublicKey: "conv1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.ConversationReplicationInfo{CID: "cid_1", ConversationPublicKey: "conv1", ReplicationServer: "server1"}).Error)
+	require.NoError(t, db.db.Create(&messengertypes.ConversationReplicationInfo{CID: "cid_2", ConversationPublicKey: "conv1", ReplicationServer: "server2"}).Error)
+
+	deleted, err := db.deleteConversationReplicationInfo("conv1", "unknown")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), deleted)
+
+	deleted, err = db.deleteConversationReplicationInfo("conv1", "server1")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	conv, err := db.getConversationByPK("conv1")
+	require.NoError(t, err)
+	require.Len(t, conv.ReplicationInfo, 1)
+	require.Equal(t, "server2", conv.ReplicationInfo[0].ReplicationServer)
+}
@@ -40,7 +40,8 @@ func ensureSeamlessDBUpdate(db *gorm.DB, models []interface{}) error {
 
 func dropAllTables(db *gorm.DB) error {
 	tables := []string(nil)
-	if err := db.Raw("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'").Scan(&tables).Error; err != nil {
+	// shadow tables of the search index are dropped along with it
+	if err := db.Raw("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name NOT GLOB ?", interactionsSearchTable+"_*").Scan(&tables).Error; err != nil {
 		return errcode.ErrDBRead.Wrap(err)
 	}
 
@@ -115,6 +116,7 @@ func restoreDatabaseLocalState(db *dbWrapper, state *messengertypes.LocalDatabas
 			"display_name":                       state.DisplayName,
 			"link":                               state.AccountLink,
 			"replicate_new_groups_automatically": state.ReplicateFlag,
+			"disable_read_receipts":              state.DisableReadReceipts,
 		}); res.Error != nil {
 		return errcode.ErrInternal.Wrap(fmt.Errorf("unable to update account: %w", res.Error))
 	} else if res.RowsAffected == 0 {
@@ -147,7 +149,8 @@ func getDBTablesSchemas(db *gorm.DB) (map[string][]*ColumnInfo, error) {
 	schemas := map[string][]*ColumnInfo{}
 	tableNamesAndSQL := []NameSQL{}
 
-	err := db.Raw("SELECT name, sql FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%';").Scan(&tableNamesAndSQL).Error
+	// the search index is not a gorm model and is handled by initSearchIndex
+	err := db.Raw("SELECT name, sql FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name NOT GLOB ?;", interactionsSearchTable+"*").Scan(&tableNamesAndSQL).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,181 @@
+package bertymessenger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+// ConversationEphemeralStream sends and receives non-persistent events such as typing indicators and presence,
+// each request subscribes the stream to its conversation and broadcasts its event if set
+func (svc *service) ConversationEphemeralStream(stream messengertypes.MessengerService_ConversationEphemeralStreamServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	var (
+		sendLock   sync.Mutex
+		subsLock   sync.Mutex
+		subscribed = map[string]bool{}
+		reqs       = make(chan *messengertypes.ConversationEphemeralStream_Request)
+		recvErr    = make(chan error, 1)
+	)
+
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	subscribe := func(conv *messengertypes.Conversation, groupPK []byte) error {
+		sub, err := svc.protocolClient.GroupEphemeralSubscribe(ctx, &protocoltypes.GroupEphemeralSubscribe_Request{GroupPK: groupPK})
+		if err != nil {
+			return errcode.ErrIPFSPubSubSubscribe.Wrap(err)
+		}
+
+		go func() {
+			defer func() {
+				subsLock.Lock()
+				delete(subscribed, conv.GetPublicKey())
+				subsLock.Unlock()
+			}()
+
+			for {
+				evt, err := sub.Recv()
+				if err != nil {
+					if ctx.Err() == nil {
+						svc.logger.Warn("ephemeral subscription ended", zap.String("conv", conv.GetPublicKey()), zap.Error(err))
+					}
+					return
+				}
+
+				event, err := ephemeralEventFromGroupEvent(conv, evt)
+				if err != nil {
+					svc.logger.Debug("ignoring invalid ephemeral event", zap.String("conv", conv.GetPublicKey()), zap.Error(err))
+					continue
+				}
+
+				sendLock.Lock()
+				err = stream.Send(&messengertypes.ConversationEphemeralStream_Reply{Event: event})
+				sendLock.Unlock()
+				if err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+
+		return nil
+	}
+
+	for {
+		var req *messengertypes.ConversationEphemeralStream_Request
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-recvErr:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case req = <-reqs:
+		}
+
+		pk := req.GetConversationPublicKey()
+		if pk == "" {
+			return errcode.ErrMissingInput
+		}
+
+		groupPK, err := b64DecodeBytes(pk)
+		if err != nil {
+			return errcode.ErrInvalidInput.Wrap(err)
+		}
+
+		conv, err := svc.db.getConversationByPK(pk)
+		if err != nil {
+			return errcode.ErrDBRead.Wrap(err)
+		}
+
+		subsLock.Lock()
+		isSubscribed := subscribed[pk]
+		subscribed[pk] = true
+		subsLock.Unlock()
+
+		if !isSubscribed {
+			if err := subscribe(conv, groupPK); err != nil {
+				return err
+			}
+		}
+
+		if req.GetEvent() == nil {
+			continue
+		}
+
+		payload, err := ephemeralEventPayload(req.GetEvent(), time.Now())
+		if err != nil {
+			return err
+		}
+
+		if _, err := svc.protocolClient.GroupEphemeralSend(ctx, &protocoltypes.GroupEphemeralSend_Request{GroupPK: groupPK, Payload: payload}); err != nil {
+			return errcode.ErrProtocolSend.Wrap(err)
+		}
+	}
+}
+
+// ephemeralEventPayload returns the serialized event broadcast to the conversation members,
+// the sender related fields are filled by the receivers
+func ephemeralEventPayload(event *messengertypes.EphemeralEvent, now time.Time) ([]byte, error) {
+	switch event.GetType() {
+	case messengertypes.EphemeralEvent_TypeTyping, messengertypes.EphemeralEvent_TypePresence, messengertypes.EphemeralEvent_TypeHeartbeat:
+	default:
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unsupported ephemeral event type: %s", event.GetType()))
+	}
+
+	payload, err := proto.Marshal(&messengertypes.EphemeralEvent{
+		Type:     event.GetType(),
+		SentDate: timestampMs(now),
+		Typing:   event.GetTyping(),
+		Presence: event.GetPresence(),
+	})
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return payload, nil
+}
+
+func ephemeralEventFromGroupEvent(conv *messengertypes.Conversation, evt *protocoltypes.GroupEphemeralEvent) (*messengertypes.EphemeralEvent, error) {
+	event := &messengertypes.EphemeralEvent{}
+	if err := proto.Unmarshal(evt.GetPayload(), event); err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	memberPK := b64EncodeBytes(evt.GetMemberPK())
+
+	event.ConversationPublicKey = conv.GetPublicKey()
+	event.MemberPublicKey = memberPK
+	event.DevicePublicKey = b64EncodeBytes(evt.GetDevicePK())
+	event.IsMe = memberPK == conv.GetAccountMemberPublicKey()
+	event.SentDate = timestampMs(time.Unix(0, evt.GetSentDate()))
+
+	return event, nil
+}
@@ -0,0 +1,58 @@
+package bertymessenger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+func Test_ephemeralEventPayload(t *testing.T) {
+	now := time.Now()
+
+	_, err := ephemeralEventPayload(&messengertypes.EphemeralEvent{}, now)
+	require.Error(t, err)
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	payload, err := ephemeralEventPayload(&messengertypes.EphemeralEvent{
+		Type:                  messengertypes.EphemeralEvent_TypeTyping,
+		ConversationPublicKey: "conv_1",
+		MemberPublicKey:       "spoofed_member",
+		IsMe:                  true,
+		Typing:                true,
+	}, now)
+	require.NoError(t, err)
+
+	conv := &messengertypes.Conversation{PublicKey: "conv_1", AccountMemberPublicKey: b64EncodeBytes([]byte("member_me"))}
+	sentDate := now.Add(time.Second)
+
+	event, err := ephemeralEventFromGroupEvent(conv, &protocoltypes.GroupEphemeralEvent{
+		MemberPK: []byte("member_1"),
+		DevicePK: []byte("device_1"),
+		Payload:  payload,
+		SentDate: sentDate.UnixNano(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, messengertypes.EphemeralEvent_TypeTyping, event.Type)
+	require.Equal(t, "conv_1", event.ConversationPublicKey)
+	require.Equal(t, b64EncodeBytes([]byte("member_1")), event.MemberPublicKey)
+	require.Equal(t, b64EncodeBytes([]byte("device_1")), event.DevicePublicKey)
+	require.Equal(t, timestampMs(sentDate), event.SentDate)
+	require.False(t, event.IsMe)
+	require.True(t, event.Typing)
+
+	event, err = ephemeralEventFromGroupEvent(conv, &protocoltypes.GroupEphemeralEvent{
+		MemberPK: []byte("member_me"),
+		DevicePK: []byte("device_2"),
+		Payload:  payload,
+	})
+	require.NoError(t, err)
+	require.True(t, event.IsMe)
+
+	_, err = ephemeralEventFromGroupEvent(conv, &protocoltypes.GroupEphemeralEvent{Payload: []byte("invalid")})
+	require.Error(t, err)
+}
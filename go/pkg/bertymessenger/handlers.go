@@ -9,6 +9,7 @@ import (
 	// nolint:staticcheck // cannot use the new protobuf API while keeping gogoproto
 	"github.com/golang/protobuf/proto"
 	ipfscid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/crypto"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
@@ -54,19 +55,31 @@ func newEventHandler(ctx context.Context, db *dbWrapper, protocolClient protocol
 		protocoltypes.EventTypeGroupMemberDeviceAdded:                 h.groupMemberDeviceAdded,
 		protocoltypes.EventTypeGroupMetadataPayloadSent:               h.groupMetadataPayloadSent,
 		protocoltypes.EventTypeAccountServiceTokenAdded:               h.accountServiceTokenAdded,
+		protocoltypes.EventTypeAccountDeviceRevoked:                   h.accountDeviceRevoked,
 		protocoltypes.EventTypeGroupReplicating:                       h.groupReplicating,
+		protocoltypes.EventTypeGroupReplicatingRemoved:                h.groupReplicatingRemoved,
 		protocoltypes.EventTypeMultiMemberGroupInitialMemberAnnounced: h.multiMemberGroupInitialMemberAnnounced,
+		protocoltypes.EventTypeMultiMemberGroupAdminRoleGranted:       h.multiMemberGroupAdminRoleGranted,
+		protocoltypes.EventTypeMultiMemberGroupAdminRoleRevoked:       h.multiMemberGroupAdminRoleRevoked,
 	}
 
 	h.appMessageHandlers = map[messengertypes.AppMessage_Type]struct {
 		handler        func(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error)
 		isVisibleEvent bool
 	}{
-		messengertypes.AppMessage_TypeAcknowledge:     {h.handleAppMessageAcknowledge, false},
-		messengertypes.AppMessage_TypeGroupInvitation: {h.handleAppMessageGroupInvitation, true},
-		messengertypes.AppMessage_TypeUserMessage:     {h.handleAppMessageUserMessage, true},
-		messengertypes.AppMessage_TypeSetUserInfo:     {h.handleAppMessageSetUserInfo, false},
-		messengertypes.AppMessage_TypeReplyOptions:    {h.handleAppMessageReplyOptions, true},
+		messengertypes.AppMessage_TypeAcknowledge:         {h.handleAppMessageAcknowledge, false},
+		messengertypes.AppMessage_TypeGroupInvitation:     {h.handleAppMessageGroupInvitation, true},
+		messengertypes.AppMessage_TypeUserMessage:         {h.handleAppMessageUserMessage, true},
+		messengertypes.AppMessage_TypeUserReaction:        {h.handleAppMessageUserReaction, false},
+		messengertypes.AppMessage_TypeSetUserInfo:         {h.handleAppMessageSetUserInfo, false},
+		messengertypes.AppMessage_TypeSetGroupInfo:        {h.handleAppMessageSetGroupInfo, false},
+		messengertypes.AppMessage_TypeReplyOptions:        {h.handleAppMessageReplyOptions, true},
+		messengertypes.AppMessage_TypeEditUserMessage:     {h.handleAppMessageUserMessageChange, false},
+		messengertypes.AppMessage_TypeDeleteUserMessage:   {h.handleAppMessageUserMessageChange, false},
+		messengertypes.AppMessage_TypeSetMessageRetention: {h.handleAppMessageSetMessageRetention, false},
+		messengertypes.AppMessage_TypeReadReceipt:         {h.handleAppMessageReadReceipt, false},
+		messengertypes.AppMessage_TypeSetPinnedMessage:    {h.handleAppMessageSetPinnedMessage, false},
+		messengertypes.AppMessage_TypeSetStarredMessage:   {h.handleAppMessageSetStarredMessage, false},
 	}
 
 	return h
@@ -105,6 +118,29 @@ func (h *eventHandler) handleAppMessage(gpk string, gme *protocoltypes.GroupMess
 		return nil
 	}
 
+	// don't store interactions that already expired, ie. when replaying logs
+	if i.GetType() != messengertypes.AppMessage_TypeSetMessageRetention {
+		expired, err := h.interactionIsExpired(i)
+		if err != nil {
+			return err
+		}
+
+		if expired {
+			h.logger.Debug("ignoring expired interaction", zap.String("cid", i.GetCID()), zap.String("conv", gpk))
+
+			// the secrets of the attachments have been stored again when the message was opened
+			if h.svc != nil {
+				for _, media := range i.GetMedias() {
+					if err := h.svc.attachmentRemove(h.ctx, media.GetCID(), false); err != nil {
+						h.logger.Warn("unable to remove expired attachment", zap.String("cid", media.GetCID()), zap.Error(err))
+					}
+				}
+			}
+
+			return nil
+		}
+	}
+
 	medias := i.GetMedias()
 	var mediasAdded []bool
 
@@ -115,6 +151,10 @@ func (h *eventHandler) handleAppMessage(gpk string, gme *protocoltypes.GroupMess
 			return err
 		}
 
+		if err := tx.addMediaReferences(i.GetCID(), medias); err != nil {
+			return err
+		}
+
 		if err := h.interactionFetchRelations(tx, i); err != nil {
 			return err
 		}
@@ -186,6 +226,58 @@ func (h *eventHandler) accountServiceTokenAdded(gme *protocoltypes.GroupMetadata
 	return nil
 }
 
+func (h *eventHandler) accountDeviceRevoked(gme *protocoltypes.GroupMetadataEvent) error {
+	var ev protocoltypes.AccountDeviceRevoked
+	if err := proto.Unmarshal(gme.GetEvent(), &ev); err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	config, err := h.protocolClient.InstanceGetConfiguration(h.ctx, &protocoltypes.InstanceGetConfiguration_Request{})
+	if err != nil {
+		return err
+	}
+
+	// the revocation is also published in the other groups of the account, only the one of the account group updates the device
+	if !bytes.Equal(gme.GetEventContext().GetGroupPK(), config.AccountGroupPK) {
+		return nil
+	}
+
+	// the protocol only accepts revocations signed by the account, check it again before updating the device
+	accountPK, err := crypto.UnmarshalEd25519PublicKey(config.AccountPK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	if ok, err := accountPK.Verify(ev.GetRevokedDevicePK(), ev.GetAccountSig()); err != nil || !ok {
+		h.logger.Warn("ignoring device revocation not signed by the account", zap.String("device-pk", b64EncodeBytes(ev.GetRevokedDevicePK())))
+		return nil
+	}
+
+	dpk := b64EncodeBytes(ev.GetRevokedDevicePK())
+
+	updated, err := h.db.markDeviceAsRevoked(dpk)
+	if err != nil {
+		return err
+	}
+
+	if !updated || h.svc == nil {
+		return nil
+	}
+
+	device, err := h.db.getDeviceByPK(dpk)
+	if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeDeviceUpdated, &messengertypes.StreamEvent_DeviceUpdated{Device: device}, false); err != nil {
+		return err
+	}
+
+	h.logger.Info("dispatched device update", zap.String("device-pk", dpk), zap.Bool("revoked", true))
+
+	return nil
+}
+
 func (h *eventHandler) groupReplicating(gme *protocoltypes.GroupMetadataEvent) error {
 	var ev protocoltypes.GroupReplicating
 	if err := proto.Unmarshal(gme.GetEvent(), &ev); err != nil {
@@ -222,6 +314,32 @@ func (h *eventHandler) groupReplicating(gme *protocoltypes.GroupMetadataEvent) e
 	return nil
 }
 
+func (h *eventHandler) groupReplicatingRemoved(gme *protocoltypes.GroupMetadataEvent) error {
+	var ev protocoltypes.GroupReplicatingRemoved
+	if err := proto.Unmarshal(gme.GetEvent(), &ev); err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	convPK := b64EncodeBytes(gme.EventContext.GroupPK)
+
+	deleted, err := h.db.deleteConversationReplicationInfo(convPK, ev.ReplicationServer)
+	if err != nil {
+		return err
+	}
+
+	if h.svc == nil || deleted == 0 {
+		return nil
+	}
+
+	if conv, err := h.db.getConversationByPK(convPK); err != nil {
+		h.logger.Warn("unknown conversation", zap.String("conversation-pk", convPK))
+	} else if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeConversationUpdated, &messengertypes.StreamEvent_ConversationUpdated{Conversation: conv}, false); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (h *eventHandler) groupMetadataPayloadSent(gme *protocoltypes.GroupMetadataEvent) error {
 	var appMetadata protocoltypes.AppMetadata
 	if err := proto.Unmarshal(gme.GetEvent(), &appMetadata); err != nil {
@@ -612,6 +730,117 @@ func (h *eventHandler) multiMemberGroupInitialMemberAnnounced(gme *protocoltypes
 	return nil
 }
 
+func (h *eventHandler) multiMemberGroupAdminRoleGranted(gme *protocoltypes.GroupMetadataEvent) error {
+	var ev protocoltypes.MultiMemberGrantAdminRole
+	if err := proto.Unmarshal(gme.GetEvent(), &ev); err != nil {
+		return err
+	}
+
+	return h.setMemberAdminRole(gme, ev.GetDevicePK(), ev.GetGranteeMemberPK(), true)
+}
+
+func (h *eventHandler) multiMemberGroupAdminRoleRevoked(gme *protocoltypes.GroupMetadataEvent) error {
+	var ev protocoltypes.MultiMemberRevokeAdminRole
+	if err := proto.Unmarshal(gme.GetEvent(), &ev); err != nil {
+		return err
+	}
+
+	return h.setMemberAdminRole(gme, ev.GetDevicePK(), ev.GetRevokedMemberPK(), false)
+}
+
+func (h *eventHandler) setMemberAdminRole(gme *protocoltypes.GroupMetadataEvent, dpkb []byte, mpkb []byte, isAdmin bool) error {
+	gpkb := gme.GetEventContext().GetGroupPK()
+
+	if dpkb == nil || mpkb == nil || gpkb == nil {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("some metadata event references are missing"))
+	}
+
+	cid, err := ipfscid.Cast(gme.GetEventContext().GetID())
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	return h.applyMemberAdminRole(messengertypes.AdminRoleBacklog{
+		CID:                   cid.String(),
+		ConversationPublicKey: b64EncodeBytes(gpkb),
+		DevicePublicKey:       b64EncodeBytes(dpkb),
+		MemberPublicKey:       b64EncodeBytes(mpkb),
+		IsAdmin:               isAdmin,
+	})
+}
+
+// applyMemberAdminRole grants or revokes the admin role of a member, the event is stored in backlog if its sender device
+// is not known yet
+func (h *eventHandler) applyMemberAdminRole(ev messengertypes.AdminRoleBacklog) error {
+	dpk, mpk, gpk := ev.GetDevicePublicKey(), ev.GetMemberPublicKey(), ev.GetConversationPublicKey()
+
+	updated := false
+
+	if err := h.db.tx(func(tx *dbWrapper) error {
+		// the protocol only accepts admin role events sent by the devices of an admin, check it again against the known members
+		device, err := tx.getDeviceByPK(dpk)
+		if err == gorm.ErrRecordNotFound {
+			// store in backlog until the device is known
+			h.logger.Info("storing admin role event in backlog", zap.String("device-pk", dpk), zap.String("conv", gpk))
+			return tx.addAdminRoleBacklog(ev)
+		} else if err != nil {
+			return errcode.ErrDBRead.Wrap(err)
+		}
+
+		isSenderAdmin, err := tx.isMemberAdmin(device.GetMemberPublicKey(), gpk)
+		if err != nil {
+			return err
+		}
+
+		if !isSenderAdmin {
+			h.logger.Warn("ignoring admin role event from non admin member", zap.String("member-pk", device.GetMemberPublicKey()), zap.String("conv", gpk))
+			return nil
+		}
+
+		if _, err := tx.getMemberByPK(mpk, gpk); err == gorm.ErrRecordNotFound {
+			gpkb, err := b64DecodeBytes(gpk)
+			if err != nil {
+				return errcode.ErrDeserialization.Wrap(err)
+			}
+
+			gi, err := h.protocolClient.GroupInfo(h.ctx, &protocoltypes.GroupInfo_Request{GroupPK: gpkb})
+			if err != nil {
+				return errcode.ErrGroupInfo.Wrap(err)
+			}
+			isMe := b64EncodeBytes(gi.GetMemberPK()) == mpk
+
+			if _, err := tx.addMember(mpk, gpk, "", "", isMe, false); err != nil {
+				return errcode.ErrDBWrite.Wrap(err)
+			}
+		} else if err != nil {
+			return errcode.ErrDBRead.Wrap(err)
+		}
+
+		updated, err = tx.setMemberAdminRole(mpk, gpk, ev.GetIsAdmin())
+
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if !updated || h.svc == nil {
+		return nil
+	}
+
+	member, err := h.db.getMemberByPK(mpk, gpk)
+	if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeMemberUpdated, &messengertypes.StreamEvent_MemberUpdated{Member: member}, false); err != nil {
+		return err
+	}
+
+	h.logger.Info("dispatched member update", zap.String("member-pk", mpk), zap.Bool("is-admin", ev.GetIsAdmin()))
+
+	return nil
+}
+
 // groupMemberDeviceAdded is called at different moments
 // * on AccountGroup when you add a new device to your group
 // * on ContactGroup when you or your contact add a new device
@@ -697,6 +926,69 @@ func (h *eventHandler) groupMemberDeviceAdded(gme *protocoltypes.GroupMetadataEv
 					}
 				}
 
+			case messengertypes.AppMessage_TypeSetGroupInfo:
+				var payload messengertypes.AppMessage_SetGroupInfo
+
+				if err := proto.Unmarshal(elem.GetPayload(), &payload); err != nil {
+					return err
+				}
+
+				if err := h.applySetGroupInfo(h.db, elem, mpk, &payload); err != nil {
+					return err
+				}
+
+				if err := h.db.deleteInteractions([]string{elem.CID}); err != nil {
+					return err
+				}
+
+				if h.svc != nil {
+					if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionDeleted, &messengertypes.StreamEvent_InteractionDeleted{CID: elem.GetCID()}, false); err != nil {
+						return err
+					}
+				}
+
+			case messengertypes.AppMessage_TypeReadReceipt:
+				var payload messengertypes.AppMessage_ReadReceipt
+
+				if err := proto.Unmarshal(elem.GetPayload(), &payload); err != nil {
+					return err
+				}
+
+				if err := h.applyReadReceipt(h.db, elem, &payload); err != nil {
+					return err
+				}
+
+				if err := h.db.deleteInteractions([]string{elem.CID}); err != nil {
+					return err
+				}
+
+				if h.svc != nil {
+					if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionDeleted, &messengertypes.StreamEvent_InteractionDeleted{CID: elem.GetCID()}, false); err != nil {
+						return err
+					}
+				}
+
+			case messengertypes.AppMessage_TypeUserReaction:
+				var payload messengertypes.AppMessage_UserReaction
+
+				if err := proto.Unmarshal(elem.GetPayload(), &payload); err != nil {
+					return err
+				}
+
+				if err := h.applyUserReaction(h.db, elem, &payload); err != nil {
+					return err
+				}
+
+				if err := h.db.deleteInteractions([]string{elem.CID}); err != nil {
+					return err
+				}
+
+				if h.svc != nil {
+					if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionDeleted, &messengertypes.StreamEvent_InteractionDeleted{CID: elem.GetCID()}, false); err != nil {
+						return err
+					}
+				}
+
 			default:
 				if h.svc != nil {
 					if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionUpdated, &messengertypes.StreamEvent_InteractionUpdated{Interaction: elem}, false); err != nil {
@@ -725,6 +1017,18 @@ func (h *eventHandler) groupMemberDeviceAdded(gme *protocoltypes.GroupMetadataEv
 
 			h.logger.Info("dispatched member update", zap.Any("member", member), zap.Bool("isNew", isNew))
 		}
+
+		// the admin role events of the device are applied once its member is known
+		adminRoleBacklog, err := h.db.popAdminRoleBacklog(dpk, gpk)
+		if err != nil {
+			return err
+		}
+
+		for _, pending := range adminRoleBacklog {
+			if err := h.applyMemberAdminRole(*pending); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -775,11 +1079,28 @@ func (h *eventHandler) handleAppMessageGroupInvitation(tx *dbWrapper, i *messeng
 }
 
 func (h *eventHandler) handleAppMessageUserMessage(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	payload := amPayload.(*messengertypes.AppMessage_UserMessage)
+
+	// stored as columns so that threads can be listed without decoding the payloads
+	i.ReplyToCID = payload.GetReplyToCID()
+	i.ThreadRootCID = payload.GetThreadRootCID()
+
 	i, isNew, err := tx.addInteraction(*i)
 	if err != nil {
 		return nil, isNew, err
 	}
 
+	if isNew {
+		if i, err = h.interactionConsumeUserMessageChanges(tx, i); err != nil {
+			return nil, isNew, err
+		}
+
+		if i.IsDeleted {
+			// deleted before being received
+			return i, false, nil
+		}
+	}
+
 	if h.svc == nil {
 		return i, isNew, nil
 	}
@@ -812,7 +1133,6 @@ func (h *eventHandler) handleAppMessageUserMessage(tx *dbWrapper, i *messengerty
 		}
 	}
 
-	payload := amPayload.(*messengertypes.AppMessage_UserMessage)
 	var title string
 	body := payload.GetBody()
 	if contact != nil && i.Conversation.Type == messengertypes.Conversation_ContactType {
@@ -839,6 +1159,61 @@ func (h *eventHandler) handleAppMessageUserMessage(tx *dbWrapper, i *messengerty
 	return i, isNew, nil
 }
 
+func (h *eventHandler) handleAppMessageUserReaction(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	payload := amPayload.(*messengertypes.AppMessage_UserReaction)
+
+	if i.GetConversation().GetType() == messengertypes.Conversation_MultiMemberType && !i.IsMe && i.MemberPublicKey == "" {
+		// store in backlog until the member is known
+		h.logger.Info("storing UserReaction in backlog", zap.String("target", payload.GetTarget()), zap.String("device-pk", i.GetDevicePublicKey()), zap.String("conv", i.ConversationPublicKey))
+		i.TargetCID = payload.GetTarget()
+		ni, isNew, err := tx.addInteraction(*i)
+		if err != nil {
+			return nil, false, err
+		}
+		return ni, isNew, nil
+	}
+
+	if err := h.applyUserReaction(tx, i, payload); err != nil {
+		return nil, false, err
+	}
+
+	return i, false, nil
+}
+
+func (h *eventHandler) applyUserReaction(tx *dbWrapper, i *messengertypes.Interaction, payload *messengertypes.AppMessage_UserReaction) error {
+	updated, err := tx.upsertReaction(messengertypes.Reaction{
+		TargetCID:       payload.GetTarget(),
+		MemberPublicKey: i.GetMemberPublicKey(),
+		IsMine:          i.GetIsMe(),
+		Emoji:           payload.GetEmoji(),
+		State:           !payload.GetRemove(),
+		StateDate:       i.GetSentDate(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if !updated || h.svc == nil {
+		return nil
+	}
+
+	target, err := tx.getInteractionByCID(payload.GetTarget())
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		// reactions will be attached to the target interaction once received
+		h.logger.Debug("reaction target not found", zap.String("target", payload.GetTarget()), zap.String("cid", i.GetCID()))
+		return nil
+	case err != nil:
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionUpdated, &messengertypes.StreamEvent_InteractionUpdated{Interaction: target}, false); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (h *eventHandler) handleAppMessageSetUserInfo(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
 	payload := amPayload.(*messengertypes.AppMessage_SetUserInfo)
 
@@ -921,10 +1296,304 @@ func (h *eventHandler) handleAppMessageSetUserInfo(tx *dbWrapper, i *messengerty
 	return i, false, nil
 }
 
-func interactionFromAppMessage(h *eventHandler, gpk string, gme *protocoltypes.GroupMessageEvent, am *messengertypes.AppMessage) (*messengertypes.Interaction, error) {
-	amt := am.GetType()
-	cid, err := ipfscid.Cast(gme.GetEventContext().GetID())
-	if err != nil {
+func (h *eventHandler) handleAppMessageSetGroupInfo(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	payload := amPayload.(*messengertypes.AppMessage_SetGroupInfo)
+
+	if i.GetConversation().GetType() != messengertypes.Conversation_MultiMemberType {
+		h.logger.Warn("SetGroupInfo is only supported in multi-member conversations", zap.String("conv", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	memberPK, err := h.interactionMemberPK(i)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if memberPK == "" {
+		// store in backlog until the member is known
+		h.logger.Info("storing SetGroupInfo in backlog", zap.String("device-pk", i.GetDevicePublicKey()), zap.String("conv", i.ConversationPublicKey))
+		ni, isNew, err := tx.addInteraction(*i)
+		if err != nil {
+			return nil, false, err
+		}
+		return ni, isNew, nil
+	}
+
+	if err := h.applySetGroupInfo(tx, i, memberPK, payload); err != nil {
+		return nil, false, err
+	}
+
+	return i, false, nil
+}
+
+func (h *eventHandler) applySetGroupInfo(tx *dbWrapper, i *messengertypes.Interaction, memberPK string, payload *messengertypes.AppMessage_SetGroupInfo) error {
+	isAdmin, err := tx.isMemberAdmin(memberPK, i.ConversationPublicKey)
+	if err != nil {
+		return err
+	}
+
+	if !isAdmin {
+		h.logger.Warn("ignoring SetGroupInfo from non admin member", zap.String("member-pk", memberPK), zap.String("conv", i.ConversationPublicKey))
+		return nil
+	}
+
+	updated, err := tx.updateConversationInfo(i.ConversationPublicKey, payload.GetDisplayName(), payload.GetAvatarCID(), i.GetSentDate(), i.GetCID())
+	if err != nil {
+		return err
+	}
+
+	if !updated || h.svc == nil {
+		return nil
+	}
+
+	conv, err := tx.getConversationByPK(i.ConversationPublicKey)
+	if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeConversationUpdated, &messengertypes.StreamEvent_ConversationUpdated{Conversation: conv}, false); err != nil {
+		return err
+	}
+
+	h.logger.Info("dispatched conversation update", zap.String("display-name", conv.GetDisplayName()), zap.String("conv", i.ConversationPublicKey))
+
+	return nil
+}
+
+func (h *eventHandler) handleAppMessageSetMessageRetention(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	payload := amPayload.(*messengertypes.AppMessage_SetMessageRetention)
+
+	switch i.GetConversation().GetType() {
+	case messengertypes.Conversation_ContactType, messengertypes.Conversation_MultiMemberType:
+	default:
+		h.logger.Warn("SetMessageRetention is only supported in contact and multi-member conversations", zap.String("conv", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	if payload.GetRetention() < 0 {
+		h.logger.Warn("ignoring SetMessageRetention with a negative retention", zap.Int64("retention", payload.GetRetention()), zap.String("conv", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	updated, err := tx.updateConversationMessageRetention(i.ConversationPublicKey, payload.GetRetention(), i.GetSentDate())
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !updated || h.svc == nil {
+		return i, false, nil
+	}
+
+	conv, err := tx.getConversationByPK(i.ConversationPublicKey)
+	if err != nil {
+		return nil, false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeConversationUpdated, &messengertypes.StreamEvent_ConversationUpdated{Conversation: conv}, false); err != nil {
+		return nil, false, err
+	}
+
+	h.logger.Info("dispatched conversation message retention update", zap.Int64("retention", conv.GetMessageRetention()), zap.String("conv", i.ConversationPublicKey))
+
+	return i, false, nil
+}
+
+func (h *eventHandler) handleAppMessageReadReceipt(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	payload := amPayload.(*messengertypes.AppMessage_ReadReceipt)
+
+	if payload.GetLastReadCID() == "" {
+		h.logger.Warn("ignoring ReadReceipt without a last read cid", zap.String("conv", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	if i.GetConversation().GetType() == messengertypes.Conversation_MultiMemberType && !i.IsMe && i.MemberPublicKey == "" {
+		// store in backlog until the member is known
+		h.logger.Info("storing ReadReceipt in backlog", zap.String("device-pk", i.GetDevicePublicKey()), zap.String("conv", i.ConversationPublicKey))
+		ni, isNew, err := tx.addInteraction(*i)
+		if err != nil {
+			return nil, false, err
+		}
+		return ni, isNew, nil
+	}
+
+	if err := h.applyReadReceipt(tx, i, payload); err != nil {
+		return nil, false, err
+	}
+
+	return i, false, nil
+}
+
+func (h *eventHandler) applyReadReceipt(tx *dbWrapper, i *messengertypes.Interaction, payload *messengertypes.AppMessage_ReadReceipt) error {
+	updated, err := tx.upsertReadReceipt(messengertypes.ReadReceipt{
+		ConversationPublicKey: i.ConversationPublicKey,
+		MemberPublicKey:       i.MemberPublicKey,
+		IsMine:                i.IsMe,
+		LastReadCID:           payload.GetLastReadCID(),
+		ReadDate:              i.GetSentDate(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if !updated || h.svc == nil {
+		return nil
+	}
+
+	conv, err := tx.getConversationByPK(i.ConversationPublicKey)
+	if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeConversationUpdated, &messengertypes.StreamEvent_ConversationUpdated{Conversation: conv}, false); err != nil {
+		return err
+	}
+
+	// the target may not have been received yet, its readers will be attached when it is
+	target, err := tx.getInteractionByCID(payload.GetLastReadCID())
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	} else if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionUpdated, &messengertypes.StreamEvent_InteractionUpdated{Interaction: target}, false); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (h *eventHandler) handleAppMessageSetPinnedMessage(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	payload := amPayload.(*messengertypes.AppMessage_SetPinnedMessage)
+
+	switch i.GetConversation().GetType() {
+	case messengertypes.Conversation_ContactType, messengertypes.Conversation_MultiMemberType:
+	default:
+		h.logger.Warn("SetPinnedMessage is only supported in contact and multi-member conversations", zap.String("conv", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	if payload.GetTarget() == "" {
+		h.logger.Warn("ignoring SetPinnedMessage without a target", zap.String("conv", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	updated, err := tx.upsertPinnedMessage(messengertypes.PinnedMessage{
+		ConversationPublicKey: i.ConversationPublicKey,
+		TargetCID:             payload.GetTarget(),
+		Pinned:                payload.GetPinned(),
+		MemberPublicKey:       i.MemberPublicKey,
+		IsMine:                i.IsMe,
+		StateDate:             i.GetSentDate(),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !updated || h.svc == nil {
+		return i, false, nil
+	}
+
+	conv, err := tx.getConversationByPK(i.ConversationPublicKey)
+	if err != nil {
+		return nil, false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeConversationUpdated, &messengertypes.StreamEvent_ConversationUpdated{Conversation: conv}, false); err != nil {
+		return nil, false, err
+	}
+
+	return i, false, nil
+}
+
+func (h *eventHandler) handleAppMessageSetStarredMessage(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	payload := amPayload.(*messengertypes.AppMessage_SetStarredMessage)
+
+	// starred interactions are private, they are only accepted from the account group whose devices all belong to the
+	// account, so the stars set on the other linked devices are synced too
+	config, err := h.protocolClient.InstanceGetConfiguration(h.ctx, &protocoltypes.InstanceGetConfiguration_Request{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if i.ConversationPublicKey != b64EncodeBytes(config.GetAccountGroupPK()) {
+		h.logger.Warn("ignoring SetStarredMessage outside of the account group", zap.String("group", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	if payload.GetConversationPublicKey() == "" || payload.GetTarget() == "" {
+		h.logger.Warn("ignoring SetStarredMessage without a conversation or a target", zap.String("group", i.ConversationPublicKey))
+		return i, false, nil
+	}
+
+	updated, err := tx.upsertStarredMessage(messengertypes.StarredMessage{
+		ConversationPublicKey: payload.GetConversationPublicKey(),
+		TargetCID:             payload.GetTarget(),
+		Starred:               payload.GetStarred(),
+		StateDate:             i.GetSentDate(),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !updated || h.svc == nil {
+		return i, false, nil
+	}
+
+	// the conversation may not be known yet on this device
+	conv, err := tx.getConversationByPK(payload.GetConversationPublicKey())
+	if err == gorm.ErrRecordNotFound {
+		return i, false, nil
+	} else if err != nil {
+		return nil, false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeConversationUpdated, &messengertypes.StreamEvent_ConversationUpdated{Conversation: conv}, false); err != nil {
+		return nil, false, err
+	}
+
+	return i, false, nil
+}
+
+// interactionIsExpired returns true if the interaction is older than the message retention of its conversation
+func (h *eventHandler) interactionIsExpired(i *messengertypes.Interaction) (bool, error) {
+	conv, err := h.db.getConversationByPK(i.GetConversationPublicKey())
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	return isExpired(i.GetSentDate(), conv.GetMessageRetention(), time.Now()), nil
+}
+
+// interactionMemberPK returns the public key of the member who sent the interaction, including when it has been sent by the current account
+func (h *eventHandler) interactionMemberPK(i *messengertypes.Interaction) (string, error) {
+	if !i.GetIsMe() {
+		return i.GetMemberPublicKey(), nil
+	}
+
+	if pk := i.GetConversation().GetAccountMemberPublicKey(); pk != "" {
+		return pk, nil
+	}
+
+	gpkb, err := b64DecodeBytes(i.GetConversationPublicKey())
+	if err != nil {
+		return "", errcode.ErrDeserialization.Wrap(err)
+	}
+
+	gi, err := h.protocolClient.GroupInfo(h.ctx, &protocoltypes.GroupInfo_Request{GroupPK: gpkb})
+	if err != nil {
+		return "", errcode.ErrGroupInfo.Wrap(err)
+	}
+
+	return b64EncodeBytes(gi.GetMemberPK()), nil
+}
+
+func interactionFromAppMessage(h *eventHandler, gpk string, gme *protocoltypes.GroupMessageEvent, am *messengertypes.AppMessage) (*messengertypes.Interaction, error) {
+	amt := am.GetType()
+	cid, err := ipfscid.Cast(gme.GetEventContext().GetID())
+	if err != nil {
 		return nil, err
 	}
 
@@ -959,6 +1628,8 @@ func interactionFromAppMessage(h *eventHandler, gpk string, gme *protocoltypes.G
 	for _, media := range i.Medias {
 		media.InteractionCID = i.CID
 		media.State = messengertypes.Media_StateNeverDownloaded
+		media.DownloadedSize = 0
+		media.LastAccessDate = 0
 	}
 
 	return &i, nil
@@ -1008,7 +1679,9 @@ func (h *eventHandler) dispatchVisibleInteraction(i *messengertypes.Interaction)
 		return nil
 	}
 
-	return h.db.tx(func(tx *dbWrapper) error {
+	sendReceipt := false
+
+	if err := h.db.tx(func(tx *dbWrapper) error {
 		// FIXME: check if app is in foreground
 		// if conv is not open, increment the unread_count
 		opened, err := tx.isConversationOpened(i.ConversationPublicKey)
@@ -1017,6 +1690,7 @@ func (h *eventHandler) dispatchVisibleInteraction(i *messengertypes.Interaction)
 		}
 
 		newUnread := !h.replay && !i.IsMe && !opened
+		sendReceipt = !h.replay && !i.IsMe && opened
 
 		// db update
 		if err := tx.updateConversationReadState(i.ConversationPublicKey, newUnread, time.Now()); err != nil {
@@ -1036,7 +1710,18 @@ func (h *eventHandler) dispatchVisibleInteraction(i *messengertypes.Interaction)
 		}
 
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+
+	// the conversation is being read, let the other members know
+	if sendReceipt {
+		if err := h.sendReadReceipt(i.ConversationPublicKey); err != nil {
+			h.logger.Error("unable to send read receipt", zap.String("conv", i.ConversationPublicKey), zap.Error(err))
+		}
+	}
+
+	return nil
 }
 
 func (h *eventHandler) sendAck(cid, conversationPK string) error {
@@ -1064,6 +1749,65 @@ func (h *eventHandler) sendAck(cid, conversationPK string) error {
 	return nil
 }
 
+// sendReadReceipt lets the other members know the last interaction read in a conversation,
+// nothing is sent if read receipts are disabled or if the conversation has already been read
+func (h *eventHandler) sendReadReceipt(conversationPK string) error {
+	acc, err := h.db.getAccount()
+	if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	if acc.GetDisableReadReceipts() {
+		return nil
+	}
+
+	conv, err := h.db.getConversationByPK(conversationPK)
+	if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	switch conv.GetType() {
+	case messengertypes.Conversation_ContactType, messengertypes.Conversation_MultiMemberType:
+	default:
+		return nil
+	}
+
+	last, err := h.db.getLastReceivedInteraction(conversationPK)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	} else if err != nil {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	own, err := h.db.getOwnReadReceipt(conversationPK)
+	if err == nil && own.GetLastReadCID() == last.GetCID() {
+		return nil
+	} else if err != nil && err != gorm.ErrRecordNotFound {
+		return errcode.ErrDBRead.Wrap(err)
+	}
+
+	h.logger.Debug("sending read receipt", zap.String("target", last.GetCID()))
+
+	amp, err := messengertypes.AppMessage_TypeReadReceipt.MarshalPayload(timestampMs(time.Now()), nil, &messengertypes.AppMessage_ReadReceipt{LastReadCID: last.GetCID()})
+	if err != nil {
+		return err
+	}
+
+	cpk, err := b64DecodeBytes(conversationPK)
+	if err != nil {
+		return err
+	}
+
+	if _, err = h.protocolClient.AppMessageSend(h.ctx, &protocoltypes.AppMessageSend_Request{
+		GroupPK: cpk,
+		Payload: amp,
+	}); err != nil {
+		return errcode.ErrProtocolSend.Wrap(err)
+	}
+
+	return nil
+}
+
 func (h *eventHandler) interactionConsumeAck(tx *dbWrapper, i *messengertypes.Interaction) error {
 	cids, err := tx.getAcknowledgementsCIDsForInteraction(i.CID)
 	if err != nil {
@@ -1092,6 +1836,188 @@ func (h *eventHandler) interactionConsumeAck(tx *dbWrapper, i *messengertypes.In
 	return nil
 }
 
+func (h *eventHandler) handleAppMessageUserMessageChange(tx *dbWrapper, i *messengertypes.Interaction, amPayload proto.Message) (*messengertypes.Interaction, bool, error) {
+	switch payload := amPayload.(type) {
+	case *messengertypes.AppMessage_EditUserMessage:
+		i.TargetCID = payload.GetTarget()
+	case *messengertypes.AppMessage_DeleteUserMessage:
+		i.TargetCID = payload.GetTarget()
+	}
+
+	if i.TargetCID == "" {
+		return nil, false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("a target cid is required"))
+	}
+
+	target, err := tx.getInteractionByCID(i.TargetCID)
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		h.logger.Debug("added user message change in backlog", zap.String("type", i.GetType().String()), zap.String("target", i.TargetCID), zap.String("cid", i.GetCID()))
+		i, _, err = tx.addInteraction(*i)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return i, false, nil
+
+	case err != nil:
+		return nil, false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	updated, err := h.applyUserMessageChange(tx, target, i)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !updated || h.svc == nil {
+		return i, false, nil
+	}
+
+	if i.GetType() == messengertypes.AppMessage_TypeDeleteUserMessage {
+		if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionDeleted, &messengertypes.StreamEvent_InteractionDeleted{CID: target.GetCID()}, false); err != nil {
+			return nil, false, err
+		}
+
+		return i, false, nil
+	}
+
+	if target, err = tx.getInteractionByCID(target.GetCID()); err != nil {
+		return nil, false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionUpdated, &messengertypes.StreamEvent_InteractionUpdated{Interaction: target}, false); err != nil {
+		return nil, false, err
+	}
+
+	return i, false, nil
+}
+
+// applyUserMessageChange applies an edit or a deletion to a user message, it returns true if the target has been modified
+func (h *eventHandler) applyUserMessageChange(tx *dbWrapper, target *messengertypes.Interaction, change *messengertypes.Interaction) (bool, error) {
+	if target.GetType() != messengertypes.AppMessage_TypeUserMessage {
+		h.logger.Warn("user message change targets an unsupported interaction", zap.String("target-type", target.GetType().String()), zap.String("target", target.GetCID()), zap.String("cid", change.GetCID()))
+		return false, nil
+	}
+
+	targetAuthor, err := h.interactionAuthorPK(tx, target)
+	if err != nil {
+		return false, err
+	}
+
+	changeAuthor, err := h.interactionAuthorPK(tx, change)
+	if err != nil {
+		return false, err
+	}
+
+	if !isSameInteractionAuthor(target, change, targetAuthor, changeAuthor) {
+		h.logger.Warn("user message change not sent by the original author", zap.String("target", target.GetCID()), zap.String("cid", change.GetCID()), zap.String("device-pk", change.GetDevicePublicKey()))
+		return false, nil
+	}
+
+	switch change.GetType() {
+	case messengertypes.AppMessage_TypeEditUserMessage:
+		var payload messengertypes.AppMessage_EditUserMessage
+		if err := proto.Unmarshal(change.GetPayload(), &payload); err != nil {
+			return false, errcode.ErrDeserialization.Wrap(err)
+		}
+
+		// only the body is edited, the reply and the thread of the message are kept
+		var edited messengertypes.AppMessage_UserMessage
+		if err := proto.Unmarshal(target.GetPayload(), &edited); err != nil {
+			return false, errcode.ErrDeserialization.Wrap(err)
+		}
+
+		edited.Body = payload.GetBody()
+
+		body, err := proto.Marshal(&edited)
+		if err != nil {
+			return false, errcode.ErrSerialization.Wrap(err)
+		}
+
+		return tx.addUserMessageEdit(target.GetCID(), change.GetCID(), body, change.GetSentDate())
+
+	case messengertypes.AppMessage_TypeDeleteUserMessage:
+		return tx.markInteractionAsDeleted(target.GetCID())
+
+	default:
+		return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unsupported user message change type: %s", change.GetType().String()))
+	}
+}
+
+// interactionConsumeUserMessageChanges applies the edits and deletions received before the user message itself
+func (h *eventHandler) interactionConsumeUserMessageChanges(tx *dbWrapper, i *messengertypes.Interaction) (*messengertypes.Interaction, error) {
+	changes, err := tx.getUserMessageChangesForInteraction(i.CID)
+	if err != nil {
+		return nil, errcode.ErrDBRead.Wrap(err)
+	}
+
+	if len(changes) == 0 {
+		return i, nil
+	}
+
+	cids := make([]string, len(changes))
+	for idx, change := range changes {
+		h.logger.Debug("found user message change in backlog", zap.String("type", change.GetType().String()), zap.String("target", i.GetCID()), zap.String("cid", change.GetCID()))
+
+		change.Conversation = i.Conversation
+		if _, err := h.applyUserMessageChange(tx, i, change); err != nil {
+			return nil, err
+		}
+
+		cids[idx] = change.GetCID()
+	}
+
+	if err := tx.deleteInteractions(cids); err != nil {
+		return nil, err
+	}
+
+	if h.svc != nil {
+		for _, c := range cids {
+			if err := h.svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionDeleted, &messengertypes.StreamEvent_InteractionDeleted{CID: c}, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return tx.getInteractionByCID(i.CID)
+}
+
+// isSameInteractionAuthor checks that both interactions have been sent by the same member, authorA and authorB are the
+// member public keys of their authors when known, the devices of an account share the same member
+func isSameInteractionAuthor(a, b *messengertypes.Interaction, authorA, authorB string) bool {
+	if a.GetConversationPublicKey() != b.GetConversationPublicKey() {
+		return false
+	}
+
+	if a.GetDevicePublicKey() != "" && a.GetDevicePublicKey() == b.GetDevicePublicKey() {
+		return true
+	}
+
+	if authorA != "" && authorB != "" {
+		return authorA == authorB
+	}
+
+	if a.GetIsMe() || b.GetIsMe() {
+		return a.GetIsMe() == b.GetIsMe()
+	}
+
+	// only the contact can send messages from devices that are not known to be ours
+	return b.GetConversation().GetType() == messengertypes.Conversation_ContactType
+}
+
+// interactionAuthorPK returns the public key of the member who sent the interaction or an empty string if its device is
+// unknown, it is the same for all the devices of an account
+func (h *eventHandler) interactionAuthorPK(tx *dbWrapper, i *messengertypes.Interaction) (string, error) {
+	if i.GetIsMe() {
+		return h.interactionMemberPK(i)
+	}
+
+	if device, err := tx.getDeviceByPK(i.GetDevicePublicKey()); err == nil && device.GetMemberPublicKey() != "" {
+		return device.GetMemberPublicKey(), nil
+	}
+
+	return i.GetMemberPublicKey(), nil
+}
+
 func (h *eventHandler) handleAppMessageReplyOptions(tx *dbWrapper, i *messengertypes.Interaction, _ proto.Message) (*messengertypes.Interaction, bool, error) {
 	i, isNew, err := tx.addInteraction(*i)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/require"
 
 	"berty.tech/berty/v2/go/pkg/bertyprotocol"
@@ -146,6 +147,104 @@ func Test_eventHandler_hydrateInteraction(t *testing.T) {
 	t.Skip("TODO")
 }
 
+func Test_isSameInteractionAuthor(t *testing.T) {
+	multiMember := &messengertypes.Conversation{Type: messengertypes.Conversation_MultiMemberType}
+	contact := &messengertypes.Conversation{Type: messengertypes.Conversation_ContactType}
+
+	for _, tc := range []struct {
+		name             string
+		a, b             *messengertypes.Interaction
+		authorA, authorB string
+		expected         bool
+	}{
+		{"both mine", &messengertypes.Interaction{IsMe: true, ConversationPublicKey: "conv"}, &messengertypes.Interaction{IsMe: true, ConversationPublicKey: "conv", Conversation: multiMember}, "", "", true},
+		{"one mine", &messengertypes.Interaction{IsMe: true, ConversationPublicKey: "conv"}, &messengertypes.Interaction{ConversationPublicKey: "conv", Conversation: multiMember}, "me", "", false},
+		{"other device of the account", &messengertypes.Interaction{IsMe: true, DevicePublicKey: "dev1", ConversationPublicKey: "conv"}, &messengertypes.Interaction{DevicePublicKey: "dev2", ConversationPublicKey: "conv", Conversation: multiMember}, "me", "me", true},
+		{"other device of the account in a contact conversation", &messengertypes.Interaction{DevicePublicKey: "dev2", ConversationPublicKey: "conv"}, &messengertypes.Interaction{IsMe: true, DevicePublicKey: "dev1", ConversationPublicKey: "conv", Conversation: contact}, "me", "me", true},
+		{"other conversation", &messengertypes.Interaction{IsMe: true, ConversationPublicKey: "conv"}, &messengertypes.Interaction{IsMe: true, ConversationPublicKey: "conv2", Conversation: multiMember}, "me", "me", false},
+		{"same device", &messengertypes.Interaction{DevicePublicKey: "dev", ConversationPublicKey: "conv"}, &messengertypes.Interaction{DevicePublicKey: "dev", ConversationPublicKey: "conv", Conversation: multiMember}, "", "", true},
+		{"same member", &messengertypes.Interaction{DevicePublicKey: "dev1", ConversationPublicKey: "conv"}, &messengertypes.Interaction{DevicePublicKey: "dev2", ConversationPublicKey: "conv", Conversation: multiMember}, "member", "member", true},
+		{"other member", &messengertypes.Interaction{DevicePublicKey: "dev1", ConversationPublicKey: "conv"}, &messengertypes.Interaction{DevicePublicKey: "dev2", ConversationPublicKey: "conv", Conversation: multiMember}, "member1", "member2", false},
+		{"unknown member", &messengertypes.Interaction{DevicePublicKey: "dev1", ConversationPublicKey: "conv"}, &messengertypes.Interaction{DevicePublicKey: "dev2", ConversationPublicKey: "conv", Conversation: multiMember}, "", "", false},
+		{"contact", &messengertypes.Interaction{DevicePublicKey: "dev1", ConversationPublicKey: "conv"}, &messengertypes.Interaction{DevicePublicKey: "dev2", ConversationPublicKey: "conv", Conversation: contact}, "", "", true},
+		{"contact impersonating the account", &messengertypes.Interaction{IsMe: true, DevicePublicKey: "dev1", ConversationPublicKey: "conv"}, &messengertypes.Interaction{DevicePublicKey: "dev2", ConversationPublicKey: "conv", Conversation: contact}, "me", "contact", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, isSameInteractionAuthor(tc.a, tc.b, tc.authorA, tc.authorB))
+		})
+	}
+}
+
+func Test_eventHandler_applyUserMessageChange(t *testing.T) {
+	handler, dispose := getEventHandlerForTests(t)
+	defer dispose()
+
+	original, err := proto.Marshal(&messengertypes.AppMessage_UserMessage{Body: "original", ReplyToCID: "Qm0001", ThreadRootCID: "Qm0001"})
+	require.NoError(t, err)
+
+	target := &messengertypes.Interaction{CID: "Qm0002", Type: messengertypes.AppMessage_TypeUserMessage, DevicePublicKey: "dev", ConversationPublicKey: "conv", Payload: original, SentDate: 1}
+	require.NoError(t, handler.db.db.Create(target).Error)
+
+	edit, err := proto.Marshal(&messengertypes.AppMessage_EditUserMessage{Target: "Qm0002", Body: "edited"})
+	require.NoError(t, err)
+
+	change := &messengertypes.Interaction{CID: "Qm0003", Type: messengertypes.AppMessage_TypeEditUserMessage, DevicePublicKey: "dev", ConversationPublicKey: "conv", Payload: edit, SentDate: 2}
+
+	updated, err := handler.applyUserMessageChange(handler.db, target, change)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	edited, err := handler.db.getInteractionByCID("Qm0002")
+	require.NoError(t, err)
+
+	// the edit only replaces the body of the message
+	var payload messengertypes.AppMessage_UserMessage
+	require.NoError(t, proto.Unmarshal(edited.GetPayload(), &payload))
+	require.Equal(t, "edited", payload.GetBody())
+	require.Equal(t, "Qm0001", payload.GetReplyToCID())
+	require.Equal(t, "Qm0001", payload.GetThreadRootCID())
+}
+
+func Test_eventHandler_handleAppMessageReadReceiptBacklog(t *testing.T) {
+	handler, dispose := getEventHandlerForTests(t)
+	defer dispose()
+
+	payload := &messengertypes.AppMessage_ReadReceipt{LastReadCID: "Qm0001"}
+	raw, err := proto.Marshal(payload)
+	require.NoError(t, err)
+
+	i := &messengertypes.Interaction{
+		CID:                   "Qm0002",
+		Type:                  messengertypes.AppMessage_TypeReadReceipt,
+		DevicePublicKey:       "dev",
+		ConversationPublicKey: "conv",
+		Conversation:          &messengertypes.Conversation{PublicKey: "conv", Type: messengertypes.Conversation_MultiMemberType},
+		Payload:               raw,
+		SentDate:              1,
+	}
+
+	// the receipt of an unknown member is kept until its device is added
+	_, isNew, err := handler.handleAppMessageReadReceipt(handler.db, i, payload)
+	require.NoError(t, err)
+	require.True(t, isNew)
+
+	var count int64
+	require.NoError(t, handler.db.db.Model(&messengertypes.ReadReceipt{}).Count(&count).Error)
+	require.Equal(t, int64(0), count)
+
+	backlog, err := handler.db.attributeBacklogInteractions("dev", "conv", "member")
+	require.NoError(t, err)
+	require.Len(t, backlog, 1)
+
+	backlog[0].MemberPublicKey = "member"
+	require.NoError(t, handler.applyReadReceipt(handler.db, backlog[0], payload))
+
+	receipt := &messengertypes.ReadReceipt{}
+	require.NoError(t, handler.db.db.First(receipt).Error)
+	require.Equal(t, "member", receipt.GetMemberPublicKey())
+	require.Equal(t, "Qm0001", receipt.GetLastReadCID())
+}
+
 func Test_interactionFromAppMessage(t *testing.T) {
 	// TODO
 	t.Skip("TODO")
@@ -0,0 +1,174 @@
+package bertymessenger
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	_ "image/png" // register png decoder
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+)
+
+const (
+	// mediaMetadataMaxSourceSize is the maximum size of the images kept in memory to extract their metadata
+	mediaMetadataMaxSourceSize = 16 * 1024 * 1024
+
+	// mediaMetadataMaxPixels is the maximum number of pixels of the images decoded to prepare their thumbnail, the header of an
+	// image is checked first as a small file can describe a huge image
+	mediaMetadataMaxPixels = 32 * 1024 * 1024
+
+	// mediaThumbnailMaxDimension is the maximum width and height of the thumbnails
+	mediaThumbnailMaxDimension = 256
+
+	mediaThumbnailQuality = 75
+
+	mediaThumbnailMimeType = "image/jpeg"
+)
+
+// mediaMetadataRecorder counts the bytes of a media while it is being prepared, and keeps a copy of small files to extract their metadata
+type mediaMetadataRecorder struct {
+	size     uint64
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (r *mediaMetadataRecorder) Write(p []byte) (int, error) {
+	r.size += uint64(len(p))
+
+	if !r.overflow {
+		if r.buf.Len()+len(p) > mediaMetadataMaxSourceSize {
+			r.overflow = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+// extract fills the media with the recorded metadata and returns a jpeg thumbnail, the thumbnail is nil when the media is not a
+// supported image
+func (r *mediaMetadataRecorder) extract(media *messengertypes.Media) ([]byte, error) {
+	media.Size = r.size
+
+	if r.overflow || r.buf.Len() == 0 {
+		return nil, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(r.buf.Bytes()))
+	if err != nil {
+		return nil, nil // not a supported image
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("invalid image dimensions %dx%d", cfg.Width, cfg.Height))
+	}
+
+	media.Width = uint32(cfg.Width)
+	media.Height = uint32(cfg.Height)
+
+	if uint64(cfg.Width)*uint64(cfg.Height) > mediaMetadataMaxPixels {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("image too large to prepare a thumbnail: %dx%d", cfg.Width, cfg.Height))
+	}
+
+	if format == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(r.buf.Bytes()))
+		if err != nil {
+			return nil, errcode.ErrDeserialization.Wrap(err)
+		}
+
+		if len(anim.Delay) > 1 {
+			duration := uint64(0)
+			for _, delay := range anim.Delay {
+				duration += uint64(delay) * 10 // delays are in 100ths of second
+			}
+			media.Duration = duration
+		}
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(r.buf.Bytes()))
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	thumbnail := bytes.Buffer{}
+	if err := jpeg.Encode(&thumbnail, mediaThumbnail(src), &jpeg.Options{Quality: mediaThumbnailQuality}); err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return thumbnail.Bytes(), nil
+}
+
+// mediaThumbnail downscales an image using a box filter, transparent areas are rendered on a white background
+func mediaThumbnail(src image.Image) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := srcW, srcH
+	if dstW > mediaThumbnailMaxDimension || dstH > mediaThumbnailMaxDimension {
+		if srcW > srcH {
+			dstW, dstH = mediaThumbnailMaxDimension, srcH*mediaThumbnailMaxDimension/srcW
+		} else {
+			dstW, dstH = srcW*mediaThumbnailMaxDimension/srcH, mediaThumbnailMaxDimension
+		}
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := bounds.Min.Y+y*srcH/dstH, bounds.Min.Y+(y+1)*srcH/dstH
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < dstW; x++ {
+			x0, x1 := bounds.Min.X+x*srcW/dstW, bounds.Min.X+(x+1)*srcW/dstW
+			if x1 == x0 {
+				x1++
+			}
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sr, sg, sb, sa := src.At(sx, sy).RGBA()
+					// blend premultiplied colors over white
+					r += uint64(sr + 0xffff - sa)
+					g += uint64(sg + 0xffff - sa)
+					b += uint64(sb + 0xffff - sa)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: 0xffff})
+		}
+	}
+
+	return dst
+}
+
+// prepareMediaThumbnail stores the thumbnail of a media as a separate encrypted attachment
+func (svc *service) prepareMediaThumbnail(media *messengertypes.Media, recorder *mediaMetadataRecorder) error {
+	thumbnail, err := recorder.extract(media)
+	if err != nil || thumbnail == nil {
+		return err
+	}
+
+	cid, err := svc.attachmentPrepare(bytes.NewReader(thumbnail), false)
+	if err != nil {
+		return errcode.ErrAttachmentPrepare.Wrap(err)
+	}
+
+	media.ThumbnailCID = b64EncodeBytes(cid)
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package bertymessenger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+)
+
+const (
+	// mediaGCGracePeriod protects the medias which have just been prepared and are about to be attached to an interaction
+	mediaGCGracePeriod = time.Hour
+
+	mediaQuotaEvictionBatchSize = 50
+)
+
+func (svc *service) MediaStorageUsage(ctx context.Context, req *messengertypes.MediaStorageUsage_Request) (*messengertypes.MediaStorageUsage_Reply, error) {
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	usages, err := svc.db.getMediaStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	return &messengertypes.MediaStorageUsage_Reply{Conversations: usages, Quota: svc.mediaStorageQuota}, nil
+}
+
+func (svc *service) MediaGC(ctx context.Context, req *messengertypes.MediaGC_Request) (*messengertypes.MediaGC_Reply, error) {
+	svc.handlerMutex.Lock()
+	defer svc.handlerMutex.Unlock()
+
+	removed, freedSize, err := svc.collectMediaGarbage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &messengertypes.MediaGC_Reply{Removed: removed, FreedSize: freedSize}, nil
+}
+
+// collectMediaGarbage removes the unreferenced medias along with their attachments and thumbnails,
+// handlerMutex must be held by the caller
+func (svc *service) collectMediaGarbage(ctx context.Context) (uint32, uint64, error) {
+	medias, err := svc.db.getUnreferencedMedias(timestampMs(time.Now().Add(-mediaGCGracePeriod)))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the blocks shared between the collected medias are not retained by each other, the ones shared with the remaining
+	// medias are kept, they are listed once for all the collected medias
+	removed := []string(nil)
+	cids := []string(nil)
+	freedSize := uint64(0)
+	for _, media := range medias {
+		removed = append(removed, media.GetCID())
+		if thumbnailCID := media.GetThumbnailCID(); thumbnailCID != "" {
+			removed = append(removed, thumbnailCID)
+		}
+
+		cids = append(cids, media.GetCID())
+		freedSize += mediaLocalSize(media)
+	}
+
+	if err := svc.attachmentsRemove(ctx, removed, false); err != nil {
+		return 0, 0, err
+	}
+
+	if err := svc.db.deleteMedias(cids); err != nil {
+		return 0, 0, err
+	}
+
+	svc.logger.Debug("collected unreferenced medias", zap.Int("count", len(cids)), zap.Uint64("freed-size", freedSize))
+
+	return uint32(len(cids)), freedSize, nil
+}
+
+// enforceMediaStorageQuota evicts the local blocks of the least recently used received medias until their size fits in the
+// quota, evicted medias can be downloaded again, the medias being retrieved are kept, handlerMutex must be held by the caller
+func (svc *service) enforceMediaStorageQuota(ctx context.Context) error {
+	if svc.mediaStorageQuota == 0 {
+		return nil
+	}
+
+	size, err := svc.db.getReceivedMediasSize()
+	if err != nil {
+		return err
+	}
+
+	retrieved := make([]string, 0, len(svc.retrievedMedias))
+	for cid := range svc.retrievedMedias {
+		retrieved = append(retrieved, cid)
+	}
+
+	for size > svc.mediaStorageQuota {
+		medias, err := svc.db.getLeastRecentlyUsedReceivedMedias(mediaQuotaEvictionBatchSize, retrieved...)
+		if err != nil {
+			return err
+		}
+
+		if len(medias) == 0 {
+			return nil
+		}
+
+		// the retained blocks are listed once for all the medias evicted from the batch
+		evicted := []*messengertypes.Media(nil)
+		evictedCIDs := []string(nil)
+		for _, media := range medias {
+			if size <= svc.mediaStorageQuota {
+				break
+			}
+
+			evicted = append(evicted, media)
+			evictedCIDs = append(evictedCIDs, media.GetCID())
+			size -= media.GetDownloadedSize()
+		}
+
+		if err := svc.attachmentsRemove(ctx, evictedCIDs, true); err != nil {
+			return err
+		}
+
+		for _, media := range evicted {
+			if err := svc.db.resetMediaDownloadProgress(media.GetCID()); err != nil {
+				return err
+			}
+
+			media.State = messengertypes.Media_StateNeverDownloaded
+			media.DownloadedSize = 0
+
+			if err := svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeMediaUpdated, &messengertypes.StreamEvent_MediaUpdated{Media: media}, false); err != nil {
+				svc.logger.Error("unable to dispatch notification for media", zap.String("cid", media.GetCID()), zap.Error(err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkMediaStorageQuota refuses the retrieval of a received media which can't fit in the quota, it would be evicted as soon as it
+// has been downloaded
+func (svc *service) checkMediaStorageQuota(media *messengertypes.Media) error {
+	if svc.mediaStorageQuota == 0 || media.GetSize() <= svc.mediaStorageQuota {
+		return nil
+	}
+
+	for _, state := range mediaSentStates {
+		if media.GetState() == state {
+			return nil
+		}
+	}
+
+	return errcode.ErrMessengerMediaLargerThanQuota.Wrap(fmt.Errorf("media size %d exceeds the storage quota %d", media.GetSize(), svc.mediaStorageQuota))
+}
+
+// startMediaRetrieval protects a media from the quota enforcement while it is being retrieved, handlerMutex must be held by the
+// caller
+func (svc *service) startMediaRetrieval(cid string) {
+	svc.retrievedMedias[cid]++
+}
+
+// endMediaRetrieval allows a media to be evicted again once all of its retrievals have ended, handlerMutex must be held by the
+// caller
+func (svc *service) endMediaRetrieval(cid string) {
+	if svc.retrievedMedias[cid] <= 1 {
+		delete(svc.retrievedMedias, cid)
+		return
+	}
+
+	svc.retrievedMedias[cid]--
+}
+
+// mediaLocalSize returns the number of bytes of a media stored on the device
+func mediaLocalSize(media *messengertypes.Media) uint64 {
+	for _, state := range mediaSentStates {
+		if media.GetState() == state {
+			return media.GetSize()
+		}
+	}
+
+	return media.GetDownloadedSize()
+}
@@ -0,0 +1,117 @@
+package bertymessenger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+)
+
+func TestMediaMetadataRecorder(t *testing.T) {
+	// not an image
+	recorder := &mediaMetadataRecorder{}
+	_, err := recorder.Write([]byte("hello world!"))
+	require.NoError(t, err)
+
+	media := &messengertypes.Media{}
+	thumbnail, err := recorder.extract(media)
+	require.NoError(t, err)
+	require.Nil(t, thumbnail)
+	require.Equal(t, &messengertypes.Media{Size: 12}, media)
+
+	// png
+	src := image.NewRGBA(image.Rect(0, 0, 512, 128))
+	for x := 0; x < 512; x++ {
+		src.Set(x, x%128, color.RGBA{R: 0xff, A: 0xff})
+	}
+	buf := bytes.Buffer{}
+	require.NoError(t, png.Encode(&buf, src))
+
+	recorder = &mediaMetadataRecorder{}
+	_, err = recorder.Write(buf.Bytes())
+	require.NoError(t, err)
+
+	media = &messengertypes.Media{}
+	thumbnail, err = recorder.extract(media)
+	require.NoError(t, err)
+	require.Equal(t, uint64(buf.Len()), media.Size)
+	require.Equal(t, uint32(512), media.Width)
+	require.Equal(t, uint32(128), media.Height)
+	require.Zero(t, media.Duration)
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+	require.NoError(t, err)
+	require.Equal(t, mediaThumbnailMaxDimension, cfg.Width)
+	require.Equal(t, mediaThumbnailMaxDimension/4, cfg.Height)
+
+	// animated gif
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{
+		Image: []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 16, 8), palette), image.NewPaletted(image.Rect(0, 0, 16, 8), palette)},
+		Delay: []int{50, 25},
+	}
+	buf = bytes.Buffer{}
+	require.NoError(t, gif.EncodeAll(&buf, anim))
+
+	recorder = &mediaMetadataRecorder{}
+	_, err = recorder.Write(buf.Bytes())
+	require.NoError(t, err)
+
+	media = &messengertypes.Media{}
+	thumbnail, err = recorder.extract(media)
+	require.NoError(t, err)
+	require.NotNil(t, thumbnail)
+	require.Equal(t, uint32(16), media.Width)
+	require.Equal(t, uint32(8), media.Height)
+	require.Equal(t, uint64(750), media.Duration)
+
+	// huge image described by a small file
+	buf = bytes.Buffer{}
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+
+	huge := buf.Bytes()
+	binary.BigEndian.PutUint32(huge[16:20], 1<<15)
+	binary.BigEndian.PutUint32(huge[20:24], 1<<15)
+	binary.BigEndian.PutUint32(huge[29:33], crc32.ChecksumIEEE(huge[12:29]))
+
+	recorder = &mediaMetadataRecorder{}
+	_, err = recorder.Write(huge)
+	require.NoError(t, err)
+
+	media = &messengertypes.Media{}
+	thumbnail, err = recorder.extract(media)
+	require.Error(t, err)
+	require.Nil(t, thumbnail)
+	require.Equal(t, uint32(1<<15), media.Width)
+	require.Equal(t, uint32(1<<15), media.Height)
+}
+
+func TestServiceMediaStorageQuota(t *testing.T) {
+	svc := &service{mediaStorageQuota: 100, retrievedMedias: make(map[string]uint)}
+
+	// received medias larger than the quota are refused, the sent ones are always available
+	require.NoError(t, svc.checkMediaStorageQuota(&messengertypes.Media{State: messengertypes.Media_StateNeverDownloaded, Size: 100}))
+	require.True(t, errcode.Is(svc.checkMediaStorageQuota(&messengertypes.Media{State: messengertypes.Media_StateNeverDownloaded, Size: 101}), errcode.ErrMessengerMediaLargerThanQuota))
+	require.NoError(t, svc.checkMediaStorageQuota(&messengertypes.Media{State: messengertypes.Media_StatePrepared, Size: 101}))
+
+	// a media is protected until all of its retrievals have ended
+	svc.startMediaRetrieval("cid")
+	svc.startMediaRetrieval("cid")
+	svc.endMediaRetrieval("cid")
+	require.Contains(t, svc.retrievedMedias, "cid")
+	svc.endMediaRetrieval("cid")
+	require.NotContains(t, svc.retrievedMedias, "cid")
+
+	svc.mediaStorageQuota = 0
+	require.NoError(t, svc.checkMediaStorageQuota(&messengertypes.Media{State: messengertypes.Media_StateNeverDownloaded, Size: 101}))
+}
@@ -32,6 +32,11 @@ func replayLogsToDB(ctx context.Context, client protocoltypes.ProtocolServiceCli
 		return errcode.ErrDBWrite.Wrap(err)
 	}
 
+	// Create the search index before replaying so interactions are indexed as they are added
+	if err := wrappedDB.initSearchIndex(); err != nil {
+		return errcode.ErrDBWrite.Wrap(err)
+	}
+
 	handler := newEventHandler(ctx, wrappedDB, client, zap.NewNop(), nil, true)
 
 	// Replay all account group metadata events
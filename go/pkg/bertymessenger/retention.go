@@ -0,0 +1,127 @@
+package bertymessenger
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+const (
+	messageRetentionPurgeInterval  = time.Minute
+	messageRetentionPurgeBatchSize = 500
+)
+
+// monitorMessageRetention periodically purges the interactions that expired
+func (svc *service) monitorMessageRetention(ctx context.Context) {
+	ticker := time.NewTicker(messageRetentionPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		svc.handlerMutex.Lock()
+		err := svc.purgeExpiredInteractions(ctx)
+		svc.handlerMutex.Unlock()
+
+		if err != nil {
+			svc.logger.Error("unable to purge expired interactions", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeExpiredInteractions removes the expired interactions, their medias and attachments,
+// handlerMutex must be held by the caller
+func (svc *service) purgeExpiredInteractions(ctx context.Context) error {
+	for {
+		cids, err := svc.db.getExpiredInteractionsCIDs(time.Now(), messageRetentionPurgeBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(cids) == 0 {
+			return nil
+		}
+
+		medias, err := svc.db.purgeInteractions(cids)
+		if err != nil {
+			return err
+		}
+
+		// the retained blocks are listed once for all the medias of the batch
+		mediaCIDs := make([]string, len(medias))
+		for i, media := range medias {
+			mediaCIDs[i] = media.GetCID()
+		}
+
+		if err := svc.attachmentsRemove(ctx, mediaCIDs, false); err != nil {
+			svc.logger.Warn("unable to remove expired attachments", zap.Strings("cids", mediaCIDs), zap.Error(err))
+		}
+
+		for _, cid := range cids {
+			if err := svc.dispatcher.StreamEvent(messengertypes.StreamEvent_TypeInteractionDeleted, &messengertypes.StreamEvent_InteractionDeleted{CID: cid}, false); err != nil {
+				svc.logger.Error("unable to dispatch interaction deletion", zap.String("cid", cid), zap.Error(err))
+			}
+		}
+
+		svc.logger.Debug("purged expired interactions", zap.Int("count", len(cids)), zap.Int("medias", len(medias)))
+
+		if len(cids) < messageRetentionPurgeBatchSize {
+			return nil
+		}
+	}
+}
+
+// attachmentRemove removes the local blocks of an attachment and forgets its secret unless keepSecret is set, see
+// attachmentsRemove
+func (svc *service) attachmentRemove(ctx context.Context, cid string, keepSecret bool, released ...string) error {
+	return svc.attachmentsRemove(ctx, []string{cid}, keepSecret, released...)
+}
+
+// attachmentsRemove removes the local blocks of attachments and forgets their secrets unless keepSecret is set, the blocks
+// shared with the other medias stored on the device are kept, as well as the attachments still in use, the released cids
+// are removed along with them and aren't considered in use
+func (svc *service) attachmentsRemove(ctx context.Context, cids []string, keepSecret bool, released ...string) error {
+	if len(cids) == 0 {
+		return nil
+	}
+
+	cidsBytes := make([][]byte, len(cids))
+	for i, cid := range cids {
+		var err error
+		if cidsBytes[i], err = b64DecodeBytes(cid); err != nil {
+			return errcode.ErrDeserialization.Wrap(err)
+		}
+	}
+
+	retained, err := svc.db.getLocalMediaCIDs(append(released, cids...)...)
+	if err != nil {
+		return err
+	}
+
+	retainedBytes := make([][]byte, len(retained))
+	for i, r := range retained {
+		if retainedBytes[i], err = b64DecodeBytes(r); err != nil {
+			return errcode.ErrDeserialization.Wrap(err)
+		}
+	}
+
+	if _, err := svc.protocolClient.AttachmentRemove(ctx, &protocoltypes.AttachmentRemove_Request{
+		AttachmentCID:          cidsBytes[0],
+		OtherAttachmentCIDs:    cidsBytes[1:],
+		KeepSecret:             keepSecret,
+		RetainedAttachmentCIDs: retainedBytes,
+	}); err != nil {
+		return errcode.ErrAttachmentRemove.Wrap(err)
+	}
+
+	return nil
+}
@@ -52,6 +52,8 @@ type service struct {
 	notifmanager          notification.Manager
 	lcmanager             *lifecycle.Manager
 	eventHandler          *eventHandler
+	mediaStorageQuota     uint64
+	retrievedMedias       map[string]uint
 }
 
 type Opts struct {
@@ -61,6 +63,10 @@ type Opts struct {
 	NotificationManager notification.Manager
 	LifeCycleManager    *lifecycle.Manager
 	StateBackup         *messengertypes.LocalDatabaseState
+
+	// MediaStorageQuota is the maximum size in bytes of the received medias kept in cache, the least recently used
+	// ones are evicted first, zero means unlimited
+	MediaStorageQuota uint64
 }
 
 func (opts *Opts) applyDefaults() (func(), error) {
@@ -180,6 +186,8 @@ func New(client protocoltypes.ProtocolServiceClient, opts *Opts) (Service, error
 		optsCleanup:           optsCleanup,
 		ctx:                   ctx,
 		handlerMutex:          sync.Mutex{},
+		mediaStorageQuota:     opts.MediaStorageQuota,
+		retrievedMedias:       make(map[string]uint),
 	}
 
 	svc.eventHandler = newEventHandler(ctx, db, client, opts.Logger, &svc, false)
@@ -217,6 +225,9 @@ func New(client protocoltypes.ProtocolServiceClient, opts *Opts) (Service, error
 	// monitor messenger lifecycle
 	go svc.monitorState(ctx)
 
+	// purge interactions older than the retention of their conversation
+	go svc.monitorMessageRetention(ctx)
+
 	// Dispatch app notifications to native manager
 	svc.dispatcher.Register(&NotifieeBundle{StreamEventImpl: func(se *messengertypes.StreamEvent) error {
 		if se.GetType() != messengertypes.StreamEvent_TypeNotified {
@@ -429,14 +440,14 @@ func (svc *service) subscribeToGroup(gpkb []byte) error {
 	return svc.subscribeToMessages(gpkb)
 }
 
-func (svc *service) attachmentPrepare(attachment io.Reader) ([]byte, error) {
+func (svc *service) attachmentPrepare(attachment io.Reader, disableEncryption bool) ([]byte, error) {
 	stream, err := svc.protocolClient.AttachmentPrepare(svc.ctx)
 	if err != nil {
 		return nil, errcode.ErrAttachmentPrepare.Wrap(err)
 	}
 
 	// send header
-	if err := stream.Send(&protocoltypes.AttachmentPrepare_Request{}); err != nil {
+	if err := stream.Send(&protocoltypes.AttachmentPrepare_Request{DisableEncryption: disableEncryption}); err != nil {
 		return nil, errcode.ErrStreamHeaderWrite.Wrap(err)
 	}
 
@@ -455,13 +466,18 @@ func (svc *service) attachmentPrepare(attachment io.Reader) ([]byte, error) {
 	return reply.GetAttachmentCID(), nil
 }
 
-func (svc *service) attachmentRetrieve(cid string) (*io.PipeReader, error) {
+func (svc *service) attachmentRetrieve(cid string, disableEncryption bool, offset, length uint64) (*io.PipeReader, error) {
 	cidBytes, err := b64DecodeBytes(cid)
 	if err != nil {
 		return nil, errcode.ErrDeserialization.Wrap(err)
 	}
 
-	stream, err := svc.protocolClient.AttachmentRetrieve(svc.ctx, &protocoltypes.AttachmentRetrieve_Request{AttachmentCID: cidBytes})
+	stream, err := svc.protocolClient.AttachmentRetrieve(svc.ctx, &protocoltypes.AttachmentRetrieve_Request{
+		AttachmentCID:     cidBytes,
+		DisableEncryption: disableEncryption,
+		Offset:            offset,
+		Length:            length,
+	})
 	if err != nil {
 		return nil, errcode.ErrAttachmentRetrieve.Wrap(err)
 	}
@@ -482,24 +498,38 @@ func (svc *service) sendAccountUserInfo(groupPK string) error {
 	var attachmentCIDs [][]byte
 	var medias []*messengertypes.Media
 	if acc.GetAvatarCID() != "" {
-		// TODO: add AttachmentRecrypt to bertyprotocol
-		avatar, err := svc.attachmentRetrieve(acc.GetAvatarCID())
-		if err != nil {
-			return errcode.ErrAttachmentRetrieve.Wrap(err)
-		}
-		avatarCIDBytes, err := svc.attachmentPrepare(avatar)
-		if err != nil {
-			return errcode.ErrAttachmentPrepare.Wrap(err)
-		}
-		avatarCID = b64EncodeBytes(avatarCIDBytes)
-		attachmentCIDs = [][]byte{avatarCIDBytes}
-
 		if medias, err = svc.db.getMedias([]string{acc.GetAvatarCID()}); err != nil {
 			return errcode.ErrDBRead.Wrap(err)
 		}
 		if len(medias) < 1 {
 			return errcode.ErrInternal
 		}
+
+		if medias[0].GetUnencrypted() {
+			// plaintext avatars can be shared as is
+			avatarCID = acc.GetAvatarCID()
+		} else {
+			// TODO: add AttachmentRecrypt to bertyprotocol
+			avatar, err := svc.attachmentRetrieve(acc.GetAvatarCID(), false, 0, 0)
+			if err != nil {
+				return errcode.ErrAttachmentRetrieve.Wrap(err)
+			}
+			avatarCIDBytes, err := svc.attachmentPrepare(avatar, false)
+			if err != nil {
+				return errcode.ErrAttachmentPrepare.Wrap(err)
+			}
+			avatarCID = b64EncodeBytes(avatarCIDBytes)
+			attachmentCIDs = [][]byte{avatarCIDBytes}
+		}
+
+		if thumbnailCID := medias[0].GetThumbnailCID(); thumbnailCID != "" {
+			thumbnailCIDBytes, err := b64DecodeBytes(thumbnailCID)
+			if err != nil {
+				return errcode.ErrDeserialization.Wrap(err)
+			}
+			attachmentCIDs = append(attachmentCIDs, thumbnailCIDBytes)
+		}
+
 		medias[0].CID = avatarCID
 	}
 
@@ -1660,8 +1660,11 @@ func TestSendMedia(t *testing.T) {
 	require.NotEmpty(t, cid)
 	require.Equal(t, b64CID, cid)
 
-	// check media
+	// the download progress is sent on the event stream
+	events, err := friend.client.EventStream(ctx, &messengertypes.EventStream_Request{})
 	require.NoError(t, err)
+
+	// check media
 	retStream, err := friend.client.MediaRetrieve(ctx, &messengertypes.MediaRetrieve_Request{Cid: b64CID})
 	require.NoError(t, err)
 
@@ -1669,6 +1672,7 @@ func TestSendMedia(t *testing.T) {
 	expectedMedia := testMedia
 	expectedMedia.CID = cid
 	expectedMedia.InteractionCID = inte.GetCID()
+	expectedMedia.Size = uint64(len(testData))
 	expectedMedia.State = messengertypes.Media_StateNeverDownloaded // FIXME: should be Media_StateInCache
 
 	// get and check header
@@ -1688,9 +1692,104 @@ func TestSendMedia(t *testing.T) {
 	}
 	require.Equal(t, testData, data)
 
-	// check that the media was sent on the event stream
-	clientMedia := friend.GetMedia(t, cid)
+	// check that the download progress was sent on the event stream
+	expectedMedia.State = messengertypes.Media_StateDownloaded
+	expectedMedia.DownloadedSize = uint64(len(testData))
+	clientMedia := (*messengertypes.Media)(nil)
+	for clientMedia == nil {
+		rsp, err := events.Recv()
+		require.NoError(t, err)
+
+		if rsp.GetEvent().GetType() != messengertypes.StreamEvent_TypeMediaUpdated {
+			continue
+		}
+
+		payload, err := rsp.GetEvent().UnmarshalPayload()
+		require.NoError(t, err)
+
+		if media := payload.(*messengertypes.StreamEvent_MediaUpdated).Media; media.GetCID() == cid && media.GetState() == messengertypes.Media_StateDownloaded {
+			clientMedia = media
+		}
+	}
+	require.NotZero(t, clientMedia.LastAccessDate)
+	expectedMedia.LastAccessDate = clientMedia.LastAccessDate
 	require.Equal(t, &expectedMedia, clientMedia)
+
+	// resume the download at an offset
+	retStream, err = friend.client.MediaRetrieve(ctx, &messengertypes.MediaRetrieve_Request{Cid: b64CID, Offset: split, Length: 3})
+	require.NoError(t, err)
+	_, err = retStream.Recv() // header
+	require.NoError(t, err)
+	data = []byte(nil)
+	for {
+		rsp, err := retStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data = append(data, rsp.GetBlock()...)
+	}
+	require.Equal(t, testData[split:split+3], data)
+}
+
+func TestMediaPrepareUnencrypted(t *testing.T) {
+	testutil.FilterStabilityAndSpeed(t, testutil.Stable, testutil.Slow)
+
+	logger, cleanup := testutil.Logger(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	clients, protocols, cleanup := TestingInfra(ctx, t, 1, logger)
+	defer cleanup()
+
+	user := NewTestingAccount(ctx, t, clients[0], protocols[0].Client, logger)
+	close := user.ProcessWholeStream(t)
+	defer close()
+
+	testData := []byte("hello world!")
+	testMedia := messengertypes.Media{MimeType: "image/png", Filename: "avatar.png", Unencrypted: true}
+
+	prepare := func() string {
+		stream, err := user.client.MediaPrepare(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&messengertypes.MediaPrepare_Request{Info: &testMedia})) // send header
+		require.NoError(t, stream.Send(&messengertypes.MediaPrepare_Request{Block: testData}))  // send block
+		reply, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		return reply.GetCid()
+	}
+
+	// plaintext files are deduplicated
+	b64CID := prepare()
+	require.Equal(t, b64CID, prepare())
+
+	// no secret is stored for plaintext files
+	cidBytes, err := b64DecodeBytes(b64CID)
+	require.NoError(t, err)
+	encStream, err := protocols[0].Client.AttachmentRetrieve(ctx, &protocoltypes.AttachmentRetrieve_Request{AttachmentCID: cidBytes})
+	require.NoError(t, err)
+	_, err = encStream.Recv()
+	require.Error(t, err)
+
+	retStream, err := user.client.MediaRetrieve(ctx, &messengertypes.MediaRetrieve_Request{Cid: b64CID})
+	require.NoError(t, err)
+
+	header, err := retStream.Recv()
+	require.NoError(t, err)
+	require.True(t, header.GetInfo().GetUnencrypted())
+
+	data := []byte(nil)
+	for {
+		rsp, err := retStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data = append(data, rsp.GetBlock()...)
+	}
+	require.Equal(t, testData, data)
 }
 
 func Test_exportMessengerData(t *testing.T) {
@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"time"
 
+	"gorm.io/gorm"
+
 	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/messengertypes"
 	"berty.tech/berty/v2/go/pkg/protocoltypes"
 )
 
@@ -26,6 +29,36 @@ func checkDeviceIsMe(ctx context.Context, client protocoltypes.ProtocolServiceCl
 	return bytes.Equal(dpk, mdpk), nil
 }
 
+// checkInteractionIsFromAccount returns true if the interaction has been sent by any of the devices of the account
+func checkInteractionIsFromAccount(ctx context.Context, client protocoltypes.ProtocolServiceClient, db *dbWrapper, i *messengertypes.Interaction) (bool, error) {
+	if i.GetIsMe() {
+		return true, nil
+	}
+
+	if i.GetDevicePublicKey() == "" {
+		return false, nil
+	}
+
+	device, err := db.getDeviceByPK(i.GetDevicePublicKey())
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, errcode.ErrDBRead.Wrap(err)
+	}
+
+	gpkb, err := b64DecodeBytes(i.GetConversationPublicKey())
+	if err != nil {
+		return false, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	gi, err := client.GroupInfo(ctx, &protocoltypes.GroupInfo_Request{GroupPK: gpkb})
+	if err != nil {
+		return false, errcode.ErrGroupInfo.Wrap(err)
+	}
+
+	return device.GetMemberPublicKey() == b64EncodeBytes(gi.GetMemberPK()), nil
+}
+
 func groupPKFromContactPK(ctx context.Context, client protocoltypes.ProtocolServiceClient, contactPK []byte) ([]byte, error) {
 	req := &protocoltypes.GroupInfo_Request{ContactPK: contactPK}
 	groupInfo, err := client.GroupInfo(ctx, req)
@@ -52,3 +85,8 @@ func b64DecodeBytes(s string) ([]byte, error) {
 func timestampMs(t time.Time) int64 {
 	return t.UnixNano() / 1000000
 }
+
+// isExpired returns true if a date in milliseconds is older than a retention in seconds, a zero retention never expires
+func isExpired(dateMs int64, retention int64, now time.Time) bool {
+	return retention > 0 && dateMs+retention*1000 <= timestampMs(now)
+}
@@ -341,6 +341,35 @@ func readExportCBORNode(expectedSize int64, cidStr string, reader *tar.Reader) (
 	return node, nil
 }
 
+// CopyAccountExport copies the files of an account export to a tar writer, the trailer of the archive isn't written
+// so more files can be appended, every orbitdb entry is kept as the logs can't be loaded with missing entries
+func CopyAccountExport(reader io.Reader, tw *tar.Writer) error {
+	tr := tar.NewReader(reader)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errcode.ErrStreamRead.Wrap(err)
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return errcode.ErrStreamWrite.Wrap(err)
+		}
+
+		if _, err := io.Copy(tw, tr); err != nil {
+			return errcode.ErrStreamWrite.Wrap(err)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return errcode.ErrStreamWrite.Wrap(err)
+	}
+
+	return nil
+}
+
 type RestoreAccountHandler struct {
 	Handler     func(header *tar.Header, reader *tar.Reader) (bool, error)
 	PostProcess func() error
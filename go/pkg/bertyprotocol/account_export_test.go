@@ -190,6 +190,22 @@ func TestUnstableRestoreAccount(t *testing.T) {
 	_, err = tmpFile.Seek(0, io.SeekStart)
 	require.NoError(t, err)
 
+	// the export is copied with appended files, as done by the messenger, the logs must still be fully loaded
+	copyFile, err := ioutil.TempFile(os.TempDir(), "test-export-")
+	require.NoError(t, err)
+
+	defer os.Remove(copyFile.Name())
+
+	tw := tar.NewWriter(copyFile)
+	require.NoError(t, CopyAccountExport(tmpFile, tw))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "appended", Mode: 0o600, Size: 3}))
+	_, err = tw.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	_, err = copyFile.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
 	{
 		dsB := dsync.MutexWrap(ds.NewMapDatastore())
 		ipfsNodeB, cleanupNodeB := ipfsutil.TestingCoreAPIUsingMockNet(ctx, t, &ipfsutil.TestingAPIOpts{
@@ -211,7 +227,7 @@ func TestUnstableRestoreAccount(t *testing.T) {
 		})
 		require.NoError(t, err)
 
-		err = RestoreAccountExport(ctx, tmpFile, ipfsNodeB.API(), odb, logger)
+		err = RestoreAccountExport(ctx, copyFile, ipfsNodeB.API(), odb, logger)
 		require.NoError(t, err)
 
 		nodeB, closeNodeB := NewTestingProtocol(ctx, t, &TestingOpts{
@@ -272,3 +288,64 @@ func TestUnstableRestoreAccount(t *testing.T) {
 	}
 	// TODO: test account metadata entries
 }
+
+func TestCopyAccountExport(t *testing.T) {
+	files := map[string]string{
+		exportAccountKeyFilename:              "account key",
+		exportOrbitDBEntriesPrefix + "first":  "first entry",
+		exportOrbitDBEntriesPrefix + "second": "second entry",
+		exportOrbitDBHeadsPrefix + "group":    "heads",
+	}
+
+	src, err := ioutil.TempFile(os.TempDir(), "test-export-")
+	require.NoError(t, err)
+	defer os.Remove(src.Name())
+
+	tw := tar.NewWriter(src)
+	for _, name := range []string{exportAccountKeyFilename, exportOrbitDBEntriesPrefix + "first", exportOrbitDBEntriesPrefix + "second", exportOrbitDBHeadsPrefix + "group"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o600, Size: int64(len(files[name]))}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	_, err = src.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	dst, err := ioutil.TempFile(os.TempDir(), "test-export-")
+	require.NoError(t, err)
+	defer os.Remove(dst.Name())
+
+	tw = tar.NewWriter(dst)
+	require.NoError(t, CopyAccountExport(src, tw))
+
+	// files can be appended to the copy
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "appended", Mode: 0o600, Size: 3}))
+	_, err = tw.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	_, err = dst.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	names := []string(nil)
+	tr := tar.NewReader(dst)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+
+		if expected, ok := files[header.Name]; ok {
+			require.Equal(t, expected, string(data))
+		}
+
+		names = append(names, header.Name)
+	}
+
+	require.Equal(t, []string{exportAccountKeyFilename, exportOrbitDBEntriesPrefix + "first", exportOrbitDBEntriesPrefix + "second", exportOrbitDBHeadsPrefix + "group", "appended"}, names)
+}
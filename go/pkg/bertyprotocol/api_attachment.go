@@ -1,12 +1,17 @@
 package bertyprotocol
 
 import (
+	"context"
 	"errors"
+	"io"
+	"strings"
 
 	ipfscid "github.com/ipfs/go-cid"
 	ipfsfiles "github.com/ipfs/go-ipfs-files"
+	ipfsinterface "github.com/ipfs/interface-go-ipfs-core"
 	ipfsoptions "github.com/ipfs/interface-go-ipfs-core/options"
 	ipfspath "github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/libp2p/go-libp2p-core/crypto"
 
 	"berty.tech/berty/v2/go/internal/streamutil"
 	"berty.tech/berty/v2/go/pkg/errcode"
@@ -22,9 +27,6 @@ func (s *service) AttachmentPrepare(stream protocoltypes.ProtocolService_Attachm
 	if len(headerMsg.GetBlock()) > 0 {
 		return errcode.ErrInvalidInput.Wrap(errors.New("unexpected non-empty block"))
 	}
-	if headerMsg.GetDisableEncryption() {
-		return errcode.ErrNotImplemented.Wrap(errors.New("disable_encryption not implemented"))
-	}
 	s.logger.Debug("AttachmentPrepare: header received")
 
 	// open requests reader
@@ -34,26 +36,32 @@ func (s *service) AttachmentPrepare(stream protocoltypes.ProtocolService_Attachm
 	}, s.logger)
 	defer plaintext.Close()
 
-	// open stream cipher
-	sk, ciphertext, err := attachmentSealer(plaintext, s.logger)
-	if err != nil {
-		return errcode.ErrCryptoCipherInit.Wrap(err)
-	}
-	defer ciphertext.Close()
-
-	// sink ciphertext to ipfs
-	ipfsFile := ipfsfiles.NewReaderFile(ciphertext)
-	defer ipfsFile.Close()
-	ipfsPath, err := s.ipfsCoreAPI.Unixfs().Add(stream.Context(), ipfsFile, attachmentForcePin)
-	if err != nil {
-		return errcode.ErrIPFSAdd.Wrap(err)
-	}
-	cid := ipfsPath.Cid().Bytes()
-
-	// store associated private key
-	err = s.deviceKeystore.AttachmentPrivKeyPut(cid, sk)
-	if err != nil {
-		return errcode.ErrKeystorePut.Wrap(err)
+	var cid []byte
+	if headerMsg.GetDisableEncryption() {
+		// sink plaintext to ipfs, the same file will always have the same cid
+		cid, err = s.attachmentAdd(stream.Context(), plaintext)
+		if err != nil {
+			return err
+		}
+	} else {
+		// open stream cipher
+		sk, ciphertext, err := attachmentSealer(plaintext, s.logger)
+		if err != nil {
+			return errcode.ErrCryptoCipherInit.Wrap(err)
+		}
+		defer ciphertext.Close()
+
+		// sink ciphertext to ipfs
+		cid, err = s.attachmentAdd(stream.Context(), ciphertext)
+		if err != nil {
+			return err
+		}
+
+		// store associated private key
+		err = s.deviceKeystore.AttachmentPrivKeyPut(cid, sk)
+		if err != nil {
+			return errcode.ErrKeystorePut.Wrap(err)
+		}
 	}
 
 	// return cid to client
@@ -72,27 +80,42 @@ func (s *service) AttachmentRetrieve(req *protocoltypes.AttachmentRetrieve_Reque
 		return errcode.ErrDeserialization.Wrap(err)
 	}
 
-	// get associated private key
-	sk, err := s.deviceKeystore.AttachmentPrivKey(req.GetAttachmentCID())
-	if err != nil {
-		return errcode.ErrKeystoreGet.Wrap(err)
+	// get associated private key, plaintext files don't have one
+	var sk crypto.PrivKey
+	if !req.GetDisableEncryption() {
+		sk, err = s.deviceKeystore.AttachmentPrivKey(req.GetAttachmentCID())
+		if err != nil {
+			return errcode.ErrKeystoreGet.Wrap(err)
+		}
 	}
 
-	// open ciphertext reader
+	// open ipfs file reader
 	ipfsNode, err := s.ipfsCoreAPI.Unixfs().Get(stream.Context(), ipfspath.IpfsPath(cid))
 	if err != nil {
 		return errcode.ErrIPFSGet.Wrap(err)
 	}
 	defer ipfsNode.Close()
-	ciphertext := ipfsfiles.ToFile(ipfsNode)
-	defer ciphertext.Close()
+	ipfsFile := ipfsfiles.ToFile(ipfsNode)
+	defer ipfsFile.Close()
 
-	// open stream cipher
-	plaintext, err := attachmentOpener(ciphertext, sk, s.logger)
-	if err != nil {
-		return errcode.ErrCryptoCipherInit.Wrap(err)
+	var plaintext io.Reader = ipfsFile
+	if sk != nil {
+		// open stream cipher at the requested offset
+		opener, err := attachmentOpenerAt(ipfsFile, sk, req.GetOffset(), s.logger)
+		if err != nil {
+			return errcode.ErrCryptoCipherInit.Wrap(err)
+		}
+		defer opener.Close()
+		plaintext = opener
+	} else if req.GetOffset() > 0 {
+		if _, err := ipfsFile.Seek(int64(req.GetOffset()), io.SeekStart); err != nil {
+			return errcode.ErrStreamRead.Wrap(err)
+		}
+	}
+
+	if req.GetLength() > 0 {
+		plaintext = io.LimitReader(plaintext, int64(req.GetLength()))
 	}
-	defer plaintext.Close()
 
 	// sink plaintext to client
 	if err := streamutil.FuncSink(make([]byte, 64*1024), plaintext, func(block []byte) error {
@@ -105,6 +128,118 @@ func (s *service) AttachmentRetrieve(req *protocoltypes.AttachmentRetrieve_Reque
 	return nil
 }
 
+func (s *service) AttachmentRemove(ctx context.Context, req *protocoltypes.AttachmentRemove_Request) (*protocoltypes.AttachmentRemove_Reply, error) {
+	cids := make([]ipfscid.Cid, 0, 1+len(req.GetOtherAttachmentCIDs()))
+	for _, cidBytes := range append([][]byte{req.GetAttachmentCID()}, req.GetOtherAttachmentCIDs()...) {
+		cid, err := ipfscid.Cast(cidBytes)
+		if err != nil {
+			return nil, errcode.ErrDeserialization.Wrap(err)
+		}
+
+		cids = append(cids, cid)
+	}
+
+	retainedCIDs := make([]ipfscid.Cid, len(req.GetRetainedAttachmentCIDs()))
+	retainedKeys := make(map[string]struct{}, len(retainedCIDs))
+	for i, retained := range req.GetRetainedAttachmentCIDs() {
+		var err error
+		if retainedCIDs[i], err = ipfscid.Cast(retained); err != nil {
+			return nil, errcode.ErrDeserialization.Wrap(err)
+		}
+
+		retainedKeys[retainedCIDs[i].KeyString()] = struct{}{}
+	}
+
+	// don't fetch missing blocks from the network only to remove them
+	offlineAPI, err := s.ipfsCoreAPI.WithOptions(ipfsoptions.Api.Offline(true))
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	// the blocks shared with other attachments are kept, they are listed once for all the removed attachments
+	retainedBlocks := map[string]struct{}{}
+	for _, retained := range retainedCIDs {
+		attachmentListBlocks(ctx, offlineAPI, retained, retainedBlocks)
+	}
+
+	for _, cid := range cids {
+		// the attachment is still in use, nothing can be removed
+		if _, ok := retainedKeys[cid.KeyString()]; ok {
+			continue
+		}
+
+		if !req.GetKeepSecret() {
+			// forget the key first, remaining copies of the blocks can't be decrypted anymore
+			if err := s.deviceKeystore.AttachmentPrivKeyRemove(cid.Bytes()); err != nil {
+				return nil, errcode.ErrKeystoreDelete.Wrap(err)
+			}
+
+			// attachments are only pinned on the device that prepared them
+			path := ipfspath.IpfsPath(cid)
+			if err := s.ipfsCoreAPI.Pin().Rm(ctx, path); err != nil && !strings.Contains(err.Error(), "not pinned") {
+				return nil, errcode.ErrIPFSRemove.Wrap(err)
+			}
+		}
+
+		if err := attachmentRemoveBlocks(ctx, offlineAPI, cid, retainedBlocks); err != nil {
+			return nil, errcode.ErrIPFSRemove.Wrap(err)
+		}
+	}
+
+	return &protocoltypes.AttachmentRemove_Reply{}, nil
+}
+
+// attachmentAdd pins the content of the reader to ipfs and returns its cid
+func (s *service) attachmentAdd(ctx context.Context, r io.Reader) ([]byte, error) {
+	ipfsFile := ipfsfiles.NewReaderFile(r)
+	defer ipfsFile.Close()
+
+	ipfsPath, err := s.ipfsCoreAPI.Unixfs().Add(ctx, ipfsFile, attachmentForcePin)
+	if err != nil {
+		return nil, errcode.ErrIPFSAdd.Wrap(err)
+	}
+
+	return ipfsPath.Cid().Bytes(), nil
+}
+
+// attachmentListBlocks adds the cids of the locally available blocks of a dag to blocks
+func attachmentListBlocks(ctx context.Context, api ipfsinterface.CoreAPI, cid ipfscid.Cid, blocks map[string]struct{}) {
+	if _, ok := blocks[cid.KeyString()]; ok {
+		return
+	}
+
+	node, err := api.Dag().Get(ctx, cid)
+	if err != nil {
+		return // not available locally
+	}
+
+	blocks[cid.KeyString()] = struct{}{}
+
+	for _, link := range node.Links() {
+		attachmentListBlocks(ctx, api, link.Cid, blocks)
+	}
+}
+
+// attachmentRemoveBlocks removes the locally available blocks of a dag, except the retained ones
+func attachmentRemoveBlocks(ctx context.Context, api ipfsinterface.CoreAPI, cid ipfscid.Cid, retained map[string]struct{}) error {
+	if _, ok := retained[cid.KeyString()]; ok {
+		return nil
+	}
+
+	node, err := api.Dag().Get(ctx, cid)
+	if err != nil {
+		return nil // not available locally
+	}
+
+	for _, link := range node.Links() {
+		if err := attachmentRemoveBlocks(ctx, api, link.Cid, retained); err != nil {
+			return err
+		}
+	}
+
+	return api.Block().Rm(ctx, ipfspath.IpfsPath(cid), ipfsoptions.Block.Force(true))
+}
+
 func attachmentForcePin(settings *ipfsoptions.UnixfsAddSettings) error {
 	if settings == nil {
 		return errcode.ErrInvalidInput.Wrap(errors.New("nil ipfs settings"))
@@ -117,7 +117,7 @@ func (s *service) DebugInspectGroupStore(req *protocoltypes.DebugInspectGroupSto
 
 			if op, err := operation.ParseOperation(e); err != nil {
 				s.logger.Error("unable to parse operation", zap.Error(err))
-			} else if meta, event, _, err := openGroupEnvelope(cg.group, op.GetValue(), s.deviceKeystore); err != nil {
+			} else if meta, event, _, err := openGroupEnvelope(cg.group, op.GetValue(), s.deviceKeystore, nil); err != nil {
 				s.logger.Error("unable to open group envelope", zap.Error(err))
 			} else if metaEvent, err := newGroupMetadataEventFromEntry(log, e, meta, event, cg.group, nil); err != nil {
 				s.logger.Error("unable to get group metadata event from entry", zap.Error(err))
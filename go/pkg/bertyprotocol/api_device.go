@@ -0,0 +1,29 @@
+package bertyprotocol
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+// DeviceRevoke revokes another device of the account, every group then stops accepting its messages
+func (s *service) DeviceRevoke(ctx context.Context, req *protocoltypes.DeviceRevoke_Request) (*protocoltypes.DeviceRevoke_Reply, error) {
+	pk, err := crypto.UnmarshalEd25519PublicKey(req.DevicePK)
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	cutoff, err := getRevocationCutoff(s.accountGroup, pk)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.accountGroup.MetadataStore().RevokeDevice(ctx, pk, cutoff); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.DeviceRevoke_Reply{}, nil
+}
@@ -0,0 +1,117 @@
+package bertyprotocol
+
+import (
+	"context"
+	"time"
+
+	ggio "github.com/gogo/protobuf/io"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/network"
+	"go.uber.org/zap"
+
+	"berty.tech/berty/v2/go/internal/ipfsutil"
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+// DeviceLinkInvitationCreate creates a one-time invitation allowing a new device to join the account
+func (s *service) DeviceLinkInvitationCreate(ctx context.Context, req *protocoltypes.DeviceLinkInvitationCreate_Request) (*protocoltypes.DeviceLinkInvitationCreate_Reply, error) {
+	secret, err := newDeviceLinkSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &protocoltypes.DeviceLinkInvitation{
+		PeerID: s.ipfsCoreAPI.ID().String(),
+		Secret: secret,
+	}
+
+	for _, addr := range s.ipfsCoreAPI.Addrs() {
+		inv.Addrs = append(inv.Addrs, addr.String())
+	}
+
+	link, err := encodeDeviceLinkInvitation(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	expiration := time.Now().Add(deviceLinkInvitationTTL)
+	s.deviceLinks.add(secret, expiration)
+
+	return &protocoltypes.DeviceLinkInvitationCreate_Reply{
+		Invitation:     inv,
+		Link:           link,
+		ExpirationDate: expiration.UnixNano(),
+	}, nil
+}
+
+func (s *service) handleDeviceLinkStream(stream network.Stream) {
+	defer func() {
+		if err := ipfsutil.FullClose(stream); err != nil {
+			s.logger.Warn("error while closing stream with other peer", zap.Error(err))
+		}
+	}()
+
+	_ = stream.SetDeadline(time.Now().Add(deviceLinkTimeout))
+
+	reqEnv := &protocoltypes.DeviceLinkEnvelope{}
+	if err := ggio.NewDelimitedReader(stream, network.MessageSizeMax).ReadMsg(reqEnv); err != nil {
+		s.logger.Error("unable to read device link request", zap.Error(err))
+		return
+	}
+
+	req := &protocoltypes.DeviceLinkRequest{}
+	secret, ok := s.deviceLinks.consume(func(secret []byte) bool {
+		return openDeviceLinkMessage(reqEnv, secret, req) == nil
+	}, time.Now())
+	if !ok {
+		s.logger.Warn("received a device link request without a valid invitation", zap.String("peer", stream.Conn().RemotePeer().String()))
+		return
+	}
+
+	devicePK, err := crypto.UnmarshalEd25519PublicKey(req.GetDevicePK())
+	if err != nil {
+		s.logger.Error("invalid device public key", zap.Error(errcode.ErrDeserialization.Wrap(err)))
+		return
+	}
+
+	accountSK, err := s.deviceKeystore.AccountPrivKey()
+	if err != nil {
+		s.logger.Error("unable to get account private key", zap.Error(err))
+		return
+	}
+
+	accountProofSK, err := s.deviceKeystore.AccountProofPrivKey()
+	if err != nil {
+		s.logger.Error("unable to get account proof private key", zap.Error(err))
+		return
+	}
+
+	reply, err := newDeviceLinkReply(accountSK, accountProofSK, devicePK)
+	if err != nil {
+		s.logger.Error("unable to create device link reply", zap.Error(err))
+		return
+	}
+
+	// the device is authorized in the account group before receiving the account keys, the other devices then send it their secrets
+	ctx, cancel := context.WithTimeout(s.ctx, deviceLinkTimeout)
+	defer cancel()
+
+	if _, err := s.accountGroup.MetadataStore().AddAccountDevice(ctx, devicePK); err != nil {
+		s.logger.Error("unable to add device to the account group", zap.Error(err))
+		return
+	}
+
+	replyEnv, err := sealDeviceLinkMessage(reply, secret)
+	if err != nil {
+		s.logger.Error("unable to seal device link reply", zap.Error(err))
+		return
+	}
+
+	if err := ggio.NewDelimitedWriter(stream).WriteMsg(replyEnv); err != nil {
+		s.logger.Error("unable to write device link reply", zap.Error(errcode.ErrStreamWrite.Wrap(err)))
+		return
+	}
+
+	s.logger.Info("new device linked to account", zap.String("peer", stream.Conn().RemotePeer().String()))
+}
@@ -0,0 +1,61 @@
+package bertyprotocol
+
+import (
+	"context"
+	"fmt"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+// DiscoveryStats returns the statistics of the monitored discovery drivers
+func (s *service) DiscoveryStats(context.Context, *protocoltypes.DiscoveryStats_Request) (*protocoltypes.DiscoveryStats_Reply, error) {
+	reply := &protocoltypes.DiscoveryStats_Reply{
+		Drivers: make([]*protocoltypes.DiscoveryStats_Driver, len(s.discoveryMonitors)),
+	}
+
+	for i, monitor := range s.discoveryMonitors {
+		stats := monitor.Stats()
+		reply.Drivers[i] = &protocoltypes.DiscoveryStats_Driver{
+			Name:               stats.DriverName,
+			AdvertiseSuccesses: stats.AdvertiseSuccesses,
+			AdvertiseFailures:  stats.AdvertiseFailures,
+			FindPeersSuccesses: stats.FindPeersSuccesses,
+			FindPeersFailures:  stats.FindPeersFailures,
+			PeersFound:         stats.PeersFound,
+			AdvertiseLatency:   stats.AdvertiseLatency.Milliseconds(),
+			FindPeersLatency:   stats.FindPeersLatency.Milliseconds(),
+		}
+	}
+
+	return reply, nil
+}
+
+// GroupDiscoveryFocus sets whether a group is opened by the user, the peers of its stores are looked up more often while focused,
+// it is a no-op for unknown or inactive groups
+func (s *service) GroupDiscoveryFocus(_ context.Context, req *protocoltypes.GroupDiscoveryFocus_Request) (*protocoltypes.GroupDiscoveryFocus_Reply, error) {
+	if len(req.GroupPK) == 0 {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("no group id provided"))
+	}
+
+	cg, err := s.getContextGroupForID(req.GroupPK)
+	if err != nil || cg == nil {
+		return &protocoltypes.GroupDiscoveryFocus_Reply{}, nil
+	}
+
+	s.setGroupDiscoveryFocus(cg, req.Focused)
+
+	return &protocoltypes.GroupDiscoveryFocus_Reply{}, nil
+}
+
+func (s *service) setGroupDiscoveryFocus(cg *groupContext, focused bool) {
+	if s.focusedTopics == nil {
+		return
+	}
+
+	// the peers of the stores are found on the pubsub topics named after their addresses
+	s.focusedTopics.SetFocus(focused,
+		cg.MetadataStore().Address().String(),
+		cg.MessageStore().Address().String(),
+	)
+}
@@ -0,0 +1,48 @@
+package bertyprotocol
+
+import (
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dsync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/internal/tinder"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+func TestGroupDiscoveryFocus(t *testing.T) {
+	ctx, cancel, mn, rdvPeer := testHelperIPFSSetUp(t)
+	defer cancel()
+
+	node, closeNode := NewTestingProtocol(ctx, t, &TestingOpts{
+		Mocknet: mn,
+		RDVPeer: rdvPeer.Peerstore().PeerInfo(rdvPeer.ID()),
+	}, dsync.MutexWrap(ds.NewMapDatastore()))
+	defer closeNode()
+
+	s, ok := node.Service.(*service)
+	require.True(t, ok)
+
+	s.focusedTopics = tinder.NewFocusedTopics()
+
+	// unknown groups are ignored
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	_, err = s.GroupDiscoveryFocus(ctx, &protocoltypes.GroupDiscoveryFocus_Request{GroupPK: g.PublicKey, Focused: true})
+	require.NoError(t, err)
+	require.Equal(t, 0, s.focusedTopics.Len())
+
+	created, err := s.MultiMemberGroupCreate(ctx, &protocoltypes.MultiMemberGroupCreate_Request{})
+	require.NoError(t, err)
+
+	_, err = s.GroupDiscoveryFocus(ctx, &protocoltypes.GroupDiscoveryFocus_Request{GroupPK: created.GroupPK, Focused: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, s.focusedTopics.Len())
+
+	// the focus is cleared once the group is deactivated
+	_, err = s.DeactivateGroup(ctx, &protocoltypes.DeactivateGroup_Request{GroupPK: created.GroupPK})
+	require.NoError(t, err)
+	require.Equal(t, 0, s.focusedTopics.Len())
+}
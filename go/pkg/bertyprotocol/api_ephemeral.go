@@ -0,0 +1,86 @@
+package bertyprotocol
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+// GroupEphemeralSend broadcasts a message to the online members of a group, the message is never stored
+func (s *service) GroupEphemeralSend(ctx context.Context, req *protocoltypes.GroupEphemeralSend_Request) (*protocoltypes.GroupEphemeralSend_Reply, error) {
+	cg, err := s.getContextGroupForID(req.GetGroupPK())
+	if err != nil {
+		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	env, err := sealEphemeralMessage(req.GetPayload(), cg.memberDevice.device, cg.Group(), time.Now())
+	if err != nil {
+		return nil, errcode.ErrCryptoEncrypt.Wrap(err)
+	}
+
+	if err := s.ipfsCoreAPI.PubSub().Publish(ctx, ephemeralTopicForGroup(cg.Group()), env); err != nil {
+		return nil, errcode.ErrIPFSPubSubPublish.Wrap(err)
+	}
+
+	return &protocoltypes.GroupEphemeralSend_Reply{}, nil
+}
+
+// GroupEphemeralSubscribe subscribes to the ephemeral messages sent by the other devices of the group
+func (s *service) GroupEphemeralSubscribe(req *protocoltypes.GroupEphemeralSubscribe_Request, sub protocoltypes.ProtocolService_GroupEphemeralSubscribeServer) error {
+	cg, err := s.getContextGroupForID(req.GetGroupPK())
+	if err != nil {
+		return errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	ps, err := s.ipfsCoreAPI.PubSub().Subscribe(sub.Context(), ephemeralTopicForGroup(cg.Group()))
+	if err != nil {
+		return errcode.ErrIPFSPubSubSubscribe.Wrap(err)
+	}
+	defer ps.Close()
+
+	for {
+		m, err := ps.Next(sub.Context())
+		if err != nil {
+			if sub.Context().Err() != nil {
+				return nil
+			}
+
+			return errcode.ErrIPFSPubSubSubscribe.Wrap(err)
+		}
+
+		msg, devicePK, err := openEphemeralMessage(m.Data(), cg.Group(), time.Now())
+		if err != nil {
+			cg.logger.Debug("ignoring invalid ephemeral message", zap.Error(err))
+			continue
+		}
+
+		if devicePK.Equals(cg.DevicePubKey()) {
+			continue
+		}
+
+		memberPK, err := cg.MetadataStore().GetMemberByDevice(devicePK)
+		if err != nil {
+			cg.logger.Debug("ignoring ephemeral message from unknown device", zap.Error(err))
+			continue
+		}
+
+		memberPKRaw, err := memberPK.Raw()
+		if err != nil {
+			return errcode.ErrSerialization.Wrap(err)
+		}
+
+		if err := sub.Send(&protocoltypes.GroupEphemeralEvent{
+			GroupPK:  cg.Group().GetPublicKey(),
+			MemberPK: memberPKRaw,
+			DevicePK: msg.GetDevicePK(),
+			Payload:  msg.GetPayload(),
+			SentDate: msg.GetSentDate(),
+		}); err != nil {
+			return err
+		}
+	}
+}
@@ -89,17 +89,84 @@ func (s *service) MultiMemberGroupAliasResolverDisclose(ctx context.Context, req
 }
 
 // MultiMemberGroupAdminRoleGrant grants admin role to another member of the group
-func (s *service) MultiMemberGroupAdminRoleGrant(context.Context, *protocoltypes.MultiMemberGroupAdminRoleGrant_Request) (*protocoltypes.MultiMemberGroupAdminRoleGrant_Reply, error) {
-	return nil, errcode.ErrNotImplemented
+func (s *service) MultiMemberGroupAdminRoleGrant(ctx context.Context, req *protocoltypes.MultiMemberGroupAdminRoleGrant_Request) (*protocoltypes.MultiMemberGroupAdminRoleGrant_Reply, error) {
+	cg, err := s.getContextGroupForID(req.GroupPK)
+	if err != nil {
+		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	pk, err := crypto.UnmarshalEd25519PublicKey(req.MemberPK)
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	if _, err := cg.MetadataStore().GrantAdminRole(ctx, pk); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.MultiMemberGroupAdminRoleGrant_Reply{}, nil
 }
 
-// MultiMemberGroupInvitationCreate creates a group invitation
+// MultiMemberGroupAdminRoleRevoke revokes the admin role of another member of the group
+func (s *service) MultiMemberGroupAdminRoleRevoke(ctx context.Context, req *protocoltypes.MultiMemberGroupAdminRoleRevoke_Request) (*protocoltypes.MultiMemberGroupAdminRoleRevoke_Reply, error) {
+	cg, err := s.getContextGroupForID(req.GroupPK)
+	if err != nil {
+		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	pk, err := crypto.UnmarshalEd25519PublicKey(req.MemberPK)
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	if _, err := cg.MetadataStore().RevokeAdminRole(ctx, pk); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.MultiMemberGroupAdminRoleRevoke_Reply{}, nil
+}
+
+// MultiMemberGroupMemberRemove removes another member from the group, the remaining members then rotate their secrets
+func (s *service) MultiMemberGroupMemberRemove(ctx context.Context, req *protocoltypes.MultiMemberGroupMemberRemove_Request) (*protocoltypes.MultiMemberGroupMemberRemove_Reply, error) {
+	cg, err := s.getContextGroupForID(req.GroupPK)
+	if err != nil {
+		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	pk, err := crypto.UnmarshalEd25519PublicKey(req.MemberPK)
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	devices, err := cg.MetadataStore().GetDevicesForMember(pk)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown group member: %w", err))
+	}
+
+	// the other members stop accepting the messages of the removed member after the last ones known by the current device
+	cutoffs, err := cg.MessageStore().GetDevicesCutoffs(devices)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cg.MetadataStore().RemoveMember(ctx, pk, cutoffs); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.MultiMemberGroupMemberRemove_Reply{}, nil
+}
+
+// MultiMemberGroupInvitationCreate creates a group invitation, only the admins of the group can invite new members
 func (s *service) MultiMemberGroupInvitationCreate(ctx context.Context, req *protocoltypes.MultiMemberGroupInvitationCreate_Request) (*protocoltypes.MultiMemberGroupInvitationCreate_Reply, error) {
 	cg, err := s.getContextGroupForID(req.GroupPK)
 	if err != nil {
 		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
 	}
 
+	if err := cg.MetadataStore().checkOwnAdminRole(); err != nil {
+		return nil, err
+	}
+
 	return &protocoltypes.MultiMemberGroupInvitationCreate_Reply{
 		Group: cg.Group(),
 	}, nil
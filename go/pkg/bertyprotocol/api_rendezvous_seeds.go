@@ -0,0 +1,59 @@
+package bertyprotocol
+
+import (
+	"context"
+	crand "crypto/rand"
+	"io"
+	"io/ioutil"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+// GroupAdditionalRendezvousSeedAdd adds a rendezvous seed to a group, a random seed is generated when none is provided
+func (s *service) GroupAdditionalRendezvousSeedAdd(ctx context.Context, req *protocoltypes.GroupAdditionalRendezvousSeedAdd_Request) (*protocoltypes.GroupAdditionalRendezvousSeedAdd_Reply, error) {
+	cg, err := s.getContextGroupForID(req.GroupPK)
+	if err != nil {
+		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	seed := req.Seed
+	if len(seed) == 0 {
+		if seed, err = ioutil.ReadAll(io.LimitReader(crand.Reader, protocoltypes.RendezvousSeedLength)); err != nil {
+			return nil, errcode.ErrCryptoKeyGeneration.Wrap(err)
+		}
+	}
+
+	if _, err := cg.MetadataStore().AddAdditionalRendezvousSeed(ctx, seed, req.RotationInterval); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.GroupAdditionalRendezvousSeedAdd_Reply{Seed: seed}, nil
+}
+
+// GroupAdditionalRendezvousSeedRemove removes a rendezvous seed from a group, the members stop announcing themselves on its
+// rendezvous points
+func (s *service) GroupAdditionalRendezvousSeedRemove(ctx context.Context, req *protocoltypes.GroupAdditionalRendezvousSeedRemove_Request) (*protocoltypes.GroupAdditionalRendezvousSeedRemove_Reply, error) {
+	cg, err := s.getContextGroupForID(req.GroupPK)
+	if err != nil {
+		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	if _, err := cg.MetadataStore().RemoveAdditionalRendezvousSeed(ctx, req.Seed); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.GroupAdditionalRendezvousSeedRemove_Reply{}, nil
+}
+
+// GroupAdditionalRendezvousSeedList lists the rendezvous seeds added to a group
+func (s *service) GroupAdditionalRendezvousSeedList(_ context.Context, req *protocoltypes.GroupAdditionalRendezvousSeedList_Request) (*protocoltypes.GroupAdditionalRendezvousSeedList_Reply, error) {
+	cg, err := s.getContextGroupForID(req.GroupPK)
+	if err != nil {
+		return nil, errcode.ErrGroupMemberUnknownGroupID.Wrap(err)
+	}
+
+	return &protocoltypes.GroupAdditionalRendezvousSeedList_Reply{
+		Seeds: cg.MetadataStore().ListAdditionalRendezvousSeeds(),
+	}, nil
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
 
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 
@@ -24,36 +29,16 @@ func (s *service) ReplicationServiceRegisterGroup(ctx context.Context, request *
 		return nil, errcode.TODO.Wrap(err)
 	}
 
-	token, err := s.accountGroup.metadataStore.getServiceToken(request.TokenID)
+	token, endpoint, err := s.replicationServiceToken(request.TokenID)
 	if err != nil {
-		return nil, errcode.ErrInvalidInput.Wrap(err)
-	}
-
-	if token == nil {
-		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("invalid token"))
-	}
-
-	endpoint := ""
-	for _, t := range token.SupportedServices {
-		if t.ServiceType != ServiceReplicationID {
-			continue
-		}
-
-		endpoint = t.ServiceEndpoint
-		break
+		return nil, err
 	}
 
-	if endpoint == "" {
-		return nil, errcode.ErrServiceReplicationMissingEndpoint
-	}
-
-	cc, err := grpc.Dial(endpoint, []grpc.DialOption{
-		grpc.WithPerRPCCredentials(grpcutil.NewUnsecureSimpleAuthAccess("bearer", token.Token)),
-		grpc.WithInsecure(), // TODO: remove this, enforce security
-	}...)
+	cc, err := s.replicationServiceDial(ctx, endpoint, grpc.WithPerRPCCredentials(grpcutil.NewUnsecureSimpleAuthAccess("bearer", token.Token)))
 	if err != nil {
-		return nil, errcode.ErrStreamWrite.Wrap(err)
+		return nil, err
 	}
+	defer cc.Close()
 
 	client := NewReplicationServiceClient(cc)
 
@@ -71,3 +56,174 @@ func (s *service) ReplicationServiceRegisterGroup(ctx context.Context, request *
 
 	return &protocoltypes.ReplicationServiceRegisterGroup_Reply{}, nil
 }
+
+func (s *service) ReplicationServiceUnregisterGroup(ctx context.Context, request *protocoltypes.ReplicationServiceUnregisterGroup_Request) (*protocoltypes.ReplicationServiceUnregisterGroup_Reply, error) {
+	gc, err := s.getContextGroupForID(request.GroupPK)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	token, endpoint, err := s.replicationServiceToken(request.TokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := s.replicationServiceDial(ctx, endpoint, grpc.WithPerRPCCredentials(grpcutil.NewUnsecureSimpleAuthAccess("bearer", token.Token)))
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+
+	if _, err := NewReplicationServiceClient(cc).UnreplicateGroup(ctx, &protocoltypes.ReplicationServiceUnreplicateGroup_Request{
+		GroupPK: request.GroupPK,
+	}); err != nil {
+		return nil, errcode.ErrServiceReplicationServer.Wrap(err)
+	}
+
+	s.logger.Info("group won't be replicated anymore", zap.String("public-key", base64.RawURLEncoding.EncodeToString(request.GroupPK)))
+
+	if _, err := gc.metadataStore.SendGroupReplicatingRemoved(ctx, endpoint); err != nil {
+		s.logger.Error("error while notifying group about replication removal", zap.Error(err))
+	}
+
+	return &protocoltypes.ReplicationServiceUnregisterGroup_Reply{}, nil
+}
+
+func (s *service) ReplicationServiceGroupStatus(ctx context.Context, request *protocoltypes.ReplicationServiceGroupStatus_Request) (*protocoltypes.ReplicationServiceGroupStatus_Reply, error) {
+	token, endpoint, err := s.replicationServiceToken(request.TokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := s.replicationServiceDial(ctx, endpoint, grpc.WithPerRPCCredentials(grpcutil.NewUnsecureSimpleAuthAccess("bearer", token.Token)))
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+
+	reply, err := NewReplicationServiceClient(cc).ReplicationStatus(ctx, &protocoltypes.ReplicationServiceReplicationStatus_Request{
+		GroupPK: request.GroupPK,
+	})
+	if err != nil {
+		return nil, errcode.ErrServiceReplicationServer.Wrap(err)
+	}
+
+	return &protocoltypes.ReplicationServiceGroupStatus_Reply{
+		Status:            reply.Status,
+		ReplicationServer: endpoint,
+	}, nil
+}
+
+// replicationServiceToken returns a service token and the endpoint of its replication service
+func (s *service) replicationServiceToken(tokenID string) (*protocoltypes.ServiceToken, string, error) {
+	token, err := s.accountGroup.metadataStore.getServiceToken(tokenID)
+	if err != nil {
+		return nil, "", errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	if token == nil {
+		return nil, "", errcode.ErrInvalidInput.Wrap(fmt.Errorf("invalid token"))
+	}
+
+	for _, t := range token.SupportedServices {
+		if t.ServiceType == ServiceReplicationID && t.ServiceEndpoint != "" {
+			return token, t.ServiceEndpoint, nil
+		}
+	}
+
+	return nil, "", errcode.ErrServiceReplicationMissingEndpoint
+}
+
+// replicationEndpoint describes how to reach a replication service
+type replicationEndpoint struct {
+	// peer is set when the service is reached over libp2p
+	peer *peer.AddrInfo
+
+	address     string
+	tls         bool
+	fingerprint string
+}
+
+// parseReplicationEndpoint parses the endpoint of a replication service, it is either a multiaddr including the peer ID of the
+// server, a tls://host:port url optionally pinning the server certificate with a sha256 query parameter, or a plaintext
+// host:port restricted to loopback addresses as the token would be sent in clear
+func parseReplicationEndpoint(endpoint string) (*replicationEndpoint, error) {
+	switch {
+	case strings.HasPrefix(endpoint, "/"):
+		maddr, err := ma.NewMultiaddr(endpoint)
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+
+		info, err := peer.AddrInfoFromP2pAddr(maddr)
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("replication endpoint must include the server peer ID: %w", err))
+		}
+
+		return &replicationEndpoint{peer: info, address: info.ID.String()}, nil
+
+	case strings.HasPrefix(endpoint, "tls://"):
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+
+		if u.Host == "" {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("missing host in replication endpoint"))
+		}
+
+		return &replicationEndpoint{address: u.Host, tls: true, fingerprint: u.Query().Get("sha256")}, nil
+
+	default:
+		host, _, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("insecure replication endpoint %s, use a tls:// or a libp2p endpoint", endpoint))
+		}
+
+		return &replicationEndpoint{address: endpoint}, nil
+	}
+}
+
+// replicationServiceDial connects to a replication service using the transport required by its endpoint
+func (s *service) replicationServiceDial(ctx context.Context, endpoint string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	e, err := parseReplicationEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case e.peer != nil:
+		if len(e.peer.Addrs) > 0 {
+			if err := s.ipfsCoreAPI.Connect(ctx, *e.peer); err != nil {
+				return nil, errcode.ErrServiceReplicationServer.Wrap(err)
+			}
+		}
+
+		opts = append(opts,
+			grpc.WithContextDialer(grpcutil.Libp2pDialer(s.ipfsCoreAPI, ReplicationServiceProtocolID)),
+			grpc.WithInsecure(), // the stream is encrypted and the server authenticated by libp2p
+		)
+
+	case e.tls:
+		creds, err := grpcutil.NewPinnedTLSCredentials(e.fingerprint)
+		if err != nil {
+			return nil, errcode.ErrInvalidInput.Wrap(err)
+		}
+
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+
+	default:
+		opts = append(opts, grpc.WithInsecure()) // loopback only
+	}
+
+	cc, err := grpc.DialContext(ctx, e.address, opts...)
+	if err != nil {
+		return nil, errcode.ErrStreamWrite.Wrap(err)
+	}
+
+	return cc, nil
+}
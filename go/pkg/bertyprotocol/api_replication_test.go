@@ -0,0 +1,49 @@
+package bertyprotocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplicationEndpoint(t *testing.T) {
+	const peerID = "QmdT7AmhhnbuwvCpa5PH1ySK9HJVB82jr3fo1bxMxBPW6p"
+
+	e, err := parseReplicationEndpoint("/ip4/1.2.3.4/tcp/4040/p2p/" + peerID)
+	require.NoError(t, err)
+	require.NotNil(t, e.peer)
+	assert.Equal(t, peerID, e.address)
+	assert.Len(t, e.peer.Addrs, 1)
+
+	e, err = parseReplicationEndpoint("/p2p/" + peerID)
+	require.NoError(t, err)
+	require.NotNil(t, e.peer)
+	assert.Empty(t, e.peer.Addrs)
+
+	e, err = parseReplicationEndpoint("tls://replication.berty.io:443?sha256=abc")
+	require.NoError(t, err)
+	assert.Nil(t, e.peer)
+	assert.True(t, e.tls)
+	assert.Equal(t, "replication.berty.io:443", e.address)
+	assert.Equal(t, "abc", e.fingerprint)
+
+	e, err = parseReplicationEndpoint("127.0.0.1:9091")
+	require.NoError(t, err)
+	assert.False(t, e.tls)
+	assert.Equal(t, "127.0.0.1:9091", e.address)
+
+	_, err = parseReplicationEndpoint("localhost:9091")
+	require.NoError(t, err)
+
+	for _, endpoint := range []string{
+		"1.2.3.4:9091",              // token sent in clear
+		"replication.berty.io:9091", // token sent in clear
+		"/ip4/1.2.3.4/tcp/4040",     // missing peer ID
+		"tls://",                    // missing host
+		"not an endpoint",
+	} {
+		_, err = parseReplicationEndpoint(endpoint)
+		assert.Error(t, err, endpoint)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/big"
 
 	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
@@ -24,6 +25,7 @@ import (
 
 const (
 	attachmentCipherblockSize = 64 * 1024
+	attachmentPlainblockSize  = attachmentCipherblockSize - chacha20poly1305.Overhead
 	attachmentNonceIV         = 0
 	attachmentKeyV0Prefix     = "/libp2psk+xchacha20poly1305_64_0/" // TODO: replace when multikey rolls out
 )
@@ -73,7 +75,7 @@ func attachmentSealer(plaintext io.Reader, l *zap.Logger) (libp2pcrypto.PrivKey,
 		return nil, nil, errcode.ErrCryptoCipherInit.Wrap(err)
 	}
 
-	return sk, streamutil.FuncBlockTransformer(make([]byte, attachmentCipherblockSize-16), plaintext, l, func(pt []byte) ([]byte, error) {
+	return sk, streamutil.FuncBlockTransformer(make([]byte, attachmentPlainblockSize), plaintext, l, func(pt []byte) ([]byte, error) {
 		ct := ac.aead.Seal([]byte(nil), ac.nonceBuf[:], pt, []byte(nil))
 
 		ac.nonce.Add(ac.nonce, bigOne)
@@ -84,11 +86,42 @@ func attachmentSealer(plaintext io.Reader, l *zap.Logger) (libp2pcrypto.PrivKey,
 }
 
 func attachmentOpener(ciphertext io.Reader, sk libp2pcrypto.PrivKey, l *zap.Logger) (*io.PipeReader, error) {
+	return attachmentBlockOpener(ciphertext, sk, 0, l)
+}
+
+// attachmentOpenerAt returns the plaintext starting at the given offset, blocks are encrypted independently so only the blocks
+// following the offset are read
+func attachmentOpenerAt(ciphertext io.ReadSeeker, sk libp2pcrypto.PrivKey, offset uint64, l *zap.Logger) (*io.PipeReader, error) {
+	block := offset / attachmentPlainblockSize
+	if _, err := ciphertext.Seek(int64(block*attachmentCipherblockSize), io.SeekStart); err != nil {
+		return nil, errcode.ErrStreamRead.Wrap(err)
+	}
+
+	plaintext, err := attachmentBlockOpener(ciphertext, sk, block, l)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the beginning of the first block
+	if _, err := io.CopyN(ioutil.Discard, plaintext, int64(offset%attachmentPlainblockSize)); err != nil && err != io.EOF {
+		_ = plaintext.CloseWithError(err)
+		return nil, errcode.ErrStreamRead.Wrap(err)
+	}
+
+	return plaintext, nil
+}
+
+// attachmentBlockOpener decrypts a ciphertext starting at the given block
+func attachmentBlockOpener(ciphertext io.Reader, sk libp2pcrypto.PrivKey, block uint64, l *zap.Logger) (*io.PipeReader, error) {
 	ac, err := attachmentNewCipher(sk)
 	if err != nil {
 		return nil, errcode.ErrCryptoCipherInit.Wrap(err)
 	}
 
+	// the nonce is incremented for each block
+	ac.nonce.Add(ac.nonce, new(big.Int).SetUint64(block))
+	bigIntFillBytes(ac.nonce, ac.nonceBuf[:])
+
 	return streamutil.FuncBlockTransformer(make([]byte, attachmentCipherblockSize), ciphertext, l, func(ct []byte) ([]byte, error) {
 		pt, err := ac.aead.Open([]byte(nil), ac.nonceBuf[:], ct, []byte(nil))
 		if err != nil {
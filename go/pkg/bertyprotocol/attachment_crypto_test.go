@@ -0,0 +1,32 @@
+package bertyprotocol
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAttachmentOpenerAt(t *testing.T) {
+	plaintext := make([]byte, 3*attachmentPlainblockSize+42)
+	_, err := crand.Read(plaintext)
+	require.NoError(t, err)
+
+	sk, sealed, err := attachmentSealer(bytes.NewReader(plaintext), zap.NewNop())
+	require.NoError(t, err)
+
+	ciphertext, err := ioutil.ReadAll(sealed)
+	require.NoError(t, err)
+
+	for _, offset := range []uint64{0, 1, attachmentPlainblockSize - 1, attachmentPlainblockSize, 2*attachmentPlainblockSize + 7, uint64(len(plaintext))} {
+		opened, err := attachmentOpenerAt(bytes.NewReader(ciphertext), sk, offset, zap.NewNop())
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadAll(opened)
+		require.NoError(t, err)
+		require.Equal(t, plaintext[offset:], data, "offset %d", offset)
+	}
+}
@@ -0,0 +1,306 @@
+package bertyprotocol
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	ggio "github.com/gogo/protobuf/io"
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"berty.tech/berty/v2/go/internal/cryptoutil"
+	"berty.tech/berty/v2/go/internal/ipfsutil"
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+const (
+	deviceLinkProtocolID = protocol.ID("/berty/device-link/1.0.0")
+
+	// deviceLinkInvitationTTL is the duration during which an invitation can be used
+	deviceLinkInvitationTTL = 5 * time.Minute
+
+	// deviceLinkTimeout is the maximum duration of the exchange between the inviting device and the new device
+	deviceLinkTimeout = time.Minute
+
+	deviceLinkSecretSize = 32
+)
+
+// deviceLinkInvitations holds the pending invitations of the inviting device, each invitation can only be used once
+type deviceLinkInvitations struct {
+	secrets map[string]time.Time
+	mu      sync.Mutex
+}
+
+func (d *deviceLinkInvitations) add(secret []byte, expiration time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.secrets == nil {
+		d.secrets = map[string]time.Time{}
+	}
+
+	d.secrets[string(secret)] = expiration
+}
+
+// consume returns and removes the first valid secret accepted by the given function
+func (d *deviceLinkInvitations) consume(accept func(secret []byte) bool, now time.Time) ([]byte, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for secret, expiration := range d.secrets {
+		if now.After(expiration) {
+			delete(d.secrets, secret)
+			continue
+		}
+
+		if accept([]byte(secret)) {
+			delete(d.secrets, secret)
+			return []byte(secret), true
+		}
+	}
+
+	return nil, false
+}
+
+func newDeviceLinkSecret() ([]byte, error) {
+	secret := make([]byte, deviceLinkSecretSize)
+	if _, err := crand.Read(secret); err != nil {
+		return nil, errcode.ErrCryptoRandomGeneration.Wrap(err)
+	}
+
+	return secret, nil
+}
+
+func deviceLinkKey(secret []byte) (*[32]byte, error) {
+	if len(secret) != deviceLinkSecretSize {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("invalid device link secret size, expected %d got %d", deviceLinkSecretSize, len(secret)))
+	}
+
+	key := [32]byte{}
+	copy(key[:], secret)
+
+	return &key, nil
+}
+
+func sealDeviceLinkMessage(msg proto.Message, secret []byte) (*protocoltypes.DeviceLinkEnvelope, error) {
+	key, err := deviceLinkKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	nonce, err := cryptoutil.GenerateNonce()
+	if err != nil {
+		return nil, errcode.ErrCryptoNonceGeneration.Wrap(err)
+	}
+
+	return &protocoltypes.DeviceLinkEnvelope{
+		Nonce:   nonce[:],
+		Message: secretbox.Seal(nil, msgBytes, nonce, key),
+	}, nil
+}
+
+func openDeviceLinkMessage(env *protocoltypes.DeviceLinkEnvelope, secret []byte, msg proto.Message) error {
+	key, err := deviceLinkKey(secret)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := cryptoutil.NonceSliceToArray(env.GetNonce())
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	msgBytes, ok := secretbox.Open(nil, env.GetMessage(), nonce, key)
+	if !ok {
+		return errcode.ErrCryptoDecrypt.Wrap(fmt.Errorf("secretbox failed to open device link message"))
+	}
+
+	if err := proto.Unmarshal(msgBytes, msg); err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	return nil
+}
+
+func encodeDeviceLinkInvitation(inv *protocoltypes.DeviceLinkInvitation) (string, error) {
+	data, err := proto.Marshal(inv)
+	if err != nil {
+		return "", errcode.ErrSerialization.Wrap(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeDeviceLinkInvitation(link string) (*protocoltypes.DeviceLinkInvitation, error) {
+	data, err := base64.RawURLEncoding.DecodeString(link)
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	inv := &protocoltypes.DeviceLinkInvitation{}
+	if err := proto.Unmarshal(data, inv); err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	if _, err := deviceLinkKey(inv.GetSecret()); err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+// newDeviceLinkReply shares the account keys with a new device, the account key authorizes the device by signing its public key
+func newDeviceLinkReply(accountSK, accountProofSK crypto.PrivKey, devicePK crypto.PubKey) (*protocoltypes.DeviceLinkReply, error) {
+	devicePKRaw, err := devicePK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	deviceSig, err := accountSK.Sign(devicePKRaw)
+	if err != nil {
+		return nil, errcode.ErrCryptoSignature.Wrap(err)
+	}
+
+	accountSKBytes, err := crypto.MarshalPrivateKey(accountSK)
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	accountProofSKBytes, err := crypto.MarshalPrivateKey(accountProofSK)
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return &protocoltypes.DeviceLinkReply{
+		AccountSK:      accountSKBytes,
+		AccountProofSK: accountProofSKBytes,
+		DeviceSig:      deviceSig,
+	}, nil
+}
+
+// openDeviceLinkReply returns the account keys shared by the inviting device, after checking that the new device has been authorized
+func openDeviceLinkReply(reply *protocoltypes.DeviceLinkReply, devicePK crypto.PubKey) (crypto.PrivKey, crypto.PrivKey, error) {
+	accountSK, err := crypto.UnmarshalPrivateKey(reply.GetAccountSK())
+	if err != nil {
+		return nil, nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	accountProofSK, err := crypto.UnmarshalPrivateKey(reply.GetAccountProofSK())
+	if err != nil {
+		return nil, nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	devicePKRaw, err := devicePK.Raw()
+	if err != nil {
+		return nil, nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	if ok, err := accountSK.GetPublic().Verify(devicePKRaw, reply.GetDeviceSig()); err != nil || !ok {
+		return nil, nil, errcode.ErrCryptoSignatureVerification.Wrap(fmt.Errorf("device has not been authorized by the account key"))
+	}
+
+	return accountSK, accountProofSK, nil
+}
+
+// LinkDeviceToAccount joins the account of the device which created the invitation link, the current device keeps its own device
+// key and receives the account keys, it must be called before the protocol service is started on a keystore without account keys
+func LinkDeviceToAccount(ctx context.Context, api ipfsutil.ExtendedCoreAPI, ks DeviceKeystore, link string, logger *zap.Logger) error {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	inv, err := decodeDeviceLinkInvitation(link)
+	if err != nil {
+		return err
+	}
+
+	pid, err := peer.Decode(inv.GetPeerID())
+	if err != nil {
+		return errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	pi := peer.AddrInfo{ID: pid}
+	for _, addr := range inv.GetAddrs() {
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return errcode.ErrInvalidInput.Wrap(err)
+		}
+
+		pi.Addrs = append(pi.Addrs, maddr)
+	}
+
+	deviceSK, err := ks.DevicePrivKey()
+	if err != nil {
+		return errcode.ErrKeystoreGet.Wrap(err)
+	}
+
+	devicePKRaw, err := deviceSK.GetPublic().Raw()
+	if err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	}
+
+	reqEnv, err := sealDeviceLinkMessage(&protocoltypes.DeviceLinkRequest{DevicePK: devicePKRaw}, inv.GetSecret())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deviceLinkTimeout)
+	defer cancel()
+
+	if err := api.Connect(ctx, pi); err != nil {
+		return errcode.ErrInternal.Wrap(fmt.Errorf("unable to connect to the inviting device: %w", err))
+	}
+
+	s, err := api.NewStream(ctx, pid, deviceLinkProtocolID)
+	if err != nil {
+		return errcode.ErrInternal.Wrap(fmt.Errorf("unable to open a stream to the inviting device: %w", err))
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+
+	if err := ggio.NewDelimitedWriter(s).WriteMsg(reqEnv); err != nil {
+		return errcode.ErrStreamWrite.Wrap(err)
+	}
+
+	replyEnv := &protocoltypes.DeviceLinkEnvelope{}
+	if err := ggio.NewDelimitedReader(s, network.MessageSizeMax).ReadMsg(replyEnv); err != nil {
+		return errcode.ErrStreamRead.Wrap(err)
+	}
+
+	reply := &protocoltypes.DeviceLinkReply{}
+	if err := openDeviceLinkMessage(replyEnv, inv.GetSecret(), reply); err != nil {
+		return err
+	}
+
+	accountSK, accountProofSK, err := openDeviceLinkReply(reply, deviceSK.GetPublic())
+	if err != nil {
+		return err
+	}
+
+	if err := ks.RestoreAccountKeys(accountSK, accountProofSK); err != nil {
+		return errcode.ErrKeystorePut.Wrap(err)
+	}
+
+	logger.Info("device linked to account", zap.String("inviting-peer", pid.String()))
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package bertyprotocol
+
+import (
+	crand "crypto/rand"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dsync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/internal/ipfsutil"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+func TestDeviceLinkMessage(t *testing.T) {
+	secret, err := newDeviceLinkSecret()
+	require.NoError(t, err)
+
+	otherSecret, err := newDeviceLinkSecret()
+	require.NoError(t, err)
+
+	env, err := sealDeviceLinkMessage(&protocoltypes.DeviceLinkRequest{DevicePK: []byte("device_pk")}, secret)
+	require.NoError(t, err)
+
+	req := &protocoltypes.DeviceLinkRequest{}
+	require.Error(t, openDeviceLinkMessage(env, otherSecret, req))
+
+	require.NoError(t, openDeviceLinkMessage(env, secret, req))
+	require.Equal(t, []byte("device_pk"), req.DevicePK)
+
+	_, err = sealDeviceLinkMessage(req, []byte("too_short"))
+	require.Error(t, err)
+}
+
+func TestDeviceLinkInvitationEncoding(t *testing.T) {
+	secret, err := newDeviceLinkSecret()
+	require.NoError(t, err)
+
+	inv := &protocoltypes.DeviceLinkInvitation{
+		PeerID: "peer_id",
+		Addrs:  []string{"/ip4/127.0.0.1/tcp/4242"},
+		Secret: secret,
+	}
+
+	link, err := encodeDeviceLinkInvitation(inv)
+	require.NoError(t, err)
+
+	decoded, err := decodeDeviceLinkInvitation(link)
+	require.NoError(t, err)
+	require.Equal(t, inv.PeerID, decoded.PeerID)
+	require.Equal(t, inv.Addrs, decoded.Addrs)
+	require.Equal(t, inv.Secret, decoded.Secret)
+
+	_, err = decodeDeviceLinkInvitation("invalid link")
+	require.Error(t, err)
+}
+
+func TestDeviceLinkReply(t *testing.T) {
+	accountSK, _, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	accountProofSK, _, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	_, devicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	_, otherDevicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	reply, err := newDeviceLinkReply(accountSK, accountProofSK, devicePK)
+	require.NoError(t, err)
+
+	_, _, err = openDeviceLinkReply(reply, otherDevicePK)
+	require.Error(t, err)
+
+	sk, proofSK, err := openDeviceLinkReply(reply, devicePK)
+	require.NoError(t, err)
+	require.True(t, accountSK.Equals(sk))
+	require.True(t, accountProofSK.Equals(proofSK))
+}
+
+func TestLinkDeviceToAccount(t *testing.T) {
+	ctx, cancel, mn, rdvPeer := testHelperIPFSSetUp(t)
+	defer cancel()
+
+	dsA := dsync.MutexWrap(ds.NewMapDatastore())
+	nodeA, closeNodeA := NewTestingProtocol(ctx, t, &TestingOpts{
+		Mocknet: mn,
+		RDVPeer: rdvPeer.Peerstore().PeerInfo(rdvPeer.ID()),
+	}, dsA)
+	defer closeNodeA()
+
+	dsB := dsync.MutexWrap(ds.NewMapDatastore())
+	ipfsNodeB, cleanupNodeB := ipfsutil.TestingCoreAPIUsingMockNet(ctx, t, &ipfsutil.TestingAPIOpts{
+		Mocknet:   mn,
+		RDVPeer:   rdvPeer.Peerstore().PeerInfo(rdvPeer.ID()),
+		Datastore: dsB,
+	})
+	defer cleanupNodeB()
+
+	require.NoError(t, mn.LinkAll())
+
+	dksB := NewDeviceKeystore(ipfsutil.NewDatastoreKeystore(ipfsutil.NewNamespacedDatastore(dsB, ds.NewKey(NamespaceDeviceKeystore))))
+
+	inv, err := nodeA.Client.DeviceLinkInvitationCreate(ctx, &protocoltypes.DeviceLinkInvitationCreate_Request{})
+	require.NoError(t, err)
+	require.NotEmpty(t, inv.Link)
+
+	require.NoError(t, LinkDeviceToAccount(ctx, ipfsNodeB.API(), dksB, inv.Link, nil))
+
+	accountSKA, err := nodeA.Service.(*service).deviceKeystore.AccountPrivKey()
+	require.NoError(t, err)
+
+	accountSKB, err := dksB.AccountPrivKey()
+	require.NoError(t, err)
+	require.True(t, accountSKA.Equals(accountSKB))
+
+	deviceSKA, err := nodeA.Service.(*service).deviceKeystore.DevicePrivKey()
+	require.NoError(t, err)
+
+	deviceSKB, err := dksB.DevicePrivKey()
+	require.NoError(t, err)
+	require.False(t, deviceSKA.Equals(deviceSKB))
+
+	// the new device has been authorized in the account group and the secret of the inviting device is sent to the account member
+	accountGroupA := nodeA.Service.(*service).accountGroup
+	require.True(t, accountGroupA.MetadataStore().IsDeviceLinked(deviceSKB.GetPublic()))
+	require.Eventually(t, func() bool {
+		ok, err := accountGroupA.MetadataStore().Index().(*metadataStoreIndex).areSecretsAlreadySent(accountGroupA.MemberPubKey())
+		return err == nil && ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	nodeB, closeNodeB := NewTestingProtocol(ctx, t, &TestingOpts{
+		Mocknet:        mn,
+		RDVPeer:        rdvPeer.Peerstore().PeerInfo(rdvPeer.ID()),
+		CoreAPIMock:    ipfsNodeB,
+		DeviceKeystore: dksB,
+	}, dsB)
+	defer closeNodeB()
+
+	infoA, err := nodeA.Client.GroupInfo(ctx, &protocoltypes.GroupInfo_Request{GroupPK: accountGroupA.Group().PublicKey})
+	require.NoError(t, err)
+
+	infoB, err := nodeB.Client.GroupInfo(ctx, &protocoltypes.GroupInfo_Request{GroupPK: accountGroupA.Group().PublicKey})
+	require.NoError(t, err)
+	require.Equal(t, infoA.MemberPK, infoB.MemberPK)
+	require.NotEqual(t, infoA.DevicePK, infoB.DevicePK)
+
+	// invitations can only be used once
+	dsC := dsync.MutexWrap(ds.NewMapDatastore())
+	dksC := NewDeviceKeystore(ipfsutil.NewDatastoreKeystore(ipfsutil.NewNamespacedDatastore(dsC, ds.NewKey(NamespaceDeviceKeystore))))
+	require.Error(t, LinkDeviceToAccount(ctx, ipfsNodeB.API(), dksC, inv.Link, nil))
+}
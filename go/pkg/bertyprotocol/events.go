@@ -19,6 +19,9 @@ var eventTypesMapper = map[protocoltypes.EventType]struct {
 }{
 	protocoltypes.EventTypeGroupMemberDeviceAdded:                 {Message: &protocoltypes.GroupAddMemberDevice{}, SigChecker: sigCheckerMemberDeviceAdded},
 	protocoltypes.EventTypeGroupDeviceSecretAdded:                 {Message: &protocoltypes.GroupAddDeviceSecret{}, SigChecker: sigCheckerDeviceSigned},
+	protocoltypes.EventTypeGroupAdditionalRendezvousSeedAdded:     {Message: &protocoltypes.GroupAddAdditionalRendezvousSeed{}, SigChecker: sigCheckerDeviceSigned},
+	protocoltypes.EventTypeGroupAdditionalRendezvousSeedRemoved:   {Message: &protocoltypes.GroupRemoveAdditionalRendezvousSeed{}, SigChecker: sigCheckerDeviceSigned},
+	protocoltypes.EventTypeGroupMemberKeyRotated:                  {Message: &protocoltypes.GroupMemberKeyRotated{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeAccountGroupJoined:                     {Message: &protocoltypes.AccountGroupJoined{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeAccountGroupLeft:                       {Message: &protocoltypes.AccountGroupLeft{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeAccountContactRequestDisabled:          {Message: &protocoltypes.AccountContactRequestDisabled{}, SigChecker: sigCheckerDeviceSigned},
@@ -31,14 +34,19 @@ var eventTypesMapper = map[protocoltypes.EventType]struct {
 	protocoltypes.EventTypeAccountContactRequestIncomingAccepted:  {Message: &protocoltypes.AccountContactRequestAccepted{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeAccountContactBlocked:                  {Message: &protocoltypes.AccountContactBlocked{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeAccountContactUnblocked:                {Message: &protocoltypes.AccountContactUnblocked{}, SigChecker: sigCheckerDeviceSigned},
+	protocoltypes.EventTypeAccountDeviceRevoked:                   {Message: &protocoltypes.AccountDeviceRevoked{}, SigChecker: sigCheckerDeviceSigned},
+	protocoltypes.EventTypeAccountDeviceAdded:                     {Message: &protocoltypes.AccountDeviceAdded{}, SigChecker: sigCheckerAccountDeviceAdded},
 	protocoltypes.EventTypeContactAliasKeyAdded:                   {Message: &protocoltypes.ContactAddAliasKey{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeMultiMemberGroupAliasResolverAdded:     {Message: &protocoltypes.MultiMemberGroupAddAliasResolver{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeMultiMemberGroupInitialMemberAnnounced: {Message: &protocoltypes.MultiMemberInitialMember{}, SigChecker: sigCheckerGroupSigned},
-	protocoltypes.EventTypeMultiMemberGroupAdminRoleGranted:       {Message: &protocoltypes.MultiMemberGrantAdminRole{}, SigChecker: sigCheckerDeviceSigned},
+	protocoltypes.EventTypeMultiMemberGroupAdminRoleGranted:       {Message: &protocoltypes.MultiMemberGrantAdminRole{}, SigChecker: sigCheckerAdminSigned},
+	protocoltypes.EventTypeMultiMemberGroupAdminRoleRevoked:       {Message: &protocoltypes.MultiMemberRevokeAdminRole{}, SigChecker: sigCheckerAdminSigned},
+	protocoltypes.EventTypeMultiMemberGroupMemberRemoved:          {Message: &protocoltypes.MultiMemberRemoveMember{}, SigChecker: sigCheckerAdminSigned},
 	protocoltypes.EventTypeGroupMetadataPayloadSent:               {Message: &protocoltypes.AppMetadata{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeAccountServiceTokenAdded:               {Message: &protocoltypes.AccountServiceTokenAdded{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeAccountServiceTokenRemoved:             {Message: &protocoltypes.AccountServiceTokenRemoved{}, SigChecker: sigCheckerDeviceSigned},
 	protocoltypes.EventTypeGroupReplicating:                       {Message: &protocoltypes.GroupReplicating{}, SigChecker: sigCheckerDeviceSigned},
+	protocoltypes.EventTypeGroupReplicatingRemoved:                {Message: &protocoltypes.GroupReplicatingRemoved{}, SigChecker: sigCheckerDeviceSigned},
 }
 
 func newEventContext(eventID cid.Cid, parentIDs []cid.Cid, g *protocoltypes.Group, attachmentsCIDs [][]byte) *protocoltypes.EventContext {
@@ -103,7 +111,7 @@ func newGroupMetadataEventFromEntry(log ipfslog.Log, e ipfslog.Entry, metadata *
 	return &gme, nil
 }
 
-func openGroupEnvelope(g *protocoltypes.Group, envelopeBytes []byte, devKS DeviceKeystore) (*protocoltypes.GroupMetadata, proto.Message, [][]byte, error) {
+func openGroupEnvelope(g *protocoltypes.Group, envelopeBytes []byte, devKS DeviceKeystore, r adminResolver) (*protocoltypes.GroupMetadata, proto.Message, [][]byte, error) {
 	env := &protocoltypes.GroupEnvelope{}
 	if err := env.Unmarshal(envelopeBytes); err != nil {
 		return nil, nil, nil, errcode.ErrInvalidInput.Wrap(err)
@@ -136,7 +144,7 @@ func openGroupEnvelope(g *protocoltypes.Group, envelopeBytes []byte, devKS Devic
 		return nil, nil, nil, errcode.ErrDeserialization.Wrap(err)
 	}
 
-	if err := et.SigChecker(g, metadataEvent, payload); err != nil {
+	if err := et.SigChecker(g, metadataEvent, payload, r); err != nil {
 		return nil, nil, nil, errcode.ErrCryptoSignatureVerification.Wrap(err)
 	}
 
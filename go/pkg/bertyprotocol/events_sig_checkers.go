@@ -8,9 +8,9 @@ import (
 	"berty.tech/berty/v2/go/pkg/protocoltypes"
 )
 
-type sigChecker func(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message) error
+type sigChecker func(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message, r adminResolver) error
 
-func sigCheckerGroupSigned(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message) error {
+func sigCheckerGroupSigned(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message, _ adminResolver) error {
 	pk, err := g.GetPubKey()
 	if err != nil {
 		return err
@@ -33,7 +33,7 @@ type eventDeviceSigned interface {
 	GetDevicePK() []byte
 }
 
-func sigCheckerDeviceSigned(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message) error {
+func sigCheckerDeviceSigned(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message, _ adminResolver) error {
 	msg, ok := message.(eventDeviceSigned)
 	if !ok {
 		return errcode.ErrDeserialization
@@ -56,7 +56,7 @@ func sigCheckerDeviceSigned(g *protocoltypes.Group, metadata *protocoltypes.Grou
 	return nil
 }
 
-func sigCheckerMemberDeviceAdded(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message) error {
+func sigCheckerMemberDeviceAdded(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message, _ adminResolver) error {
 	msg, ok := message.(*protocoltypes.GroupAddMemberDevice)
 	if !ok {
 		return errcode.ErrDeserialization
@@ -76,5 +76,77 @@ func sigCheckerMemberDeviceAdded(g *protocoltypes.Group, metadata *protocoltypes
 		return errcode.ErrCryptoSignatureVerification
 	}
 
-	return sigCheckerDeviceSigned(g, metadata, message)
+	return sigCheckerDeviceSigned(g, metadata, message, nil)
+}
+
+// sigCheckerAdminSigned checks that an admin role event is signed by a device of a current admin of a multi-member group, only the
+// signature is checked without a resolver, ie. for the events which have already been accepted by the metadata index
+func sigCheckerAdminSigned(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message, r adminResolver) error {
+	if g.GroupType != protocoltypes.GroupTypeMultiMember {
+		return errcode.ErrGroupInvalidType
+	}
+
+	if err := sigCheckerDeviceSigned(g, metadata, message, nil); err != nil {
+		return err
+	}
+
+	if r == nil {
+		return nil
+	}
+
+	_, err := checkAdminDevice(r, message)
+	return err
+}
+
+// sigCheckerAccountDeviceAdded checks that a device addition is signed by a device in the account group, the metadata index then
+// checks the account signature of the added device as the account public key is only known by the index
+func sigCheckerAccountDeviceAdded(g *protocoltypes.Group, metadata *protocoltypes.GroupMetadata, message proto.Message, _ adminResolver) error {
+	if g.GroupType != protocoltypes.GroupTypeAccount {
+		return errcode.ErrGroupInvalidType
+	}
+
+	return sigCheckerDeviceSigned(g, metadata, message, nil)
+}
+
+type adminResolver interface {
+	unsafeGetMemberByDevice(pk crypto.PubKey) (crypto.PubKey, error)
+	unsafeIsAdmin(memberPK crypto.PubKey) bool
+}
+
+// lockedAdminResolver resolves the admins of a group while its metadata index is not being updated, unlike the index itself it
+// acquires the index lock
+type lockedAdminResolver struct {
+	index *metadataStoreIndex
+}
+
+func (r *lockedAdminResolver) unsafeGetMemberByDevice(pk crypto.PubKey) (crypto.PubKey, error) {
+	return r.index.getMemberByDevice(pk)
+}
+
+func (r *lockedAdminResolver) unsafeIsAdmin(memberPK crypto.PubKey) bool {
+	return r.index.isAdmin(memberPK)
+}
+
+// checkAdminDevice returns the member of the device which signed an admin role event, it fails if this member is not an admin
+func checkAdminDevice(r adminResolver, message proto.Message) (crypto.PubKey, error) {
+	msg, ok := message.(eventDeviceSigned)
+	if !ok {
+		return nil, errcode.ErrDeserialization
+	}
+
+	devPK, err := crypto.UnmarshalEd25519PublicKey(msg.GetDevicePK())
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	memberPK, err := r.unsafeGetMemberByDevice(devPK)
+	if err != nil {
+		return nil, errcode.ErrGroupMemberNotAdmin.Wrap(err)
+	}
+
+	if !r.unsafeIsAdmin(memberPK) {
+		return nil, errcode.ErrGroupMemberNotAdmin
+	}
+
+	return memberPK, nil
 }
@@ -1,6 +1,7 @@
 package bertyprotocol
 
 import (
+	"bytes"
 	"context"
 	"crypto/ed25519"
 	crand "crypto/rand"
@@ -154,7 +155,6 @@ func metadataStoreListSecrets(ctx context.Context, gc *groupContext) map[crypto.
 	publishedSecrets := map[crypto.PubKey]*protocoltypes.DeviceSecret{}
 
 	m := gc.MetadataStore()
-	ownSK := gc.getMemberPrivKey()
 	g := gc.Group()
 
 	metadatas, err := m.ListEvents(ctx, nil, nil, false)
@@ -166,7 +166,7 @@ func metadataStoreListSecrets(ctx context.Context, gc *groupContext) map[crypto.
 			continue
 		}
 
-		pk, ds, err := openDeviceSecret(metadata.Metadata, ownSK, g)
+		pk, ds, err := openDeviceSecret(metadata.Metadata, gc.memberDevice, gc.MetadataStore().devKS, g)
 		if errcode.Is(err, errcode.ErrInvalidInput) || errcode.Is(err, errcode.ErrGroupSecretOtherDestMember) {
 			continue
 		}
@@ -198,7 +198,7 @@ func FillMessageKeysHolderUsingNewData(ctx context.Context, gc *groupContext) <-
 				continue
 			}
 
-			pk, ds, err := openDeviceSecret(e.Metadata, gc.getMemberPrivKey(), gc.Group())
+			pk, ds, err := openDeviceSecret(e.Metadata, gc.memberDevice, gc.MetadataStore().devKS, gc.Group())
 			if errcode.Is(err, errcode.ErrInvalidInput) {
 				continue
 			}
@@ -252,7 +252,7 @@ func FillMessageKeysHolderUsingPreviousData(ctx context.Context, gc *groupContex
 
 func activateGroupContext(ctx context.Context, gc *groupContext, contact crypto.PubKey, selfAnnouncement bool) error {
 	wg := sync.WaitGroup{}
-	wg.Add(2)
+	wg.Add(4)
 
 	syncChMKH := make(chan bool, 1)
 	syncChSecrets := make(chan bool, 1)
@@ -290,9 +290,36 @@ func activateGroupContext(ctx context.Context, gc *groupContext, contact crypto.
 		close(syncChSecrets)
 	}()
 
+	go func() {
+		ch := WatchRemovedMembersAndRotateSecrets(ctx, gc)
+		wg.Done()
+
+		for pk := range ch {
+			if rawPK, err := pk.Raw(); err == nil {
+				gc.logger.Info("secrets rotated after member removal", zap.String("memberpk", base64.StdEncoding.EncodeToString(rawPK)))
+			}
+		}
+	}()
+
+	go func() {
+		ch := WatchRevokedDevicesAndRotateSecrets(ctx, gc)
+		wg.Done()
+
+		for pk := range ch {
+			if rawPK, err := pk.Raw(); err == nil {
+				gc.logger.Info("secrets rotated after device revocation", zap.String("devicepk", base64.StdEncoding.EncodeToString(rawPK)))
+			}
+		}
+	}()
+
 	wg.Wait()
 
 	start := time.Now()
+	FillRotatedMemberKeysUsingPreviousData(ctx, gc)
+
+	gc.logger.Info(fmt.Sprintf("FillRotatedMemberKeysUsingPreviousData took %s", time.Since(start)))
+
+	start = time.Now()
 	ch := FillMessageKeysHolderUsingPreviousData(ctx, gc)
 	for pk := range ch {
 		if pk.Equals(gc.memberDevice.device.GetPublic()) {
@@ -318,6 +345,24 @@ func activateGroupContext(ctx context.Context, gc *groupContext, contact crypto.
 
 	gc.logger.Info(fmt.Sprintf("SendSecretsToExistingMembers took %s", time.Since(start)))
 
+	start = time.Now()
+	for _, memberPK := range gc.MetadataStore().ListRemovedMembers() {
+		if err := handleRemovedMember(ctx, gc, memberPK); err != nil {
+			gc.logger.Error("unable to handle removed member", zap.Error(err))
+		}
+	}
+
+	gc.logger.Info(fmt.Sprintf("handling removed members took %s", time.Since(start)))
+
+	start = time.Now()
+	for _, devicePK := range gc.MetadataStore().ListRevokedDevices() {
+		if err := handleRevokedDevice(ctx, gc, devicePK); err != nil {
+			gc.logger.Error("unable to handle revoked device", zap.Error(err))
+		}
+	}
+
+	gc.logger.Info(fmt.Sprintf("handling revoked devices took %s", time.Since(start)))
+
 	if selfAnnouncement {
 		start = time.Now()
 		op, err := gc.MetadataStore().AddDeviceToGroup(ctx)
@@ -456,7 +501,338 @@ func WatchNewMembersAndSendSecrets(ctx context.Context, logger *zap.Logger, gctx
 	return ch
 }
 
-func openDeviceSecret(m *protocoltypes.GroupMetadata, localMemberPrivateKey crypto.PrivKey, group *protocoltypes.Group) (crypto.PubKey, *protocoltypes.DeviceSecret, error) {
+// WatchRemovedMembersAndRotateSecrets revokes the devices of the members removed from the group and sends a new secret of the current
+// device to the remaining members
+func WatchRemovedMembersAndRotateSecrets(ctx context.Context, gctx *groupContext) <-chan crypto.PubKey {
+	ch := make(chan crypto.PubKey)
+	sub := gctx.MetadataStore().Subscribe(ctx)
+
+	go func() {
+		for evt := range sub {
+			e, ok := evt.(*protocoltypes.GroupMetadataEvent)
+			if !ok {
+				continue
+			}
+
+			if e.Metadata.EventType != protocoltypes.EventTypeMultiMemberGroupMemberRemoved {
+				continue
+			}
+
+			event := &protocoltypes.MultiMemberRemoveMember{}
+			if err := event.Unmarshal(e.Event); err != nil {
+				gctx.logger.Error("unable to unmarshal payload", zap.Error(err))
+				continue
+			}
+
+			memberPK, err := crypto.UnmarshalEd25519PublicKey(event.RemovedMemberPK)
+			if err != nil {
+				gctx.logger.Error("unable to unmarshal removed member pk", zap.Error(err))
+				continue
+			}
+
+			// the event might have been rejected by the index, ie. if it was not sent by an admin
+			if !gctx.MetadataStore().IsMemberRemoved(memberPK) {
+				continue
+			}
+
+			if err := handleRemovedMember(ctx, gctx, memberPK); err != nil {
+				gctx.logger.Error("unable to handle removed member", zap.Error(err))
+				continue
+			}
+
+			ch <- memberPK
+		}
+
+		close(ch)
+	}()
+
+	return ch
+}
+
+func handleRemovedMember(ctx context.Context, gctx *groupContext, memberPK crypto.PubKey) error {
+	devices, err := gctx.MetadataStore().GetDevicesForMember(memberPK)
+	if err != nil {
+		return errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	// the messages are accepted up to the cutoffs published along with the removal, so that every member agrees on them
+	for _, device := range devices {
+		if err := gctx.MessageKeystore().RevokeDevice(gctx.Group(), device, gctx.MetadataStore().GetRevocationCutoff(device)); err != nil {
+			return err
+		}
+	}
+
+	if memberPK.Equals(gctx.MemberPubKey()) {
+		gctx.logger.Warn("current member has been removed from the group")
+		return nil
+	}
+
+	// secrets are only rotated once per removed member, the removal is retried if the new secret couldn't be sent
+	if ok, err := gctx.MessageKeystore().IsMemberRemovalHandled(gctx.Group(), memberPK); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+
+	if err := rotateSecretsWithMembers(ctx, gctx, nil); err != nil {
+		return err
+	}
+
+	return gctx.MessageKeystore().MarkMemberRemovalHandled(gctx.Group(), memberPK)
+}
+
+// handleLinkedDevice sends the secret of the current device to the account member, as a linked device shares it with the current
+// device, the secret is only sent if it has not been already
+func handleLinkedDevice(ctx context.Context, gctx *groupContext, devicePK crypto.PubKey) error {
+	if devicePK.Equals(gctx.DevicePubKey()) {
+		return nil
+	}
+
+	if _, err := gctx.MetadataStore().SendSecret(ctx, gctx.MemberPubKey()); err != nil && !errcode.Is(err, errcode.ErrGroupSecretAlreadySentToMember) {
+		return err
+	}
+
+	if ok, err := gctx.MetadataStore().Index().(*metadataStoreIndex).areSecretsAlreadySent(gctx.MemberPubKey()); err != nil {
+		return err
+	} else if !ok {
+		return errcode.ErrInternal.Wrap(fmt.Errorf("secret has not been sent to the account member"))
+	}
+
+	return nil
+}
+
+// WatchRevokedDevicesAndRotateSecrets stops accepting the messages of the devices revoked by their account in the group and sends a
+// new secret of the current device to the members
+func WatchRevokedDevicesAndRotateSecrets(ctx context.Context, gctx *groupContext) <-chan crypto.PubKey {
+	ch := make(chan crypto.PubKey)
+	sub := gctx.MetadataStore().Subscribe(ctx)
+
+	go func() {
+		for evt := range sub {
+			e, ok := evt.(*protocoltypes.GroupMetadataEvent)
+			if !ok {
+				continue
+			}
+
+			// the rotated key of the current member is received from the device which revoked another device of the member
+			if e.Metadata.EventType == protocoltypes.EventTypeGroupMemberKeyRotated {
+				if err := handleRotatedMemberKey(ctx, gctx, e.Event); err != nil {
+					gctx.logger.Error("unable to handle rotated member key", zap.Error(err))
+				}
+
+				continue
+			}
+
+			if e.Metadata.EventType != protocoltypes.EventTypeAccountDeviceRevoked {
+				continue
+			}
+
+			event := &protocoltypes.AccountDeviceRevoked{}
+			if err := event.Unmarshal(e.Event); err != nil {
+				gctx.logger.Error("unable to unmarshal payload", zap.Error(err))
+				continue
+			}
+
+			devicePK, err := crypto.UnmarshalEd25519PublicKey(event.RevokedDevicePK)
+			if err != nil {
+				gctx.logger.Error("unable to unmarshal revoked device pk", zap.Error(err))
+				continue
+			}
+
+			// the event might have been rejected by the index, ie. if it was not signed by the member of the device
+			if !gctx.MetadataStore().IsDeviceRevoked(devicePK) {
+				continue
+			}
+
+			if err := handleRevokedDevice(ctx, gctx, devicePK); err != nil {
+				gctx.logger.Error("unable to handle revoked device", zap.Error(err))
+				continue
+			}
+
+			ch <- devicePK
+		}
+
+		close(ch)
+	}()
+
+	return ch
+}
+
+// handleRevokedDevice stops accepting the messages of a revoked device and sends a new secret of the current device to the members
+// of the group, the new secret is sent to the remaining devices of the member of the revoked device as it holds the member key, the
+// device which revoked it also replaces the member key
+func handleRevokedDevice(ctx context.Context, gctx *groupContext, devicePK crypto.PubKey) error {
+	if devicePK.Equals(gctx.DevicePubKey()) {
+		gctx.logger.Warn("current device has been revoked by the account")
+		return nil
+	}
+
+	if err := gctx.MessageKeystore().RevokeDevice(gctx.Group(), devicePK, gctx.MetadataStore().GetRevocationCutoff(devicePK)); err != nil {
+		return err
+	}
+
+	// secrets are only rotated once per revoked device, the revocation is retried if the new secret couldn't be sent
+	if ok, err := gctx.MessageKeystore().IsDeviceRevocationHandled(gctx.Group(), devicePK); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+
+	memberPK, err := gctx.MetadataStore().GetRevokedDeviceMember(devicePK)
+	if err != nil {
+		return err
+	}
+
+	if memberPK.Equals(gctx.MemberPubKey()) {
+		if revokerPK, err := gctx.MetadataStore().GetDeviceRevoker(devicePK); err == nil && revokerPK.Equals(gctx.DevicePubKey()) {
+			if _, err := gctx.MetadataStore().RotateMemberKey(ctx, devicePK); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := rotateSecretsWithMembers(ctx, gctx, memberPK); err != nil {
+		return err
+	}
+
+	return gctx.MessageKeystore().MarkDeviceRevocationHandled(gctx.Group(), devicePK)
+}
+
+// storeRotatedMemberKey stores the rotated key of the current member sent by another of its devices, it returns false if the event
+// doesn't hold a key for the current device
+func storeRotatedMemberKey(gctx *groupContext, payload []byte) (bool, error) {
+	event := &protocoltypes.GroupMemberKeyRotated{}
+	if err := event.Unmarshal(payload); err != nil {
+		return false, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	memberPK, err := crypto.UnmarshalEd25519PublicKey(event.MemberPK)
+	if err != nil {
+		return false, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	if !memberPK.Equals(gctx.MemberPubKey()) {
+		return false, nil
+	}
+
+	senderPK, err := crypto.UnmarshalEd25519PublicKey(event.DevicePK)
+	if err != nil {
+		return false, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	// the key must come from a device of the current member which has not been revoked
+	if senderMemberPK, err := gctx.MetadataStore().GetMemberByDevice(senderPK); err != nil || !senderMemberPK.Equals(memberPK) || gctx.MetadataStore().IsDeviceRevoked(senderPK) {
+		return false, nil
+	}
+
+	rotatedPK, err := crypto.UnmarshalEd25519PublicKey(event.RotatedMemberPK)
+	if err != nil {
+		return false, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	deviceRaw, err := gctx.DevicePubKey().Raw()
+	if err != nil {
+		return false, errcode.ErrSerialization.Wrap(err)
+	}
+
+	for _, envelope := range event.Envelopes {
+		if !bytes.Equal(envelope.DevicePK, deviceRaw) {
+			continue
+		}
+
+		sk, err := openRotatedMemberKeyEnvelope(envelope, gctx.memberDevice.device, senderPK)
+		if err != nil {
+			return false, err
+		}
+
+		if !sk.GetPublic().Equals(rotatedPK) {
+			return false, errcode.ErrInvalidInput.Wrap(fmt.Errorf("rotated member key mismatch"))
+		}
+
+		if err := gctx.MetadataStore().devKS.RotatedMemberPrivKeyPut(sk); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// handleRotatedMemberKey stores the rotated key of the current member and registers the secrets which were sent to it before it was
+// received
+func handleRotatedMemberKey(ctx context.Context, gctx *groupContext, payload []byte) error {
+	if ok, err := storeRotatedMemberKey(gctx, payload); err != nil || !ok {
+		return err
+	}
+
+	for pk, ds := range metadataStoreListSecrets(ctx, gctx) {
+		if err := gctx.MessageKeystore().RegisterChainKey(gctx.Group(), pk, ds, gctx.DevicePubKey().Equals(pk)); err != nil {
+			gctx.logger.Error("unable to register chain key", zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// FillRotatedMemberKeysUsingPreviousData stores the rotated keys of the current member found in the log, they are needed to open the
+// secrets sent to the member
+func FillRotatedMemberKeysUsingPreviousData(ctx context.Context, gctx *groupContext) {
+	metadatas, err := gctx.MetadataStore().ListEvents(ctx, nil, nil, false)
+	if err != nil {
+		return
+	}
+
+	for metadata := range metadatas {
+		if metadata == nil || metadata.Metadata.GetEventType() != protocoltypes.EventTypeGroupMemberKeyRotated {
+			continue
+		}
+
+		if _, err := storeRotatedMemberKey(gctx, metadata.Event); err != nil {
+			gctx.logger.Error("unable to store rotated member key", zap.Error(err))
+		}
+	}
+}
+
+// rotateSecretsWithMembers sends a new secret of the current device to the members of the group, the secret is sent to the remaining
+// devices of the compromised member, if any, instead of its member key
+func rotateSecretsWithMembers(ctx context.Context, gctx *groupContext, compromisedMemberPK crypto.PubKey) error {
+	ds, err := gctx.MessageKeystore().RotateDeviceSecret(gctx.Group(), gctx.DevicePubKey())
+	if err != nil {
+		return err
+	}
+
+	for _, pk := range gctx.MetadataStore().ListMembers() {
+		if compromisedMemberPK == nil || !pk.Equals(compromisedMemberPK) {
+			if _, err := metadataStoreSendSecret(ctx, gctx.MetadataStore(), gctx.Group(), gctx.memberDevice, pk, ds); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		devices, err := gctx.MetadataStore().GetDevicesForMember(pk)
+		if err != nil {
+			return errcode.ErrInvalidInput.Wrap(err)
+		}
+
+		for _, devicePK := range devices {
+			if devicePK.Equals(gctx.DevicePubKey()) || gctx.MetadataStore().IsDeviceRevoked(devicePK) {
+				continue
+			}
+
+			if _, err := metadataStoreSendSecret(ctx, gctx.MetadataStore(), gctx.Group(), gctx.memberDevice, devicePK, ds); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// openDeviceSecret opens a secret sent to the current member, or to the current device once a device of the member has been revoked,
+// or to the rotated key of the member received from another of its devices
+func openDeviceSecret(m *protocoltypes.GroupMetadata, md *ownMemberDevice, devKS DeviceKeystore, group *protocoltypes.Group) (crypto.PubKey, *protocoltypes.DeviceSecret, error) {
 	if m == nil || m.EventType != protocoltypes.EventTypeGroupDeviceSecretAdded {
 		return nil, nil, errcode.ErrInvalidInput
 	}
@@ -476,16 +852,35 @@ func openDeviceSecret(m *protocoltypes.GroupMetadata, localMemberPrivateKey cryp
 		return nil, nil, errcode.ErrDeserialization.Wrap(err)
 	}
 
-	if !localMemberPrivateKey.GetPublic().Equals(destMemberPubKey) {
-		return nil, nil, errcode.ErrGroupSecretOtherDestMember
+	var localPrivateKey crypto.PrivKey
+
+	switch {
+	case md.member.GetPublic().Equals(destMemberPubKey):
+		localPrivateKey = md.member
+	case md.device.GetPublic().Equals(destMemberPubKey):
+		localPrivateKey = md.device
+	default:
+		if devKS == nil {
+			return nil, nil, errcode.ErrGroupSecretOtherDestMember
+		}
+
+		if localPrivateKey, err = devKS.RotatedMemberPrivKey(destMemberPubKey); err != nil {
+			return nil, nil, errcode.ErrGroupSecretOtherDestMember
+		}
 	}
 
-	mongPriv, mongPub, err := cryptoutil.EdwardsToMontgomery(localMemberPrivateKey, senderDevicePubKey)
+	mongPriv, mongPub, err := cryptoutil.EdwardsToMontgomery(localPrivateKey, senderDevicePubKey)
 	if err != nil {
 		return nil, nil, errcode.ErrCryptoKeyConversion.Wrap(err)
 	}
 
 	nonce := groupIDToNonce(group)
+	if len(s.Nonce) > 0 {
+		if nonce, err = cryptoutil.NonceSliceToArray(s.Nonce); err != nil {
+			return nil, nil, errcode.ErrDeserialization.Wrap(err)
+		}
+	}
+
 	decryptedSecret := &protocoltypes.DeviceSecret{}
 	decryptedMessage, ok := box.Open(nil, s.Payload, nonce, mongPub, mongPriv)
 	if !ok {
@@ -502,10 +897,11 @@ func openDeviceSecret(m *protocoltypes.GroupMetadata, localMemberPrivateKey cryp
 
 func groupIDToNonce(group *protocoltypes.Group) *[cryptoutil.NonceSize]byte {
 	// Nonce doesn't need to be secret, random nor unpredictable, it just needs
-	// to be used only once for a given {sender, receiver} set and we will send
-	// only one SecretEntryPayload per {localDevicePrivKey, remoteMemberPubKey}
-	// So we can reuse groupID as nonce for all SecretEntryPayload and save
-	// 24 bytes of storage and bandwidth for each of them.
+	// to be used only once for a given {sender, receiver} set. The groupID was
+	// used as nonce when only one SecretEntryPayload was sent per
+	// {localDevicePrivKey, remoteMemberPubKey}, secrets are now rotated so a
+	// random nonce is sent along with the payload, this one is only used to
+	// open the payloads sent without a nonce.
 	//
 	// See https://pynacl.readthedocs.io/en/stable/secret/#nonce
 	// See Security Model here: https://nacl.cr.yp.to/box.html
@@ -0,0 +1,112 @@
+package bertyprotocol
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"berty.tech/berty/v2/go/internal/cryptoutil"
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+// ephemeralMessageMaxAge is the maximum clock difference accepted between the sender and the receiver of an ephemeral message
+const ephemeralMessageMaxAge = time.Minute
+
+// ephemeralTopicForGroup returns the pubsub topic of the group ephemeral messages, it is
+// derived from the group secret so non-members can't link it to the group
+func ephemeralTopicForGroup(g *protocoltypes.Group) string {
+	h := sha256.New()
+	_, _ = h.Write(g.GetSecret())
+	_, _ = h.Write(g.GetPublicKey())
+
+	return fmt.Sprintf("/berty/ephemeral/1.0.0/%x", h.Sum(nil))
+}
+
+func sealEphemeralMessage(payload []byte, deviceSK crypto.PrivKey, g *protocoltypes.Group, now time.Time) ([]byte, error) {
+	devicePKRaw, err := deviceSK.GetPublic().Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	msg := &protocoltypes.EphemeralMessage{
+		DevicePK: devicePKRaw,
+		Payload:  payload,
+		SentDate: now.UnixNano(),
+	}
+
+	if msg.Sig, err = signProto(msg, deviceSK); err != nil {
+		return nil, err
+	}
+
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	nonce, err := cryptoutil.GenerateNonce()
+	if err != nil {
+		return nil, errcode.ErrCryptoNonceGeneration.Wrap(err)
+	}
+
+	env, err := proto.Marshal(&protocoltypes.EphemeralEnvelope{
+		Nonce:   nonce[:],
+		Message: secretbox.Seal(nil, msgBytes, nonce, g.GetSharedSecret()),
+	})
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return env, nil
+}
+
+func openEphemeralMessage(data []byte, g *protocoltypes.Group, now time.Time) (*protocoltypes.EphemeralMessage, crypto.PubKey, error) {
+	env := &protocoltypes.EphemeralEnvelope{}
+	if err := env.Unmarshal(data); err != nil {
+		return nil, nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	nonce, err := cryptoutil.NonceSliceToArray(env.GetNonce())
+	if err != nil {
+		return nil, nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	msgBytes, ok := secretbox.Open(nil, env.GetMessage(), nonce, g.GetSharedSecret())
+	if !ok {
+		return nil, nil, errcode.ErrCryptoDecrypt.Wrap(fmt.Errorf("secretbox failed to open ephemeral message"))
+	}
+
+	msg := &protocoltypes.EphemeralMessage{}
+	if err := msg.Unmarshal(msgBytes); err != nil {
+		return nil, nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	devicePK, err := crypto.UnmarshalEd25519PublicKey(msg.GetDevicePK())
+	if err != nil {
+		return nil, nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	sig := msg.Sig
+	msg.Sig = nil
+
+	signedBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	if ok, err := devicePK.Verify(signedBytes, sig); err != nil || !ok {
+		return nil, nil, errcode.ErrCryptoSignatureVerification.Wrap(fmt.Errorf("invalid ephemeral message signature"))
+	}
+
+	msg.Sig = sig
+
+	if age := now.Sub(time.Unix(0, msg.GetSentDate())); age > ephemeralMessageMaxAge || age < -ephemeralMessageMaxAge {
+		return nil, nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("stale ephemeral message"))
+	}
+
+	return msg, devicePK, nil
+}
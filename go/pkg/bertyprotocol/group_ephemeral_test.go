@@ -0,0 +1,48 @@
+package bertyprotocol
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/pkg/errcode"
+)
+
+func TestEphemeralMessageSealOpen(t *testing.T) {
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	otherGroup, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	deviceSK, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	env, err := sealEphemeralMessage([]byte("typing"), deviceSK, g, now)
+	require.NoError(t, err)
+
+	msg, devicePK, err := openEphemeralMessage(env, g, now.Add(time.Second))
+	require.NoError(t, err)
+	require.True(t, devicePK.Equals(deviceSK.GetPublic()))
+	require.Equal(t, []byte("typing"), msg.GetPayload())
+	require.Equal(t, now.UnixNano(), msg.GetSentDate())
+
+	// only group members can read the message
+	_, _, err = openEphemeralMessage(env, otherGroup, now)
+	require.True(t, errcode.Is(err, errcode.ErrCryptoDecrypt))
+
+	// stale messages are discarded
+	_, _, err = openEphemeralMessage(env, g, now.Add(2*ephemeralMessageMaxAge))
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	_, _, err = openEphemeralMessage(env, g, now.Add(-2*ephemeralMessageMaxAge))
+	require.True(t, errcode.Is(err, errcode.ErrInvalidInput))
+
+	// topics are distinct for each group
+	require.NotEqual(t, ephemeralTopicForGroup(g), ephemeralTopicForGroup(otherGroup))
+}
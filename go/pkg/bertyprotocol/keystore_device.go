@@ -27,9 +27,12 @@ type DeviceKeystore interface {
 	ContactGroupPrivKey(pk crypto.PubKey) (crypto.PrivKey, error)
 	MemberDeviceForGroup(g *protocoltypes.Group) (*ownMemberDevice, error)
 	RestoreAccountKeys(accountKey crypto.PrivKey, accountProofKey crypto.PrivKey) error
+	RotatedMemberPrivKey(pk crypto.PubKey) (crypto.PrivKey, error)
+	RotatedMemberPrivKeyPut(sk crypto.PrivKey) error
 
 	AttachmentPrivKey(cid []byte) (crypto.PrivKey, error)
 	AttachmentPrivKeyPut(cid []byte, sk crypto.PrivKey) error
+	AttachmentPrivKeyRemove(cid []byte) error
 	AttachmentSecret(cid []byte) ([]byte, error)
 	AttachmentSecretPut(cid []byte, secret []byte) error
 	AttachmentSecretSlice(cids [][]byte) ([][]byte, error)
@@ -42,12 +45,13 @@ type deviceKeystore struct {
 }
 
 const (
-	keyAccount      = "accountSK"
-	keyAccountProof = "accountProofSK"
-	keyDevice       = "deviceSK"
-	keyMemberDevice = "memberDeviceSK"
-	keyMember       = "memberSK"
-	keyContactGroup = "contactGroupSK"
+	keyAccount       = "accountSK"
+	keyAccountProof  = "accountProofSK"
+	keyDevice        = "deviceSK"
+	keyMemberDevice  = "memberDeviceSK"
+	keyMember        = "memberSK"
+	keyContactGroup  = "contactGroupSK"
+	keyRotatedMember = "rotatedMemberSK"
 )
 
 // AccountPrivKey returns the private key associated with the current account
@@ -251,6 +255,50 @@ func (a *deviceKeystore) RestoreAccountKeys(sk crypto.PrivKey, proofSK crypto.Pr
 	return nil
 }
 
+// RotatedMemberPrivKey returns a member key which replaced the one of the current member in a group after the revocation of one of
+// its devices
+func (a *deviceKeystore) RotatedMemberPrivKey(pk crypto.PubKey) (crypto.PrivKey, error) {
+	pkRaw, err := pk.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sk, err := a.ks.Get(strings.Join([]string{keyRotatedMember, hex.EncodeToString(pkRaw)}, "_"))
+	if err != nil {
+		return nil, errcode.ErrKeystoreGet.Wrap(err)
+	}
+
+	return sk, nil
+}
+
+// RotatedMemberPrivKeyPut stores a member key received from another device of the current member
+func (a *deviceKeystore) RotatedMemberPrivKeyPut(sk crypto.PrivKey) error {
+	pkRaw, err := sk.GetPublic().Raw()
+	if err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	}
+
+	name := strings.Join([]string{keyRotatedMember, hex.EncodeToString(pkRaw)}, "_")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if ok, err := a.ks.Has(name); err != nil {
+		return errcode.ErrKeystoreGet.Wrap(err)
+	} else if ok {
+		return nil
+	}
+
+	if err := a.ks.Put(name, sk); err != nil {
+		return errcode.ErrKeystorePut.Wrap(err)
+	}
+
+	return nil
+}
+
 func (a *deviceKeystore) AttachmentPrivKey(cidBytes []byte) (crypto.PrivKey, error) {
 	id, err := attachmentKeyIDFromCID(cidBytes)
 	if err != nil {
@@ -290,6 +338,28 @@ func (a *deviceKeystore) AttachmentPrivKeyPut(cidBytes []byte, sk crypto.PrivKey
 	return nil
 }
 
+func (a *deviceKeystore) AttachmentPrivKeyRemove(cidBytes []byte) error {
+	id, err := attachmentKeyIDFromCID(cidBytes)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if ok, err := a.ks.Has(id); err != nil {
+		return errcode.ErrKeystoreGet.Wrap(err)
+	} else if !ok {
+		return nil // nothing to remove
+	}
+
+	if err := a.ks.Delete(id); err != nil {
+		return errcode.ErrKeystoreDelete.Wrap(err)
+	}
+
+	return nil
+}
+
 func attachmentKeyIDFromCID(cidBytes []byte) (string, error) {
 	cid, err := ipfscid.Cast(cidBytes)
 	if err != nil {
@@ -372,9 +442,16 @@ func newDeviceSecret() (*protocoltypes.DeviceSecret, error) {
 		return nil, errcode.ErrCryptoRandomGeneration.Wrap(err)
 	}
 
+	mailboxSeed := make([]byte, 32)
+	_, err = crand.Read(mailboxSeed)
+	if err != nil {
+		return nil, errcode.ErrCryptoRandomGeneration.Wrap(err)
+	}
+
 	return &protocoltypes.DeviceSecret{
-		ChainKey: chainKey,
-		Counter:  counter.Uint64(),
+		ChainKey:    chainKey,
+		Counter:     counter.Uint64(),
+		MailboxSeed: mailboxSeed,
 	}, nil
 }
 
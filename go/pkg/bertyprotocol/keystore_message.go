@@ -3,10 +3,12 @@ package bertyprotocol
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"golang.org/x/crypto/nacl/secretbox"
@@ -118,6 +120,13 @@ func (m *messageKeystore) postDecryptActions(di *decryptInfo, g *protocoltypes.G
 		return errcode.ErrInternal.Wrap(err)
 	}
 
+	// the chain key of a revoked device is forgotten, its remaining keys are only kept for the messages sent before its revocation
+	if revoked, err := m.isDeviceRevoked(groupPK, pk); err != nil {
+		return errcode.ErrInternal.Wrap(err)
+	} else if revoked {
+		return nil
+	}
+
 	if ds, err = m.getDeviceChainKey(groupPK, pk); err != nil {
 		return errcode.ErrInvalidInput.Wrap(err)
 	}
@@ -198,11 +207,20 @@ func (m *messageKeystore) registerChainKey(g *protocoltypes.Group, devicePK cryp
 		return errcode.ErrDeserialization.Wrap(err)
 	}
 
-	if _, err := m.getDeviceChainKey(groupPK, devicePK); err == nil {
-		// device is already registered, ignore it
+	if revoked, err := m.isDeviceRevoked(groupPK, devicePK); err != nil {
+		return errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+	} else if revoked {
+		// device has been removed from the group, ignore it
 		return nil
 	}
 
+	if knownDS, err := m.getDeviceChainKey(groupPK, devicePK); err == nil {
+		// device is already registered, ignore it unless its chain key has been rotated
+		if deviceSecretEpoch(ds.Counter) <= deviceSecretEpoch(knownDS.Counter) {
+			return nil
+		}
+	}
+
 	// If own device store key as is, no need to precompute future keys
 	if isOwnPK {
 		if err := m.putDeviceChainKey(groupPK, devicePK, ds); err != nil {
@@ -270,8 +288,9 @@ func (m *messageKeystore) preComputeKeys(device crypto.PubKey, g *protocoltypes.
 	}
 
 	return &protocoltypes.DeviceSecret{
-		Counter:  counter,
-		ChainKey: ck,
+		Counter:     counter,
+		ChainKey:    ck,
+		MailboxSeed: ds.MailboxSeed,
 	}, nil
 }
 
@@ -368,6 +387,20 @@ func (m *messageKeystore) OpenEnvelope(ctx context.Context, g *protocoltypes.Gro
 		return nil, nil, nil, errcode.ErrCryptoDecrypt.Wrap(err)
 	}
 
+	// messages of a removed or revoked device are only opened up to the cutoff published along with its revocation
+	if _, err := m.getKeyForCID(id); err != nil {
+		devicePK, err := crypto.UnmarshalEd25519PublicKey(headers.DevicePK)
+		if err != nil {
+			return headers, nil, nil, errcode.ErrDeserialization.Wrap(err)
+		}
+
+		if counter, hasCutoff, revoked, err := m.getRevocationCutoff(gPK, devicePK); err != nil {
+			return headers, nil, nil, errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+		} else if revoked && (!hasCutoff || counterAfter(headers.Counter, counter)) {
+			return headers, nil, nil, errcode.ErrGroupDeviceRevoked
+		}
+	}
+
 	msgBytes, decryptInfo, err := m.openPayload(id, gPK, env.Message, headers)
 	if err != nil {
 		return headers, nil, nil, errcode.ErrCryptoDecryptPayload.Wrap(err)
@@ -547,8 +580,9 @@ func (m *messageKeystore) deriveDeviceSecret(g *protocoltypes.Group, deviceSK cr
 	}
 
 	if err = m.putDeviceChainKey(groupPK, deviceSK.GetPublic(), &protocoltypes.DeviceSecret{
-		ChainKey: ck,
-		Counter:  ds.Counter + 1,
+		ChainKey:    ck,
+		Counter:     ds.Counter + 1,
+		MailboxSeed: ds.MailboxSeed,
 	}); err != nil {
 		return errcode.ErrCryptoKeyGeneration.Wrap(err)
 	}
@@ -581,6 +615,267 @@ func (m *messageKeystore) updateCurrentKey(groupPK, pk crypto.PubKey, ds *protoc
 	return nil
 }
 
+// deviceSecretEpochShift splits a device counter in an epoch and a position in the chain, rotating a chain key moves the device to
+// the next epoch so the other members can tell a rotated chain key from a previously known one and never reuse a precomputed key
+const deviceSecretEpochShift = 32
+
+func deviceSecretEpoch(counter uint64) uint64 {
+	return counter >> deviceSecretEpochShift
+}
+
+// RotateDeviceSecret replaces the chain key of the current device, the new secret must then be sent to the remaining members
+func (m *messageKeystore) RotateDeviceSecret(g *protocoltypes.Group, devicePK crypto.PubKey) (*protocoltypes.DeviceSecret, error) {
+	if m == nil {
+		return nil, errcode.ErrInvalidInput
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	groupPK, err := g.GetPubKey()
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	currentDS, err := m.getDeviceChainKey(groupPK, devicePK)
+	if err != nil {
+		return nil, errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+	}
+
+	ds, err := newDeviceSecret()
+	if err != nil {
+		return nil, errcode.ErrCryptoKeyGeneration.Wrap(err)
+	}
+
+	ds.Counter = (deviceSecretEpoch(currentDS.Counter) + 1) << deviceSecretEpochShift
+	if deviceSecretEpoch(ds.Counter) <= deviceSecretEpoch(currentDS.Counter) {
+		return nil, errcode.ErrCryptoKeyGeneration.Wrap(fmt.Errorf("no epoch left for the device counter"))
+	}
+
+	if err := m.putDeviceChainKey(groupPK, devicePK, ds); err != nil {
+		return nil, errcode.ErrMessageKeyPersistencePut.Wrap(err)
+	}
+
+	return ds, nil
+}
+
+// RevokeDevice forgets the chain key of a device removed from the group or revoked by its account, only the messages up to the
+// cutoff published along with the revocation can still be opened, all its messages are rejected without a cutoff, the future
+// secrets of the device are ignored
+func (m *messageKeystore) RevokeDevice(g *protocoltypes.Group, devicePK crypto.PubKey, cutoff *protocoltypes.RevokedDeviceCutoff) error {
+	if m == nil {
+		return errcode.ErrInvalidInput
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	groupPK, err := g.GetPubKey()
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	groupRaw, err := groupPK.Raw()
+	if err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	}
+
+	deviceRaw, err := devicePK.Raw()
+	if err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	}
+
+	counter, hasCutoff, revoked, err := m.getRevocationCutoff(groupPK, devicePK)
+	if err != nil {
+		return errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+	}
+
+	// the device might have been revoked by several events, the latest cutoff is kept so that every member ends up with the same
+	if cutoff != nil && (!hasCutoff || counterAfter(cutoff.GetCounter(), counter)) {
+		counter, hasCutoff = cutoff.GetCounter(), true
+	}
+
+	value := []byte{}
+	if hasCutoff {
+		value = uint64AsBytes(counter)
+	}
+
+	if !revoked || hasCutoff {
+		if err := m.store.Put(idForRevokedDevice(groupRaw, deviceRaw), value); err != nil {
+			return errcode.ErrMessageKeyPersistencePut.Wrap(err)
+		}
+	}
+
+	if err := m.store.Delete(idForCurrentCK(groupRaw, deviceRaw)); err != nil {
+		return errcode.ErrMessageKeyPersistencePut.Wrap(err)
+	}
+
+	res, err := m.store.Query(query.Query{Prefix: idForCachedKeys(groupRaw, deviceRaw).String(), KeysOnly: true})
+	if err != nil {
+		return errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+	}
+
+	// the keys of the messages up to the cutoff are kept as they might not have been received yet
+	for _, entry := range entries {
+		key := datastore.NewKey(entry.Key)
+
+		if keyCounter, err := strconv.ParseUint(key.Name(), 10, 64); err == nil && hasCutoff && !counterAfter(keyCounter, counter) {
+			continue
+		}
+
+		if err := m.store.Delete(key); err != nil {
+			return errcode.ErrMessageKeyPersistencePut.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// GetMailboxSeed returns the seed of the mailboxes of a device of the group, it is renewed each time the chain key of the device
+// is rotated and is forgotten once the device is revoked
+func (m *messageKeystore) GetMailboxSeed(g *protocoltypes.Group, devicePK crypto.PubKey) ([]byte, error) {
+	if m == nil {
+		return nil, errcode.ErrInvalidInput
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	groupPK, err := g.GetPubKey()
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	ds, err := m.getDeviceChainKey(groupPK, devicePK)
+	if err != nil {
+		return nil, err
+	}
+
+	// the secrets shared before the mailboxes were introduced have no seed
+	if len(ds.MailboxSeed) == 0 {
+		return nil, errcode.ErrMissingInput.Wrap(fmt.Errorf("no mailbox seed for the device"))
+	}
+
+	return ds.MailboxSeed, nil
+}
+
+func (m *messageKeystore) isDeviceRevoked(groupPK, devicePK crypto.PubKey) (bool, error) {
+	_, _, revoked, err := m.getRevocationCutoff(groupPK, devicePK)
+	return revoked, err
+}
+
+// getRevocationCutoff returns the counter of the last message of a revoked device which can be opened, none of its messages can be
+// opened if it has no cutoff
+func (m *messageKeystore) getRevocationCutoff(groupPK, devicePK crypto.PubKey) (uint64, bool, bool, error) {
+	groupRaw, err := groupPK.Raw()
+	if err != nil {
+		return 0, false, false, errcode.ErrSerialization.Wrap(err)
+	}
+
+	deviceRaw, err := devicePK.Raw()
+	if err != nil {
+		return 0, false, false, errcode.ErrSerialization.Wrap(err)
+	}
+
+	data, err := m.store.Get(idForRevokedDevice(groupRaw, deviceRaw))
+	if err == datastore.ErrNotFound {
+		return 0, false, false, nil
+	} else if err != nil {
+		return 0, false, false, errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+	}
+
+	counter, hasCutoff := bytesAsUint64(data)
+
+	return counter, hasCutoff, true, nil
+}
+
+// IsMemberRemovalHandled returns true if the chain key of the current device has been rotated after the removal of a member
+func (m *messageKeystore) IsMemberRemovalHandled(g *protocoltypes.Group, memberPK crypto.PubKey) (bool, error) {
+	if m == nil {
+		return false, errcode.ErrInvalidInput
+	}
+
+	memberRaw, err := memberPK.Raw()
+	if err != nil {
+		return false, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return m.isRotationHandled(idForHandledRemoval(g.GetPublicKey(), memberRaw))
+}
+
+// MarkMemberRemovalHandled records that the chain key of the current device has been rotated after the removal of a member, it
+// must only be called once the new secret has been sent to the members
+func (m *messageKeystore) MarkMemberRemovalHandled(g *protocoltypes.Group, memberPK crypto.PubKey) error {
+	if m == nil {
+		return errcode.ErrInvalidInput
+	}
+
+	memberRaw, err := memberPK.Raw()
+	if err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	}
+
+	return m.markRotationHandled(idForHandledRemoval(g.GetPublicKey(), memberRaw))
+}
+
+// IsDeviceRevocationHandled returns true if the chain key of the current device has been rotated after the revocation of a device
+// of the account
+func (m *messageKeystore) IsDeviceRevocationHandled(g *protocoltypes.Group, devicePK crypto.PubKey) (bool, error) {
+	if m == nil {
+		return false, errcode.ErrInvalidInput
+	}
+
+	deviceRaw, err := devicePK.Raw()
+	if err != nil {
+		return false, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return m.isRotationHandled(idForHandledRevocation(g.GetPublicKey(), deviceRaw))
+}
+
+// MarkDeviceRevocationHandled records that the chain key of the current device has been rotated after the revocation of a device
+// of the account, it must only be called once the new secret has been sent to the members
+func (m *messageKeystore) MarkDeviceRevocationHandled(g *protocoltypes.Group, devicePK crypto.PubKey) error {
+	if m == nil {
+		return errcode.ErrInvalidInput
+	}
+
+	deviceRaw, err := devicePK.Raw()
+	if err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	}
+
+	return m.markRotationHandled(idForHandledRevocation(g.GetPublicKey(), deviceRaw))
+}
+
+func (m *messageKeystore) isRotationHandled(key datastore.Key) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ok, err := m.store.Has(key)
+	if err != nil {
+		return false, errcode.ErrMessageKeyPersistenceGet.Wrap(err)
+	}
+
+	return ok, nil
+}
+
+func (m *messageKeystore) markRotationHandled(key datastore.Key) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.store.Put(key, []byte{}); err != nil {
+		return errcode.ErrMessageKeyPersistencePut.Wrap(err)
+	}
+
+	return nil
+}
+
 // newMessageKeystore instantiate a new messageKeystore
 func newMessageKeystore(s datastore.Datastore) *messageKeystore {
 	return &messageKeystore{
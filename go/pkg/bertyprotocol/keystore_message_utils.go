@@ -151,7 +151,23 @@ func deriveNextKeys(ck []byte, salt []byte, groupID []byte) ([]byte, [32]byte, e
 }
 
 func idForCachedKey(groupPK, pk []byte, counter uint64) datastore.Key {
-	return datastore.KeyWithNamespaces([]string{"cachedCKs", hex.EncodeToString(groupPK), hex.EncodeToString(pk), fmt.Sprintf("%d", counter)})
+	return idForCachedKeys(groupPK, pk).ChildString(fmt.Sprintf("%d", counter))
+}
+
+func idForCachedKeys(groupPK, pk []byte) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{"cachedCKs", hex.EncodeToString(groupPK), hex.EncodeToString(pk)})
+}
+
+func idForRevokedDevice(groupPK, pk []byte) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{"revokedDevices", hex.EncodeToString(groupPK), hex.EncodeToString(pk)})
+}
+
+func idForHandledRemoval(groupPK, memberPK []byte) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{"handledRemovals", hex.EncodeToString(groupPK), hex.EncodeToString(memberPK)})
+}
+
+func idForHandledRevocation(groupPK, devicePK []byte) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{"handledRevocations", hex.EncodeToString(groupPK), hex.EncodeToString(devicePK)})
 }
 
 func idForCurrentCK(groupPK, pk []byte) datastore.Key {
@@ -170,3 +186,24 @@ func uint64AsNonce(val uint64) *[24]byte {
 
 	return &nonce
 }
+
+func uint64AsBytes(val uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, val)
+
+	return b
+}
+
+// bytesAsUint64 returns false for an invalid value, ie. for the revocations recorded without a cutoff
+func bytesAsUint64(b []byte) (uint64, bool) {
+	if len(b) != 8 {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint64(b), true
+}
+
+// counterAfter returns true if the counter a follows b, the counters of a device start at a random value and can wrap
+func counterAfter(a, b uint64) bool {
+	return a != b && a-b < 1<<63
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"os"
 	"path"
 	"testing"
@@ -15,6 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"berty.tech/berty/v2/go/internal/testutil"
+	"berty.tech/berty/v2/go/pkg/errcode"
 	"berty.tech/berty/v2/go/pkg/protocoltypes"
 )
 
@@ -307,6 +309,89 @@ func Test_EncryptMessageEnvelope(t *testing.T) {
 	assert.Equal(t, payloadRef1, payloadClrlBytes)
 }
 
+func TestMessageKeystoreRotateAndRevokeDevice(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	groupPK, err := g.GetPubKey()
+	require.NoError(t, err)
+
+	acc1 := NewDeviceKeystore(keystore.NewMemKeystore())
+	mkh1, cleanup := newInMemMessageKeystore()
+	defer cleanup()
+
+	omd1, err := acc1.MemberDeviceForGroup(g)
+	require.NoError(t, err)
+
+	mkh2, cleanup := newInMemMessageKeystore()
+	defer cleanup()
+
+	ds1, err := newDeviceSecret()
+	require.NoError(t, err)
+
+	require.NoError(t, mkh1.RegisterChainKey(g, omd1.device.GetPublic(), ds1, true))
+	require.NoError(t, mkh2.RegisterChainKey(g, omd1.device.GetPublic(), ds1, false))
+
+	rotatedDS, err := mkh1.RotateDeviceSecret(g, omd1.device.GetPublic())
+	require.NoError(t, err)
+	require.NotEqual(t, ds1.ChainKey, rotatedDS.ChainKey)
+	require.Equal(t, deviceSecretEpoch(ds1.Counter)+1, deviceSecretEpoch(rotatedDS.Counter))
+
+	currentDS, err := mkh1.getDeviceChainKey(groupPK, omd1.device.GetPublic())
+	require.NoError(t, err)
+	require.Equal(t, rotatedDS.ChainKey, currentDS.ChainKey)
+
+	// the previous secret is ignored once the rotated one is known
+	require.NoError(t, mkh2.RegisterChainKey(g, omd1.device.GetPublic(), rotatedDS, false))
+	require.NoError(t, mkh2.RegisterChainKey(g, omd1.device.GetPublic(), ds1, false))
+
+	knownDS, err := mkh2.getDeviceChainKey(groupPK, omd1.device.GetPublic())
+	require.NoError(t, err)
+	require.Equal(t, deviceSecretEpoch(rotatedDS.Counter), deviceSecretEpoch(knownDS.Counter))
+
+	payload, err := (&protocoltypes.EncryptedMessage{Plaintext: []byte("Test payload")}).Marshal()
+	require.NoError(t, err)
+
+	envs := make([][]byte, 3)
+	for i := range envs {
+		envs[i], err = mkh1.SealEnvelope(ctx, g, omd1.device, payload, nil)
+		require.NoError(t, err)
+	}
+
+	_, _, _, err = mkh2.OpenEnvelope(ctx, g, nil, envs[1], cid.Undef)
+	require.NoError(t, err)
+
+	_, headers, err := openEnvelopeHeaders(envs[1], g)
+	require.NoError(t, err)
+
+	// the messages sent after the cutoff published with the revocation are rejected, as well as the secrets of the revoked device
+	cutoff := &protocoltypes.RevokedDeviceCutoff{DevicePK: headers.DevicePK, Counter: headers.Counter}
+	require.NoError(t, mkh2.RevokeDevice(g, omd1.device.GetPublic(), cutoff))
+
+	_, _, _, err = mkh2.OpenEnvelope(ctx, g, nil, envs[2], cid.Undef)
+	require.True(t, errcode.Is(err, errcode.ErrGroupDeviceRevoked))
+
+	// a message sent before the revocation can still be opened when received late
+	_, _, _, err = mkh2.OpenEnvelope(ctx, g, nil, envs[0], cid.Undef)
+	require.NoError(t, err)
+
+	// revoking the device again without a cutoff doesn't drop the published one
+	require.NoError(t, mkh2.RevokeDevice(g, omd1.device.GetPublic(), nil))
+
+	_, _, _, err = mkh2.OpenEnvelope(ctx, g, nil, envs[0], cid.Undef)
+	require.NoError(t, err)
+
+	_, _, _, err = mkh2.OpenEnvelope(ctx, g, nil, envs[2], cid.Undef)
+	require.True(t, errcode.Is(err, errcode.ErrGroupDeviceRevoked))
+
+	require.NoError(t, mkh2.RegisterChainKey(g, omd1.device.GetPublic(), rotatedDS, false))
+	_, err = mkh2.getDeviceChainKey(groupPK, omd1.device.GetPublic())
+	require.Error(t, err)
+}
+
 func Test_EncryptMessageEnvelopeAndDerive(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -508,3 +593,13 @@ func TestMessageKeyHolderSubscription(t *testing.T) {
 		testMessageKeyHolderSubscription(t, testCase.expectedNewDevices, testCase.slow)
 	}
 }
+
+func TestCounterAfter(t *testing.T) {
+	require.True(t, counterAfter(2, 1))
+	require.False(t, counterAfter(1, 2))
+	require.False(t, counterAfter(1, 1))
+
+	// the counters of a device start at a random value and can wrap
+	require.True(t, counterAfter(0, math.MaxUint64))
+	require.False(t, counterAfter(math.MaxUint64, 0))
+}
@@ -0,0 +1,341 @@
+package bertyprotocol
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cbornode "github.com/ipfs/go-ipld-cbor"
+	ipfs_interface "github.com/ipfs/interface-go-ipfs-core"
+	ipfsoptions "github.com/ipfs/interface-go-ipfs-core/options"
+	ipfspath "github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mh "github.com/multiformats/go-multihash"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/sha3"
+
+	"berty.tech/berty/v2/go/internal/cryptoutil"
+	"berty.tech/berty/v2/go/internal/rendezvous"
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+	"berty.tech/go-orbit-db/stores"
+)
+
+const (
+	// mailboxRotationInterval is the period after which the mailbox ID of a device changes
+	mailboxRotationInterval = 24 * time.Hour
+
+	// mailboxTopicPrefix keeps the mailbox IDs apart from the other rendezvous points derived from the group secrets
+	mailboxTopicPrefix = "mailbox:"
+
+	// mailboxEnvelopeKeyInfo derives the key sealing the envelopes from the mailbox seed
+	mailboxEnvelopeKeyInfo = "mailbox envelope v0"
+)
+
+// mailboxIDForPeriod returns the ID of the mailbox of a device of the group for the period including the given date, the seed
+// is shared with the device secret and is renewed once a member has been removed so the removed members can't find the new
+// mailboxes
+func mailboxIDForPeriod(g *protocoltypes.Group, devicePK, seed []byte, date time.Time) []byte {
+	topic := append([]byte(mailboxTopicPrefix), g.PublicKey...)
+	topic = append(topic, devicePK...)
+
+	return generateRendezvousPointForPeriod(topic, seed, roundTimePeriod(date, mailboxRotationInterval))
+}
+
+// mailboxDriver drops the log entries written by the device in its mailboxes on the rendezvous points, and loads the
+// entries fetched from the mailboxes of the other devices into the message stores, so the members of a group which are
+// never online at the same time still receive its messages
+//
+// The entries are sealed with a key derived from the mailbox seed of the device, so the servers only see opaque envelopes,
+// once opened they keep their CID and are loaded like the ones replicated from the other peers. The entries are kept on
+// the servers until they expire as each mailbox is fetched by every other member, the driver only fetches the mailboxes
+// of the periods following its last successful fetch, and the entries following the last one it received.
+type mailboxDriver struct {
+	logger       *zap.Logger
+	host         host.Host
+	ipfs         ipfs_interface.CoreAPI
+	servers      []peer.ID
+	pollInterval time.Duration
+
+	// fetchPeriods is the number of periods whose mailbox is fetched, including the current one, so that the entries are
+	// fetched until they expire on the servers
+	fetchPeriods int
+}
+
+// mailboxCursors tracks the fetches of the mailboxes of a group
+type mailboxCursors struct {
+	// after are the IDs of the last envelopes received from each mailbox, indexed by server and mailbox ID
+	after map[string][]byte
+
+	// fetched are the dates of the last successful fetches of the mailboxes of each device, indexed by server and device
+	fetched map[string]time.Time
+}
+
+// newMailboxDriver creates a driver using the given servers, ttl is the duration the envelopes are kept on the servers
+func newMailboxDriver(logger *zap.Logger, h host.Host, ipfs ipfs_interface.CoreAPI, servers []peer.ID, pollInterval, ttl time.Duration) *mailboxDriver {
+	return &mailboxDriver{
+		logger:       logger.Named("mailbox"),
+		host:         h,
+		ipfs:         ipfs,
+		servers:      servers,
+		pollInterval: pollInterval,
+		fetchPeriods: int(ttl/mailboxRotationInterval) + 1,
+	}
+}
+
+// watchGroup drops the entries written by the device and fetches the mailboxes of the group periodically, until the
+// message store is closed
+func (d *mailboxDriver) watchGroup(ctx context.Context, gc *groupContext) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sub := gc.MessageStore().Subscribe(ctx)
+
+	go d.pollGroup(ctx, gc)
+
+	for evt := range sub {
+		// the entries of the other devices are replicated, only the ones written locally are dropped
+		e, ok := evt.(*stores.EventWrite)
+		if !ok || e.Entry == nil {
+			continue
+		}
+
+		if err := d.drop(ctx, gc, e.Entry.GetHash()); err != nil {
+			d.logger.Warn("unable to drop entry in mailbox", zap.Error(err))
+		}
+	}
+}
+
+// drop seals the entry and puts it in the current mailbox of the device on every server
+func (d *mailboxDriver) drop(ctx context.Context, gc *groupContext, id cid.Cid) error {
+	devicePK, err := gc.DevicePubKey().Raw()
+	if err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	}
+
+	seed, err := gc.MessageKeystore().GetMailboxSeed(gc.Group(), gc.DevicePubKey())
+	if err != nil {
+		return err
+	}
+
+	node, err := d.ipfs.Dag().Get(ctx, id)
+	if err != nil {
+		return errcode.ErrIPFSGet.Wrap(err)
+	}
+
+	envelope, err := sealMailboxEnvelope(seed, node.RawData())
+	if err != nil {
+		return err
+	}
+
+	mailboxID := mailboxIDForPeriod(gc.Group(), devicePK, seed, time.Now())
+
+	for _, server := range d.servers {
+		if err := rendezvous.MailboxPut(ctx, d.host, server, mailboxID, envelope); err != nil {
+			d.logger.Warn("unable to drop entry in mailbox", zap.Stringer("server", server), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+func (d *mailboxDriver) pollGroup(ctx context.Context, gc *groupContext) {
+	cursors := mailboxCursors{}
+
+	for {
+		cursors = d.fetchGroup(ctx, gc, cursors, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+// fetchGroup loads the entries of the mailboxes of the other devices of the group and returns the cursors of the mailboxes
+// still fetched
+func (d *mailboxDriver) fetchGroup(ctx context.Context, gc *groupContext, cursors mailboxCursors, now time.Time) mailboxCursors {
+	// the parents of the entries are never fetched from the network
+	offline, err := d.ipfs.WithOptions(ipfsoptions.Api.Offline(true))
+	if err != nil {
+		d.logger.Error("unable to get offline api", zap.Error(err))
+		return cursors
+	}
+
+	next := mailboxCursors{after: map[string][]byte{}, fetched: map[string]time.Time{}}
+
+	for _, devicePK := range gc.MetadataStore().ListDevices() {
+		if devicePK.Equals(gc.DevicePubKey()) {
+			continue
+		}
+
+		// the seed is unknown until the secret of the device has been received, and is forgotten once it is revoked
+		seed, err := gc.MessageKeystore().GetMailboxSeed(gc.Group(), devicePK)
+		if err != nil {
+			continue
+		}
+
+		deviceRaw, err := devicePK.Raw()
+		if err != nil {
+			continue
+		}
+
+		for _, server := range d.servers {
+			deviceKey := string(server) + string(deviceRaw)
+			periods := d.fetchPeriods
+
+			// the mailboxes of the periods preceding the last successful fetch have already been fetched, the period before
+			// it is fetched again to tolerate the clock skew between the devices
+			if last, ok := cursors.fetched[deviceKey]; ok {
+				elapsed := int(roundTimePeriod(now, mailboxRotationInterval).Sub(roundTimePeriod(last, mailboxRotationInterval)) / mailboxRotationInterval)
+				if elapsed+2 < periods {
+					periods = elapsed + 2
+				}
+			}
+
+			complete := true
+			for i := 0; i < periods; i++ {
+				mailboxID := mailboxIDForPeriod(gc.Group(), deviceRaw, seed, now.Add(-time.Duration(i)*mailboxRotationInterval))
+				key := string(server) + string(mailboxID)
+
+				after, err := d.fetchMailbox(ctx, gc, offline, seed, server, mailboxID, cursors.after[key])
+				if err != nil {
+					complete = false
+					if ctx.Err() == nil {
+						d.logger.Debug("unable to fetch mailbox", zap.Stringer("server", server), zap.Error(err))
+					}
+				}
+
+				next.after[key] = after
+			}
+
+			switch last, ok := cursors.fetched[deviceKey]; {
+			case complete:
+				next.fetched[deviceKey] = now
+			case ok:
+				next.fetched[deviceKey] = last
+			}
+		}
+	}
+
+	return next
+}
+
+// fetchMailbox loads the entries of the mailbox following the given envelope ID, it returns the ID of the last envelope
+// received
+func (d *mailboxDriver) fetchMailbox(ctx context.Context, gc *groupContext, offline ipfs_interface.CoreAPI, seed []byte, server peer.ID, mailboxID, after []byte) ([]byte, error) {
+	for {
+		msgs, err := rendezvous.MailboxFetch(ctx, d.host, server, mailboxID, after)
+		if err != nil {
+			return after, err
+		}
+
+		for _, msg := range msgs {
+			if err := d.load(ctx, gc, offline, seed, msg.Envelope); err != nil {
+				d.logger.Warn("unable to load entry from mailbox", zap.Error(err))
+			}
+		}
+
+		if len(msgs) == 0 {
+			return after, nil
+		}
+
+		after = msgs[len(msgs)-1].ID
+
+		if len(msgs) < rendezvous.MailboxMaxFetch {
+			return after, nil
+		}
+	}
+}
+
+// load opens the envelope, adds the entry to the local blocks and loads it in the message store through its replicator,
+// the signature and the access of the entry are checked as for the entries replicated from the other peers
+//
+// The replicator fetches the missing parents of the entry from the network, which blocks until they are found, so the
+// entries whose parents are not available locally are dropped, they are replicated once the devices meet.
+func (d *mailboxDriver) load(ctx context.Context, gc *groupContext, offline ipfs_interface.CoreAPI, seed, envelope []byte) error {
+	data, err := openMailboxEnvelope(seed, envelope)
+	if err != nil {
+		return err
+	}
+
+	node, err := cbornode.Decode(data, mh.SHA2_256, -1)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	if _, ok := gc.MessageStore().OpLog().Get(node.Cid()); ok {
+		return nil
+	}
+
+	for _, link := range node.Links() {
+		if _, ok := gc.MessageStore().OpLog().Get(link.Cid); ok {
+			continue
+		}
+
+		if _, err := offline.Block().Stat(ctx, ipfspath.IpfsPath(link.Cid)); err != nil {
+			return errcode.ErrNotFound.Wrap(fmt.Errorf("parent %s of entry %s is not available locally", link.Cid, node.Cid()))
+		}
+	}
+
+	if err := d.ipfs.Dag().Add(ctx, node); err != nil {
+		return errcode.ErrInternal.Wrap(fmt.Errorf("unable to add entry: %w", err))
+	}
+
+	gc.MessageStore().Replicator().Load(ctx, []cid.Cid{node.Cid()})
+
+	return nil
+}
+
+// mailboxEnvelopeKey derives the key sealing the envelopes dropped in the mailboxes of a device from their seed
+func mailboxEnvelopeKey(seed []byte) (*[cryptoutil.KeySize]byte, error) {
+	kdf := hkdf.New(sha3.New256, seed, nil, []byte(mailboxEnvelopeKeyInfo))
+
+	var key [cryptoutil.KeySize]byte
+	if _, err := io.ReadFull(kdf, key[:]); err != nil {
+		return nil, errcode.ErrStreamRead.Wrap(err)
+	}
+
+	return &key, nil
+}
+
+func sealMailboxEnvelope(seed, data []byte) ([]byte, error) {
+	key, err := mailboxEnvelopeKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := cryptoutil.GenerateNonce()
+	if err != nil {
+		return nil, errcode.ErrCryptoNonceGeneration.Wrap(err)
+	}
+
+	return append(nonce[:], secretbox.Seal(nil, data, nonce, key)...), nil
+}
+
+func openMailboxEnvelope(seed, envelope []byte) ([]byte, error) {
+	if len(envelope) <= cryptoutil.NonceSize {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("envelope too small, got %d bytes", len(envelope)))
+	}
+
+	key, err := mailboxEnvelopeKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [cryptoutil.NonceSize]byte
+	_ = copy(nonce[:], envelope[:cryptoutil.NonceSize])
+
+	data, ok := secretbox.Open(nil, envelope[cryptoutil.NonceSize:], &nonce, key)
+	if !ok {
+		return nil, errcode.ErrCryptoDecrypt
+	}
+
+	return data, nil
+}
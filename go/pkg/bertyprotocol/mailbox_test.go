@@ -0,0 +1,194 @@
+package bertyprotocol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	keystore "github.com/ipfs/go-ipfs-keystore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"berty.tech/berty/v2/go/internal/rendezvous"
+	"berty.tech/berty/v2/go/internal/testutil"
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+func TestMailboxIDForPeriod(t *testing.T) {
+	g1, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	g2, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	devicePK1, devicePK2 := []byte("device 1"), []byte("device 2")
+	seed1, seed2 := []byte("seed 1"), []byte("seed 2")
+
+	start := roundTimePeriod(time.Now(), mailboxRotationInterval)
+	id := mailboxIDForPeriod(g1, devicePK1, seed1, start)
+	require.LessOrEqual(t, len(id), rendezvous.MailboxMaxIDSize)
+
+	// the id is stable during a period
+	require.Equal(t, id, mailboxIDForPeriod(g1, devicePK1, seed1, start.Add(mailboxRotationInterval-time.Second)))
+
+	// and rotates with the periods and the seeds
+	require.NotEqual(t, id, mailboxIDForPeriod(g1, devicePK1, seed1, start.Add(mailboxRotationInterval)))
+	require.NotEqual(t, id, mailboxIDForPeriod(g1, devicePK1, seed2, start))
+
+	// each device of each group has its own mailboxes
+	require.NotEqual(t, id, mailboxIDForPeriod(g1, devicePK2, seed1, start))
+	require.NotEqual(t, id, mailboxIDForPeriod(g2, devicePK1, seed1, start))
+}
+
+func TestMessageKeystoreMailboxSeed(t *testing.T) {
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	omd1, err := NewDeviceKeystore(keystore.NewMemKeystore()).MemberDeviceForGroup(g)
+	require.NoError(t, err)
+
+	omd2, err := NewDeviceKeystore(keystore.NewMemKeystore()).MemberDeviceForGroup(g)
+	require.NoError(t, err)
+
+	mkh1, cleanup1 := newInMemMessageKeystore()
+	defer cleanup1()
+
+	mkh2, cleanup2 := newInMemMessageKeystore()
+	defer cleanup2()
+
+	ds1, err := newDeviceSecret()
+	require.NoError(t, err)
+
+	_, err = mkh2.GetMailboxSeed(g, omd1.device.GetPublic())
+	require.True(t, errcode.Is(err, errcode.ErrMissingInput))
+
+	require.NoError(t, mkh1.RegisterChainKey(g, omd1.device.GetPublic(), ds1, true))
+	require.NoError(t, mkh2.RegisterChainKey(g, omd1.device.GetPublic(), ds1, false))
+
+	// the sender and the recipients share the seed
+	seed1, err := mkh1.GetMailboxSeed(g, omd1.device.GetPublic())
+	require.NoError(t, err)
+
+	seed, err := mkh2.GetMailboxSeed(g, omd1.device.GetPublic())
+	require.NoError(t, err)
+	require.Equal(t, seed1, seed)
+
+	// the seed is renewed with the chain key
+	ds2, err := mkh1.RotateDeviceSecret(g, omd1.device.GetPublic())
+	require.NoError(t, err)
+
+	seed2, err := mkh1.GetMailboxSeed(g, omd1.device.GetPublic())
+	require.NoError(t, err)
+	require.NotEqual(t, seed1, seed2)
+
+	require.NoError(t, mkh2.RegisterChainKey(g, omd1.device.GetPublic(), ds2, false))
+
+	seed, err = mkh2.GetMailboxSeed(g, omd1.device.GetPublic())
+	require.NoError(t, err)
+	require.Equal(t, seed2, seed)
+
+	// and is forgotten once the device is revoked
+	require.NoError(t, mkh2.RevokeDevice(g, omd1.device.GetPublic(), nil))
+
+	_, err = mkh2.GetMailboxSeed(g, omd1.device.GetPublic())
+	require.True(t, errcode.Is(err, errcode.ErrMissingInput))
+
+	_, err = mkh2.GetMailboxSeed(g, omd2.device.GetPublic())
+	require.True(t, errcode.Is(err, errcode.ErrMissingInput))
+}
+
+func TestMailboxDriver_LoadsEntries(t *testing.T) {
+	testutil.FilterSpeed(t, testutil.Slow)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	peers, groupSK, cleanup := createPeersWithGroup(ctx, t, "/tmp/mailbox_test", 2, 1)
+	defer cleanup()
+
+	inviteAllPeersToGroup(ctx, t, peers, groupSK)
+
+	g := peers[0].GC.Group()
+	ds0, err := peers[0].MKS.GetDeviceSecret(g, peers[0].DevKS)
+	require.NoError(t, err)
+	require.NoError(t, peers[1].MKS.RegisterChainKey(g, peers[0].GC.DevicePubKey(), ds0, false))
+
+	// the members can't reach each other anymore, only the mailbox server
+	mn := peers[0].CoreAPI.MockNetwork()
+	p0, p1 := peers[0].CoreAPI.MockNode().Identity, peers[1].CoreAPI.MockNode().Identity
+	require.NoError(t, mn.DisconnectPeers(p0, p1))
+	require.NoError(t, mn.UnlinkPeers(p0, p1))
+
+	server, err := mn.GenPeer()
+	require.NoError(t, err)
+
+	rendezvous.NewMailbox(server, nil, rendezvous.MailboxOpts{})
+
+	for _, p := range []peer.ID{p0, p1} {
+		_, err := mn.LinkPeers(p, server.ID())
+		require.NoError(t, err)
+		_, err = mn.ConnectPeers(p, server.ID())
+		require.NoError(t, err)
+	}
+
+	d0 := newMailboxDriver(zap.NewNop(), peers[0].CoreAPI.MockNode().PeerHost, peers[0].CoreAPI.API(), []peer.ID{server.ID()}, time.Hour, rendezvous.DefaultMailboxTTL)
+	d1 := newMailboxDriver(zap.NewNop(), peers[1].CoreAPI.MockNode().PeerHost, peers[1].CoreAPI.API(), []peer.ID{server.ID()}, time.Hour, rendezvous.DefaultMailboxTTL)
+
+	op, err := peers[0].GC.MessageStore().AddMessage(ctx, []byte("offline message"), nil)
+	require.NoError(t, err)
+
+	entryCID := op.GetEntry().GetHash()
+	require.NoError(t, d0.drop(ctx, peers[0].GC, entryCID))
+
+	sub := peers[1].GC.MessageStore().Subscribe(ctx)
+
+	now := time.Now()
+	cursors := d1.fetchGroup(ctx, peers[1].GC, mailboxCursors{}, now)
+	require.Len(t, cursors.after, d1.fetchPeriods)
+	require.Len(t, cursors.fetched, 1)
+
+	var evt *protocoltypes.GroupMessageEvent
+	for e := range sub {
+		if casted, ok := e.(*protocoltypes.GroupMessageEvent); ok {
+			evt = casted
+			break
+		}
+	}
+
+	// the message is received under the CID of the entry written by the sender
+	require.NotNil(t, evt)
+	require.Equal(t, []byte("offline message"), evt.Message)
+	require.Equal(t, entryCID.Bytes(), evt.EventContext.ID)
+
+	_, ok := peers[1].GC.MessageStore().OpLog().Get(entryCID)
+	require.True(t, ok)
+
+	// the envelopes already received are not fetched again, and only the mailboxes of the periods following the last
+	// fetch are fetched
+	next := d1.fetchGroup(ctx, peers[1].GC, cursors, now.Add(time.Minute))
+	require.Len(t, next.after, 2)
+	for key, after := range next.after {
+		require.Equal(t, cursors.after[key], after)
+	}
+}
+
+func TestMailboxEnvelope(t *testing.T) {
+	seed1, seed2 := []byte("seed 1"), []byte("seed 2")
+
+	envelope, err := sealMailboxEnvelope(seed1, []byte("entry"))
+	require.NoError(t, err)
+	require.NotContains(t, string(envelope), "entry")
+
+	data, err := openMailboxEnvelope(seed1, envelope)
+	require.NoError(t, err)
+	require.Equal(t, []byte("entry"), data)
+
+	// the envelopes can only be opened with the seed of the sender
+	_, err = openMailboxEnvelope(seed2, envelope)
+	require.Error(t, err)
+
+	_, err = openMailboxEnvelope(seed1, envelope[:10])
+	require.Error(t, err)
+}
@@ -299,39 +299,42 @@ func (s *BertyOrbitDB) openGroup(ctx context.Context, g *protocoltypes.Group, op
 	return gc, nil
 }
 
-func (s *BertyOrbitDB) openGroupReplication(ctx context.Context, g *protocoltypes.Group, options *orbitdb.CreateDBOptions) error {
+// openGroupReplication opens the stores of a group without being able to read their content, the stores of the group context
+// are returned when the group is already opened as a member
+func (s *BertyOrbitDB) openGroupReplication(ctx context.Context, g *protocoltypes.Group, options *orbitdb.CreateDBOptions) (iface.Store, iface.Store, error) {
 	if g == nil || len(g.PublicKey) == 0 {
-		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("missing group or group pubkey"))
+		return nil, nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("missing group or group pubkey"))
 	}
 
 	id := g.GroupIDAsString()
 
-	_, err := s.getGroupContext(id)
+	gc, err := s.getGroupContext(id)
 	if err != nil && !errcode.Is(err, errcode.ErrMissingMapKey) {
-		return errcode.ErrInternal.Wrap(err)
+		return nil, nil, errcode.ErrInternal.Wrap(err)
 	}
 	if err == nil {
-		return nil
+		return gc.metadataStore, gc.messageStore, nil
 	}
 
 	groupID := g.GroupIDAsString()
 	s.groups.Store(groupID, g)
 
 	if err := s.registerGroupSigningPubKey(g); err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	_, err = s.storeForGroup(ctx, s, g, options, groupMetadataStoreType, GroupOpenModeReplicate)
+	metadataStore, err := s.storeForGroup(ctx, s, g, options, groupMetadataStoreType, GroupOpenModeReplicate)
 	if err != nil {
-		return errors.Wrap(err, "unable to open database")
+		return nil, nil, errors.Wrap(err, "unable to open database")
 	}
 
-	_, err = s.storeForGroup(ctx, s, g, options, groupMessageStoreType, GroupOpenModeReplicate)
+	messageStore, err := s.storeForGroup(ctx, s, g, options, groupMessageStoreType, GroupOpenModeReplicate)
 	if err != nil {
-		return errors.Wrap(err, "unable to open database")
+		_ = metadataStore.Close()
+		return nil, nil, errors.Wrap(err, "unable to open database")
 	}
 
-	return nil
+	return metadataStore, messageStore, nil
 }
 
 func (s *BertyOrbitDB) getGroupContext(id string) (*groupContext, error) {
@@ -12,10 +12,12 @@ import (
 	ipfs_core "github.com/ipfs/go-ipfs/core"
 	ipfs_interface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"go.uber.org/zap"
 
 	"berty.tech/berty/v2/go/internal/ipfsutil"
+	"berty.tech/berty/v2/go/internal/rendezvous"
 	"berty.tech/berty/v2/go/internal/tinder"
 	"berty.tech/berty/v2/go/pkg/bertyversion"
 	"berty.tech/berty/v2/go/pkg/errcode"
@@ -50,6 +52,16 @@ type service struct {
 	close          func() error
 	startedAt      time.Time
 	host           host.Host
+	deviceLinks    deviceLinkInvitations
+
+	// rendezvousSeeds is nil when no tinder driver is provided
+	rendezvousSeeds *rendezvousSeedsAnnouncer
+
+	discoveryMonitors []*tinder.DriverMonitor
+	focusedTopics     *tinder.FocusedTopics
+
+	// mailbox is nil when no mailbox server is provided
+	mailbox *mailboxDriver
 }
 
 // Opts contains optional configuration flags for building a new Client
@@ -66,6 +78,24 @@ type Opts struct {
 	PubSub                 *pubsub.PubSub
 	LocalOnly              bool
 	close                  func() error
+
+	// DiscoveryMonitors are the monitored discovery drivers whose statistics are returned by DiscoveryStats
+	DiscoveryMonitors []*tinder.DriverMonitor
+
+	// FocusedTopics is updated with the topics of the groups focused through GroupDiscoveryFocus, the focus is ignored
+	// when nil
+	FocusedTopics *tinder.FocusedTopics
+
+	// MailboxServers are the rendezvous points used to exchange the envelopes with the offline members of the groups,
+	// their addresses must be known by the host
+	MailboxServers []peer.ID
+
+	// MailboxPollInterval is the delay between two fetches of the mailboxes of a group
+	MailboxPollInterval time.Duration
+
+	// MailboxTTL is the duration the envelopes are kept by the mailbox servers, it must match their configuration so the
+	// mailboxes are fetched until their envelopes expire
+	MailboxTTL time.Duration
 }
 
 func (opts *Opts) applyDefaults(ctx context.Context) error {
@@ -73,6 +103,14 @@ func (opts *Opts) applyDefaults(ctx context.Context) error {
 		opts.Logger = zap.NewNop()
 	}
 
+	if opts.MailboxPollInterval == 0 {
+		opts.MailboxPollInterval = 5 * time.Minute
+	}
+
+	if opts.MailboxTTL == 0 {
+		opts.MailboxTTL = rendezvous.DefaultMailboxTTL
+	}
+
 	if opts.RootDatastore == nil {
 		if opts.DatastoreDir == "" || opts.DatastoreDir == InMemoryDirectory {
 			opts.RootDatastore = ds_sync.MutexWrap(datastore.NewMapDatastore())
@@ -172,23 +210,41 @@ func New(ctx context.Context, opts Opts) (Service, error) {
 		opts.Logger.Warn("no tinder driver provided, incoming and outgoing contact requests won't be enabled")
 	}
 
-	return &service{
-		ctx:            ctx,
-		host:           opts.Host,
-		ipfsCoreAPI:    opts.IpfsCoreAPI,
-		logger:         opts.Logger,
-		odb:            opts.OrbitDB,
-		deviceKeystore: opts.DeviceKeystore,
-		close:          opts.close,
-		accountGroup:   acc,
-		startedAt:      time.Now(),
+	svc := &service{
+		ctx:               ctx,
+		host:              opts.Host,
+		ipfsCoreAPI:       opts.IpfsCoreAPI,
+		logger:            opts.Logger,
+		odb:               opts.OrbitDB,
+		deviceKeystore:    opts.DeviceKeystore,
+		close:             opts.close,
+		accountGroup:      acc,
+		startedAt:         time.Now(),
+		discoveryMonitors: opts.DiscoveryMonitors,
+		focusedTopics:     opts.FocusedTopics,
 		groups: map[string]*protocoltypes.Group{
 			string(acc.Group().PublicKey): acc.Group(),
 		},
 		openedGroups: map[string]*groupContext{
 			string(acc.Group().PublicKey): acc,
 		},
-	}, nil
+	}
+
+	if opts.TinderDriver != nil && opts.Host != nil {
+		svc.rendezvousSeeds = newRendezvousSeedsAnnouncer(opts.Logger, opts.Host, opts.TinderDriver, opts.RendezvousRotationBase)
+		go svc.rendezvousSeeds.watchGroup(ctx, acc)
+	}
+
+	if len(opts.MailboxServers) > 0 && opts.Host != nil {
+		svc.mailbox = newMailboxDriver(opts.Logger, opts.Host, opts.IpfsCoreAPI, opts.MailboxServers, opts.MailboxPollInterval, opts.MailboxTTL)
+	}
+
+	opts.IpfsCoreAPI.SetStreamHandler(deviceLinkProtocolID, svc.handleDeviceLinkStream)
+
+	go svc.watchRevokedDevices(ctx)
+	go svc.watchLinkedDevices(ctx)
+
+	return svc, nil
 }
 
 func (s *service) IpfsCoreAPI() ipfs_interface.CoreAPI {
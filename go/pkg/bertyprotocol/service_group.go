@@ -1,6 +1,7 @@
 package bertyprotocol
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/libp2p/go-libp2p-core/crypto"
@@ -116,6 +117,9 @@ func (s *service) deactivateGroup(pk crypto.PubKey) error {
 		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("can't deactivate account group"))
 	}
 
+	// the topics of a closed group must not be looked up more often if it is activated again later
+	s.setGroupDiscoveryFocus(cg, false)
+
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -172,10 +176,24 @@ func (s *service) activateGroup(pk crypto.PubKey, localOnly bool) error {
 			return errcode.TODO.Wrap(err)
 		}
 
+		for _, devicePK := range s.accountGroup.MetadataStore().ListRevokedDevices() {
+			if err := publishRevokedDevice(s.ctx, gc, devicePK); err != nil {
+				s.logger.Error("unable to publish revoked device", zap.Error(err))
+			}
+		}
+
 		s.openedGroups[string(id)] = gc
 
 		TagGroupContextPeers(s.ctx, gc, s.ipfsCoreAPI, 42)
 
+		if s.rendezvousSeeds != nil {
+			go s.rendezvousSeeds.watchGroup(s.ctx, gc)
+		}
+
+		if s.mailbox != nil {
+			go s.mailbox.watchGroup(s.ctx, gc)
+		}
+
 		return nil
 	case protocoltypes.GroupTypeAccount:
 		return errcode.ErrInternal.Wrap(fmt.Errorf("deviceKeystore group should already be opened"))
@@ -200,3 +218,135 @@ func (s *service) getContextGroupForID(id []byte) (*groupContext, error) {
 
 	return nil, errcode.ErrInternal.Wrap(fmt.Errorf("unknown group or not activated yet"))
 }
+
+// watchLinkedDevices makes sure that the secrets of the current device are sent to the account member in every opened group once a
+// new device has been linked to the account, so the linked device can open the messages of the current device
+func (s *service) watchLinkedDevices(ctx context.Context) {
+	sub := s.accountGroup.MetadataStore().Subscribe(ctx)
+
+	for evt := range sub {
+		e, ok := evt.(*protocoltypes.GroupMetadataEvent)
+		if !ok || e.Metadata.EventType != protocoltypes.EventTypeAccountDeviceAdded {
+			continue
+		}
+
+		event := &protocoltypes.AccountDeviceAdded{}
+		if err := event.Unmarshal(e.Event); err != nil {
+			s.logger.Error("unable to unmarshal payload", zap.Error(err))
+			continue
+		}
+
+		devicePK, err := crypto.UnmarshalEd25519PublicKey(event.AddedDevicePK)
+		if err != nil {
+			s.logger.Error("unable to unmarshal linked device pk", zap.Error(err))
+			continue
+		}
+
+		// the event might have been rejected by the index, ie. if it was not signed by the account
+		if !s.accountGroup.MetadataStore().IsDeviceLinked(devicePK) {
+			continue
+		}
+
+		s.handleLinkedDevice(ctx, devicePK)
+	}
+}
+
+func (s *service) handleLinkedDevice(ctx context.Context, devicePK crypto.PubKey) {
+	s.lock.Lock()
+	groups := make([]*groupContext, 0, len(s.openedGroups))
+	for _, gc := range s.openedGroups {
+		groups = append(groups, gc)
+	}
+	s.lock.Unlock()
+
+	for _, gc := range groups {
+		if err := handleLinkedDevice(ctx, gc, devicePK); err != nil {
+			s.logger.Error("unable to send secret to linked device", zap.Error(err), zap.String("group", gc.Group().GroupIDAsString()))
+		}
+	}
+}
+
+// watchRevokedDevices publishes the revocations of the devices of the account in every opened group, so every member stops accepting
+// the messages of the revoked devices
+func (s *service) watchRevokedDevices(ctx context.Context) {
+	sub := s.accountGroup.MetadataStore().Subscribe(ctx)
+
+	for _, devicePK := range s.accountGroup.MetadataStore().ListRevokedDevices() {
+		s.handleRevokedDevice(ctx, devicePK)
+	}
+
+	for evt := range sub {
+		e, ok := evt.(*protocoltypes.GroupMetadataEvent)
+		if !ok || e.Metadata.EventType != protocoltypes.EventTypeAccountDeviceRevoked {
+			continue
+		}
+
+		event := &protocoltypes.AccountDeviceRevoked{}
+		if err := event.Unmarshal(e.Event); err != nil {
+			s.logger.Error("unable to unmarshal payload", zap.Error(err))
+			continue
+		}
+
+		devicePK, err := crypto.UnmarshalEd25519PublicKey(event.RevokedDevicePK)
+		if err != nil {
+			s.logger.Error("unable to unmarshal revoked device pk", zap.Error(err))
+			continue
+		}
+
+		// the event might have been rejected by the index, ie. if it was not signed by the account
+		if !s.accountGroup.MetadataStore().IsDeviceRevoked(devicePK) {
+			continue
+		}
+
+		s.handleRevokedDevice(ctx, devicePK)
+	}
+}
+
+func (s *service) handleRevokedDevice(ctx context.Context, devicePK crypto.PubKey) {
+	s.lock.Lock()
+	groups := make([]*groupContext, 0, len(s.openedGroups))
+	for _, gc := range s.openedGroups {
+		groups = append(groups, gc)
+	}
+	s.lock.Unlock()
+
+	for _, gc := range groups {
+		if err := publishRevokedDevice(ctx, gc, devicePK); err != nil {
+			s.logger.Error("unable to publish revoked device", zap.Error(err), zap.String("group", gc.Group().GroupIDAsString()))
+		}
+	}
+}
+
+// publishRevokedDevice publishes the revocation of a device of the account in a group, the secrets are then rotated by the members
+// of the group once they receive it
+func publishRevokedDevice(ctx context.Context, gc *groupContext, devicePK crypto.PubKey) error {
+	if devicePK.Equals(gc.DevicePubKey()) || gc.MetadataStore().IsDeviceRevoked(devicePK) {
+		return nil
+	}
+
+	cutoff, err := getRevocationCutoff(gc, devicePK)
+	if err != nil {
+		return err
+	}
+
+	if _, err := gc.MetadataStore().RevokeDevice(ctx, devicePK, cutoff); err != nil && !errcode.Is(err, errcode.ErrGroupDeviceRevoked) {
+		return err
+	}
+
+	return nil
+}
+
+// getRevocationCutoff returns the counter of the last message of a device known by the current device in a group, it is published
+// along with its revocation
+func getRevocationCutoff(gc *groupContext, devicePK crypto.PubKey) (*protocoltypes.RevokedDeviceCutoff, error) {
+	cutoffs, err := gc.MessageStore().GetDevicesCutoffs([]crypto.PubKey{devicePK})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cutoffs) == 0 {
+		return nil, nil
+	}
+
+	return cutoffs[0], nil
+}
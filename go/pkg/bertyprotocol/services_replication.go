@@ -3,53 +3,469 @@ package bertyprotocol
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p-core/protocol"
 	"go.uber.org/zap"
 
 	"berty.tech/berty/v2/go/pkg/errcode"
 	"berty.tech/berty/v2/go/pkg/protocoltypes"
+	ipfslog "berty.tech/go-ipfs-log"
+	"berty.tech/go-orbit-db/events"
+	"berty.tech/go-orbit-db/iface"
+	"berty.tech/go-orbit-db/stores"
 )
 
 const (
 	serviceReplicationKeyGroupPrefix = "group"
 	ServiceReplicationID             = "rpl"
+
+	// ReplicationServiceProtocolID is the libp2p protocol used to reach the replication service authenticated by its peer ID
+	ReplicationServiceProtocolID = protocol.ID("/berty/replication/1.0.0")
+
+	// serviceReplicationAnonymousToken identifies the groups registered without token, when the service doesn't require
+	// authentication
+	serviceReplicationAnonymousToken = "anonymous"
+
+	// serviceReplicationLegacyToken identifies the groups registered before the tokens were checked, each of them is migrated
+	// to the first token registering it again
+	serviceReplicationLegacyToken = "TODO"
 )
 
+// ReplicationQuota limits the resources used by each token on a replication service, zero values disable the limits
+type ReplicationQuota struct {
+	// MaxGroups is the maximum number of groups replicated for a token
+	MaxGroups int
+
+	// MaxStorage is the maximum size of the entries payloads of the groups replicated for a token, no group can be
+	// registered once it is reached and the replication of the groups is suspended once it is exceeded
+	MaxStorage uint64
+}
+
+type replicatedGroup struct {
+	group         *protocoltypes.Group
+	metadataStore iface.Store
+	messageStore  iface.Store
+
+	// tokens are the tokens having registered the group
+	tokens map[string]struct{}
+
+	// legacy is set while the group is only replicated because of a registration stored before the tokens were checked
+	legacy bool
+
+	// cancel stops accounting the entries of the stores
+	cancel context.CancelFunc
+
+	// metadataEntries, messageEntries and size account the entries replicated for the group, counted holds their
+	// hashes so an entry is accounted once even when the stores are reopened
+	metadataEntries uint64
+	messageEntries  uint64
+	size            uint64
+	counted         map[string]struct{}
+
+	// suspended is set while the stores are closed because every token replicating the group exceeds its storage quota
+	suspended bool
+}
+
 type replicationService struct {
 	odb    *BertyOrbitDB
 	ds     ds.Datastore
 	logger *zap.Logger
 	ctx    context.Context
+	quota  ReplicationQuota
+
+	muGroups sync.Mutex
+	groups   map[string]*replicatedGroup // indexed by group public key
+}
+
+func replicationGroupKey(token string, groupPK []byte) ds.Key {
+	return ds.KeyWithNamespaces([]string{serviceReplicationKeyGroupPrefix, token, string(groupPK)})
+}
+
+// replicationTokenFromContext returns the identifier of the token authenticated by the auth interceptor
+func replicationTokenFromContext(ctx context.Context) string {
+	if tokenID, ok := ctx.Value(ContextTokenHashField).(string); ok && tokenID != "" {
+		return tokenID
+	}
+
+	return serviceReplicationAnonymousToken
 }
 
 func (s *replicationService) GroupRegister(token string, group *protocoltypes.Group) error {
+	if group == nil || len(group.PublicKey) == 0 {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("missing group or group pubkey"))
+	}
+
+	s.muGroups.Lock()
+	defer s.muGroups.Unlock()
+
+	if rg, ok := s.groups[string(group.PublicKey)]; ok {
+		if _, ok := rg.tokens[token]; ok {
+			return nil
+		}
+	}
+
+	if err := s.checkQuota(token); err != nil {
+		return err
+	}
+
 	data, err := group.Marshal()
 	if err != nil {
 		s.logger.Error("error while marshaling request", zap.Error(err))
 		return err
 	}
 
-	if err := s.ds.Put(ds.KeyWithNamespaces([]string{serviceReplicationKeyGroupPrefix, token, string(group.PublicKey)}), data); err != nil {
+	if err := s.ds.Put(replicationGroupKey(token, group.PublicKey), data); err != nil {
 		s.logger.Error("error while registering group", zap.Error(err))
 		return err
 	}
 
 	s.logger.Info("registering group", zap.Binary("group_pk", group.PublicKey))
 
-	return s.GroupSubscribe(group)
+	rg, err := s.groupSubscribe(group)
+	if err != nil {
+		return err
+	}
+
+	rg.tokens[token] = struct{}{}
+
+	if err := s.resumeGroup(rg); err != nil {
+		return err
+	}
+
+	if rg.legacy {
+		if err := s.ds.Delete(replicationGroupKey(serviceReplicationLegacyToken, group.PublicKey)); err != nil {
+			s.logger.Warn("unable to delete legacy group registration", zap.Error(err))
+		} else {
+			rg.legacy = false
+			s.logger.Info("migrated legacy group registration", zap.Binary("group_pk", group.PublicKey))
+		}
+	}
+
+	return nil
+}
+
+// checkQuota returns an error when the token can't register another group, muGroups must be held by the caller
+func (s *replicationService) checkQuota(token string) error {
+	if s.quota.MaxGroups == 0 && s.quota.MaxStorage == 0 {
+		return nil
+	}
+
+	count, storage := 0, uint64(0)
+	for _, rg := range s.groups {
+		if _, ok := rg.tokens[token]; !ok {
+			continue
+		}
+
+		count++
+		storage += rg.size
+	}
+
+	if s.quota.MaxGroups > 0 && count >= s.quota.MaxGroups {
+		return errcode.ErrServiceReplicationQuotaExceeded.Wrap(fmt.Errorf("maximum number of groups reached (%d)", s.quota.MaxGroups))
+	}
+
+	if s.quota.MaxStorage > 0 && storage >= s.quota.MaxStorage {
+		return errcode.ErrServiceReplicationQuotaExceeded.Wrap(fmt.Errorf("maximum storage reached (%d bytes)", s.quota.MaxStorage))
+	}
+
+	return nil
 }
 
 func (s *replicationService) GroupSubscribe(group *protocoltypes.Group) error {
-	return s.odb.openGroupReplication(s.ctx, group, nil)
+	s.muGroups.Lock()
+	defer s.muGroups.Unlock()
+
+	_, err := s.groupSubscribe(group)
+	return err
 }
 
-func (s *replicationService) ReplicateGroup(_ context.Context, req *protocoltypes.ReplicationServiceReplicateGroup_Request) (*protocoltypes.ReplicationServiceReplicateGroup_Reply, error) {
-	// TODO: retrieve auth token
-	err := s.GroupRegister("TODO", req.Group)
+// groupSubscribe opens the stores of a group if needed, muGroups must be held by the caller
+func (s *replicationService) groupSubscribe(group *protocoltypes.Group) (*replicatedGroup, error) {
+	if rg, ok := s.groups[string(group.GetPublicKey())]; ok {
+		return rg, nil
+	}
+
+	rg := &replicatedGroup{
+		group:   group,
+		tokens:  map[string]struct{}{},
+		counted: map[string]struct{}{},
+	}
+
+	if err := s.openGroupStores(rg); err != nil {
+		return nil, err
+	}
+
+	s.groups[string(group.PublicKey)] = rg
 
-	return &protocoltypes.ReplicationServiceReplicateGroup_Reply{}, err
+	return rg, nil
+}
+
+// openGroupStores opens the stores of a group and accounts their entries as they are replicated, muGroups must be held
+// by the caller
+func (s *replicationService) openGroupStores(rg *replicatedGroup) error {
+	metadataStore, messageStore, err := s.odb.openGroupReplication(s.ctx, rg.group, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	rg.metadataStore, rg.messageStore, rg.cancel = metadataStore, messageStore, cancel
+	rg.suspended = false
+
+	// subscribe before accounting the entries already loaded so none is missed
+	metadataSub, messageSub := metadataStore.Subscribe(ctx), messageStore.Subscribe(ctx)
+
+	for _, e := range metadataStore.OpLog().GetEntries().Slice() {
+		rg.count(e, &rg.metadataEntries)
+	}
+
+	for _, e := range messageStore.OpLog().GetEntries().Slice() {
+		rg.count(e, &rg.messageEntries)
+	}
+
+	go s.countEntries(rg, metadataSub, &rg.metadataEntries)
+	go s.countEntries(rg, messageSub, &rg.messageEntries)
+
+	return nil
+}
+
+// closeGroupStores stops accounting the entries of a group and closes its stores, muGroups must be held by the caller
+func (s *replicationService) closeGroupStores(rg *replicatedGroup) {
+	rg.cancel()
+
+	// the stores are shared with the group context when the service is also a member of the group
+	if _, err := s.odb.getGroupContext(rg.group.GroupIDAsString()); err == nil {
+		return
+	}
+
+	if err := rg.metadataStore.Close(); err != nil {
+		s.logger.Warn("unable to close metadata store", zap.Error(err))
+	}
+
+	if err := rg.messageStore.Close(); err != nil {
+		s.logger.Warn("unable to close message store", zap.Error(err))
+	}
+}
+
+// countEntries accounts the entries written to or replicated on a store, and enforces the storage quota of the tokens
+// replicating the group
+func (s *replicationService) countEntries(rg *replicatedGroup, sub <-chan events.Event, entries *uint64) {
+	for evt := range sub {
+		var entry ipfslog.Entry
+
+		switch e := evt.(type) {
+		case *stores.EventWrite:
+			entry = e.Entry
+		case *stores.EventReplicateProgress:
+			entry = e.Entry
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		s.muGroups.Lock()
+		if rg.count(entry, entries) {
+			s.enforceStorageQuota(rg)
+		}
+		s.muGroups.Unlock()
+	}
+}
+
+// count accounts an entry of the group, it returns false when the entry was already accounted, muGroups must be held by
+// the caller
+func (rg *replicatedGroup) count(e ipfslog.Entry, entries *uint64) bool {
+	key := e.GetHash().KeyString()
+	if _, ok := rg.counted[key]; ok {
+		return false
+	}
+
+	rg.counted[key] = struct{}{}
+	*entries++
+	rg.size += uint64(len(e.GetPayload()))
+
+	return true
+}
+
+// tokenStorage returns the size of the entries of the groups replicated for a token, muGroups must be held by the caller
+func (s *replicationService) tokenStorage(token string) uint64 {
+	storage := uint64(0)
+	for _, rg := range s.groups {
+		if _, ok := rg.tokens[token]; ok {
+			storage += rg.size
+		}
+	}
+
+	return storage
+}
+
+// isOverStorageQuota returns whether every token replicating a group exceeds its storage quota, muGroups must be held by
+// the caller
+func (s *replicationService) isOverStorageQuota(rg *replicatedGroup) bool {
+	if s.quota.MaxStorage == 0 || rg.legacy || len(rg.tokens) == 0 {
+		return false
+	}
+
+	for token := range rg.tokens {
+		if s.tokenStorage(token) <= s.quota.MaxStorage {
+			return false
+		}
+	}
+
+	return true
+}
+
+// enforceStorageQuota suspends the replication of a group once every token replicating it exceeds its storage quota,
+// muGroups must be held by the caller
+func (s *replicationService) enforceStorageQuota(rg *replicatedGroup) {
+	if rg.suspended || !s.isOverStorageQuota(rg) {
+		return
+	}
+
+	rg.suspended = true
+	s.logger.Warn("storage quota exceeded, suspending group replication", zap.Binary("group_pk", rg.group.PublicKey), zap.Uint64("size", rg.size))
+
+	// the stores can't be closed from the goroutine consuming their events
+	rg.cancel()
+	go func(metadataStore, messageStore iface.Store) {
+		s.muGroups.Lock()
+		defer s.muGroups.Unlock()
+
+		if !rg.suspended || rg.metadataStore != metadataStore {
+			return
+		}
+
+		s.closeGroupStores(rg)
+	}(rg.metadataStore, rg.messageStore)
+}
+
+// resumeGroup reopens the stores of a suspended group when a token replicating it is within its storage quota again,
+// muGroups must be held by the caller
+func (s *replicationService) resumeGroup(rg *replicatedGroup) error {
+	if !rg.suspended || s.isOverStorageQuota(rg) {
+		return nil
+	}
+
+	if err := s.openGroupStores(rg); err != nil {
+		return err
+	}
+
+	s.logger.Info("resuming group replication", zap.Binary("group_pk", rg.group.PublicKey))
+
+	return nil
+}
+
+// GroupUnregister stops replicating a group for a token, the stores of the group are closed once no token replicates it
+func (s *replicationService) GroupUnregister(token string, groupPK []byte) error {
+	s.muGroups.Lock()
+	defer s.muGroups.Unlock()
+
+	rg, ok := s.groups[string(groupPK)]
+	if !ok {
+		return errcode.ErrNotFound.Wrap(fmt.Errorf("group is not replicated"))
+	}
+
+	if _, ok := rg.tokens[token]; !ok {
+		return errcode.ErrNotFound.Wrap(fmt.Errorf("group is not replicated for this token"))
+	}
+
+	if err := s.ds.Delete(replicationGroupKey(token, groupPK)); err != nil {
+		return errcode.ErrInternal.Wrap(err)
+	}
+
+	delete(rg.tokens, token)
+	s.logger.Info("unregistering group", zap.Binary("group_pk", groupPK))
+
+	if len(rg.tokens) == 0 && !rg.legacy {
+		delete(s.groups, string(groupPK))
+
+		if !rg.suspended {
+			s.closeGroupStores(rg)
+		}
+	}
+
+	// the storage used by the token decreased
+	s.resumeGroups()
+
+	return nil
+}
+
+// resumeGroups reopens the suspended groups which are within the storage quota of a token, muGroups must be held by the
+// caller
+func (s *replicationService) resumeGroups() {
+	for _, rg := range s.groups {
+		if err := s.resumeGroup(rg); err != nil {
+			s.logger.Error("unable to resume group replication", zap.Binary("group_pk", rg.group.PublicKey), zap.Error(err))
+		}
+	}
+}
+
+// GroupStatus returns the status of a group replicated for a token
+func (s *replicationService) GroupStatus(token string, groupPK []byte) (*protocoltypes.ReplicationGroupStatus, error) {
+	s.muGroups.Lock()
+	defer s.muGroups.Unlock()
+
+	rg, ok := s.groups[string(groupPK)]
+	if !ok {
+		return nil, errcode.ErrNotFound.Wrap(fmt.Errorf("group is not replicated"))
+	}
+
+	if _, ok := rg.tokens[token]; !ok {
+		return nil, errcode.ErrNotFound.Wrap(fmt.Errorf("group is not replicated for this token"))
+	}
+
+	if rg.suspended {
+		return nil, errcode.ErrServiceReplicationQuotaExceeded.Wrap(fmt.Errorf("group replication is suspended (%d bytes)", rg.size))
+	}
+
+	return rg.status(), nil
+}
+
+// status returns the status of a replicated group, muGroups must be held by the caller
+func (rg *replicatedGroup) status() *protocoltypes.ReplicationGroupStatus {
+	status := &protocoltypes.ReplicationGroupStatus{
+		MetadataEntries: rg.metadataEntries,
+		MessageEntries:  rg.messageEntries,
+		EntriesSize:     rg.size,
+	}
+
+	for _, head := range rg.metadataStore.OpLog().RawHeads().Slice() {
+		status.MetadataHeads = append(status.MetadataHeads, head.GetHash().Bytes())
+	}
+
+	for _, head := range rg.messageStore.OpLog().RawHeads().Slice() {
+		status.MessageHeads = append(status.MessageHeads, head.GetHash().Bytes())
+	}
+
+	return status
+}
+
+func (s *replicationService) ReplicateGroup(ctx context.Context, req *protocoltypes.ReplicationServiceReplicateGroup_Request) (*protocoltypes.ReplicationServiceReplicateGroup_Reply, error) {
+	if err := s.GroupRegister(replicationTokenFromContext(ctx), req.Group); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.ReplicationServiceReplicateGroup_Reply{OK: true}, nil
+}
+
+func (s *replicationService) UnreplicateGroup(ctx context.Context, req *protocoltypes.ReplicationServiceUnreplicateGroup_Request) (*protocoltypes.ReplicationServiceUnreplicateGroup_Reply, error) {
+	if err := s.GroupUnregister(replicationTokenFromContext(ctx), req.GroupPK); err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.ReplicationServiceUnreplicateGroup_Reply{}, nil
+}
+
+func (s *replicationService) ReplicationStatus(ctx context.Context, req *protocoltypes.ReplicationServiceReplicationStatus_Request) (*protocoltypes.ReplicationServiceReplicationStatus_Reply, error) {
+	status, err := s.GroupStatus(replicationTokenFromContext(ctx), req.GroupPK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &protocoltypes.ReplicationServiceReplicationStatus_Reply{Status: status}, nil
 }
 
 func (s *replicationService) Close() error {
@@ -65,7 +481,7 @@ type ReplicationService interface {
 	Close() error
 }
 
-func NewReplicationService(ctx context.Context, store ds.Datastore, odb *BertyOrbitDB, logger *zap.Logger) (ReplicationService, error) {
+func NewReplicationService(ctx context.Context, store ds.Datastore, odb *BertyOrbitDB, logger *zap.Logger, quota ReplicationQuota) (ReplicationService, error) {
 	if store == nil {
 		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("store should not be nil"))
 	}
@@ -83,6 +499,8 @@ func NewReplicationService(ctx context.Context, store ds.Datastore, odb *BertyOr
 		logger: logger,
 		odb:    odb,
 		ds:     store,
+		quota:  quota,
+		groups: map[string]*replicatedGroup{},
 	}
 
 	res, err := store.Query(query.Query{Prefix: serviceReplicationKeyGroupPrefix})
@@ -91,6 +509,9 @@ func NewReplicationService(ctx context.Context, store ds.Datastore, odb *BertyOr
 		return nil, err
 	}
 
+	r.muGroups.Lock()
+	defer r.muGroups.Unlock()
+
 	for data := range res.Next() {
 		group := &protocoltypes.Group{}
 		if err := group.Unmarshal(data.Value); err != nil {
@@ -98,10 +519,24 @@ func NewReplicationService(ctx context.Context, store ds.Datastore, odb *BertyOr
 			continue
 		}
 
-		if err := r.GroupSubscribe(group); err != nil {
+		rg, err := r.groupSubscribe(group)
+		if err != nil {
 			logger.Error("unable to subscribe to group updates", zap.Error(err))
 			continue
 		}
+
+		namespaces := ds.RawKey(data.Key).Namespaces()
+		switch {
+		case len(namespaces) < 2:
+		case namespaces[1] == serviceReplicationLegacyToken:
+			rg.legacy = true
+		default:
+			rg.tokens[namespaces[1]] = struct{}{}
+		}
+	}
+
+	for _, rg := range r.groups {
+		r.enforceStorageQuota(rg)
 	}
 
 	return r, nil
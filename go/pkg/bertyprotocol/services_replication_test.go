@@ -17,6 +17,7 @@ import (
 
 	"berty.tech/berty/v2/go/internal/ipfsutil"
 	"berty.tech/berty/v2/go/internal/testutil"
+	"berty.tech/berty/v2/go/pkg/errcode"
 	"berty.tech/berty/v2/go/pkg/protocoltypes"
 	orbitdb "berty.tech/go-orbit-db"
 )
@@ -54,7 +55,7 @@ func TestNewReplicationService(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	repl, err := NewReplicationService(ctx, ds, odb, zap.NewNop())
+	repl, err := NewReplicationService(ctx, ds, odb, zap.NewNop(), ReplicationQuota{})
 	require.NoError(t, err)
 	require.NotNil(t, repl)
 }
@@ -117,6 +118,182 @@ func TestReplicationService_GroupRegister(t *testing.T) {
 	require.NotNil(t, gc)
 }
 
+func TestReplicationService_Quota(t *testing.T) {
+	ctx, cancel, mn, rdvp := testHelperIPFSSetUp(t)
+	defer cancel()
+
+	repl, cancel := testHelperNewReplicationService(ctx, t, nil, mn, rdvp.Peerstore().PeerInfo(rdvp.ID()), nil)
+	defer cancel()
+
+	repl.quota = ReplicationQuota{MaxGroups: 1}
+
+	newReplGroup := func() *protocoltypes.Group {
+		g, _, err := NewGroupMultiMember()
+		require.NoError(t, err)
+
+		replGroup, err := g.FilterForReplication()
+		require.NoError(t, err)
+
+		return replGroup
+	}
+
+	g1, g2 := newReplGroup(), newReplGroup()
+
+	require.NoError(t, repl.GroupRegister("token1", g1))
+	require.NoError(t, repl.GroupRegister("token1", g1)) // already registered
+	require.True(t, errcode.Is(repl.GroupRegister("token1", g2), errcode.ErrServiceReplicationQuotaExceeded))
+	require.NoError(t, repl.GroupRegister("token2", g2))
+	require.True(t, errcode.Is(repl.GroupRegister("token2", g1), errcode.ErrServiceReplicationQuotaExceeded))
+
+	require.NoError(t, repl.GroupUnregister("token1", g1.PublicKey))
+	require.NoError(t, repl.GroupRegister("token1", g2))
+}
+
+func TestReplicationService_StorageQuota(t *testing.T) {
+	ctx, cancel, mn, rdvp := testHelperIPFSSetUp(t)
+	defer cancel()
+
+	repl, cancel := testHelperNewReplicationService(ctx, t, nil, mn, rdvp.Peerstore().PeerInfo(rdvp.ID()), nil)
+	defer cancel()
+
+	repl.quota = ReplicationQuota{MaxStorage: 10}
+
+	newReplGroup := func() *protocoltypes.Group {
+		g, _, err := NewGroupMultiMember()
+		require.NoError(t, err)
+
+		replGroup, err := g.FilterForReplication()
+		require.NoError(t, err)
+
+		return replGroup
+	}
+
+	g1, g2, g3 := newReplGroup(), newReplGroup(), newReplGroup()
+
+	require.NoError(t, repl.GroupRegister("token1", g1))
+	require.NoError(t, repl.GroupRegister("token1", g2))
+
+	// simulate the replication of entries exceeding the quota of the token
+	repl.muGroups.Lock()
+	repl.groups[string(g1.PublicKey)].size = 6
+	repl.groups[string(g2.PublicKey)].size = 6
+	repl.enforceStorageQuota(repl.groups[string(g2.PublicKey)])
+	require.True(t, repl.groups[string(g2.PublicKey)].suspended)
+	repl.muGroups.Unlock()
+
+	_, err := repl.GroupStatus("token1", g2.PublicKey)
+	require.True(t, errcode.Is(err, errcode.ErrServiceReplicationQuotaExceeded))
+
+	require.True(t, errcode.Is(repl.GroupRegister("token1", g3), errcode.ErrServiceReplicationQuotaExceeded))
+
+	// the replication is resumed once the token is within its quota again
+	require.NoError(t, repl.GroupUnregister("token1", g1.PublicKey))
+
+	status, err := repl.GroupStatus("token1", g2.PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), status.EntriesSize)
+}
+
+func TestReplicationService_UnregisterAndStatus(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	ctx, cancel, mn, rdvp := testHelperIPFSSetUp(t)
+	defer cancel()
+
+	repl, cancel := testHelperNewReplicationService(ctx, t, nil, mn, rdvp.Peerstore().PeerInfo(rdvp.ID()), ds)
+	defer cancel()
+
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	replGroup, err := g.FilterForReplication()
+	require.NoError(t, err)
+
+	_, err = repl.ReplicationStatus(ctx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.True(t, errcode.Is(err, errcode.ErrNotFound))
+
+	tokenCtx := context.WithValue(ctx, ContextTokenHashField, "token1")
+	token2Ctx := context.WithValue(ctx, ContextTokenHashField, "token2")
+	_, err = repl.ReplicateGroup(tokenCtx, &protocoltypes.ReplicationServiceReplicateGroup_Request{Group: replGroup})
+	require.NoError(t, err)
+
+	// the status is only available to the tokens which registered the group
+	_, err = repl.ReplicationStatus(token2Ctx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.True(t, errcode.Is(err, errcode.ErrNotFound))
+
+	require.NoError(t, repl.GroupRegister("token2", replGroup))
+
+	status, err := repl.ReplicationStatus(tokenCtx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.NoError(t, err)
+	require.NotNil(t, status.Status)
+	require.Equal(t, uint64(0), status.Status.MessageEntries)
+
+	_, err = repl.UnreplicateGroup(ctx, &protocoltypes.ReplicationServiceUnreplicateGroup_Request{GroupPK: replGroup.PublicKey})
+	require.True(t, errcode.Is(err, errcode.ErrNotFound), "group is not registered for the anonymous token")
+
+	_, err = repl.UnreplicateGroup(tokenCtx, &protocoltypes.ReplicationServiceUnreplicateGroup_Request{GroupPK: replGroup.PublicKey})
+	require.NoError(t, err)
+
+	// still replicated for token2
+	_, err = repl.ReplicationStatus(tokenCtx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.True(t, errcode.Is(err, errcode.ErrNotFound))
+
+	_, err = repl.ReplicationStatus(token2Ctx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.NoError(t, err)
+
+	require.NoError(t, repl.GroupUnregister("token2", replGroup.PublicKey))
+
+	_, err = repl.ReplicationStatus(token2Ctx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.True(t, errcode.Is(err, errcode.ErrNotFound))
+
+	has, err := ds.Has(replicationGroupKey("token2", replGroup.PublicKey))
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+func TestReplicationService_LegacyRegistration(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	ctx, cancel, mn, rdvp := testHelperIPFSSetUp(t)
+	defer cancel()
+
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	replGroup, err := g.FilterForReplication()
+	require.NoError(t, err)
+
+	data, err := replGroup.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, ds.Put(replicationGroupKey(serviceReplicationLegacyToken, replGroup.PublicKey), data))
+
+	repl, cancel := testHelperNewReplicationService(ctx, t, nil, mn, rdvp.Peerstore().PeerInfo(rdvp.ID()), ds)
+	defer cancel()
+
+	// the legacy registration doesn't belong to any token
+	tokenCtx := context.WithValue(ctx, ContextTokenHashField, "token1")
+	_, err = repl.ReplicationStatus(tokenCtx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.True(t, errcode.Is(err, errcode.ErrNotFound))
+
+	_, err = repl.UnreplicateGroup(tokenCtx, &protocoltypes.ReplicationServiceUnreplicateGroup_Request{GroupPK: replGroup.PublicKey})
+	require.True(t, errcode.Is(err, errcode.ErrNotFound))
+
+	// it is migrated to the first token registering the group
+	_, err = repl.ReplicateGroup(tokenCtx, &protocoltypes.ReplicationServiceReplicateGroup_Request{Group: replGroup})
+	require.NoError(t, err)
+
+	_, err = repl.ReplicationStatus(tokenCtx, &protocoltypes.ReplicationServiceReplicationStatus_Request{GroupPK: replGroup.PublicKey})
+	require.NoError(t, err)
+
+	has, err := ds.Has(replicationGroupKey(serviceReplicationLegacyToken, replGroup.PublicKey))
+	require.NoError(t, err)
+	require.False(t, has)
+
+	has, err = ds.Has(replicationGroupKey("token1", replGroup.PublicKey))
+	require.NoError(t, err)
+	require.True(t, has)
+}
+
 func TestReplicationService_Flow(t *testing.T) {
 	testutil.FilterStabilityAndSpeed(t, testutil.Unstable, testutil.Slow)
 
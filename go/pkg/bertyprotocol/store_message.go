@@ -199,6 +199,54 @@ func (m *messageStore) AddMessage(ctx context.Context, payload []byte, attachmen
 	return op, nil
 }
 
+// GetDevicesCutoffs returns the counter of the last message of each device found in the log, it is published along with the
+// revocation of the devices so that every member stops accepting their messages at the same point, the devices which have not sent
+// any message have no cutoff
+func (m *messageStore) GetDevicesCutoffs(devices []crypto.PubKey) ([]*protocoltypes.RevokedDeviceCutoff, error) {
+	cutoffs := map[string]*protocoltypes.RevokedDeviceCutoff{}
+	for _, device := range devices {
+		raw, err := device.Raw()
+		if err != nil {
+			return nil, errcode.ErrSerialization.Wrap(err)
+		}
+
+		cutoffs[string(raw)] = nil
+	}
+
+	for _, e := range m.OpLog().GetEntries().Slice() {
+		op, err := operation.ParseOperation(e)
+		if err != nil {
+			continue
+		}
+
+		_, headers, err := openEnvelopeHeaders(op.GetValue(), m.g)
+		if err != nil {
+			continue
+		}
+
+		cutoff, ok := cutoffs[string(headers.DevicePK)]
+		if !ok {
+			continue
+		}
+
+		if cutoff == nil || counterAfter(headers.Counter, cutoff.Counter) {
+			cutoffs[string(headers.DevicePK)] = &protocoltypes.RevokedDeviceCutoff{
+				DevicePK: headers.DevicePK,
+				Counter:  headers.Counter,
+			}
+		}
+	}
+
+	res := []*protocoltypes.RevokedDeviceCutoff(nil)
+	for _, cutoff := range cutoffs {
+		if cutoff != nil {
+			res = append(res, cutoff)
+		}
+	}
+
+	return res, nil
+}
+
 func constructorFactoryGroupMessage(s *BertyOrbitDB) iface.StoreConstructor {
 	return func(ctx context.Context, ipfs coreapi.CoreAPI, identity *identityprovider.Identity, addr address.Address, options *iface.NewStoreOptions) (iface.Store, error) {
 		g, err := s.getGroupFromOptions(options)
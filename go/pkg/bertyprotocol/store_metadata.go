@@ -71,13 +71,13 @@ func (m *metadataStore) setLogger(l *zap.Logger) {
 	}
 }
 
-func openMetadataEntry(log ipfslog.Log, e ipfslog.Entry, g *protocoltypes.Group, devKS DeviceKeystore) (*protocoltypes.GroupMetadataEvent, proto.Message, error) {
+func openMetadataEntry(log ipfslog.Log, e ipfslog.Entry, g *protocoltypes.Group, devKS DeviceKeystore, r adminResolver) (*protocoltypes.GroupMetadataEvent, proto.Message, error) {
 	op, err := operation.ParseOperation(e)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	meta, event, attachmentsCIDs, err := openGroupEnvelope(g, op.GetValue(), devKS)
+	meta, event, attachmentsCIDs, err := openGroupEnvelope(g, op.GetValue(), devKS, r)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -104,7 +104,12 @@ func (m *metadataStore) ListEvents(ctx context.Context, since, until []byte, rev
 			entries,
 			reverse,
 			func(entry ipliface.IPFSLogEntry) {
-				event, _, err := openMetadataEntry(m.OpLog(), entry, m.g, m.devKS)
+				// the entries of the log have already been handled by the index, which kept out the events of non admins
+				if m.Index().(*metadataStoreIndex).isEventRejected(entry.GetHash()) {
+					return
+				}
+
+				event, _, err := openMetadataEntry(m.OpLog(), entry, m.g, m.devKS, nil)
 				if err != nil {
 					m.logger.Error("unable to open metadata event", zap.Error(err))
 				} else {
@@ -157,6 +162,15 @@ func metadataStoreAddDeviceToGroup(ctx context.Context, m *metadataStore, g *pro
 		MemberSig: memberSig,
 	}
 
+	// once the member key has been rotated, the device must also be signed using the rotated key
+	if signingSK, err := m.getMemberSigningPrivKey(md); err != nil {
+		return nil, err
+	} else if !signingSK.Equals(md.member) {
+		if event.RotatedMemberSig, err = signingSK.Sign(device); err != nil {
+			return nil, errcode.ErrCryptoSignature.Wrap(err)
+		}
+	}
+
 	sig, err := signProto(event, md.device)
 	if err != nil {
 		return nil, errcode.ErrCryptoSignature.Wrap(err)
@@ -182,6 +196,10 @@ func (m *metadataStore) SendSecret(ctx context.Context, memberPK crypto.PubKey)
 		return nil, errcode.ErrGroupSecretAlreadySentToMember
 	}
 
+	if m.IsMemberRemoved(memberPK) {
+		return nil, errcode.ErrGroupMemberRemoved
+	}
+
 	if devs, err := m.GetDevicesForMember(memberPK); len(devs) == 0 || err != nil {
 		m.logger.Warn("sending secret to an unknown group member")
 	}
@@ -195,7 +213,10 @@ func (m *metadataStore) SendSecret(ctx context.Context, memberPK crypto.PubKey)
 }
 
 func metadataStoreSendSecret(ctx context.Context, m *metadataStore, g *protocoltypes.Group, md *ownMemberDevice, memberPK crypto.PubKey, ds *protocoltypes.DeviceSecret) (operation.Operation, error) {
-	payload, err := newSecretEntryPayload(md.device, memberPK, ds, g)
+	// the secret is sent to the rotated key of the member, if any, as the previous one is held by a revoked device
+	memberPK = m.Index().(*metadataStoreIndex).getMemberSigningKey(memberPK)
+
+	payload, nonce, err := newSecretEntryPayload(md.device, memberPK, ds)
 	if err != nil {
 		return nil, errcode.ErrInternal.Wrap(err)
 	}
@@ -214,6 +235,7 @@ func metadataStoreSendSecret(ctx context.Context, m *metadataStore, g *protocolt
 		DevicePK:     devicePKRaw,
 		DestMemberPK: memberPKRaw,
 		Payload:      payload,
+		Nonce:        nonce,
 	}
 
 	sig, err := signProto(event, md.device)
@@ -749,6 +771,403 @@ func (m *metadataStore) SendAliasProof(ctx context.Context) (operation.Operation
 	}, protocoltypes.EventTypeMultiMemberGroupAliasResolverAdded, nil)
 }
 
+// GrantAdminRole grants the admin role to a member of the group, it can only be done by the devices of an admin
+func (m *metadataStore) GrantAdminRole(ctx context.Context, memberPK crypto.PubKey) (operation.Operation, error) {
+	if !m.typeChecker(isMultiMemberGroup) {
+		return nil, errcode.ErrGroupInvalidType
+	}
+
+	if err := m.checkOwnAdminRole(); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.GetDevicesForMember(memberPK); err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown group member: %w", err))
+	}
+
+	if m.IsAdmin(memberPK) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("member is already an admin"))
+	}
+
+	memberPKBytes, err := memberPK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.MultiMemberGrantAdminRole{
+		GranteeMemberPK: memberPKBytes,
+	}, protocoltypes.EventTypeMultiMemberGroupAdminRoleGranted, nil)
+}
+
+// RevokeAdminRole revokes the admin role of a member of the group, it can only be done by the devices of an admin
+func (m *metadataStore) RevokeAdminRole(ctx context.Context, memberPK crypto.PubKey) (operation.Operation, error) {
+	if !m.typeChecker(isMultiMemberGroup) {
+		return nil, errcode.ErrGroupInvalidType
+	}
+
+	if err := m.checkOwnAdminRole(); err != nil {
+		return nil, err
+	}
+
+	if !m.IsAdmin(memberPK) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("member is not an admin"))
+	}
+
+	memberPKBytes, err := memberPK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.MultiMemberRevokeAdminRole{
+		RevokedMemberPK: memberPKBytes,
+	}, protocoltypes.EventTypeMultiMemberGroupAdminRoleRevoked, nil)
+}
+
+// RemoveMember removes a member from the group, it can only be done by the devices of an admin, the remaining members will then
+// rotate their secrets, the messages of the devices of the member are accepted up to the given cutoffs
+func (m *metadataStore) RemoveMember(ctx context.Context, memberPK crypto.PubKey, cutoffs []*protocoltypes.RevokedDeviceCutoff) (operation.Operation, error) {
+	if !m.typeChecker(isMultiMemberGroup) {
+		return nil, errcode.ErrGroupInvalidType
+	}
+
+	if err := m.checkOwnAdminRole(); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.GetDevicesForMember(memberPK); err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown group member: %w", err))
+	}
+
+	if m.IsMemberRemoved(memberPK) {
+		return nil, errcode.ErrGroupMemberRemoved
+	}
+
+	if initialMember := m.Index().(*metadataStoreIndex).getInitialMember(); initialMember != nil && initialMember.Equals(memberPK) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("the group creator can't be removed"))
+	}
+
+	memberPKBytes, err := memberPK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.MultiMemberRemoveMember{
+		RemovedMemberPK: memberPKBytes,
+		DeviceCutoffs:   cutoffs,
+	}, protocoltypes.EventTypeMultiMemberGroupMemberRemoved, nil)
+}
+
+// IsMemberRemoved returns true if the member has been removed from the group by an admin
+func (m *metadataStore) IsMemberRemoved(memberPK crypto.PubKey) bool {
+	if !m.typeChecker(isMultiMemberGroup) {
+		return false
+	}
+
+	return m.Index().(*metadataStoreIndex).isMemberRemoved(memberPK)
+}
+
+func (m *metadataStore) ListRemovedMembers() []crypto.PubKey {
+	if !m.typeChecker(isMultiMemberGroup) {
+		return nil
+	}
+
+	return m.Index().(*metadataStoreIndex).listRemovedMembers()
+}
+
+// AddAdditionalRendezvousSeed adds a rendezvous seed to the group, the members announce themselves on the rendezvous points derived from
+// it every rotationInterval seconds, it can only be done by the devices of an admin
+func (m *metadataStore) AddAdditionalRendezvousSeed(ctx context.Context, seed []byte, rotationInterval int64) (operation.Operation, error) {
+	if err := m.checkOwnAdminRole(); err != nil {
+		return nil, err
+	}
+
+	if len(seed) == 0 {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("missing rendezvous seed"))
+	}
+
+	if err := checkRendezvousSeedRotationInterval(rotationInterval); err != nil {
+		return nil, err
+	}
+
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.GroupAddAdditionalRendezvousSeed{
+		Seed:             seed,
+		RotationInterval: rotationInterval,
+	}, protocoltypes.EventTypeGroupAdditionalRendezvousSeedAdded, nil)
+}
+
+// RemoveAdditionalRendezvousSeed removes a rendezvous seed from the group, it can only be done by the devices of an admin
+func (m *metadataStore) RemoveAdditionalRendezvousSeed(ctx context.Context, seed []byte) (operation.Operation, error) {
+	if err := m.checkOwnAdminRole(); err != nil {
+		return nil, err
+	}
+
+	if !m.Index().(*metadataStoreIndex).hasRendezvousSeed(seed) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown rendezvous seed"))
+	}
+
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.GroupRemoveAdditionalRendezvousSeed{
+		Seed: seed,
+	}, protocoltypes.EventTypeGroupAdditionalRendezvousSeedRemoved, nil)
+}
+
+func (m *metadataStore) ListAdditionalRendezvousSeeds() []*protocoltypes.GroupAddAdditionalRendezvousSeed {
+	return m.Index().(*metadataStoreIndex).listRendezvousSeeds()
+}
+
+// AddAccountDevice authorizes a new device to act on behalf of the account, the device is signed using the account key
+func (m *metadataStore) AddAccountDevice(ctx context.Context, devicePK crypto.PubKey) (operation.Operation, error) {
+	if !m.typeChecker(isAccountGroup) {
+		return nil, errcode.ErrGroupInvalidType
+	}
+
+	md, err := m.devKS.MemberDeviceForGroup(m.g)
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	if md.device.GetPublic().Equals(devicePK) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("the current device is already part of the account"))
+	}
+
+	if m.IsDeviceRevoked(devicePK) {
+		return nil, errcode.ErrGroupDeviceRevoked
+	}
+
+	devicePKBytes, err := devicePK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	accountSK, err := m.getMemberSigningPrivKey(md)
+	if err != nil {
+		return nil, err
+	}
+
+	accountSig, err := accountSK.Sign(devicePKBytes)
+	if err != nil {
+		return nil, errcode.ErrCryptoSignature.Wrap(err)
+	}
+
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.AccountDeviceAdded{
+		AddedDevicePK: devicePKBytes,
+		AccountSig:    accountSig,
+	}, protocoltypes.EventTypeAccountDeviceAdded, nil)
+}
+
+// IsDeviceLinked returns true if the device has been linked to the account by another device
+func (m *metadataStore) IsDeviceLinked(devicePK crypto.PubKey) bool {
+	if !m.typeChecker(isAccountGroup) {
+		return false
+	}
+
+	return m.Index().(*metadataStoreIndex).isDeviceLinked(devicePK)
+}
+
+// RevokeDevice revokes another device of the account in the group, the revocation is signed using the member key of the account,
+// the messages of the device are accepted up to the given cutoff
+func (m *metadataStore) RevokeDevice(ctx context.Context, devicePK crypto.PubKey, cutoff *protocoltypes.RevokedDeviceCutoff) (operation.Operation, error) {
+	md, err := m.devKS.MemberDeviceForGroup(m.g)
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	if md.device.GetPublic().Equals(devicePK) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("the current device can't revoke itself"))
+	}
+
+	// the devices of the account are known in the account group, in the other groups the device might not have joined yet
+	memberPK, err := m.Index().(*metadataStoreIndex).getMemberByDevice(devicePK)
+	if m.typeChecker(isAccountGroup) && (err != nil || !memberPK.Equals(md.member.GetPublic())) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown device of the account"))
+	} else if err == nil && !memberPK.Equals(md.member.GetPublic()) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("device of another member"))
+	}
+
+	if m.IsDeviceRevoked(devicePK) {
+		return nil, errcode.ErrGroupDeviceRevoked
+	}
+
+	devicePKBytes, err := devicePK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	accountSK, err := m.getMemberSigningPrivKey(md)
+	if err != nil {
+		return nil, err
+	}
+
+	accountSig, err := accountSK.Sign(devicePKBytes)
+	if err != nil {
+		return nil, errcode.ErrCryptoSignature.Wrap(err)
+	}
+
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.AccountDeviceRevoked{
+		RevokedDevicePK: devicePKBytes,
+		AccountSig:      accountSig,
+		Cutoff:          cutoff,
+	}, protocoltypes.EventTypeAccountDeviceRevoked, nil)
+}
+
+// IsDeviceRevoked returns true if the device has been revoked by its member in the group
+func (m *metadataStore) IsDeviceRevoked(devicePK crypto.PubKey) bool {
+	return m.Index().(*metadataStoreIndex).isDeviceRevoked(devicePK)
+}
+
+// GetRevokedDeviceMember returns the member which revoked the device in the group
+func (m *metadataStore) GetRevokedDeviceMember(devicePK crypto.PubKey) (crypto.PubKey, error) {
+	return m.Index().(*metadataStoreIndex).getRevokedDeviceMember(devicePK)
+}
+
+func (m *metadataStore) ListRevokedDevices() []crypto.PubKey {
+	return m.Index().(*metadataStoreIndex).listRevokedDevices()
+}
+
+// GetDeviceRevoker returns the device which published the revocation of a device
+func (m *metadataStore) GetDeviceRevoker(devicePK crypto.PubKey) (crypto.PubKey, error) {
+	return m.Index().(*metadataStoreIndex).getDeviceRevoker(devicePK)
+}
+
+// IsMemberKeyRotatedFor returns true if the key of the member of a revoked device has been rotated after its revocation
+func (m *metadataStore) IsMemberKeyRotatedFor(revokedDevicePK crypto.PubKey) bool {
+	return m.Index().(*metadataStoreIndex).isMemberKeyRotatedFor(revokedDevicePK)
+}
+
+// GetMemberSigningKey returns the key of a member used to sign its devices and to receive secrets, it is replaced once one of its
+// devices has been revoked
+func (m *metadataStore) GetMemberSigningKey(memberPK crypto.PubKey) crypto.PubKey {
+	return m.Index().(*metadataStoreIndex).getMemberSigningKey(memberPK)
+}
+
+// getMemberSigningPrivKey returns the key of the current member used to sign its devices, the rotated one must have been received
+// from the device which rotated it
+func (m *metadataStore) getMemberSigningPrivKey(md *ownMemberDevice) (crypto.PrivKey, error) {
+	pk := m.GetMemberSigningKey(md.member.GetPublic())
+	if pk.Equals(md.member.GetPublic()) {
+		return md.member, nil
+	}
+
+	return m.devKS.RotatedMemberPrivKey(pk)
+}
+
+// RotateMemberKey replaces the key of the current member after the revocation of one of its devices, as the revoked device still
+// holds the previous one, the new key is sent to the remaining devices of the member
+func (m *metadataStore) RotateMemberKey(ctx context.Context, revokedDevicePK crypto.PubKey) (operation.Operation, error) {
+	md, err := m.devKS.MemberDeviceForGroup(m.g)
+	if err != nil {
+		return nil, errcode.ErrInternal.Wrap(err)
+	}
+
+	if revokedMemberPK, err := m.GetRevokedDeviceMember(revokedDevicePK); err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	} else if !revokedMemberPK.Equals(md.member.GetPublic()) {
+		return nil, errcode.ErrInvalidInput.Wrap(fmt.Errorf("device of another member"))
+	}
+
+	if m.IsMemberKeyRotatedFor(revokedDevicePK) {
+		return nil, nil
+	}
+
+	devices, err := m.GetDevicesForMember(md.member.GetPublic())
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	rotatedSK, rotatedPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	if err != nil {
+		return nil, errcode.ErrCryptoKeyGeneration.Wrap(err)
+	}
+
+	if err := m.devKS.RotatedMemberPrivKeyPut(rotatedSK); err != nil {
+		return nil, err
+	}
+
+	envelopes := []*protocoltypes.RotatedMemberKeyEnvelope(nil)
+	for _, devicePK := range devices {
+		if devicePK.Equals(md.device.GetPublic()) || m.IsDeviceRevoked(devicePK) {
+			continue
+		}
+
+		envelope, err := newRotatedMemberKeyEnvelope(md.device, devicePK, rotatedSK)
+		if err != nil {
+			return nil, err
+		}
+
+		envelopes = append(envelopes, envelope)
+	}
+
+	deviceRaw, err := md.device.GetPublic().Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	memberRaw, err := md.member.GetPublic().Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	revokedRaw, err := revokedDevicePK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	rotatedRaw, err := rotatedPK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	rotatedSig, err := rotatedSK.Sign(deviceRaw)
+	if err != nil {
+		return nil, errcode.ErrCryptoSignature.Wrap(err)
+	}
+
+	event := &protocoltypes.GroupMemberKeyRotated{
+		DevicePK:         deviceRaw,
+		MemberPK:         memberRaw,
+		RevokedDevicePK:  revokedRaw,
+		RotatedMemberPK:  rotatedRaw,
+		RotatedMemberSig: rotatedSig,
+		Envelopes:        envelopes,
+	}
+
+	sig, err := signProto(event, md.device)
+	if err != nil {
+		return nil, errcode.ErrCryptoSignature.Wrap(err)
+	}
+
+	return metadataStoreAddEvent(ctx, m, m.g, protocoltypes.EventTypeGroupMemberKeyRotated, event, sig, nil)
+}
+
+// GetRevocationCutoff returns the counter of the last message of a removed or revoked device which can be opened, none of its
+// messages can be opened without a cutoff
+func (m *metadataStore) GetRevocationCutoff(devicePK crypto.PubKey) *protocoltypes.RevokedDeviceCutoff {
+	return m.Index().(*metadataStoreIndex).getRevocationCutoff(devicePK)
+}
+
+// IsAdmin returns true if the member is allowed to act as an admin of the group
+func (m *metadataStore) IsAdmin(memberPK crypto.PubKey) bool {
+	for _, admin := range m.ListAdmins() {
+		if admin.Equals(memberPK) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *metadataStore) checkOwnAdminRole() error {
+	md, err := m.devKS.MemberDeviceForGroup(m.g)
+	if err != nil {
+		return errcode.ErrInternal.Wrap(err)
+	}
+
+	if !m.IsAdmin(md.member.GetPublic()) {
+		return errcode.ErrGroupMemberNotAdmin
+	}
+
+	return nil
+}
+
 func (m *metadataStore) SendAppMetadata(ctx context.Context, message []byte, attachmentsCIDs [][]byte) (operation.Operation, error) {
 	return m.attributeSignAndAddEvent(ctx, &protocoltypes.AppMetadata{
 		Message: message,
@@ -800,6 +1219,12 @@ func (m *metadataStore) SendGroupReplicating(ctx context.Context, t *protocoltyp
 	}, protocoltypes.EventTypeGroupReplicating, nil)
 }
 
+func (m *metadataStore) SendGroupReplicatingRemoved(ctx context.Context, endpoint string) (operation.Operation, error) {
+	return m.attributeSignAndAddEvent(ctx, &protocoltypes.GroupReplicatingRemoved{
+		ReplicationServer: endpoint,
+	}, protocoltypes.EventTypeGroupReplicatingRemoved, nil)
+}
+
 type accountSignableEvent interface {
 	proto.Message
 	proto.Marshaler
@@ -952,38 +1377,45 @@ func constructorFactoryGroupMetadata(s *BertyOrbitDB) iface.StoreConstructor {
 		chSub := store.Subscribe(ctx)
 		go func() {
 			for e := range chSub {
-				var entry ipfslog.Entry
+				var entries []ipfslog.Entry
 
+				// replicated entries are emitted once they have been handled by the index, so the admins are resolved
+				// using its updated state
 				switch evt := e.(type) {
 				case *stores.EventWrite:
-					entry = evt.Entry
+					entries = []ipfslog.Entry{evt.Entry}
 
-				case *stores.EventReplicateProgress:
-					entry = evt.Entry
+				case *stores.EventReplicated:
+					entries = evt.Entries
 
 				default:
 					continue
 				}
 
-				if entry == nil {
-					continue
-				}
-
 				store.logger.Debug("received store event", zap.Any("raw event", e))
 
-				metaEvent, event, err := openMetadataEntry(store.OpLog(), entry, g, store.devKS)
-				if err != nil {
-					store.logger.Error("unable to open metadata payload", zap.Error(err))
-					continue
-				}
+				// the events of members which have been granted the admin role since are emitted too
+				entries = append(entries, store.Index().(*metadataStoreIndex).takeAcceptedEvents()...)
+
+				for _, entry := range entries {
+					if entry == nil || store.Index().(*metadataStoreIndex).isEventRejected(entry.GetHash()) {
+						continue
+					}
+
+					metaEvent, event, err := openMetadataEntry(store.OpLog(), entry, g, store.devKS, &lockedAdminResolver{index: store.Index().(*metadataStoreIndex)})
+					if err != nil {
+						store.logger.Error("unable to open metadata payload", zap.Error(err))
+						continue
+					}
 
-				store.logger.Debug("received payload", zap.String("payload", metaEvent.Metadata.EventType.String()))
+					store.logger.Debug("received payload", zap.String("payload", metaEvent.Metadata.EventType.String()))
 
-				store.Emit(ctx, &EventMetadataReceived{
-					MetaEvent: metaEvent,
-					Event:     event,
-				})
-				store.Emit(ctx, metaEvent)
+					store.Emit(ctx, &EventMetadataReceived{
+						MetaEvent: metaEvent,
+						Event:     event,
+					})
+					store.Emit(ctx, metaEvent)
+				}
 			}
 		}()
 
@@ -1000,19 +1432,77 @@ func constructorFactoryGroupMetadata(s *BertyOrbitDB) iface.StoreConstructor {
 	}
 }
 
-func newSecretEntryPayload(localDevicePrivKey crypto.PrivKey, remoteMemberPubKey crypto.PubKey, secret *protocoltypes.DeviceSecret, group *protocoltypes.Group) ([]byte, error) {
+// newSecretEntryPayload encrypts a device secret for a member, a new nonce is used for each payload as the secrets of a device are
+// sent again to the same members once rotated
+func newSecretEntryPayload(localDevicePrivKey crypto.PrivKey, remoteMemberPubKey crypto.PubKey, secret *protocoltypes.DeviceSecret) ([]byte, []byte, error) {
 	message, err := secret.Marshal()
 	if err != nil {
-		return nil, errcode.ErrSerialization.Wrap(err)
+		return nil, nil, errcode.ErrSerialization.Wrap(err)
 	}
 
 	mongPriv, mongPub, err := cryptoutil.EdwardsToMontgomery(localDevicePrivKey, remoteMemberPubKey)
 	if err != nil {
-		return nil, errcode.ErrCryptoKeyConversion.Wrap(err)
+		return nil, nil, errcode.ErrCryptoKeyConversion.Wrap(err)
+	}
+
+	nonce, err := cryptoutil.GenerateNonce()
+	if err != nil {
+		return nil, nil, errcode.ErrCryptoNonceGeneration.Wrap(err)
 	}
 
-	nonce := groupIDToNonce(group)
 	encryptedSecret := box.Seal(nil, message, nonce, mongPub, mongPriv)
 
-	return encryptedSecret, nil
+	return encryptedSecret, nonce[:], nil
+}
+
+func newRotatedMemberKeyEnvelope(localDevicePrivKey crypto.PrivKey, remoteDevicePubKey crypto.PubKey, sk crypto.PrivKey) (*protocoltypes.RotatedMemberKeyEnvelope, error) {
+	message, err := crypto.MarshalPrivateKey(sk)
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	mongPriv, mongPub, err := cryptoutil.EdwardsToMontgomery(localDevicePrivKey, remoteDevicePubKey)
+	if err != nil {
+		return nil, errcode.ErrCryptoKeyConversion.Wrap(err)
+	}
+
+	nonce, err := cryptoutil.GenerateNonce()
+	if err != nil {
+		return nil, errcode.ErrCryptoNonceGeneration.Wrap(err)
+	}
+
+	deviceRaw, err := remoteDevicePubKey.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	return &protocoltypes.RotatedMemberKeyEnvelope{
+		DevicePK: deviceRaw,
+		Nonce:    nonce[:],
+		Payload:  box.Seal(nil, message, nonce, mongPub, mongPriv),
+	}, nil
+}
+
+func openRotatedMemberKeyEnvelope(envelope *protocoltypes.RotatedMemberKeyEnvelope, localDevicePrivKey crypto.PrivKey, remoteDevicePubKey crypto.PubKey) (crypto.PrivKey, error) {
+	mongPriv, mongPub, err := cryptoutil.EdwardsToMontgomery(localDevicePrivKey, remoteDevicePubKey)
+	if err != nil {
+		return nil, errcode.ErrCryptoKeyConversion.Wrap(err)
+	}
+
+	nonce, err := cryptoutil.NonceSliceToArray(envelope.Nonce)
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	message, ok := box.Open(nil, envelope.Payload, nonce, mongPub, mongPriv)
+	if !ok {
+		return nil, errcode.ErrCryptoDecrypt
+	}
+
+	sk, err := crypto.UnmarshalPrivateKey(message)
+	if err != nil {
+		return nil, errcode.ErrDeserialization.Wrap(err)
+	}
+
+	return sk, nil
 }
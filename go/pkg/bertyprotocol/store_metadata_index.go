@@ -1,11 +1,13 @@
 package bertyprotocol
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sync"
 
 	"github.com/gogo/protobuf/proto"
+	cid "github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"go.uber.org/zap"
 
@@ -21,8 +23,19 @@ type metadataStoreIndex struct {
 	members                  map[string][]*memberDevice
 	devices                  map[string]*memberDevice
 	handledEvents            map[string]struct{}
+	rejectedEvents           map[string]struct{}
+	acceptedEvents           []ipfslog.Entry
 	sentSecrets              map[string]struct{}
-	admins                   map[crypto.PubKey]struct{}
+	admins                   map[string]crypto.PubKey
+	initialMember            crypto.PubKey
+	removedMembers           map[string]crypto.PubKey
+	revokedDevices           map[string]*memberDevice
+	revocationCutoffs        map[string]*protocoltypes.RevokedDeviceCutoff
+	deviceRevokers           map[string]crypto.PubKey
+	rotatedMemberKeys        map[string]crypto.PubKey
+	rotatedRevocations       map[string]struct{}
+	linkedDevices            map[string]crypto.PubKey
+	rendezvousSeeds          map[string]*protocoltypes.GroupAddAdditionalRendezvousSeed
 	contacts                 map[string]*accountContact
 	contactsFromGroupPK      map[string]*accountContact
 	groups                   map[string]*accountGroup
@@ -81,12 +94,23 @@ func (m *metadataStoreIndex) UpdateIndex(log ipfslog.Log, _ []ipfslog.Entry) err
 			continue
 		}
 
-		metaEvent, event, err := openMetadataEntry(log, e, m.g, m.deviceKeystore)
-		if err != nil {
+		// admin events of non admins aren't marked as handled, they are evaluated again in the order of the log on the next
+		// updates as the grant of their sender may be replicated later
+		metaEvent, event, err := openMetadataEntry(log, e, m.g, m.deviceKeystore, m)
+		if errcode.Has(err, errcode.ErrGroupMemberNotAdmin) {
+			m.rejectedEvents[e.GetHash().String()] = struct{}{}
+			m.logger.Warn("ignoring admin event sent by a non admin member", zap.Error(err))
+			continue
+		} else if err != nil {
 			m.logger.Error("unable to open metadata entry", zap.Error(err))
 			continue
 		}
 
+		if _, ok := m.rejectedEvents[e.GetHash().String()]; ok {
+			delete(m.rejectedEvents, e.GetHash().String())
+			m.acceptedEvents = append(m.acceptedEvents, e)
+		}
+
 		handlers, ok := m.eventHandlers[metaEvent.Metadata.EventType]
 		if !ok {
 			m.handledEvents[e.GetHash().String()] = struct{}{}
@@ -121,6 +145,28 @@ func (m *metadataStoreIndex) UpdateIndex(log ipfslog.Log, _ []ipfslog.Entry) err
 	return nil
 }
 
+// isEventRejected returns true if the index currently refuses an event because of its sender, ie. an admin event sent by a
+// non admin member
+func (m *metadataStoreIndex) isEventRejected(hash cid.Cid) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	_, ok := m.rejectedEvents[hash.String()]
+
+	return ok
+}
+
+// takeAcceptedEvents returns the previously rejected events which have since been accepted, they are only returned once
+func (m *metadataStoreIndex) takeAcceptedEvents() []ipfslog.Entry {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	accepted := m.acceptedEvents
+	m.acceptedEvents = nil
+
+	return accepted
+}
+
 func (m *metadataStoreIndex) handleGroupAddMemberDevice(event proto.Message) error {
 	e, ok := event.(*protocoltypes.GroupAddMemberDevice)
 	if !ok {
@@ -141,6 +187,22 @@ func (m *metadataStoreIndex) handleGroupAddMemberDevice(event proto.Message) err
 		return nil
 	}
 
+	if _, ok := m.removedMembers[string(e.MemberPK)]; ok {
+		return errcode.ErrGroupMemberRemoved
+	}
+
+	// the device might have been revoked by its member before joining the group
+	if revoked, ok := m.revokedDevices[string(e.DevicePK)]; ok && revoked.member.Equals(member) {
+		return errcode.ErrGroupDeviceRevoked
+	}
+
+	// once the member key has been rotated, the previous one held by the revoked devices can't add devices anymore
+	if rotatedPK, ok := m.rotatedMemberKeys[string(e.MemberPK)]; ok {
+		if ok, err := rotatedPK.Verify(e.DevicePK, e.RotatedMemberSig); err != nil || !ok {
+			return errcode.ErrCryptoSignatureVerification.Wrap(fmt.Errorf("device not signed by the rotated member key"))
+		}
+	}
+
 	m.devices[string(e.DevicePK)] = &memberDevice{
 		member: member,
 		device: device,
@@ -224,7 +286,14 @@ func (m *metadataStoreIndex) MemberCount() int {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	return len(m.members)
+	count := 0
+	for id := range m.members {
+		if _, ok := m.removedMembers[id]; !ok {
+			count++
+		}
+	}
+
+	return count
 }
 
 func (m *metadataStoreIndex) DeviceCount() int {
@@ -258,12 +327,14 @@ func (m *metadataStoreIndex) listMembers() []crypto.PubKey {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	members := make([]crypto.PubKey, len(m.members))
-	i := 0
+	members := []crypto.PubKey(nil)
 
-	for _, md := range m.members {
-		members[i] = md[0].member
-		i++
+	for id, md := range m.members {
+		if _, ok := m.removedMembers[id]; ok {
+			continue
+		}
+
+		members = append(members, md[0].member)
 	}
 
 	return members
@@ -293,6 +364,13 @@ func (m *metadataStoreIndex) areSecretsAlreadySent(pk crypto.PubKey) (bool, erro
 		return false, errcode.ErrInvalidInput.Wrap(err)
 	}
 
+	// the secrets are sent to the rotated key of the member, if any
+	if rotatedPK, ok := m.rotatedMemberKeys[string(key)]; ok {
+		if key, err = rotatedPK.Raw(); err != nil {
+			return false, errcode.ErrInvalidInput.Wrap(err)
+		}
+	}
+
 	_, ok := m.sentSecrets[string(key)]
 	return ok, nil
 }
@@ -673,21 +751,447 @@ func (m *metadataStoreIndex) handleMultiMemberInitialMember(event proto.Message)
 		return errcode.ErrDeserialization.Wrap(err)
 	}
 
-	if _, ok := m.admins[pk]; ok {
+	if _, ok := m.admins[string(e.MemberPK)]; ok {
 		return errcode.ErrInternal
 	}
 
-	m.admins[pk] = struct{}{}
+	m.admins[string(e.MemberPK)] = pk
+	m.initialMember = pk
 
 	return nil
 }
 
 func (m *metadataStoreIndex) handleMultiMemberGrantAdminRole(event proto.Message) error {
-	// TODO:
+	e, ok := event.(*protocoltypes.MultiMemberGrantAdminRole)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if _, err := checkAdminDevice(m, e); err != nil {
+		return err
+	}
+
+	pk, err := crypto.UnmarshalEd25519PublicKey(e.GranteeMemberPK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	m.admins[string(e.GranteeMemberPK)] = pk
+
+	return nil
+}
+
+func (m *metadataStoreIndex) handleMultiMemberRevokeAdminRole(event proto.Message) error {
+	e, ok := event.(*protocoltypes.MultiMemberRevokeAdminRole)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if _, err := checkAdminDevice(m, e); err != nil {
+		return err
+	}
+
+	pk, err := crypto.UnmarshalEd25519PublicKey(e.RevokedMemberPK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	// the group creator always keeps the admin role
+	if m.initialMember != nil && m.initialMember.Equals(pk) {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("the admin role of the group creator can't be revoked"))
+	}
+
+	delete(m.admins, string(e.RevokedMemberPK))
+
+	return nil
+}
+
+func (m *metadataStoreIndex) handleMultiMemberRemoveMember(event proto.Message) error {
+	e, ok := event.(*protocoltypes.MultiMemberRemoveMember)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if _, err := checkAdminDevice(m, e); err != nil {
+		return err
+	}
+
+	pk, err := crypto.UnmarshalEd25519PublicKey(e.RemovedMemberPK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	// the group creator can't be removed from the group
+	if m.initialMember != nil && m.initialMember.Equals(pk) {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("the group creator can't be removed"))
+	}
+
+	delete(m.admins, string(e.RemovedMemberPK))
+	m.removedMembers[string(e.RemovedMemberPK)] = pk
+
+	// the cutoffs are only kept for the devices of the removed member
+	for _, cutoff := range e.DeviceCutoffs {
+		devicePK, err := crypto.UnmarshalEd25519PublicKey(cutoff.GetDevicePK())
+		if err != nil {
+			continue
+		}
+
+		if memberPK, err := m.unsafeGetMemberByDevice(devicePK); err == nil && memberPK.Equals(pk) {
+			m.unsafeAddRevocationCutoff(cutoff)
+		}
+	}
+
+	return nil
+}
+
+// unsafeAddRevocationCutoff keeps the latest cutoff published for a device, m.lock must be held by the caller
+func (m *metadataStoreIndex) unsafeAddRevocationCutoff(cutoff *protocoltypes.RevokedDeviceCutoff) {
+	if cutoff == nil {
+		return
+	}
+
+	if current, ok := m.revocationCutoffs[string(cutoff.DevicePK)]; ok && !counterAfter(cutoff.Counter, current.Counter) {
+		return
+	}
+
+	m.revocationCutoffs[string(cutoff.DevicePK)] = cutoff
+}
+
+// getRevocationCutoff returns the counter of the last message of a removed or revoked device which can be opened
+func (m *metadataStoreIndex) getRevocationCutoff(devicePK crypto.PubKey) *protocoltypes.RevokedDeviceCutoff {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	raw, err := devicePK.Raw()
+	if err != nil {
+		return nil
+	}
+
+	return m.revocationCutoffs[string(raw)]
+}
+
+func (m *metadataStoreIndex) isMemberRemoved(memberPK crypto.PubKey) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	raw, err := memberPK.Raw()
+	if err != nil {
+		return false
+	}
+
+	_, ok := m.removedMembers[string(raw)]
+
+	return ok
+}
+
+func (m *metadataStoreIndex) getInitialMember() crypto.PubKey {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.initialMember
+}
+
+func (m *metadataStoreIndex) listRemovedMembers() []crypto.PubKey {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	members := make([]crypto.PubKey, len(m.removedMembers))
+	i := 0
+
+	for _, member := range m.removedMembers {
+		members[i] = member
+		i++
+	}
+
+	return members
+}
+
+func (m *metadataStoreIndex) handleAccountDeviceRevoked(event proto.Message) error {
+	e, ok := event.(*protocoltypes.AccountDeviceRevoked)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if _, ok := m.revokedDevices[string(e.RevokedDevicePK)]; ok {
+		return nil
+	}
+
+	// a revoked device can't revoke the other devices of its member
+	if _, ok := m.revokedDevices[string(e.DevicePK)]; ok {
+		return errcode.ErrGroupDeviceRevoked
+	}
+
+	senderPK, err := crypto.UnmarshalEd25519PublicKey(e.DevicePK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	revokedPK, err := crypto.UnmarshalEd25519PublicKey(e.RevokedDevicePK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	memberPK, err := m.unsafeGetMemberByDevice(senderPK)
+	if err != nil {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown sender device: %w", err))
+	}
+
+	// a device which hasn't joined the group yet can be revoked by the member, it won't be allowed to join it
+	if revokedMemberPK, err := m.unsafeGetMemberByDevice(revokedPK); err == nil && !revokedMemberPK.Equals(memberPK) {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("revoked device is not a device of the member"))
+	}
+
+	if ok, err := m.unsafeGetMemberSigningKey(memberPK).Verify(e.RevokedDevicePK, e.AccountSig); err != nil {
+		return errcode.ErrCryptoSignatureVerification.Wrap(err)
+	} else if !ok {
+		return errcode.ErrCryptoSignatureVerification
+	}
+
+	m.revokedDevices[string(e.RevokedDevicePK)] = &memberDevice{
+		member: memberPK,
+		device: revokedPK,
+	}
+	m.deviceRevokers[string(e.RevokedDevicePK)] = senderPK
+
+	// the cutoff must be the one of the revoked device
+	if e.Cutoff != nil && bytes.Equal(e.Cutoff.DevicePK, e.RevokedDevicePK) {
+		m.unsafeAddRevocationCutoff(e.Cutoff)
+	}
+
+	return nil
+}
+
+func (m *metadataStoreIndex) isDeviceRevoked(devicePK crypto.PubKey) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	raw, err := devicePK.Raw()
+	if err != nil {
+		return false
+	}
+
+	_, ok := m.revokedDevices[string(raw)]
+
+	return ok
+}
+
+func (m *metadataStoreIndex) getRevokedDeviceMember(devicePK crypto.PubKey) (crypto.PubKey, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	raw, err := devicePK.Raw()
+	if err != nil {
+		return nil, errcode.ErrSerialization.Wrap(err)
+	}
+
+	revoked, ok := m.revokedDevices[string(raw)]
+	if !ok {
+		return nil, errcode.ErrMissingInput
+	}
+
+	return revoked.member, nil
+}
+
+func (m *metadataStoreIndex) listRevokedDevices() []crypto.PubKey {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	devices := make([]crypto.PubKey, len(m.revokedDevices))
+	i := 0
+
+	for _, revoked := range m.revokedDevices {
+		devices[i] = revoked.device
+		i++
+	}
+
+	return devices
+}
+
+// getDeviceRevoker returns the device which published the revocation of a device
+func (m *metadataStoreIndex) getDeviceRevoker(devicePK crypto.PubKey) (crypto.PubKey, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	raw, err := devicePK.Raw()
+	if err != nil {
+		return nil, errcode.ErrInvalidInput.Wrap(err)
+	}
+
+	revoker, ok := m.deviceRevokers[string(raw)]
+	if !ok {
+		return nil, errcode.ErrMissingInput
+	}
+
+	return revoker, nil
+}
+
+func (m *metadataStoreIndex) handleGroupMemberKeyRotated(event proto.Message) error {
+	e, ok := event.(*protocoltypes.GroupMemberKeyRotated)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if _, ok := m.rotatedRevocations[string(e.RevokedDevicePK)]; ok {
+		return nil
+	}
+
+	// a revoked device can't rotate the key of its member
+	if _, ok := m.revokedDevices[string(e.DevicePK)]; ok {
+		return errcode.ErrGroupDeviceRevoked
+	}
+
+	senderPK, err := crypto.UnmarshalEd25519PublicKey(e.DevicePK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	rotatedPK, err := crypto.UnmarshalEd25519PublicKey(e.RotatedMemberPK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	memberPK, err := m.unsafeGetMemberByDevice(senderPK)
+	if err != nil {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown sender device: %w", err))
+	}
+
+	if memberRaw, err := memberPK.Raw(); err != nil {
+		return errcode.ErrSerialization.Wrap(err)
+	} else if !bytes.Equal(e.MemberPK, memberRaw) {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("sender device is not a device of the member"))
+	}
+
+	// the rotation must answer a revocation of a device of the same member
+	if revoked, ok := m.revokedDevices[string(e.RevokedDevicePK)]; !ok || !revoked.member.Equals(memberPK) {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("rotation without a revoked device of the member"))
+	}
+
+	if ok, err := rotatedPK.Verify(e.DevicePK, e.RotatedMemberSig); err != nil {
+		return errcode.ErrCryptoSignatureVerification.Wrap(err)
+	} else if !ok {
+		return errcode.ErrCryptoSignatureVerification
+	}
+
+	m.rotatedMemberKeys[string(e.MemberPK)] = rotatedPK
+	m.rotatedRevocations[string(e.RevokedDevicePK)] = struct{}{}
 
 	return nil
 }
 
+// unsafeGetMemberSigningKey returns the key of a member used to sign its devices and to receive secrets, it is its own key until a
+// device has been revoked, m.lock must be held by the caller
+func (m *metadataStoreIndex) unsafeGetMemberSigningKey(memberPK crypto.PubKey) crypto.PubKey {
+	raw, err := memberPK.Raw()
+	if err != nil {
+		return memberPK
+	}
+
+	if rotatedPK, ok := m.rotatedMemberKeys[string(raw)]; ok {
+		return rotatedPK
+	}
+
+	return memberPK
+}
+
+func (m *metadataStoreIndex) getMemberSigningKey(memberPK crypto.PubKey) crypto.PubKey {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.unsafeGetMemberSigningKey(memberPK)
+}
+
+// isMemberKeyRotatedFor returns true if the key of the member of a revoked device has been rotated after its revocation
+func (m *metadataStoreIndex) isMemberKeyRotatedFor(revokedDevicePK crypto.PubKey) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	raw, err := revokedDevicePK.Raw()
+	if err != nil {
+		return false
+	}
+
+	_, ok := m.rotatedRevocations[string(raw)]
+
+	return ok
+}
+
+func (m *metadataStoreIndex) handleAccountDeviceAdded(event proto.Message) error {
+	e, ok := event.(*protocoltypes.AccountDeviceAdded)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if _, ok := m.linkedDevices[string(e.AddedDevicePK)]; ok {
+		return nil
+	}
+
+	// a revoked device can't link new devices to the account, nor be linked again
+	if _, ok := m.revokedDevices[string(e.DevicePK)]; ok {
+		return errcode.ErrGroupDeviceRevoked
+	}
+
+	if _, ok := m.revokedDevices[string(e.AddedDevicePK)]; ok {
+		return errcode.ErrGroupDeviceRevoked
+	}
+
+	senderPK, err := crypto.UnmarshalEd25519PublicKey(e.DevicePK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	addedPK, err := crypto.UnmarshalEd25519PublicKey(e.AddedDevicePK)
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	accountPK, err := m.unsafeGetMemberByDevice(senderPK)
+	if err != nil {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("unknown sender device: %w", err))
+	}
+
+	if ok, err := m.unsafeGetMemberSigningKey(accountPK).Verify(e.AddedDevicePK, e.AccountSig); err != nil {
+		return errcode.ErrCryptoSignatureVerification.Wrap(err)
+	} else if !ok {
+		return errcode.ErrCryptoSignatureVerification
+	}
+
+	m.linkedDevices[string(e.AddedDevicePK)] = addedPK
+
+	return nil
+}
+
+func (m *metadataStoreIndex) isDeviceLinked(devicePK crypto.PubKey) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	raw, err := devicePK.Raw()
+	if err != nil {
+		return false
+	}
+
+	_, ok := m.linkedDevices[string(raw)]
+
+	return ok
+}
+
+func (m *metadataStoreIndex) isAdmin(memberPK crypto.PubKey) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.unsafeIsAdmin(memberPK)
+}
+
+func (m *metadataStoreIndex) unsafeIsAdmin(memberPK crypto.PubKey) bool {
+	raw, err := memberPK.Raw()
+	if err != nil {
+		return false
+	}
+
+	_, ok := m.admins[string(raw)]
+
+	return ok
+}
+
 func (m *metadataStoreIndex) listAdmins() []crypto.PubKey {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -695,7 +1199,7 @@ func (m *metadataStoreIndex) listAdmins() []crypto.PubKey {
 	admins := make([]crypto.PubKey, len(m.admins))
 	i := 0
 
-	for admin := range m.admins {
+	for _, admin := range m.admins {
 		admins[i] = admin
 		i++
 	}
@@ -703,6 +1207,89 @@ func (m *metadataStoreIndex) listAdmins() []crypto.PubKey {
 	return admins
 }
 
+// checkRendezvousSeedSender ensures that the device which sent a rendezvous seed event belongs to an admin, every member is an admin of
+// the contact and account groups
+func (m *metadataStoreIndex) checkRendezvousSeedSender(event eventDeviceSigned) error {
+	if m.g.GroupType == protocoltypes.GroupTypeMultiMember {
+		_, err := checkAdminDevice(m, event)
+		return err
+	}
+
+	devPK, err := crypto.UnmarshalEd25519PublicKey(event.GetDevicePK())
+	if err != nil {
+		return errcode.ErrDeserialization.Wrap(err)
+	}
+
+	if _, err := m.unsafeGetMemberByDevice(devPK); err != nil {
+		return errcode.ErrGroupMemberNotAdmin.Wrap(err)
+	}
+
+	return nil
+}
+
+func (m *metadataStoreIndex) handleGroupAddAdditionalRendezvousSeed(event proto.Message) error {
+	e, ok := event.(*protocoltypes.GroupAddAdditionalRendezvousSeed)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if err := m.checkRendezvousSeedSender(e); err != nil {
+		return err
+	}
+
+	if len(e.Seed) == 0 {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("missing rendezvous seed"))
+	}
+
+	if err := checkRendezvousSeedRotationInterval(e.RotationInterval); err != nil {
+		return err
+	}
+
+	// adding a known seed updates its rotation interval
+	m.rendezvousSeeds[string(e.Seed)] = e
+
+	return nil
+}
+
+func (m *metadataStoreIndex) handleGroupRemoveAdditionalRendezvousSeed(event proto.Message) error {
+	e, ok := event.(*protocoltypes.GroupRemoveAdditionalRendezvousSeed)
+	if !ok {
+		return errcode.ErrInvalidInput
+	}
+
+	if err := m.checkRendezvousSeedSender(e); err != nil {
+		return err
+	}
+
+	delete(m.rendezvousSeeds, string(e.Seed))
+
+	return nil
+}
+
+func (m *metadataStoreIndex) hasRendezvousSeed(seed []byte) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	_, ok := m.rendezvousSeeds[string(seed)]
+
+	return ok
+}
+
+func (m *metadataStoreIndex) listRendezvousSeeds() []*protocoltypes.GroupAddAdditionalRendezvousSeed {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	seeds := make([]*protocoltypes.GroupAddAdditionalRendezvousSeed, len(m.rendezvousSeeds))
+	i := 0
+
+	for _, seed := range m.rendezvousSeeds {
+		seeds[i] = seed
+		i++
+	}
+
+	return seeds
+}
+
 func (m *metadataStoreIndex) contactRequestsEnabled() bool {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -769,9 +1356,18 @@ func newMetadataIndex(ctx context.Context, eventEmitter events.EmitterInterface,
 		m := &metadataStoreIndex{
 			members:                map[string][]*memberDevice{},
 			devices:                map[string]*memberDevice{},
-			admins:                 map[crypto.PubKey]struct{}{},
+			admins:                 map[string]crypto.PubKey{},
+			removedMembers:         map[string]crypto.PubKey{},
+			revokedDevices:         map[string]*memberDevice{},
+			revocationCutoffs:      map[string]*protocoltypes.RevokedDeviceCutoff{},
+			deviceRevokers:         map[string]crypto.PubKey{},
+			rotatedMemberKeys:      map[string]crypto.PubKey{},
+			rotatedRevocations:     map[string]struct{}{},
+			linkedDevices:          map[string]crypto.PubKey{},
+			rendezvousSeeds:        map[string]*protocoltypes.GroupAddAdditionalRendezvousSeed{},
 			sentSecrets:            map[string]struct{}{},
 			handledEvents:          map[string]struct{}{},
+			rejectedEvents:         map[string]struct{}{},
 			contacts:               map[string]*accountContact{},
 			contactsFromGroupPK:    map[string]*accountContact{},
 			groups:                 map[string]*accountGroup{},
@@ -796,13 +1392,20 @@ func newMetadataIndex(ctx context.Context, eventEmitter events.EmitterInterface,
 			protocoltypes.EventTypeAccountContactRequestOutgoingSent:      {m.handleContactRequestOutgoingSent},
 			protocoltypes.EventTypeAccountContactRequestReferenceReset:    {m.handleContactRequestReferenceReset},
 			protocoltypes.EventTypeAccountContactUnblocked:                {m.handleContactUnblocked},
+			protocoltypes.EventTypeAccountDeviceAdded:                     {m.handleAccountDeviceAdded},
+			protocoltypes.EventTypeAccountDeviceRevoked:                   {m.handleAccountDeviceRevoked},
 			protocoltypes.EventTypeAccountGroupJoined:                     {m.handleGroupJoined},
 			protocoltypes.EventTypeAccountGroupLeft:                       {m.handleGroupLeft},
 			protocoltypes.EventTypeContactAliasKeyAdded:                   {m.handleContactAliasKeyAdded},
 			protocoltypes.EventTypeGroupDeviceSecretAdded:                 {m.handleGroupAddDeviceSecret},
+			protocoltypes.EventTypeGroupAdditionalRendezvousSeedAdded:     {m.handleGroupAddAdditionalRendezvousSeed},
+			protocoltypes.EventTypeGroupAdditionalRendezvousSeedRemoved:   {m.handleGroupRemoveAdditionalRendezvousSeed},
 			protocoltypes.EventTypeGroupMemberDeviceAdded:                 {m.handleGroupAddMemberDevice},
+			protocoltypes.EventTypeGroupMemberKeyRotated:                  {m.handleGroupMemberKeyRotated},
 			protocoltypes.EventTypeMultiMemberGroupAdminRoleGranted:       {m.handleMultiMemberGrantAdminRole},
+			protocoltypes.EventTypeMultiMemberGroupAdminRoleRevoked:       {m.handleMultiMemberRevokeAdminRole},
 			protocoltypes.EventTypeMultiMemberGroupInitialMemberAnnounced: {m.handleMultiMemberInitialMember},
+			protocoltypes.EventTypeMultiMemberGroupMemberRemoved:          {m.handleMultiMemberRemoveMember},
 			protocoltypes.EventTypeAccountServiceTokenAdded:               {m.handleAccountServiceTokenAdded},
 			protocoltypes.EventTypeAccountServiceTokenRemoved:             {m.handleAccountServiceTokenRemoved},
 		}
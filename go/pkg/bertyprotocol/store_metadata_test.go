@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	datastore "github.com/ipfs/go-datastore"
 	ds_sync "github.com/ipfs/go-datastore/sync"
 	"github.com/libp2p/go-libp2p-core/crypto"
@@ -19,6 +20,7 @@ import (
 
 	"berty.tech/berty/v2/go/internal/ipfsutil"
 	"berty.tech/berty/v2/go/internal/testutil"
+	"berty.tech/berty/v2/go/pkg/errcode"
 	"berty.tech/berty/v2/go/pkg/protocoltypes"
 )
 
@@ -823,3 +825,439 @@ func TestMultiDevices_Basic(t *testing.T) {
 	groups = meta[pi[1][2]].ListMultiMemberGroups()
 	require.Len(t, groups, 1)
 }
+
+func TestMetadataStoreIndexAdminRoles(t *testing.T) {
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	idx, ok := newMetadataIndex(context.Background(), nil, g, nil, nil)(nil).(*metadataStoreIndex)
+	require.True(t, ok)
+
+	newMember := func() ([]byte, []byte) {
+		_, memberPK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		_, devicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		memberPKBytes, err := memberPK.Raw()
+		require.NoError(t, err)
+
+		devicePKBytes, err := devicePK.Raw()
+		require.NoError(t, err)
+
+		require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: memberPKBytes, DevicePK: devicePKBytes}))
+
+		return memberPKBytes, devicePKBytes
+	}
+
+	isAdmin := func(memberPK []byte) bool {
+		pk, err := crypto.UnmarshalEd25519PublicKey(memberPK)
+		require.NoError(t, err)
+
+		return idx.isAdmin(pk)
+	}
+
+	creatorPK, creatorDevicePK := newMember()
+	memberPK, memberDevicePK := newMember()
+	otherPK, otherDevicePK := newMember()
+
+	require.NoError(t, idx.handleMultiMemberInitialMember(&protocoltypes.MultiMemberInitialMember{MemberPK: creatorPK}))
+	require.True(t, isAdmin(creatorPK))
+	require.False(t, isAdmin(memberPK))
+
+	// a non admin can't grant the admin role
+	require.Error(t, idx.handleMultiMemberGrantAdminRole(&protocoltypes.MultiMemberGrantAdminRole{DevicePK: otherDevicePK, GranteeMemberPK: otherPK}))
+	require.False(t, isAdmin(otherPK))
+
+	require.NoError(t, idx.handleMultiMemberGrantAdminRole(&protocoltypes.MultiMemberGrantAdminRole{DevicePK: creatorDevicePK, GranteeMemberPK: memberPK}))
+	require.True(t, isAdmin(memberPK))
+	require.Len(t, idx.listAdmins(), 2)
+
+	// admins can't revoke the group creator
+	require.Error(t, idx.handleMultiMemberRevokeAdminRole(&protocoltypes.MultiMemberRevokeAdminRole{DevicePK: memberDevicePK, RevokedMemberPK: creatorPK}))
+	require.True(t, isAdmin(creatorPK))
+
+	// a non admin can't revoke the admin role
+	require.Error(t, idx.handleMultiMemberRevokeAdminRole(&protocoltypes.MultiMemberRevokeAdminRole{DevicePK: otherDevicePK, RevokedMemberPK: memberPK}))
+	require.True(t, isAdmin(memberPK))
+
+	require.NoError(t, idx.handleMultiMemberRevokeAdminRole(&protocoltypes.MultiMemberRevokeAdminRole{DevicePK: creatorDevicePK, RevokedMemberPK: memberPK}))
+	require.False(t, isAdmin(memberPK))
+	require.Len(t, idx.listAdmins(), 1)
+}
+
+func TestSigCheckerAdminSigned(t *testing.T) {
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	idx, ok := newMetadataIndex(context.Background(), nil, g, nil, nil)(nil).(*metadataStoreIndex)
+	require.True(t, ok)
+
+	newMember := func() ([]byte, crypto.PrivKey, []byte) {
+		_, memberPK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		deviceSK, devicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		memberPKBytes, err := memberPK.Raw()
+		require.NoError(t, err)
+
+		devicePKBytes, err := devicePK.Raw()
+		require.NoError(t, err)
+
+		require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: memberPKBytes, DevicePK: devicePKBytes}))
+
+		return memberPKBytes, deviceSK, devicePKBytes
+	}
+
+	grant := func(deviceSK crypto.PrivKey, devicePK []byte, granteePK []byte) (*protocoltypes.GroupMetadata, proto.Message) {
+		event := &protocoltypes.MultiMemberGrantAdminRole{DevicePK: devicePK, GranteeMemberPK: granteePK}
+
+		payload, err := event.Marshal()
+		require.NoError(t, err)
+
+		sig, err := deviceSK.Sign(payload)
+		require.NoError(t, err)
+
+		return &protocoltypes.GroupMetadata{EventType: protocoltypes.EventTypeMultiMemberGroupAdminRoleGranted, Payload: payload, Sig: sig}, event
+	}
+
+	creatorPK, creatorDeviceSK, creatorDevicePK := newMember()
+	otherPK, otherDeviceSK, otherDevicePK := newMember()
+
+	require.NoError(t, idx.handleMultiMemberInitialMember(&protocoltypes.MultiMemberInitialMember{MemberPK: creatorPK}))
+
+	resolver := &lockedAdminResolver{index: idx}
+
+	metadata, event := grant(creatorDeviceSK, creatorDevicePK, otherPK)
+	require.NoError(t, sigCheckerAdminSigned(g, metadata, event, resolver))
+
+	// a valid signature of a non admin device is not enough
+	metadata, event = grant(otherDeviceSK, otherDevicePK, otherPK)
+	require.NoError(t, sigCheckerAdminSigned(g, metadata, event, nil))
+	require.True(t, errcode.Is(sigCheckerAdminSigned(g, metadata, event, resolver), errcode.ErrGroupMemberNotAdmin))
+}
+
+func TestMetadataStoreIndexRendezvousSeeds(t *testing.T) {
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	idx, ok := newMetadataIndex(context.Background(), nil, g, nil, nil)(nil).(*metadataStoreIndex)
+	require.True(t, ok)
+
+	newMember := func() ([]byte, []byte) {
+		_, memberPK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		_, devicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		memberPKBytes, err := memberPK.Raw()
+		require.NoError(t, err)
+
+		devicePKBytes, err := devicePK.Raw()
+		require.NoError(t, err)
+
+		require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: memberPKBytes, DevicePK: devicePKBytes}))
+
+		return memberPKBytes, devicePKBytes
+	}
+
+	creatorPK, creatorDevicePK := newMember()
+	_, memberDevicePK := newMember()
+	seed := []byte("rendezvous seed")
+
+	require.NoError(t, idx.handleMultiMemberInitialMember(&protocoltypes.MultiMemberInitialMember{MemberPK: creatorPK}))
+
+	// a non admin can't add a seed
+	require.Error(t, idx.handleGroupAddAdditionalRendezvousSeed(&protocoltypes.GroupAddAdditionalRendezvousSeed{DevicePK: memberDevicePK, Seed: seed}))
+	require.Len(t, idx.listRendezvousSeeds(), 0)
+
+	// the rotation interval must be reasonable
+	require.Error(t, idx.handleGroupAddAdditionalRendezvousSeed(&protocoltypes.GroupAddAdditionalRendezvousSeed{DevicePK: creatorDevicePK, Seed: seed, RotationInterval: 1}))
+	require.Len(t, idx.listRendezvousSeeds(), 0)
+
+	require.NoError(t, idx.handleGroupAddAdditionalRendezvousSeed(&protocoltypes.GroupAddAdditionalRendezvousSeed{DevicePK: creatorDevicePK, Seed: seed}))
+	require.Len(t, idx.listRendezvousSeeds(), 1)
+
+	// adding the seed again updates its rotation interval
+	require.NoError(t, idx.handleGroupAddAdditionalRendezvousSeed(&protocoltypes.GroupAddAdditionalRendezvousSeed{DevicePK: creatorDevicePK, Seed: seed, RotationInterval: 3600}))
+	require.Len(t, idx.listRendezvousSeeds(), 1)
+	require.Equal(t, int64(3600), idx.listRendezvousSeeds()[0].RotationInterval)
+
+	// a non admin can't remove a seed
+	require.Error(t, idx.handleGroupRemoveAdditionalRendezvousSeed(&protocoltypes.GroupRemoveAdditionalRendezvousSeed{DevicePK: memberDevicePK, Seed: seed}))
+	require.Len(t, idx.listRendezvousSeeds(), 1)
+	require.True(t, idx.hasRendezvousSeed(seed))
+
+	require.NoError(t, idx.handleGroupRemoveAdditionalRendezvousSeed(&protocoltypes.GroupRemoveAdditionalRendezvousSeed{DevicePK: creatorDevicePK, Seed: seed}))
+	require.Len(t, idx.listRendezvousSeeds(), 0)
+	require.False(t, idx.hasRendezvousSeed(seed))
+}
+
+func TestMetadataStoreIndexRemoveMember(t *testing.T) {
+	g, _, err := NewGroupMultiMember()
+	require.NoError(t, err)
+
+	idx, ok := newMetadataIndex(context.Background(), nil, g, nil, nil)(nil).(*metadataStoreIndex)
+	require.True(t, ok)
+
+	newMember := func() ([]byte, []byte) {
+		_, memberPK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		_, devicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		memberPKBytes, err := memberPK.Raw()
+		require.NoError(t, err)
+
+		devicePKBytes, err := devicePK.Raw()
+		require.NoError(t, err)
+
+		require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: memberPKBytes, DevicePK: devicePKBytes}))
+
+		return memberPKBytes, devicePKBytes
+	}
+
+	isRemoved := func(memberPK []byte) bool {
+		pk, err := crypto.UnmarshalEd25519PublicKey(memberPK)
+		require.NoError(t, err)
+
+		return idx.isMemberRemoved(pk)
+	}
+
+	creatorPK, creatorDevicePK := newMember()
+	adminPK, adminDevicePK := newMember()
+	memberPK, memberDevicePK := newMember()
+
+	require.NoError(t, idx.handleMultiMemberInitialMember(&protocoltypes.MultiMemberInitialMember{MemberPK: creatorPK}))
+	require.NoError(t, idx.handleMultiMemberGrantAdminRole(&protocoltypes.MultiMemberGrantAdminRole{DevicePK: creatorDevicePK, GranteeMemberPK: adminPK}))
+	require.Len(t, idx.listMembers(), 3)
+
+	// a non admin can't remove a member
+	require.Error(t, idx.handleMultiMemberRemoveMember(&protocoltypes.MultiMemberRemoveMember{DevicePK: memberDevicePK, RemovedMemberPK: adminPK}))
+	require.False(t, isRemoved(adminPK))
+
+	// the group creator can't be removed
+	require.Error(t, idx.handleMultiMemberRemoveMember(&protocoltypes.MultiMemberRemoveMember{DevicePK: adminDevicePK, RemovedMemberPK: creatorPK}))
+	require.False(t, isRemoved(creatorPK))
+
+	require.NoError(t, idx.handleMultiMemberRemoveMember(&protocoltypes.MultiMemberRemoveMember{DevicePK: adminDevicePK, RemovedMemberPK: memberPK}))
+	require.True(t, isRemoved(memberPK))
+	require.Len(t, idx.listMembers(), 2)
+	require.Len(t, idx.listRemovedMembers(), 1)
+	require.Equal(t, 2, idx.MemberCount())
+
+	// removed members can't add new devices
+	_, newDevicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	newDevicePKBytes, err := newDevicePK.Raw()
+	require.NoError(t, err)
+
+	require.Error(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: memberPK, DevicePK: newDevicePKBytes}))
+
+	// removed admins lose their role
+	require.NoError(t, idx.handleMultiMemberRemoveMember(&protocoltypes.MultiMemberRemoveMember{DevicePK: creatorDevicePK, RemovedMemberPK: adminPK}))
+	require.True(t, isRemoved(adminPK))
+	require.Len(t, idx.listAdmins(), 1)
+}
+
+func TestMetadataStoreIndexRevokeDevice(t *testing.T) {
+	accountSK, accountPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	signingSK, _, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	otherSK, _, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	g, err := getGroupForAccount(accountSK, signingSK)
+	require.NoError(t, err)
+
+	idx, ok := newMetadataIndex(context.Background(), nil, g, nil, nil)(nil).(*metadataStoreIndex)
+	require.True(t, ok)
+
+	accountPKBytes, err := accountPK.Raw()
+	require.NoError(t, err)
+
+	newDevice := func() (crypto.PubKey, []byte) {
+		_, devicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		devicePKBytes, err := devicePK.Raw()
+		require.NoError(t, err)
+
+		require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: accountPKBytes, DevicePK: devicePKBytes}))
+
+		return devicePK, devicePKBytes
+	}
+
+	sign := func(sk crypto.PrivKey, devicePK []byte) []byte {
+		sig, err := sk.Sign(devicePK)
+		require.NoError(t, err)
+
+		return sig
+	}
+
+	_, device1 := newDevice()
+	device2PK, device2 := newDevice()
+	device3PK, device3 := newDevice()
+
+	// revocations must be signed by the account key
+	require.Error(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device1, RevokedDevicePK: device3, AccountSig: sign(otherSK, device3)}))
+	require.False(t, idx.isDeviceRevoked(device3PK))
+
+	require.NoError(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device1, RevokedDevicePK: device2, AccountSig: sign(accountSK, device2)}))
+	require.True(t, idx.isDeviceRevoked(device2PK))
+	require.Len(t, idx.listRevokedDevices(), 1)
+
+	// a revoked device can't revoke the other devices
+	require.Error(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device2, RevokedDevicePK: device3, AccountSig: sign(accountSK, device3)}))
+	require.False(t, idx.isDeviceRevoked(device3PK))
+
+	// the devices of another member can't be revoked
+	_, otherMemberPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	otherMember, err := otherMemberPK.Raw()
+	require.NoError(t, err)
+
+	_, otherDevicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	otherDevice, err := otherDevicePK.Raw()
+	require.NoError(t, err)
+
+	require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: otherMember, DevicePK: otherDevice}))
+	require.Error(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device1, RevokedDevicePK: otherDevice, AccountSig: sign(accountSK, otherDevice)}))
+	require.False(t, idx.isDeviceRevoked(otherDevicePK))
+
+	// devices which haven't joined the group yet can be revoked, they can't join it afterwards
+	_, pendingPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	pending, err := pendingPK.Raw()
+	require.NoError(t, err)
+
+	require.NoError(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device1, RevokedDevicePK: pending, AccountSig: sign(accountSK, pending)}))
+	require.True(t, idx.isDeviceRevoked(pendingPK))
+	require.Len(t, idx.listRevokedDevices(), 2)
+	require.Error(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: accountPKBytes, DevicePK: pending}))
+
+	revokedMemberPK, err := idx.getRevokedDeviceMember(pendingPK)
+	require.NoError(t, err)
+	require.True(t, revokedMemberPK.Equals(accountPK))
+
+	_, unknownPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	unknown, err := unknownPK.Raw()
+	require.NoError(t, err)
+
+	// linked devices must be signed by the account key
+	require.Error(t, idx.handleAccountDeviceAdded(&protocoltypes.AccountDeviceAdded{DevicePK: device1, AddedDevicePK: unknown, AccountSig: sign(otherSK, unknown)}))
+	require.False(t, idx.isDeviceLinked(unknownPK))
+
+	require.NoError(t, idx.handleAccountDeviceAdded(&protocoltypes.AccountDeviceAdded{DevicePK: device1, AddedDevicePK: unknown, AccountSig: sign(accountSK, unknown)}))
+	require.True(t, idx.isDeviceLinked(unknownPK))
+
+	// a revoked device can't link new devices, nor be linked again
+	_, linkedPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	linked, err := linkedPK.Raw()
+	require.NoError(t, err)
+
+	require.Error(t, idx.handleAccountDeviceAdded(&protocoltypes.AccountDeviceAdded{DevicePK: device2, AddedDevicePK: linked, AccountSig: sign(accountSK, linked)}))
+	require.False(t, idx.isDeviceLinked(linkedPK))
+
+	require.Error(t, idx.handleAccountDeviceAdded(&protocoltypes.AccountDeviceAdded{DevicePK: device1, AddedDevicePK: device2, AccountSig: sign(accountSK, device2)}))
+	require.False(t, idx.isDeviceLinked(device2PK))
+}
+
+func TestMetadataStoreIndexRotateMemberKey(t *testing.T) {
+	accountSK, accountPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	signingSK, _, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	rotatedSK, rotatedPK, err := crypto.GenerateEd25519Key(crand.Reader)
+	require.NoError(t, err)
+
+	g, err := getGroupForAccount(accountSK, signingSK)
+	require.NoError(t, err)
+
+	idx, ok := newMetadataIndex(context.Background(), nil, g, nil, nil)(nil).(*metadataStoreIndex)
+	require.True(t, ok)
+
+	accountPKBytes, err := accountPK.Raw()
+	require.NoError(t, err)
+
+	rotated, err := rotatedPK.Raw()
+	require.NoError(t, err)
+
+	newDevice := func() (crypto.PubKey, []byte) {
+		_, devicePK, err := crypto.GenerateEd25519Key(crand.Reader)
+		require.NoError(t, err)
+
+		devicePKBytes, err := devicePK.Raw()
+		require.NoError(t, err)
+
+		return devicePK, devicePKBytes
+	}
+
+	sign := func(sk crypto.PrivKey, devicePK []byte) []byte {
+		sig, err := sk.Sign(devicePK)
+		require.NoError(t, err)
+
+		return sig
+	}
+
+	device1PK, device1 := newDevice()
+	device2PK, device2 := newDevice()
+	device3PK, device3 := newDevice()
+
+	for _, device := range [][]byte{device1, device2, device3} {
+		require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: accountPKBytes, DevicePK: device}))
+	}
+
+	rotation := func(sender []byte, sk crypto.PrivKey) *protocoltypes.GroupMemberKeyRotated {
+		return &protocoltypes.GroupMemberKeyRotated{DevicePK: sender, MemberPK: accountPKBytes, RevokedDevicePK: device2, RotatedMemberPK: rotated, RotatedMemberSig: sign(sk, sender)}
+	}
+
+	// the key can't be rotated without a revoked device
+	require.Error(t, idx.handleGroupMemberKeyRotated(rotation(device1, rotatedSK)))
+
+	require.NoError(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device1, RevokedDevicePK: device2, AccountSig: sign(accountSK, device2)}))
+
+	revokerPK, err := idx.getDeviceRevoker(device2PK)
+	require.NoError(t, err)
+	require.True(t, revokerPK.Equals(device1PK))
+
+	// the revoked device can't rotate the key, and the rotation must be signed by the new key
+	require.Error(t, idx.handleGroupMemberKeyRotated(rotation(device2, rotatedSK)))
+	require.Error(t, idx.handleGroupMemberKeyRotated(rotation(device1, accountSK)))
+	require.False(t, idx.isMemberKeyRotatedFor(device2PK))
+	require.True(t, idx.getMemberSigningKey(accountPK).Equals(accountPK))
+
+	require.NoError(t, idx.handleGroupMemberKeyRotated(rotation(device1, rotatedSK)))
+	require.True(t, idx.isMemberKeyRotatedFor(device2PK))
+	require.True(t, idx.getMemberSigningKey(accountPK).Equals(rotatedPK))
+
+	// the previous key, still held by the revoked device, can't revoke devices nor add new ones anymore
+	require.Error(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device1, RevokedDevicePK: device3, AccountSig: sign(accountSK, device3)}))
+	require.False(t, idx.isDeviceRevoked(device3PK))
+
+	_, device4 := newDevice()
+	require.Error(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: accountPKBytes, DevicePK: device4, RotatedMemberSig: sign(accountSK, device4)}))
+	require.NoError(t, idx.handleGroupAddMemberDevice(&protocoltypes.GroupAddMemberDevice{MemberPK: accountPKBytes, DevicePK: device4, RotatedMemberSig: sign(rotatedSK, device4)}))
+
+	require.NoError(t, idx.handleAccountDeviceRevoked(&protocoltypes.AccountDeviceRevoked{DevicePK: device1, RevokedDevicePK: device3, AccountSig: sign(rotatedSK, device3)}))
+	require.True(t, idx.isDeviceRevoked(device3PK))
+}
@@ -183,7 +183,7 @@ func testHelperNewReplicationService(ctx context.Context, t *testing.T, logger *
 	})
 	require.NoError(t, err)
 
-	repl, err := NewReplicationService(ctx, ds, odb, logger)
+	repl, err := NewReplicationService(ctx, ds, odb, logger, ReplicationQuota{})
 	require.NoError(t, err)
 	require.NotNil(t, repl)
 
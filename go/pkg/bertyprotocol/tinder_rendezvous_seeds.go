@@ -0,0 +1,230 @@
+package bertyprotocol
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/discovery"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"go.uber.org/zap"
+
+	"berty.tech/berty/v2/go/internal/tinder"
+	"berty.tech/berty/v2/go/pkg/errcode"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+const (
+	// the rotation interval of the additional rendezvous seeds must be within those bounds
+	minRendezvousSeedRotationInterval = time.Minute
+	maxRendezvousSeedRotationInterval = 30 * 24 * time.Hour
+
+	// rendezvousSeedLookupInterval is the delay between two lookups of the devices announced on a rendezvous point
+	rendezvousSeedLookupInterval = 5 * time.Minute
+
+	// rendezvousSeedRetryDelay is the delay before advertising again after a failure
+	rendezvousSeedRetryDelay = time.Minute
+
+	// rendezvousSeedMinTTL is the shortest registration accepted by the rendezvous points
+	rendezvousSeedMinTTL = 2 * time.Minute
+)
+
+func checkRendezvousSeedRotationInterval(seconds int64) error {
+	if seconds == 0 {
+		return nil
+	}
+
+	if seconds < int64(minRendezvousSeedRotationInterval/time.Second) || seconds > int64(maxRendezvousSeedRotationInterval/time.Second) {
+		return errcode.ErrInvalidInput.Wrap(fmt.Errorf("rendezvous seed rotation interval must be between %s and %s", minRendezvousSeedRotationInterval, maxRendezvousSeedRotationInterval))
+	}
+
+	return nil
+}
+
+// rendezvousSeedsAnnouncer advertises the device on the rendezvous points derived from the additional seeds of the opened groups and
+// connects to the other devices found on them, each seed rotates its rendezvous point using its own interval
+type rendezvousSeedsAnnouncer struct {
+	logger          *zap.Logger
+	host            host.Host
+	driver          tinder.Driver
+	defaultInterval time.Duration
+}
+
+type runningRendezvousSeed struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newRendezvousSeedsAnnouncer(logger *zap.Logger, h host.Host, driver tinder.Driver, defaultInterval time.Duration) *rendezvousSeedsAnnouncer {
+	return &rendezvousSeedsAnnouncer{
+		logger:          logger.Named("rdv-seeds"),
+		host:            h,
+		driver:          driver,
+		defaultInterval: defaultInterval,
+	}
+}
+
+func (a *rendezvousSeedsAnnouncer) rotationInterval(seed *protocoltypes.GroupAddAdditionalRendezvousSeed) time.Duration {
+	if seed.RotationInterval == 0 {
+		return a.defaultInterval
+	}
+
+	return time.Duration(seed.RotationInterval) * time.Second
+}
+
+// watchGroup announces the group on the rendezvous points of its additional seeds, following their changes until the metadata store
+// is closed
+func (a *rendezvousSeedsAnnouncer) watchGroup(ctx context.Context, gc *groupContext) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sub := gc.MetadataStore().Subscribe(ctx)
+	running := map[string]*runningRendezvousSeed{}
+
+	a.updateGroupSeeds(ctx, gc.Group().PublicKey, running, gc.MetadataStore().ListAdditionalRendezvousSeeds())
+
+	for evt := range sub {
+		e, ok := evt.(*protocoltypes.GroupMetadataEvent)
+		if !ok {
+			continue
+		}
+
+		if e.Metadata.EventType != protocoltypes.EventTypeGroupAdditionalRendezvousSeedAdded &&
+			e.Metadata.EventType != protocoltypes.EventTypeGroupAdditionalRendezvousSeedRemoved {
+			continue
+		}
+
+		// the index only holds the seeds of the events which have been accepted
+		a.updateGroupSeeds(ctx, gc.Group().PublicKey, running, gc.MetadataStore().ListAdditionalRendezvousSeeds())
+	}
+}
+
+// updateGroupSeeds starts announcing the new seeds of a group, the removed seeds and the ones whose interval changed are stopped
+func (a *rendezvousSeedsAnnouncer) updateGroupSeeds(ctx context.Context, topic []byte, running map[string]*runningRendezvousSeed, seeds []*protocoltypes.GroupAddAdditionalRendezvousSeed) {
+	intervals := make(map[string]time.Duration, len(seeds))
+	for _, seed := range seeds {
+		intervals[string(seed.Seed)] = a.rotationInterval(seed)
+	}
+
+	stopped := map[string]chan struct{}{}
+	for seed, r := range running {
+		if interval, ok := intervals[seed]; !ok || interval != r.interval {
+			r.cancel()
+			stopped[seed] = r.done
+			delete(running, seed)
+		}
+	}
+
+	for seed, interval := range intervals {
+		if _, ok := running[seed]; ok {
+			continue
+		}
+
+		seedCtx, cancel := context.WithCancel(ctx)
+		r := &runningRendezvousSeed{interval: interval, cancel: cancel, done: make(chan struct{})}
+		running[seed] = r
+
+		go func(seed []byte, interval time.Duration, previous chan struct{}) {
+			defer close(r.done)
+
+			// both intervals may lead to the same rendezvous point, the previous registration must be removed first
+			if previous != nil {
+				<-previous
+			}
+
+			a.announce(seedCtx, topic, seed, interval)
+		}([]byte(seed), interval, stopped[seed])
+	}
+}
+
+// announce advertises the device on the rendezvous point of the current period until the context is done
+func (a *rendezvousSeedsAnnouncer) announce(ctx context.Context, topic, seed []byte, interval time.Duration) {
+	for {
+		roundedTime := roundTimePeriod(time.Now(), interval)
+		point := string(generateRendezvousPointForPeriod(topic, seed, roundedTime))
+		periodEnd := nextTimePeriod(roundedTime, interval)
+
+		periodCtx, cancel := context.WithDeadline(ctx, periodEnd)
+		a.announcePeriod(periodCtx, point, periodEnd)
+		cancel()
+
+		// the registration is removed right away rather than when it expires
+		unregisterCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		if err := a.driver.Unregister(unregisterCtx, point); err != nil {
+			a.logger.Debug("unable to unregister from rendezvous point", zap.Error(err))
+		}
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (a *rendezvousSeedsAnnouncer) announcePeriod(ctx context.Context, point string, periodEnd time.Time) {
+	nextAdvertise := time.Now()
+
+	for {
+		if !time.Now().Before(nextAdvertise) {
+			nextAdvertise = time.Now().Add(a.advertise(ctx, point, periodEnd))
+		}
+
+		a.lookup(ctx, point)
+
+		wait := time.Until(nextAdvertise)
+		if wait > rendezvousSeedLookupInterval {
+			wait = rendezvousSeedLookupInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// advertise registers the device on the rendezvous point until the end of the period, it returns the delay before the next
+// registration
+func (a *rendezvousSeedsAnnouncer) advertise(ctx context.Context, point string, periodEnd time.Time) time.Duration {
+	ttl := time.Until(periodEnd)
+	if ttl < rendezvousSeedMinTTL {
+		ttl = rendezvousSeedMinTTL
+	}
+
+	ttl, err := a.driver.Advertise(ctx, point, discovery.TTL(ttl))
+	if err != nil {
+		if ctx.Err() == nil {
+			a.logger.Warn("unable to advertise on rendezvous point", zap.Error(err))
+		}
+		return rendezvousSeedRetryDelay
+	}
+
+	return 7 * ttl / 8
+}
+
+// lookup connects to the devices announced on the rendezvous point
+func (a *rendezvousSeedsAnnouncer) lookup(ctx context.Context, point string) {
+	peers, err := a.driver.FindPeers(ctx, point)
+	if err != nil {
+		if ctx.Err() == nil {
+			a.logger.Warn("unable to find peers on rendezvous point", zap.Error(err))
+		}
+		return
+	}
+
+	for p := range peers {
+		if p.ID == a.host.ID() || a.host.Network().Connectedness(p.ID) == network.Connected {
+			continue
+		}
+
+		go func(p peer.AddrInfo) {
+			if err := a.host.Connect(ctx, p); err != nil {
+				a.logger.Debug("unable to connect to peer found on rendezvous point", zap.Stringer("peer", p.ID), zap.Error(err))
+			}
+		}(p)
+	}
+}
@@ -0,0 +1,87 @@
+package bertyprotocol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	p2pmocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/require"
+
+	"berty.tech/berty/v2/go/internal/testutil"
+	"berty.tech/berty/v2/go/internal/tinder"
+	"berty.tech/berty/v2/go/pkg/protocoltypes"
+)
+
+func TestRendezvousSeedsAnnouncer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger, cleanup := testutil.Logger(t)
+	defer cleanup()
+
+	mn := p2pmocknet.New(ctx)
+
+	server := tinder.NewMockedDriverServer()
+
+	hosts := make([]host.Host, 3)
+	announcers := make([]*rendezvousSeedsAnnouncer, len(hosts))
+	for i := range hosts {
+		var err error
+		hosts[i], err = mn.GenPeer()
+		require.NoError(t, err)
+
+		announcers[i] = newRendezvousSeedsAnnouncer(logger, hosts[i], tinder.NewMockedDriverClient(hosts[i], server), time.Hour)
+	}
+	require.NoError(t, mn.LinkAll())
+
+	topic := []byte("group pk")
+	seed := &protocoltypes.GroupAddAdditionalRendezvousSeed{Seed: []byte("seed"), RotationInterval: 3600}
+	otherSeed := &protocoltypes.GroupAddAdditionalRendezvousSeed{Seed: []byte("other seed")}
+	point := string(generateRendezvousPointForPeriod(topic, seed.Seed, roundTimePeriod(time.Now(), time.Hour)))
+
+	running := make([]map[string]*runningRendezvousSeed, len(hosts))
+	for i := range running {
+		running[i] = map[string]*runningRendezvousSeed{}
+	}
+
+	announcers[0].updateGroupSeeds(ctx, topic, running[0], []*protocoltypes.GroupAddAdditionalRendezvousSeed{seed})
+	require.Eventually(t, func() bool { return server.HasPeerRecord(point, hosts[0].ID()) }, 5*time.Second, 10*time.Millisecond)
+
+	// the devices announced on the same seed connect to each other
+	announcers[1].updateGroupSeeds(ctx, topic, running[1], []*protocoltypes.GroupAddAdditionalRendezvousSeed{seed})
+	require.Eventually(t, func() bool {
+		return hosts[1].Network().Connectedness(hosts[0].ID()) == network.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the devices using another seed are not found
+	announcers[2].updateGroupSeeds(ctx, topic, running[2], []*protocoltypes.GroupAddAdditionalRendezvousSeed{otherSeed})
+	time.Sleep(100 * time.Millisecond)
+	require.NotEqual(t, network.Connected, hosts[2].Network().Connectedness(hosts[0].ID()))
+	require.False(t, server.HasPeerRecord(point, hosts[2].ID()))
+
+	// removing the seed unregisters the device from its rendezvous point
+	announcers[0].updateGroupSeeds(ctx, topic, running[0], nil)
+	require.Len(t, running[0], 0)
+	require.Eventually(t, func() bool { return !server.HasPeerRecord(point, hosts[0].ID()) }, 5*time.Second, 10*time.Millisecond)
+	require.True(t, server.HasPeerRecord(point, hosts[1].ID()))
+
+	// changing the rotation interval restarts the announcement with the new rendezvous point
+	announcers[1].updateGroupSeeds(ctx, topic, running[1], []*protocoltypes.GroupAddAdditionalRendezvousSeed{{Seed: seed.Seed, RotationInterval: 7200}})
+	newPoint := string(generateRendezvousPointForPeriod(topic, seed.Seed, roundTimePeriod(time.Now(), 2*time.Hour)))
+	require.Eventually(t, func() bool {
+		return server.HasPeerRecord(newPoint, hosts[1].ID()) && (newPoint == point || !server.HasPeerRecord(point, hosts[1].ID()))
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCheckRendezvousSeedRotationInterval(t *testing.T) {
+	require.NoError(t, checkRendezvousSeedRotationInterval(0))
+	require.NoError(t, checkRendezvousSeedRotationInterval(60))
+	require.NoError(t, checkRendezvousSeedRotationInterval(24*3600))
+
+	require.Error(t, checkRendezvousSeedRotationInterval(-3600))
+	require.Error(t, checkRendezvousSeedRotationInterval(59))
+	require.Error(t, checkRendezvousSeedRotationInterval(365*24*3600))
+}
@@ -82,6 +82,18 @@ func (am AppMessage) UnmarshalPayload() (proto.Message, error) {
 		message = &AppMessage_SetUserInfo{}
 	case AppMessage_TypeReplyOptions:
 		message = &AppMessage_ReplyOptions{}
+	case AppMessage_TypeEditUserMessage:
+		message = &AppMessage_EditUserMessage{}
+	case AppMessage_TypeDeleteUserMessage:
+		message = &AppMessage_DeleteUserMessage{}
+	case AppMessage_TypeSetMessageRetention:
+		message = &AppMessage_SetMessageRetention{}
+	case AppMessage_TypeReadReceipt:
+		message = &AppMessage_ReadReceipt{}
+	case AppMessage_TypeSetPinnedMessage:
+		message = &AppMessage_SetPinnedMessage{}
+	case AppMessage_TypeSetStarredMessage:
+		message = &AppMessage_SetStarredMessage{}
 	case AppMessage_TypeMonitorMetadata:
 		message = &AppMessage_MonitorMetadata{}
 
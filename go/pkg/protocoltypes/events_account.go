@@ -84,6 +84,30 @@ func (m *MultiMemberGrantAdminRole) SetDevicePK(pk []byte) {
 	m.DevicePK = pk
 }
 
+func (m *MultiMemberRevokeAdminRole) SetDevicePK(pk []byte) {
+	m.DevicePK = pk
+}
+
+func (m *MultiMemberRemoveMember) SetDevicePK(pk []byte) {
+	m.DevicePK = pk
+}
+
+func (m *GroupAddAdditionalRendezvousSeed) SetDevicePK(pk []byte) {
+	m.DevicePK = pk
+}
+
+func (m *GroupRemoveAdditionalRendezvousSeed) SetDevicePK(pk []byte) {
+	m.DevicePK = pk
+}
+
+func (m *AccountDeviceRevoked) SetDevicePK(pk []byte) {
+	m.DevicePK = pk
+}
+
+func (m *AccountDeviceAdded) SetDevicePK(pk []byte) {
+	m.DevicePK = pk
+}
+
 func (m *AppMetadata) SetDevicePK(pk []byte) {
 	m.DevicePK = pk
 }
@@ -99,3 +123,7 @@ func (m *AccountServiceTokenRemoved) SetDevicePK(pk []byte) {
 func (m *GroupReplicating) SetDevicePK(pk []byte) {
 	m.DevicePK = pk
 }
+
+func (m *GroupReplicatingRemoved) SetDevicePK(pk []byte) {
+	m.DevicePK = pk
+}